@@ -1,7 +1,9 @@
 package api
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
@@ -24,6 +26,7 @@ import (
 	"codexray/constructor"
 	"codexray/db"
 	"codexray/model"
+	"codexray/notifications"
 	"codexray/prom"
 	"codexray/rbac"
 	"codexray/timeseries"
@@ -44,6 +47,7 @@ type Api struct {
 
 	authSecret        string
 	authAnonymousRole rbac.RoleName
+	authBackend       AuthBackend
 	Domains           map[string]struct{}
 }
 
@@ -65,6 +69,10 @@ func (api *Api) User(w http.ResponseWriter, r *http.Request, u *db.User) {
 		if u.Anonymous {
 			return
 		}
+		if u.AccessToken != nil {
+			http.Error(w, "The password can't be changed using an access token.", http.StatusForbidden)
+			return
+		}
 		var form forms.ChangePasswordForm
 		if err := forms.ReadAndValidate(r, &form); err != nil {
 			klog.Warningln("bad request:", err)
@@ -85,6 +93,8 @@ func (api *Api) User(w http.ResponseWriter, r *http.Request, u *db.User) {
 			}
 			return
 		}
+		api.auditLog(r, u, rbac.Actions.Users().Edit(), db.AuditOperationUpdate, u.Email,
+			&auditUser{Email: u.Email}, &auditUser{Email: u.Email, Password: form.NewPassword})
 		return
 	}
 
@@ -145,6 +155,14 @@ func (api *Api) Users(w http.ResponseWriter, r *http.Request, u *db.User) {
 		if form.Email == db.AdminUserLogin {
 			return
 		}
+		var before *db.User
+		if form.Action != forms.UserActionCreate {
+			if before, err = api.db.GetUser(form.Id); err != nil && !errors.Is(err, db.ErrNotFound) {
+				klog.Errorln(err)
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+		}
 		switch form.Action {
 		case forms.UserActionCreate:
 			if !form.Role.Valid(roles) {
@@ -170,6 +188,13 @@ func (api *Api) Users(w http.ResponseWriter, r *http.Request, u *db.User) {
 				http.Error(w, "", http.StatusInternalServerError)
 				return
 			}
+			if form.SSO {
+				if err := api.db.AllowSSOLogin(form.Id); err != nil {
+					klog.Errorln(err)
+					http.Error(w, "", http.StatusInternalServerError)
+					return
+				}
+			}
 		case forms.UserActionDelete:
 			if err := api.db.DeleteUser(form.Id); err != nil {
 				klog.Errorln(err)
@@ -177,6 +202,17 @@ func (api *Api) Users(w http.ResponseWriter, r *http.Request, u *db.User) {
 				return
 			}
 		}
+		after := &auditUser{Email: form.Email, Name: form.Name, Roles: []rbac.RoleName{form.Role}, Password: form.Password, SSO: form.SSO}
+		switch form.Action {
+		case forms.UserActionCreate:
+			api.auditLog(r, u, rbac.Actions.Users().Edit(), db.AuditOperationCreate, form.Email, nil, after)
+		case forms.UserActionUpdate:
+			api.auditLog(r, u, rbac.Actions.Users().Edit(), db.AuditOperationUpdate, form.Email, newAuditUser(before), after)
+		case forms.UserActionDelete:
+			if before != nil {
+				api.auditLog(r, u, rbac.Actions.Users().Edit(), db.AuditOperationDelete, before.Email, newAuditUser(before), nil)
+			}
+		}
 		return
 	}
 
@@ -190,42 +226,176 @@ func (api *Api) Users(w http.ResponseWriter, r *http.Request, u *db.User) {
 }
 
 func (api *Api) Roles(w http.ResponseWriter, r *http.Request, u *db.User) {
+	editable, configurable := api.roles.(rbac.EditableRoleManager)
 	if r.Method == http.MethodPost {
-		http.Error(w, "", http.StatusMethodNotAllowed)
+		if !api.IsAllowed(u, rbac.Actions.Roles().Edit()) {
+			http.Error(w, "You are not allowed to edit roles.", http.StatusForbidden)
+			return
+		}
+		if !configurable {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		var form forms.RoleForm
+		if err := forms.ReadAndValidate(r, &form); err != nil {
+			klog.Warningln("bad request:", err)
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if form.Action != forms.RoleActionCreate && form.Id.Builtin() {
+			http.Error(w, "Built-in roles cannot be changed.", http.StatusBadRequest)
+			return
+		}
+		if form.Action != forms.RoleActionDelete {
+			if err := form.Role().Validate(); err != nil {
+				http.Error(w, "Invalid role: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		roles, err := api.roles.GetRoles()
+		if err != nil {
+			klog.Errorln(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		var before *rbac.Role
+		if i := slices.IndexFunc(roles, func(role rbac.Role) bool { return role.Name == form.Id }); i >= 0 {
+			before = &roles[i]
+		}
+		switch form.Action {
+		case forms.RoleActionCreate:
+			err = editable.CreateRole(form.Role())
+		case forms.RoleActionUpdate:
+			err = editable.UpdateRole(form.Id, form.Role())
+		case forms.RoleActionDelete:
+			err = editable.DeleteRole(form.Id)
+		}
+		switch {
+		case err == nil:
+			after := form.Role()
+			switch form.Action {
+			case forms.RoleActionCreate:
+				api.auditLog(r, u, rbac.Actions.Roles().Edit(), db.AuditOperationCreate, string(after.Name), nil, after)
+			case forms.RoleActionUpdate:
+				api.auditLog(r, u, rbac.Actions.Roles().Edit(), db.AuditOperationUpdate, string(form.Id), before, after)
+			case forms.RoleActionDelete:
+				api.auditLog(r, u, rbac.Actions.Roles().Edit(), db.AuditOperationDelete, string(form.Id), before, nil)
+			}
+		case errors.Is(err, db.ErrConflict) && form.Action == forms.RoleActionDelete:
+			http.Error(w, "The role is assigned to users.", http.StatusConflict)
+		case errors.Is(err, db.ErrConflict):
+			http.Error(w, "A role with this name already exists.", http.StatusConflict)
+		case errors.Is(err, db.ErrNotFound):
+			http.Error(w, "The role is not found.", http.StatusNotFound)
+		default:
+			klog.Errorln(err)
+			http.Error(w, "", http.StatusInternalServerError)
+		}
 		return
 	}
-	qaSample := rbac.NewRole("QA",
-		rbac.NewPermission(rbac.ScopeProjectAll, rbac.ActionAll, rbac.Object{"project_id": "staging"}),
-	)
-	dbaSample := rbac.NewRole("DBA",
-		rbac.NewPermission(rbac.ScopeProjectInstrumentations, rbac.ActionEdit, nil),
-		rbac.NewPermission(rbac.ScopeApplication, rbac.ActionView, rbac.Object{"application_category": "databases"}),
-		rbac.NewPermission(rbac.ScopeNode, rbac.ActionView, rbac.Object{"node_name": "db*"}),
-	)
 	roles, err := api.roles.GetRoles()
 	if err != nil {
 		klog.Errorln(err)
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
-	utils.WriteJson(w, views.Roles(append(roles, qaSample, dbaSample)))
+	v := views.Roles(roles)
+	v.Configurable = configurable && api.IsAllowed(u, rbac.Actions.Roles().Edit())
+	utils.WriteJson(w, v)
 }
 
-func (api *Api) SSO(w http.ResponseWriter, r *http.Request, u *db.User) {
-	roles, err := api.roles.GetRoles()
+// AccessTokens manages the personal access tokens of the user; users allowed to edit users can list and revoke the tokens of all users.
+func (api *Api) AccessTokens(w http.ResponseWriter, r *http.Request, u *db.User) {
+	if u.Anonymous {
+		http.Error(w, "Access tokens require authentication.", http.StatusForbidden)
+		return
+	}
+	if u.AccessToken != nil {
+		http.Error(w, "Access tokens can't be managed using an access token.", http.StatusForbidden)
+		return
+	}
+	isAdmin := api.IsAllowed(u, rbac.Actions.Users().Edit())
+
+	if r.Method == http.MethodPost {
+		var form forms.AccessTokenForm
+		if err := forms.ReadAndValidate(r, &form); err != nil {
+			klog.Warningln("bad request:", err)
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		switch form.Action {
+		case forms.AccessTokenActionCreate:
+			t := &db.AccessToken{
+				UserId:      u.Id,
+				Name:        form.Name,
+				Permissions: form.Permissions,
+				ExpiresAt:   timeseries.Now().Add(timeseries.Day * timeseries.Duration(form.ExpiresInDays)),
+			}
+			token, err := api.db.CreateAccessToken(t)
+			if err != nil {
+				klog.Errorln(err)
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+			api.auditLog(r, u, rbac.Actions.Users().Edit(), db.AuditOperationCreate, "access_token:"+t.Id, nil, t)
+			utils.WriteJson(w, struct {
+				*db.AccessToken
+				Token string `json:"token"`
+			}{AccessToken: t, Token: token})
+		case forms.AccessTokenActionRevoke:
+			userId := u.Id
+			if isAdmin {
+				userId = 0
+			}
+			if err := api.db.RevokeAccessToken(userId, form.Id); err != nil {
+				if errors.Is(err, db.ErrNotFound) {
+					http.Error(w, "The token is not found.", http.StatusNotFound)
+					return
+				}
+				klog.Errorln(err)
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+			api.auditLog(r, u, rbac.Actions.Users().Edit(), db.AuditOperationDelete, "access_token:"+form.Id, nil, nil)
+		}
+		return
+	}
+
+	userId := u.Id
+	if isAdmin && r.URL.Query().Get("user") == "all" {
+		userId = 0
+	}
+	tokens, err := api.db.GetAccessTokens(userId)
 	if err != nil {
 		klog.Errorln(err)
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
+	users, err := api.db.GetUsers()
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	emails := map[int]string{}
+	for _, user := range users {
+		emails[user.Id] = user.Email
+	}
+	type Token struct {
+		*db.AccessToken
+		User    string `json:"user"`
+		Expired bool   `json:"expired"`
+	}
 	res := struct {
-		Roles       []rbac.RoleName `json:"roles"`
-		DefaultRole rbac.RoleName   `json:"default_role"`
+		Tokens   []Token `json:"tokens"`
+		Editable bool    `json:"editable"` // whether the tokens of all users can be listed and revoked
 	}{
-		DefaultRole: rbac.RoleViewer,
+		Tokens:   []Token{},
+		Editable: isAdmin,
 	}
-	for _, role := range roles {
-		res.Roles = append(res.Roles, role.Name)
+	now := timeseries.Now()
+	for _, t := range tokens {
+		res.Tokens = append(res.Tokens, Token{AccessToken: t, User: emails[t.UserId], Expired: t.ExpiresAt.Before(now)})
 	}
 	utils.WriteJson(w, res)
 }
@@ -277,6 +447,16 @@ func (api *Api) Project(w http.ResponseWriter, r *http.Request, u *db.User) {
 			http.Error(w, "", http.StatusBadRequest)
 			return
 		}
+		var before *db.Project
+		if projectId != "" {
+			p, err := api.db.GetProject(db.ProjectId(projectId))
+			if err != nil && !errors.Is(err, db.ErrNotFound) {
+				klog.Errorln("failed to get project:", err)
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+			before = p
+		}
 		project := db.Project{
 			Id:   db.ProjectId(projectId),
 			Name: form.Name,
@@ -291,6 +471,11 @@ func (api *Api) Project(w http.ResponseWriter, r *http.Request, u *db.User) {
 			http.Error(w, "", http.StatusInternalServerError)
 			return
 		}
+		if before == nil {
+			api.auditLog(r, u, rbac.Actions.Project(string(id)).Settings().Edit(), db.AuditOperationCreate, string(id), nil, map[string]string{"name": form.Name})
+		} else {
+			api.auditLog(r, u, rbac.Actions.Project(string(id)).Settings().Edit(), db.AuditOperationUpdate, string(id), map[string]string{"name": before.Name}, map[string]string{"name": form.Name})
+		}
 		http.Error(w, string(id), http.StatusOK)
 
 	case http.MethodDelete:
@@ -298,11 +483,20 @@ func (api *Api) Project(w http.ResponseWriter, r *http.Request, u *db.User) {
 			http.Error(w, "You are not allowed to delete the project.", http.StatusForbidden)
 			return
 		}
+		project, err := api.db.GetProject(db.ProjectId(projectId))
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			klog.Errorln("failed to get project:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
 		if err := api.db.DeleteProject(db.ProjectId(projectId)); err != nil {
 			klog.Errorln("failed to delete project:", err)
 			http.Error(w, "", http.StatusInternalServerError)
 			return
 		}
+		if project != nil {
+			api.auditLog(r, u, rbac.Actions.Project(projectId).Settings().Edit(), db.AuditOperationDelete, projectId, map[string]string{"name": project.Name}, nil)
+		}
 		http.Error(w, "", http.StatusOK)
 
 	default:
@@ -409,6 +603,11 @@ func (api *Api) ApiKeys(w http.ResponseWriter, r *http.Request, u *db.User) {
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
+	var before *db.ApiKey
+	if i := slices.IndexFunc(project.Settings.ApiKeys, func(k db.ApiKey) bool { return k.Key == form.Key }); i >= 0 {
+		k := project.Settings.ApiKeys[i]
+		before = &k
+	}
 	switch form.Action {
 	case "generate":
 		form.Key = utils.RandomString(32)
@@ -431,6 +630,350 @@ func (api *Api) ApiKeys(w http.ResponseWriter, r *http.Request, u *db.User) {
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
+	action := rbac.Actions.Project(projectId).Settings().Edit()
+	switch form.Action {
+	case "generate":
+		api.auditLog(r, u, action, db.AuditOperationCreate, "api_key", nil, form.ApiKey)
+	case "delete":
+		api.auditLog(r, u, action, db.AuditOperationDelete, "api_key", before, nil)
+	case "edit":
+		api.auditLog(r, u, action, db.AuditOperationUpdate, "api_key", before, form.ApiKey)
+	}
+}
+
+func (api *Api) AlertRules(w http.ResponseWriter, r *http.Request, u *db.User) {
+	vars := mux.Vars(r)
+	projectId := db.ProjectId(vars["project"])
+
+	isAllowed := api.IsAllowed(u, rbac.Actions.Project(string(projectId)).Inspections().Edit())
+
+	if r.Method == http.MethodGet {
+		rules, err := api.db.GetAlertRules(projectId)
+		if err != nil {
+			klog.Errorln("failed to get alert rules:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		res := struct {
+			Editable bool            `json:"editable"`
+			Rules    []*db.AlertRule `json:"rules"`
+		}{
+			Editable: isAllowed,
+			Rules:    rules,
+		}
+		utils.WriteJson(w, res)
+		return
+	}
+
+	if !isAllowed {
+		http.Error(w, "You are not allowed to configure alert rules.", http.StatusForbidden)
+		return
+	}
+	var form forms.AlertRuleForm
+	if err := forms.ReadAndValidate(r, &form); err != nil {
+		klog.Warningln("bad request:", err)
+		http.Error(w, "Invalid alert rule", http.StatusBadRequest)
+		return
+	}
+	var before *db.AlertRule
+	if form.Action != "create" {
+		rules, err := api.db.GetAlertRules(projectId)
+		if err != nil {
+			klog.Errorln("failed to get alert rules:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		before = itemById(rules, form.Id, func(i *db.AlertRule) string { return i.Id })
+	}
+	var err error
+	switch form.Action {
+	case "create":
+		form.Id = ""
+		err = api.db.SaveAlertRule(projectId, &form.AlertRule)
+	case "update":
+		err = api.db.SaveAlertRule(projectId, &form.AlertRule)
+	case "delete":
+		if err = api.db.DeleteAlertRule(projectId, form.Id); err == nil && before != nil {
+			var project *db.Project
+			if project, err = api.db.GetProject(projectId); err == nil {
+				notifications.ResolveAlertRuleIncidents(api.db, project, before, model.ApplicationId{}, timeseries.Now())
+			}
+		}
+	}
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		http.Error(w, "Alert rule not found", http.StatusNotFound)
+	case err != nil:
+		klog.Errorln("failed to save alert rule:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	default:
+		after := &form.AlertRule
+		if form.Action == "delete" {
+			after = nil
+		}
+		api.auditLog(r, u, rbac.Actions.Project(string(projectId)).Inspections().Edit(), db.AuditOperation(form.Action), form.Id, before, after)
+	}
+}
+
+func (api *Api) EscalationPolicies(w http.ResponseWriter, r *http.Request, u *db.User) {
+	vars := mux.Vars(r)
+	projectId := db.ProjectId(vars["project"])
+
+	isAllowed := api.IsAllowed(u, rbac.Actions.Project(string(projectId)).Integrations().Edit())
+
+	if r.Method == http.MethodGet {
+		policies, err := api.db.GetEscalationPolicies(projectId)
+		if err != nil {
+			klog.Errorln("failed to get escalation policies:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		res := struct {
+			Editable bool                   `json:"editable"`
+			Policies []*db.EscalationPolicy `json:"policies"`
+		}{
+			Editable: isAllowed,
+			Policies: policies,
+		}
+		if !isAllowed {
+			res.Policies = forms.MaskEscalationPolicies(res.Policies)
+		}
+		utils.WriteJson(w, res)
+		return
+	}
+
+	if !isAllowed {
+		http.Error(w, "You are not allowed to configure escalation policies.", http.StatusForbidden)
+		return
+	}
+	var form forms.EscalationPolicyForm
+	if err := forms.ReadAndValidate(r, &form); err != nil {
+		klog.Warningln("bad request:", err)
+		http.Error(w, "Invalid escalation policy", http.StatusBadRequest)
+		return
+	}
+	var before *db.EscalationPolicy
+	if form.Action != "create" {
+		policies, err := api.db.GetEscalationPolicies(projectId)
+		if err != nil {
+			klog.Errorln("failed to get escalation policies:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		before = itemById(policies, form.Id, func(i *db.EscalationPolicy) string { return i.Id })
+	}
+	var err error
+	switch form.Action {
+	case "create":
+		form.Id = ""
+		err = api.db.SaveEscalationPolicy(projectId, &form.EscalationPolicy)
+	case "update":
+		err = api.db.SaveEscalationPolicy(projectId, &form.EscalationPolicy)
+	case "delete":
+		err = api.db.DeleteEscalationPolicy(projectId, form.Id)
+	}
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		http.Error(w, "Escalation policy not found", http.StatusNotFound)
+	case err != nil:
+		klog.Errorln("failed to save escalation policy:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	default:
+		after := &form.EscalationPolicy
+		if form.Action == "delete" {
+			after = nil
+		}
+		api.auditLog(r, u, rbac.Actions.Project(string(projectId)).Integrations().Edit(), db.AuditOperation(form.Action), form.Id, before, after)
+	}
+}
+
+func (api *Api) IncidentSettings(w http.ResponseWriter, r *http.Request, u *db.User) {
+	vars := mux.Vars(r)
+	projectId := db.ProjectId(vars["project"])
+
+	isAllowed := api.IsAllowed(u, rbac.Actions.Project(string(projectId)).Settings().Edit())
+
+	project, err := api.db.GetProject(projectId)
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		res := struct {
+			Editable bool                `json:"editable"`
+			Settings db.IncidentSettings `json:"settings"`
+		}{
+			Editable: isAllowed,
+			Settings: project.Settings.Incidents,
+		}
+		utils.WriteJson(w, res)
+		return
+	}
+
+	if !isAllowed {
+		http.Error(w, "You are not allowed to configure incident settings.", http.StatusForbidden)
+		return
+	}
+	var form forms.IncidentSettingsForm
+	if err := forms.ReadAndValidate(r, &form); err != nil {
+		klog.Warningln("bad request:", err)
+		http.Error(w, "Invalid incident settings", http.StatusBadRequest)
+		return
+	}
+	before := project.Settings.Incidents
+	project.Settings.Incidents = form.IncidentSettings
+	if err := api.db.SaveProjectSettings(project); err != nil {
+		klog.Errorln("failed to save incident settings:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	api.auditLog(r, u, rbac.Actions.Project(string(projectId)).Settings().Edit(), db.AuditOperationUpdate, "incident_settings", before, project.Settings.Incidents)
+}
+
+func (api *Api) NotificationRoutes(w http.ResponseWriter, r *http.Request, u *db.User) {
+	vars := mux.Vars(r)
+	projectId := db.ProjectId(vars["project"])
+
+	isAllowed := api.IsAllowed(u, rbac.Actions.Project(string(projectId)).Integrations().Edit())
+
+	project, err := api.db.GetProject(projectId)
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		res := struct {
+			Editable bool                  `json:"editable"`
+			Routes   db.NotificationRoutes `json:"routes"`
+		}{
+			Editable: isAllowed,
+			Routes:   project.Settings.NotificationRoutes,
+		}
+		if !isAllowed {
+			res.Routes = forms.MaskNotificationRoutes(res.Routes)
+		}
+		utils.WriteJson(w, res)
+		return
+	}
+
+	if !isAllowed {
+		http.Error(w, "You are not allowed to configure notification routing.", http.StatusForbidden)
+		return
+	}
+	var form forms.NotificationRoutesForm
+	if err := forms.ReadAndValidate(r, &form); err != nil {
+		klog.Warningln("bad request:", err)
+		http.Error(w, "Invalid notification routes", http.StatusBadRequest)
+		return
+	}
+	before := project.Settings.NotificationRoutes
+	project.Settings.NotificationRoutes = form.Routes
+	if err := api.db.SaveProjectSettings(project); err != nil {
+		klog.Errorln("failed to save notification routes:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	api.auditLog(r, u, rbac.Actions.Project(string(projectId)).Integrations().Edit(), db.AuditOperationUpdate, "notification_routes", before, project.Settings.NotificationRoutes)
+}
+
+func (api *Api) MaintenanceWindows(w http.ResponseWriter, r *http.Request, u *db.User) {
+	vars := mux.Vars(r)
+	projectId := db.ProjectId(vars["project"])
+
+	isAllowed := api.IsAllowed(u, rbac.Actions.Project(string(projectId)).Integrations().Edit())
+
+	if r.Method == http.MethodGet {
+		windows, err := api.db.GetMaintenanceWindows(projectId)
+		if err != nil {
+			klog.Errorln("failed to get maintenance windows:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		now := timeseries.Now()
+		from := utils.ParseTime(now, r.URL.Query().Get("from"), now.Add(-7*timeseries.Day))
+		suppressed, err := api.db.GetSuppressedIncidentNotifications(projectId, from)
+		if err != nil {
+			klog.Errorln("failed to get suppressed notifications:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		type suppressedNotification struct {
+			ApplicationId model.ApplicationId `json:"application_id"`
+			IncidentKey   string              `json:"incident_key"`
+			Status        model.Status        `json:"status"`
+			Destination   db.IntegrationType  `json:"destination"`
+			Timestamp     timeseries.Time     `json:"timestamp"`
+			SuppressedBy  string              `json:"suppressed_by"`
+		}
+		res := struct {
+			Editable   bool                       `json:"editable"`
+			Windows    []*model.MaintenanceWindow `json:"windows"`
+			Suppressed []suppressedNotification   `json:"suppressed"`
+		}{
+			Editable: isAllowed,
+			Windows:  windows,
+		}
+		for _, n := range suppressed {
+			res.Suppressed = append(res.Suppressed, suppressedNotification{
+				ApplicationId: n.ApplicationId,
+				IncidentKey:   n.IncidentKey,
+				Status:        n.Status,
+				Destination:   n.Destination,
+				Timestamp:     n.Timestamp,
+				SuppressedBy:  n.SuppressedBy,
+			})
+		}
+		utils.WriteJson(w, res)
+		return
+	}
+
+	if !isAllowed {
+		http.Error(w, "You are not allowed to configure maintenance windows.", http.StatusForbidden)
+		return
+	}
+	var form forms.MaintenanceWindowForm
+	if err := forms.ReadAndValidate(r, &form); err != nil {
+		klog.Warningln("bad request:", err)
+		http.Error(w, "Invalid maintenance window", http.StatusBadRequest)
+		return
+	}
+	var before *model.MaintenanceWindow
+	if form.Action != "create" {
+		windows, err := api.db.GetMaintenanceWindows(projectId)
+		if err != nil {
+			klog.Errorln("failed to get maintenance windows:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		before = itemById(windows, form.Id, func(i *model.MaintenanceWindow) string { return i.Id })
+	}
+	var err error
+	switch form.Action {
+	case "create":
+		form.Id = ""
+		err = api.db.SaveMaintenanceWindow(projectId, &form.MaintenanceWindow)
+	case "update":
+		err = api.db.SaveMaintenanceWindow(projectId, &form.MaintenanceWindow)
+	case "delete":
+		err = api.db.DeleteMaintenanceWindow(projectId, form.Id)
+	}
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		http.Error(w, "Maintenance window not found", http.StatusNotFound)
+	case err != nil:
+		klog.Errorln("failed to save maintenance window:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	default:
+		after := &form.MaintenanceWindow
+		if form.Action == "delete" {
+			after = nil
+		}
+		api.auditLog(r, u, rbac.Actions.Project(string(projectId)).Integrations().Edit(), db.AuditOperation(form.Action), form.Id, before, after)
+	}
 }
 
 func (api *Api) Inspections(w http.ResponseWriter, r *http.Request, u *db.User) {
@@ -460,11 +1003,24 @@ func (api *Api) Categories(w http.ResponseWriter, r *http.Request, u *db.User) {
 			http.Error(w, "Invalid name or patterns", http.StatusBadRequest)
 			return
 		}
+		p, err := api.db.GetProject(db.ProjectId(projectId))
+		if err != nil {
+			klog.Errorln(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		before := auditCategory(p, form.Name)
 		if err := api.db.SaveApplicationCategory(db.ProjectId(projectId), form.Name, form.NewName, form.CustomPatterns, form.NotifyOfDeployments); err != nil {
 			klog.Errorln("failed to save:", err)
 			http.Error(w, "", http.StatusInternalServerError)
 			return
 		}
+		if p, err = api.db.GetProject(db.ProjectId(projectId)); err != nil {
+			klog.Errorln(err)
+			return
+		}
+		after := auditCategory(p, cmp.Or(form.NewName, form.Name))
+		api.auditLog(r, u, rbac.Actions.Project(projectId).ApplicationCategories().Edit(), auditOperation(before, after), string(form.Name), before, after)
 		return
 	}
 
@@ -492,11 +1048,24 @@ func (api *Api) CustomApplications(w http.ResponseWriter, r *http.Request, u *db
 			http.Error(w, "Invalid name or patterns", http.StatusBadRequest)
 			return
 		}
+		p, err := api.db.GetProject(db.ProjectId(projectId))
+		if err != nil {
+			klog.Errorln(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		before := auditCustomApplication(p, form.Name)
 		if err := api.db.SaveCustomApplication(db.ProjectId(projectId), form.Name, form.NewName, form.InstancePatterns); err != nil {
 			klog.Errorln("failed to save:", err)
 			http.Error(w, "", http.StatusInternalServerError)
 			return
 		}
+		if p, err = api.db.GetProject(db.ProjectId(projectId)); err != nil {
+			klog.Errorln(err)
+			return
+		}
+		after := auditCustomApplication(p, cmp.Or(form.NewName, form.Name))
+		api.auditLog(r, u, rbac.Actions.Project(projectId).CustomApplications().Edit(), auditOperation(before, after), form.Name, before, after)
 		return
 	}
 	p, err := api.db.GetProject(db.ProjectId(projectId))
@@ -523,11 +1092,19 @@ func (api *Api) Integrations(w http.ResponseWriter, r *http.Request, u *db.User)
 			http.Error(w, "Invalid base url", http.StatusBadRequest)
 			return
 		}
+		p, err := api.db.GetProject(db.ProjectId(projectId))
+		if err != nil {
+			klog.Errorln(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
 		if err := api.db.SaveIntegrationsBaseUrl(db.ProjectId(projectId), form.BaseUrl); err != nil {
 			klog.Errorln("failed to save:", err)
 			http.Error(w, "", http.StatusInternalServerError)
 			return
 		}
+		api.auditLog(r, u, rbac.Actions.Project(projectId).Integrations().Edit(), db.AuditOperationUpdate, "base_url",
+			map[string]string{"base_url": p.Settings.Integrations.BaseUrl}, map[string]string{"base_url": form.BaseUrl})
 		return
 	}
 
@@ -595,6 +1172,7 @@ func (api *Api) Integration(w http.ResponseWriter, r *http.Request, u *db.User)
 		}
 	}
 
+	before := auditIntegrations(project)
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 	switch r.Method {
@@ -621,6 +1199,11 @@ func (api *Api) Integration(w http.ResponseWriter, r *http.Request, u *db.User)
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
+	op := db.AuditOperationUpdate
+	if r.Method == http.MethodDelete {
+		op = db.AuditOperationDelete
+	}
+	api.auditLog(r, u, rbac.Actions.Project(projectId).Integrations().Edit(), op, string(t), before, auditIntegrations(project))
 	if api.globalClickHouse == nil {
 		cfg := project.Settings.Integrations.Clickhouse
 		err = api.collector.UpdateClickhouseClient(r.Context(), project.Id, cfg)
@@ -632,6 +1215,80 @@ func (api *Api) Integration(w http.ResponseWriter, r *http.Request, u *db.User)
 	}
 }
 
+func (api *Api) IntegrationTest(w http.ResponseWriter, r *http.Request, u *db.User) {
+	vars := mux.Vars(r)
+	projectId := vars["project"]
+	if !api.IsAllowed(u, rbac.Actions.Project(projectId).Integrations().Edit()) {
+		http.Error(w, "You are not allowed to configure integrations.", http.StatusForbidden)
+		return
+	}
+	project, err := api.db.GetProject(db.ProjectId(projectId))
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	res, err := notifications.SendTestNotifications(ctx, project, db.IntegrationType(vars["type"]))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	utils.WriteJson(w, res)
+}
+
+type IntegrationDelivery struct {
+	Timestamp     timeseries.Time     `json:"timestamp"`
+	ApplicationId model.ApplicationId `json:"application_id"`
+	IncidentKey   string              `json:"incident_key"`
+	Status        model.Status        `json:"status"`
+	Target        string              `json:"target"`
+	Escalation    bool                `json:"escalation"`
+	Sent          bool                `json:"sent"`
+	Attempts      int                 `json:"attempts"`
+	AttemptedAt   timeseries.Time     `json:"attempted_at"`
+	StatusCode    int                 `json:"status_code"`
+	Error         string              `json:"error"`
+	LatencyMs     int64               `json:"latency_ms"`
+}
+
+func (api *Api) IntegrationDeliveries(w http.ResponseWriter, r *http.Request, u *db.User) {
+	vars := mux.Vars(r)
+	projectId := vars["project"]
+	isAllowed := api.IsAllowed(u, rbac.Actions.Project(projectId).Integrations().Edit())
+	now := timeseries.Now()
+	from := utils.ParseTime(now, r.URL.Query().Get("from"), now.Add(-timeseries.Day))
+	attempted, err := api.db.GetIncidentNotificationDeliveries(db.ProjectId(projectId), db.IntegrationType(vars["type"]), from, 100)
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	res := make([]IntegrationDelivery, 0, len(attempted))
+	for _, n := range attempted {
+		d := IntegrationDelivery{
+			Timestamp:     n.Timestamp,
+			ApplicationId: n.ApplicationId,
+			IncidentKey:   n.IncidentKey,
+			Status:        n.Status,
+			Target:        n.Target,
+			Escalation:    n.EscalationStep != 0,
+			Sent:          !n.SentAt.IsZero(),
+			Attempts:      n.Delivery.Attempts,
+			AttemptedAt:   n.Delivery.AttemptedAt,
+			StatusCode:    n.Delivery.StatusCode,
+			Error:         n.Delivery.Error,
+			LatencyMs:     n.Delivery.Latency.Milliseconds(),
+		}
+		if !isAllowed && d.Target != "" {
+			d.Target = "<hidden>"
+		}
+		res = append(res, d)
+	}
+	utils.WriteJson(w, res)
+}
+
 func (api *Api) Prom(w http.ResponseWriter, r *http.Request, u *db.User) {
 	vars := mux.Vars(r)
 	projectId := vars["project"]
@@ -641,12 +1298,16 @@ func (api *Api) Prom(w http.ResponseWriter, r *http.Request, u *db.User) {
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
-	p := project.Prometheus
-	cfg := prom.NewClientConfig(p.Url, p.RefreshInterval)
-	cfg.BasicAuth = p.BasicAuth
-	cfg.TlsSkipVerify = p.TlsSkipVerify
-	cfg.ExtraSelector = p.ExtraSelector
-	cfg.CustomHeaders = p.CustomHeaders
+	// the sources can't be merged at the level of the Prometheus HTTP API, so a single one is proxied:
+	// the one of the cluster specified by the request, or the first one, which has no cluster
+	configs := project.Prometheus.ClientConfigs()
+	cluster := r.URL.Query().Get("cluster")
+	i := slices.IndexFunc(configs, func(c prom.ClientConfig) bool { return c.Cluster == cluster })
+	if i < 0 {
+		http.Error(w, "Unknown cluster: "+cluster, http.StatusBadRequest)
+		return
+	}
+	cfg := configs[i]
 	c, err := prom.NewClient(cfg)
 	if err != nil {
 		klog.Errorln(err)
@@ -753,6 +1414,142 @@ func (api *Api) Incident(w http.ResponseWriter, r *http.Request, u *db.User) {
 	utils.WriteJson(w, api.WithContext(project, cacheStatus, world, views.Incident(world, app, incident)))
 }
 
+// getIncident loads the incident and checks that the user is allowed to view its application.
+func (api *Api) getIncident(w http.ResponseWriter, r *http.Request, u *db.User) (*db.Project, *model.ApplicationIncident) {
+	vars := mux.Vars(r)
+	projectId := vars["project"]
+	project, err := api.db.GetProject(db.ProjectId(projectId))
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return nil, nil
+	}
+	incident, err := api.db.GetIncidentByKey(project.Id, vars["incident"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Incident not found", http.StatusNotFound)
+			return nil, nil
+		}
+		klog.Errorln("failed to get incident:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return nil, nil
+	}
+	appId := incident.ApplicationId
+	category := model.CalcApplicationCategory(appId, project.Settings.ApplicationCategories)
+	if !api.IsAllowed(u, rbac.Actions.Project(projectId).Application(category, appId.Namespace, appId.Kind, appId.Name).View()) {
+		http.Error(w, "You are not allowed to view this application.", http.StatusForbidden)
+		return nil, nil
+	}
+	return project, incident
+}
+
+func (api *Api) IncidentAction(w http.ResponseWriter, r *http.Request, u *db.User) {
+	project, incident := api.getIncident(w, r, u)
+	if incident == nil {
+		return
+	}
+	editAction := rbac.Actions.Project(string(project.Id)).Incidents().Edit()
+	if !api.IsAllowed(u, editAction) {
+		http.Error(w, "You are not allowed to manage incidents.", http.StatusForbidden)
+		return
+	}
+	var form forms.IncidentActionForm
+	if err := forms.ReadAndValidate(r, &form); err != nil {
+		klog.Warningln("bad request:", err)
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	appId := incident.ApplicationId
+	category := model.CalcApplicationCategory(appId, project.Settings.ApplicationCategories)
+	now := timeseries.Now()
+	var err error
+	var change map[string]any
+	switch form.Action {
+	case "acknowledge":
+		if err = api.db.AcknowledgeIncident(project.Id, incident.Key, u.Id, now); err == nil {
+			go notifications.AcknowledgeIncident(api.db, project, incident, u.Name)
+		}
+		change = map[string]any{"acknowledged": true}
+	case "assign":
+		var assignee *db.User
+		if assignee, err = api.db.GetUser(form.Assignee); err == nil {
+			if !api.IsAllowed(assignee, rbac.Actions.Project(string(project.Id)).Application(category, appId.Namespace, appId.Kind, appId.Name).View()) {
+				http.Error(w, "The assignee has no access to the application.", http.StatusBadRequest)
+				return
+			}
+			err = api.db.AssignIncident(project.Id, incident.Key, assignee, u.Id, now)
+			change = map[string]any{"assignee": assignee.Email}
+		}
+	case "note":
+		err = api.db.AddIncidentNote(project.Id, incident.Key, form.Text, u.Id, now)
+		change = map[string]any{"note": form.Text}
+	case "link_deployment":
+		var exists bool
+		if exists, err = api.db.ApplicationDeploymentExists(project.Id, appId, form.DeploymentId); err == nil {
+			if !exists {
+				http.Error(w, "The application has no such deployment.", http.StatusBadRequest)
+				return
+			}
+			err = api.db.LinkIncidentDeployment(project.Id, incident.Key, form.DeploymentId, u.Id, now)
+			change = map[string]any{"deployment_id": form.DeploymentId}
+		}
+	case "root_cause":
+		err = api.db.SetIncidentRootCause(project.Id, incident.Key, form.RootCause, u.Id, now)
+		change = map[string]any{"root_cause": form.RootCause}
+	}
+	if err == nil {
+		api.auditLog(r, u, editAction, db.AuditOperationUpdate, incident.Key, nil, change)
+	}
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		if form.Action == "acknowledge" {
+			http.Error(w, "Incident is already acknowledged", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	case err != nil:
+		klog.Errorln("failed to update incident:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+func (api *Api) IncidentTimeline(w http.ResponseWriter, r *http.Request, u *db.User) {
+	project, incident := api.getIncident(w, r, u)
+	if incident == nil {
+		return
+	}
+	events, err := api.db.GetIncidentTimeline(project.Id, incident.Key)
+	if err != nil {
+		klog.Errorln("failed to get incident timeline:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	type event struct {
+		db.IncidentEvent
+		User string `json:"user,omitempty"`
+	}
+	users := map[int]string{}
+	res := struct {
+		Incident *model.ApplicationIncident `json:"incident"`
+		Events   []event                    `json:"events"`
+	}{Incident: incident}
+	for _, e := range events {
+		ev := event{IncidentEvent: e}
+		if e.UserId > 0 {
+			name, ok := users[e.UserId]
+			if !ok {
+				if user, err := api.db.GetUser(e.UserId); err == nil {
+					name = user.Name
+				}
+				users[e.UserId] = name
+			}
+			ev.User = name
+		}
+		res.Events = append(res.Events, ev)
+	}
+	utils.WriteJson(w, res)
+}
+
 func (api *Api) Inspection(w http.ResponseWriter, r *http.Request, u *db.User) {
 	vars := mux.Vars(r)
 	projectId := vars["project"]
@@ -814,6 +1611,12 @@ func (api *Api) Inspection(w http.ResponseWriter, r *http.Request, u *db.User) {
 			http.Error(w, "You are not allowed to configure inspections.", http.StatusForbidden)
 			return
 		}
+		before, err := api.checkConfigsSnapshot(db.ProjectId(projectId), appId, checkId)
+		if err != nil {
+			klog.Errorln("failed to get check configs:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
 		switch checkId {
 		case model.Checks.SLOAvailability.Id:
 			var form forms.CheckConfigSLOAvailabilityForm
@@ -862,8 +1665,13 @@ func (api *Api) Inspection(w http.ResponseWriter, r *http.Request, u *db.User) {
 					return
 				}
 			}
+		}
+		after, err := api.checkConfigsSnapshot(db.ProjectId(projectId), appId, checkId)
+		if err != nil {
+			klog.Errorln("failed to get check configs:", err)
 			return
 		}
+		api.auditLog(r, u, rbac.Actions.Project(projectId).Inspections().Edit(), db.AuditOperationUpdate, appId.String()+"/"+string(checkId), before, after)
 	}
 }
 
@@ -906,7 +1714,7 @@ func (api *Api) Instrumentation(w http.ResponseWriter, r *http.Request, u *db.Us
 			http.Error(w, "invalid data", http.StatusBadRequest)
 			return
 		}
-		if err = api.db.SaveApplicationSetting(db.ProjectId(projectId), appId, &form.ApplicationInstrumentation); err != nil {
+		if err = api.saveApplicationSetting(r, u, rbac.Actions.Project(projectId).Instrumentations().Edit(), db.ProjectId(projectId), appId, &form.ApplicationInstrumentation); err != nil {
 			klog.Errorln(err)
 			http.Error(w, "", http.StatusInternalServerError)
 			return
@@ -953,7 +1761,7 @@ func (api *Api) Profiling(w http.ResponseWriter, r *http.Request, u *db.User) {
 			http.Error(w, "invalid data", http.StatusBadRequest)
 			return
 		}
-		if err := api.db.SaveApplicationSetting(db.ProjectId(projectId), appId, &form.ApplicationSettingsProfiling); err != nil {
+		if err := api.saveApplicationSetting(r, u, rbac.Actions.Project(projectId).Inspections().Edit(), db.ProjectId(projectId), appId, &form.ApplicationSettingsProfiling); err != nil {
 			klog.Errorln(err)
 			http.Error(w, "", http.StatusInternalServerError)
 			return
@@ -1007,7 +1815,7 @@ func (api *Api) Tracing(w http.ResponseWriter, r *http.Request, u *db.User) {
 			http.Error(w, "invalid data", http.StatusBadRequest)
 			return
 		}
-		if err := api.db.SaveApplicationSetting(db.ProjectId(projectId), appId, &form.ApplicationSettingsTracing); err != nil {
+		if err := api.saveApplicationSetting(r, u, rbac.Actions.Project(projectId).Inspections().Edit(), db.ProjectId(projectId), appId, &form.ApplicationSettingsTracing); err != nil {
 			klog.Errorln(err)
 			http.Error(w, "", http.StatusInternalServerError)
 			return
@@ -1117,7 +1925,7 @@ func (api *Api) Logs(w http.ResponseWriter, r *http.Request, u *db.User) {
 			http.Error(w, "invalid data", http.StatusBadRequest)
 			return
 		}
-		if err := api.db.SaveApplicationSetting(db.ProjectId(projectId), appId, &form.ApplicationSettingsLogs); err != nil {
+		if err := api.saveApplicationSetting(r, u, rbac.Actions.Project(projectId).Inspections().Edit(), db.ProjectId(projectId), appId, &form.ApplicationSettingsLogs); err != nil {
 			klog.Errorln(err)
 			http.Error(w, "", http.StatusInternalServerError)
 			return
@@ -1454,6 +2262,11 @@ func (api *Api) LoadWorld(ctx context.Context, project *db.Project, from, to tim
 	step = increaseStepForBigDurations(duration, step)
 
 	ctr := constructor.New(api.db, project, cacheClient, api.pricing)
+	if ch, err := api.getClickhouseClient(project); err != nil {
+		klog.Warningln(err)
+	} else if ch != nil {
+		ctr.SetKubernetesEventsSource(ch)
+	}
 	world, err := ctr.LoadWorld(ctx, from, to, step, nil)
 	return world, cacheStatus, err
 }
@@ -1468,6 +2281,7 @@ func (api *Api) TrustDomainsHandler(w http.ResponseWriter, r *http.Request, u *d
 	}
 
 	var form forms.TrustDomainsForm
+	before := auditSnapshot(project.Settings.TrustDomains)
 
 	isAllowed := api.IsAllowed(u, rbac.Actions.Project(string(projectId)).Integrations().Edit())
 	klog.Infof("User %s (ID: %d, Roles: %v) allowed to edit: %v", u.Email, u.Id, u.Roles, isAllowed)
@@ -1494,6 +2308,7 @@ func (api *Api) TrustDomainsHandler(w http.ResponseWriter, r *http.Request, u *d
 				return
 			}
 			api.Domains = project.Settings.TrustDomains
+			api.auditLog(r, u, rbac.Actions.Project(string(projectId)).Integrations().Edit(), db.AuditOperationUpdate, "trust_domains", before, project.Settings.TrustDomains)
 		} else {
 			http.Error(w, "Failed to update database", http.StatusConflict)
 			return
@@ -1530,6 +2345,7 @@ func (api *Api) TrustDomainsHandler(w http.ResponseWriter, r *http.Request, u *d
 			return
 		}
 		api.Domains = project.Settings.TrustDomains
+		api.auditLog(r, u, rbac.Actions.Project(string(projectId)).Integrations().Edit(), db.AuditOperationDelete, "trust_domains", before, project.Settings.TrustDomains)
 		w.WriteHeader(http.StatusOK)
 
 	case http.MethodPut:
@@ -1554,6 +2370,7 @@ func (api *Api) TrustDomainsHandler(w http.ResponseWriter, r *http.Request, u *d
 			return
 		}
 		api.Domains = project.Settings.TrustDomains
+		api.auditLog(r, u, rbac.Actions.Project(string(projectId)).Integrations().Edit(), db.AuditOperationUpdate, "trust_domains", before, project.Settings.TrustDomains)
 		w.WriteHeader(http.StatusOK)
 	}
 }
@@ -1640,18 +2457,5 @@ func maxDuration(d1, d2 timeseries.Duration) timeseries.Duration {
 }
 
 func (api *Api) getClickhouseClient(project *db.Project) (*clickhouse.Client, error) {
-	cfg := project.ClickHouseConfig(api.globalClickHouse)
-	if cfg == nil {
-		return nil, nil
-	}
-	config := clickhouse.NewClientConfig(cfg.Addr, cfg.Auth.User, cfg.Auth.Password)
-	config.Protocol = cfg.Protocol
-	config.Database = cfg.Database
-	config.TlsEnable = cfg.TlsEnable
-	config.TlsSkipVerify = cfg.TlsSkipVerify
-	distributed, err := api.collector.IsClickhouseDistributed(project)
-	if err != nil {
-		return nil, err
-	}
-	return clickhouse.NewClient(config, distributed)
+	return clickhouse.NewProjectClient(project, api.globalClickHouse, api.collector)
 }
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"codexray/api/forms"
+	"codexray/db"
+	"codexray/model"
+	"codexray/rbac"
+	"codexray/timeseries"
+	"codexray/utils"
+
+	"github.com/gorilla/mux"
+	"k8s.io/klog"
+)
+
+const (
+	auditLogDefaultLimit = 100
+	auditLogMaxLimit     = 1000
+)
+
+// auditLog records a successful change made by the user. Before and after are the states of the target, nil if it didn't exist.
+// A failure to record the change is logged, but it doesn't fail the request since the change has already been made.
+func (api *Api) auditLog(r *http.Request, u *db.User, action rbac.Action, op db.AuditOperation, target string, before, after any) {
+	changes, err := db.AuditLogDiff(before, after)
+	if err != nil {
+		klog.Errorln("failed to calculate the audit log diff:", err)
+	}
+	if op == db.AuditOperationUpdate && before != nil && after != nil && len(changes) == 0 {
+		return
+	}
+	e := &db.AuditLogEntry{
+		Timestamp: timeseries.Now(),
+		ProjectId: db.ProjectId(cmp.Or(action.Object["project_id"], mux.Vars(r)["project"])),
+		UserId:    u.Id,
+		Actor:     u.Email,
+		Scope:     action.Scope,
+		Action:    action.Action,
+		Object:    action.Object,
+		Operation: op,
+		Target:    target,
+		Changes:   changes,
+		SourceIp:  sourceIp(r),
+	}
+	switch {
+	case u.Anonymous:
+		e.Actor = u.Name
+	case u.AccessToken != nil:
+		e.Actor += " (access token: " + u.AccessToken.Name + ")"
+	}
+	if err = api.db.AddAuditLogEntry(e); err != nil {
+		klog.Errorln("failed to add an audit log entry:", err)
+	}
+}
+
+// sourceIp returns the client address, preferring the one reported by a reverse proxy.
+func sourceIp(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		ip, _, _ := strings.Cut(xff, ",")
+		return strings.TrimSpace(ip)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func (api *Api) AuditLog(w http.ResponseWriter, r *http.Request, u *db.User) {
+	if !api.IsAllowed(u, rbac.Actions.Users().Edit()) {
+		http.Error(w, "You are not allowed to view the audit log.", http.StatusForbidden)
+		return
+	}
+	settings, err := api.db.GetAuditLogSettings()
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var form forms.AuditLogSettingsForm
+		if err := forms.ReadAndValidate(r, &form); err != nil {
+			klog.Warningln("bad request:", err)
+			http.Error(w, "Invalid retention period", http.StatusBadRequest)
+			return
+		}
+		if err := api.db.SaveAuditLogSettings(form.AuditLogSettings); err != nil {
+			klog.Errorln(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		api.auditLog(r, u, rbac.Actions.Users().Edit(), db.AuditOperationUpdate, "audit_log", settings, form.AuditLogSettings)
+		return
+	}
+
+	q := r.URL.Query()
+	now := timeseries.Now()
+	f := db.AuditLogFilter{
+		From:      utils.ParseTime(now, q.Get("from"), now.Add(-7*timeseries.Day)),
+		To:        utils.ParseTime(now, q.Get("to"), now),
+		ProjectId: db.ProjectId(q.Get("project")),
+		Scope:     rbac.Scope(q.Get("scope")),
+		Operation: db.AuditOperation(q.Get("operation")),
+		Limit:     auditLogDefaultLimit,
+	}
+	if v, err := strconv.Atoi(q.Get("user")); err == nil {
+		f.UserId = v
+	}
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		f.Limit = min(v, auditLogMaxLimit)
+	}
+	entries, err := api.db.GetAuditLog(f)
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	res := struct {
+		Settings db.AuditLogSettings `json:"settings"`
+		Entries  []*db.AuditLogEntry `json:"entries"`
+	}{
+		Settings: settings,
+		Entries:  entries,
+	}
+	if res.Entries == nil {
+		res.Entries = []*db.AuditLogEntry{}
+	}
+	utils.WriteJson(w, res)
+}
+
+// auditUser is the state of a user recorded in the audit log; the password is only set if it has been changed, so the diff hides its value.
+type auditUser struct {
+	Email    string          `json:"email"`
+	Name     string          `json:"name"`
+	Roles    []rbac.RoleName `json:"roles"`
+	Password string          `json:"password,omitempty"`
+	SSO      bool            `json:"sso,omitempty"`
+}
+
+func newAuditUser(u *db.User) *auditUser {
+	if u == nil {
+		return nil
+	}
+	return &auditUser{Email: u.Email, Name: u.Name, Roles: u.Roles}
+}
+
+// itemById returns the item to record as the state before a change, or nil if there is no such item.
+func itemById[T any](items []*T, id string, getId func(*T) string) *T {
+	for _, i := range items {
+		if getId(i) == id {
+			return i
+		}
+	}
+	return nil
+}
+
+// auditSnapshot captures the state of an entity that is about to be changed in place.
+func auditSnapshot(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		klog.Errorln("failed to take an audit log snapshot:", err)
+		return nil
+	}
+	return data
+}
+
+// auditOperation infers the operation from the states of a target that can be created or deleted by an update.
+func auditOperation(before, after any) db.AuditOperation {
+	switch {
+	case before == nil:
+		return db.AuditOperationCreate
+	case after == nil:
+		return db.AuditOperationDelete
+	}
+	return db.AuditOperationUpdate
+}
+
+func auditCategory(p *db.Project, c model.ApplicationCategory) any {
+	patterns, ok := p.Settings.ApplicationCategories[c]
+	if !ok && !c.Default() {
+		return nil
+	}
+	return map[string]any{
+		"name":                  c,
+		"custom_patterns":       patterns,
+		"notify_of_deployments": p.Settings.ApplicationCategorySettings[c].NotifyOfDeployments,
+	}
+}
+
+func auditCustomApplication(p *db.Project, name string) any {
+	app, ok := p.Settings.CustomApplications[name]
+	if !ok {
+		return nil
+	}
+	return map[string]any{
+		"name":              name,
+		"instance_patterns": app.InstancePattens,
+	}
+}
+
+func auditIntegrations(p *db.Project) json.RawMessage {
+	return auditSnapshot(map[string]any{
+		"prometheus":   p.Prometheus,
+		"integrations": p.Settings.Integrations,
+	})
+}
+
+// saveApplicationSetting saves a setting of the application and records the change of its settings.
+func (api *Api) saveApplicationSetting(r *http.Request, u *db.User, action rbac.Action, projectId db.ProjectId, appId model.ApplicationId, s any) error {
+	before, err := api.db.GetApplicationSettings(projectId, appId)
+	if err != nil {
+		return err
+	}
+	if err = api.db.SaveApplicationSetting(projectId, appId, s); err != nil {
+		return err
+	}
+	after, err := api.db.GetApplicationSettings(projectId, appId)
+	if err != nil {
+		klog.Errorln(err)
+		return nil
+	}
+	api.auditLog(r, u, action, db.AuditOperationUpdate, appId.String(), before, after)
+	return nil
+}
+
+// checkConfigsSnapshot returns the configs of the check at the project and application levels.
+func (api *Api) checkConfigsSnapshot(projectId db.ProjectId, appId model.ApplicationId, checkId model.CheckId) (map[string]json.RawMessage, error) {
+	configs, err := api.db.GetCheckConfigs(projectId)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]json.RawMessage{
+		"project":     configs[model.ApplicationIdZero][checkId],
+		"application": configs[appId][checkId],
+	}, nil
+}
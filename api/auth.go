@@ -14,6 +14,7 @@ import (
 	"codexray/api/forms"
 	"codexray/db"
 	"codexray/rbac"
+	"codexray/sso"
 	"codexray/utils"
 
 	"k8s.io/klog"
@@ -30,7 +31,22 @@ const (
 	SessionCookieTTL      = 7 * 24 * time.Hour
 )
 
-func (api *Api) AuthInit(anonymousRole string, adminPassword string) error {
+// AuthBackend is the authentication backend selected with the --auth-backend flag.
+type AuthBackend struct {
+	Type        sso.BackendType
+	LDAP        *sso.LDAP
+	SAML        *sso.SAML
+	RoleMapping sso.RoleMapping
+}
+
+var errPasswordLoginDisabled = errors.New("password login is disabled")
+
+func (api *Api) AuthInit(anonymousRole string, adminPassword string, backend AuthBackend) error {
+	api.authBackend = backend
+	if backend.Type != "" && backend.Type != sso.BackendLocal {
+		klog.Infoln("authentication backend:", backend.Type)
+	}
+
 	if anonymousRole != "" {
 		role := rbac.RoleName(anonymousRole)
 		roles, err := api.roles.GetRoles()
@@ -130,12 +146,17 @@ func (api *Api) Login(w http.ResponseWriter, r *http.Request) {
 		}
 		userId = admin.Id
 	default:
-		id, err := api.db.AuthUser(form.Email, form.Password)
+		id, err := api.authenticate(form.Email, form.Password)
 		if err != nil {
 			klog.Errorln(err)
-			if errors.Is(err, db.ErrNotFound) {
+			switch {
+			case errors.Is(err, db.ErrNotFound):
 				http.Error(w, "Invalid email or password.", http.StatusNotFound)
-			} else {
+			case errors.Is(err, errPasswordLoginDisabled):
+				http.Error(w, "Please log in with single sign-on.", http.StatusBadRequest)
+			case errors.Is(err, sso.ErrNoRole), errors.Is(err, db.ErrConflict):
+				http.Error(w, "You don't have access to this instance.", http.StatusForbidden)
+			default:
 				http.Error(w, "", http.StatusInternalServerError)
 			}
 			return
@@ -150,6 +171,32 @@ func (api *Api) Login(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authenticate checks the credentials against the authentication backend and returns the id of the user.
+// The default admin is always authenticated locally, so it remains a break-glass account if the backend is unavailable.
+func (api *Api) authenticate(login, password string) (int, error) {
+	if login == db.AdminUserLogin {
+		return api.db.AuthUser(login, password)
+	}
+	switch api.authBackend.Type {
+	case sso.BackendLDAP:
+		identity, err := api.authBackend.LDAP.Authenticate(login, password)
+		if err != nil {
+			if errors.Is(err, sso.ErrInvalidCredentials) {
+				return 0, db.ErrNotFound
+			}
+			return 0, err
+		}
+		roles, err := identity.Roles(api.authBackend.RoleMapping)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", identity.Email, err)
+		}
+		return api.db.ProvisionSSOUser(identity.Email, identity.Name, roles)
+	case sso.BackendSAML:
+		return 0, errPasswordLoginDisabled
+	}
+	return api.db.AuthUser(login, password)
+}
+
 func (api *Api) Logout(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     SessionCookieName,
@@ -163,12 +210,9 @@ func (api *Api) SetSessionCookie(w http.ResponseWriter, userId int, ttl time.Dur
 	if err != nil {
 		return err
 	}
-	h := hmac.New(HashFunc.New, []byte(api.authSecret))
-	h.Write(data)
-	value := base64.URLEncoding.EncodeToString(data) + "." + base64.URLEncoding.EncodeToString(h.Sum(nil))
 	http.SetCookie(w, &http.Cookie{
 		Name:     SessionCookieName,
-		Value:    value,
+		Value:    api.sign(data),
 		Path:     "/",
 		Expires:  time.Now().Add(ttl),
 		HttpOnly: true,
@@ -181,24 +225,17 @@ func (api *Api) GetUser(r *http.Request) *db.User {
 		return db.AnonymousUser(api.authAnonymousRole)
 	}
 
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return api.getUserByAccessToken(strings.TrimSpace(token))
+	}
+
 	c, _ := r.Cookie(SessionCookieName)
 	if c == nil {
 		return nil
 	}
-	parts := strings.Split(c.Value, ".")
-	if len(parts) != 2 {
-		klog.Errorln("invalid session")
-		return nil
-	}
-	data, err := base64.URLEncoding.DecodeString(parts[0])
+	data, err := api.unsign(c.Value)
 	if err != nil {
-		klog.Errorln(err)
-		return nil
-	}
-	h := hmac.New(HashFunc.New, []byte(api.authSecret))
-	h.Write(data)
-	if parts[1] != base64.URLEncoding.EncodeToString(h.Sum(nil)) {
-		klog.Errorln("invalid session")
+		klog.Errorln("invalid session:", err)
 		return nil
 	}
 	var sess Session
@@ -216,7 +253,27 @@ func (api *Api) GetUser(r *http.Request) *db.User {
 	return user
 }
 
+func (api *Api) getUserByAccessToken(token string) *db.User {
+	t, err := api.db.AuthAccessToken(token)
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) {
+			klog.Errorln(err)
+		}
+		return nil
+	}
+	user, err := api.db.GetUser(t.UserId)
+	if err != nil {
+		klog.Errorln(err)
+		return nil
+	}
+	user.AccessToken = t
+	return user
+}
+
 func (api *Api) IsAllowed(u *db.User, action rbac.Action) bool {
+	if u.AccessToken != nil && !u.AccessToken.Allows(action) {
+		return false
+	}
 	roles, err := api.roles.GetRoles()
 	if err != nil {
 		klog.Errorln(err)
@@ -234,3 +291,27 @@ func (api *Api) IsAllowed(u *db.User, action rbac.Action) bool {
 	}
 	return false
 }
+
+// sign encodes the data along with its HMAC, so that it can be stored on the client side.
+func (api *Api) sign(data []byte) string {
+	h := hmac.New(HashFunc.New, []byte(api.authSecret))
+	h.Write(data)
+	return base64.URLEncoding.EncodeToString(data) + "." + base64.URLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (api *Api) unsign(value string) ([]byte, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed value")
+	}
+	data, err := base64.URLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(HashFunc.New, []byte(api.authSecret))
+	h.Write(data)
+	if !hmac.Equal([]byte(parts[1]), []byte(base64.URLEncoding.EncodeToString(h.Sum(nil)))) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	return data, nil
+}
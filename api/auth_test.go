@@ -0,0 +1,64 @@
+package api
+
+import (
+	"testing"
+
+	"codexray/db"
+	"codexray/rbac"
+	"codexray/timeseries"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessTokenPermissions(t *testing.T) {
+	database, err := db.Open(t.TempDir(), "")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	api := &Api{db: database, roles: rbac.NewStaticRoleManager()}
+
+	require.NoError(t, database.AddUser("editor@example.com", "password", "Editor", rbac.RoleEditor))
+	users, err := database.GetUsers()
+	require.NoError(t, err)
+	var editor *db.User
+	for _, u := range users {
+		if u.Email == "editor@example.com" {
+			editor = u
+		}
+	}
+	require.NotNil(t, editor)
+
+	newToken := func(permissions ...rbac.Permission) *db.User {
+		token, err := database.CreateAccessToken(&db.AccessToken{
+			UserId: editor.Id, Name: "ci", Permissions: permissions, ExpiresAt: timeseries.Now().Add(timeseries.Hour),
+		})
+		require.NoError(t, err)
+		u := api.getUserByAccessToken(token)
+		require.NotNil(t, u)
+		require.NotNil(t, u.AccessToken)
+		return u
+	}
+	p1, p2 := rbac.Actions.Project("p1"), rbac.Actions.Project("p2")
+
+	u := newToken()
+	assert.True(t, api.IsAllowed(u, p1.Inspections().Edit()), "a token without permissions has the ones of the user")
+	assert.True(t, api.IsAllowed(u, p2.Traces().View()))
+	assert.False(t, api.IsAllowed(u, p1.Settings().Edit()), "nor more")
+	assert.False(t, api.IsAllowed(u, rbac.Actions.Users().Edit()))
+
+	u = newToken(rbac.NewPermission(rbac.ScopeAll, rbac.ActionView, rbac.Object{"project_id": "p1"}))
+	assert.True(t, api.IsAllowed(u, p1.Traces().View()))
+	assert.False(t, api.IsAllowed(u, p2.Traces().View()), "the token is narrowed to a project")
+	assert.False(t, api.IsAllowed(u, p1.Inspections().Edit()), "the token is narrowed to viewing")
+
+	u = newToken(rbac.NewPermission(rbac.ScopeAll, rbac.ActionAll, nil))
+	assert.True(t, api.IsAllowed(u, p1.Inspections().Edit()))
+	assert.False(t, api.IsAllowed(u, p1.Settings().Edit()), "a token can't go beyond the roles of its owner")
+	assert.False(t, api.IsAllowed(u, p1.Integrations().Edit()))
+	assert.False(t, api.IsAllowed(u, rbac.Actions.Roles().Edit()))
+
+	expired, err := database.CreateAccessToken(&db.AccessToken{UserId: editor.Id, Name: "old", ExpiresAt: timeseries.Now().Add(-timeseries.Hour)})
+	require.NoError(t, err)
+	assert.Nil(t, api.getUserByAccessToken(expired))
+	assert.Nil(t, api.getUserByAccessToken(db.AccessTokenPrefix+"unknown"))
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"codexray/api/forms"
+	"codexray/cache"
+	"codexray/constructor"
+	"codexray/db"
+	"codexray/rbac"
+	"codexray/utils"
+
+	"github.com/gorilla/mux"
+	"k8s.io/klog"
+)
+
+// Cache lists the queries of the project along with the ranges the cache holds for them.
+func (api *Api) Cache(w http.ResponseWriter, r *http.Request, u *db.User) {
+	projectId := db.ProjectId(mux.Vars(r)["project"])
+	if !api.IsAllowed(u, rbac.Actions.Project(string(projectId)).Settings().Edit()) {
+		http.Error(w, "You are not allowed to manage the cache.", http.StatusForbidden)
+		return
+	}
+	queries, err := api.cache.GetCacheClient(projectId).Queries()
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteJson(w, queries)
+}
+
+// CacheQuery invalidates or re-backfills a query or a time range of it.
+func (api *Api) CacheQuery(w http.ResponseWriter, r *http.Request, u *db.User) {
+	vars := mux.Vars(r)
+	projectId := db.ProjectId(vars["project"])
+	hash := vars["query"]
+	if !api.IsAllowed(u, rbac.Actions.Project(string(projectId)).Settings().Edit()) {
+		http.Error(w, "You are not allowed to manage the cache.", http.StatusForbidden)
+		return
+	}
+	var form forms.CacheActionForm
+	if err := forms.ReadAndValidate(r, &form); err != nil {
+		klog.Warningln("bad request:", err)
+		http.Error(w, "Invalid action or time range", http.StatusBadRequest)
+		return
+	}
+	project, err := api.db.GetProject(projectId)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	cacheClient := api.cache.GetCacheClient(projectId)
+	switch form.Action {
+	case "invalidate":
+		_, _, err = cacheClient.Invalidate(hash, form.From, form.To)
+	case "backfill":
+		promClient, promErr := api.cache.GetPrometheusClient(project)
+		if promClient == nil {
+			klog.Warningln(promErr)
+			http.Error(w, "Prometheus is not configured", http.StatusBadRequest)
+			return
+		}
+		err = cacheClient.Backfill(promClient, hash, form.From, form.To)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, constructor.ErrUnknownQuery), errors.Is(err, cache.ErrRecordingRule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, cache.ErrBackfillInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	api.auditLog(r, u, rbac.Actions.Project(string(projectId)).Settings().Edit(), db.AuditOperationUpdate, "cache/"+hash, nil, form)
+	if form.Action == "backfill" {
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
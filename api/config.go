@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"codexray/config"
+	"codexray/db"
+	"codexray/rbac"
+	"codexray/utils"
+
+	"github.com/gorilla/mux"
+	"k8s.io/klog"
+)
+
+// Config exports the configuration of the project as YAML and applies YAML documents; dry_run=true only returns the diff.
+func (api *Api) Config(w http.ResponseWriter, r *http.Request, u *db.User) {
+	projectId := mux.Vars(r)["project"]
+	project, err := api.db.GetProject(db.ProjectId(projectId))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		klog.Errorln("failed to get project:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		if !api.IsAllowed(u, rbac.Actions.Project(projectId).Settings().Edit()) {
+			http.Error(w, "You are not allowed to export the project configuration.", http.StatusForbidden)
+			return
+		}
+		cfg, err := config.Export(api.db, project)
+		if err != nil {
+			klog.Errorln("failed to export config:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		data, err := config.Marshal(cfg)
+		if err != nil {
+			klog.Errorln("failed to export config:", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(data)
+		return
+	}
+
+	actions := []rbac.Action{
+		rbac.Actions.Project(projectId).Settings().Edit(),
+		rbac.Actions.Project(projectId).Integrations().Edit(),
+		rbac.Actions.Project(projectId).ApplicationCategories().Edit(),
+		rbac.Actions.Project(projectId).CustomApplications().Edit(),
+		rbac.Actions.Project(projectId).Inspections().Edit(),
+	}
+	for _, action := range actions {
+		if !api.IsAllowed(u, action) {
+			http.Error(w, "You are not allowed to apply the project configuration.", http.StatusForbidden)
+			return
+		}
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		klog.Warningln("bad request:", err)
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	plan, err := config.NewPlan(api.db, project, data, false)
+	if err != nil {
+		klog.Warningln("bad request:", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := struct {
+		Changes []db.AuditLogChange `json:"changes"`
+		Diff    string              `json:"diff"`
+		Applied bool                `json:"applied"`
+	}{
+		Changes: plan.Changes,
+		Diff:    plan.String(),
+	}
+	if res.Changes == nil {
+		res.Changes = []db.AuditLogChange{}
+	}
+	if r.URL.Query().Get("dry_run") == "true" || len(plan.Changes) == 0 {
+		utils.WriteJson(w, res)
+		return
+	}
+	if _, err = plan.Apply(api.db); err != nil {
+		klog.Errorln("failed to apply config:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	res.Applied = true
+	api.auditLog(r, u, actions[0], db.AuditOperationUpdate, "config", plan.Current, plan.Planned)
+
+	if api.globalClickHouse == nil {
+		for _, c := range plan.Changes {
+			if strings.HasPrefix(c.Path, "integrations.clickhouse") {
+				if project, err = api.db.GetProject(project.Id); err == nil {
+					err = api.collector.UpdateClickhouseClient(r.Context(), project.Id, project.Settings.Integrations.Clickhouse)
+				}
+				if err != nil {
+					klog.Errorln("clickhouse error:", err)
+				}
+				break
+			}
+		}
+	}
+	utils.WriteJson(w, res)
+}
@@ -3,7 +3,9 @@ package forms
 import (
 	"strings"
 
+	"codexray/db"
 	"codexray/rbac"
+	"codexray/timeseries"
 )
 
 type LoginForm struct {
@@ -40,6 +42,8 @@ type UserForm struct {
 	Name     string        `json:"name"`
 	Role     rbac.RoleName `json:"role"`
 	Password string        `json:"password"`
+	// SSO removes the password of the user being updated, so the user can log in with single sign-on only.
+	SSO bool `json:"sso"`
 }
 
 func (f *UserForm) Valid() bool {
@@ -50,3 +54,102 @@ func (f *UserForm) Valid() bool {
 	f.Name = strings.TrimSpace(f.Name)
 	return f.Email != "" && f.Name != ""
 }
+
+type RoleAction string
+
+const (
+	RoleActionCreate RoleAction = "create"
+	RoleActionUpdate RoleAction = "update"
+	RoleActionDelete RoleAction = "delete"
+)
+
+type RoleForm struct {
+	Action      RoleAction         `json:"action"`
+	Id          rbac.RoleName      `json:"id"` // the current name of the role being updated or deleted
+	Name        rbac.RoleName      `json:"name"`
+	Permissions rbac.PermissionSet `json:"permissions"`
+}
+
+func (f *RoleForm) Valid() bool {
+	switch f.Action {
+	case RoleActionDelete:
+		return f.Id != ""
+	case RoleActionUpdate:
+		if f.Id == "" {
+			return false
+		}
+	case RoleActionCreate:
+	default:
+		return false
+	}
+	f.Name = rbac.RoleName(strings.TrimSpace(string(f.Name)))
+	return true
+}
+
+func (f *RoleForm) Role() rbac.Role {
+	return rbac.Role{Name: f.Name, Permissions: f.Permissions}
+}
+
+type SSOForm struct {
+	db.SSOSettings
+}
+
+func (f *SSOForm) Valid() bool {
+	f.BaseUrl = strings.TrimSpace(f.BaseUrl)
+	if f.OIDC != nil {
+		f.OIDC.Issuer = strings.TrimSpace(f.OIDC.Issuer)
+		f.OIDC.ClientId = strings.TrimSpace(f.OIDC.ClientId)
+	}
+	return true
+}
+
+type AccessTokenAction string
+
+const (
+	AccessTokenActionCreate AccessTokenAction = "create"
+	AccessTokenActionRevoke AccessTokenAction = "revoke"
+)
+
+const AccessTokenMaxTTLDays = 365
+
+type AccessTokenForm struct {
+	Action AccessTokenAction `json:"action"`
+	Id     string            `json:"id"`
+	Name   string            `json:"name"`
+	// ExpiresInDays is required, tokens that never expire are not supported.
+	ExpiresInDays int `json:"expires_in_days"`
+	// ReadOnly limits the token to viewing; otherwise, Permissions may narrow down the permissions of the user's roles.
+	ReadOnly    bool               `json:"read_only"`
+	Permissions rbac.PermissionSet `json:"permissions"`
+}
+
+func (f *AccessTokenForm) Valid() bool {
+	switch f.Action {
+	case AccessTokenActionRevoke:
+		return f.Id != ""
+	case AccessTokenActionCreate:
+	default:
+		return false
+	}
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" || f.ExpiresInDays < 1 || f.ExpiresInDays > AccessTokenMaxTTLDays {
+		return false
+	}
+	if f.ReadOnly {
+		f.Permissions = rbac.PermissionSet{rbac.NewPermission(rbac.ScopeAll, rbac.ActionView, nil)}
+	}
+	for _, p := range f.Permissions {
+		if p.Validate() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+type AuditLogSettingsForm struct {
+	db.AuditLogSettings
+}
+
+func (f *AuditLogSettingsForm) Valid() bool {
+	return f.Retention >= timeseries.Day
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
 	"strings"
 
 	"codexray/clickhouse"
@@ -15,6 +16,7 @@ import (
 	"codexray/model"
 	"codexray/notifications"
 	"codexray/prom"
+	"codexray/timeseries"
 	"codexray/utils"
 )
 
@@ -140,6 +142,135 @@ func (f *CustomApplicationForm) Valid() bool {
 	return true
 }
 
+type AlertRuleForm struct {
+	Action string `json:"action"`
+	db.AlertRule
+}
+
+func (f *AlertRuleForm) Valid() bool {
+	switch f.Action {
+	case "delete":
+		return f.Id != ""
+	case "create", "update":
+		return f.AlertRule.Valid() && prom.IsExprValid(f.Expr)
+	}
+	return false
+}
+
+type IncidentActionForm struct {
+	Action       string `json:"action"`
+	Assignee     int    `json:"assignee"`
+	Text         string `json:"text"`
+	DeploymentId string `json:"deployment_id"`
+	RootCause    string `json:"root_cause"`
+}
+
+func (f *IncidentActionForm) Valid() bool {
+	switch f.Action {
+	case "acknowledge":
+		return true
+	case "assign":
+		return f.Assignee > 0
+	case "note":
+		f.Text = strings.TrimSpace(f.Text)
+		return f.Text != ""
+	case "link_deployment":
+		return f.DeploymentId != ""
+	case "root_cause":
+		f.RootCause = strings.TrimSpace(f.RootCause)
+		return true
+	}
+	return false
+}
+
+type EscalationPolicyForm struct {
+	Action string `json:"action"`
+	db.EscalationPolicy
+}
+
+func (f *EscalationPolicyForm) Valid() bool {
+	switch f.Action {
+	case "delete":
+		return f.Id != ""
+	case "create", "update":
+		return f.EscalationPolicy.Valid()
+	}
+	return false
+}
+
+type IncidentSettingsForm struct {
+	db.IncidentSettings
+}
+
+func (f *IncidentSettingsForm) Valid() bool {
+	return f.MinOpenDuration >= 0 && f.MinResolveDuration >= 0 && f.ResolveHysteresis >= 0
+}
+
+type NotificationRoutesForm struct {
+	Routes db.NotificationRoutes `json:"routes"`
+}
+
+func (f *NotificationRoutesForm) Valid() bool {
+	return f.Routes.Validate() == nil
+}
+
+// MaskNotificationRoutes returns a copy of the routes with the secrets of their destinations hidden.
+func MaskNotificationRoutes(rs db.NotificationRoutes) db.NotificationRoutes {
+	if rs == nil {
+		return nil
+	}
+	res := make(db.NotificationRoutes, 0, len(rs))
+	for _, r := range rs {
+		masked := *r
+		masked.Destinations = maskDestinations(r.Destinations)
+		masked.Routes = MaskNotificationRoutes(r.Routes)
+		res = append(res, &masked)
+	}
+	return res
+}
+
+// MaskEscalationPolicies returns copies of the policies with the secrets of their destinations hidden.
+func MaskEscalationPolicies(ps []*db.EscalationPolicy) []*db.EscalationPolicy {
+	res := make([]*db.EscalationPolicy, 0, len(ps))
+	for _, p := range ps {
+		masked := *p
+		masked.Steps = slices.Clone(p.Steps)
+		for i := range masked.Steps {
+			masked.Steps[i].Destinations = maskDestinations(masked.Steps[i].Destinations)
+		}
+		res = append(res, &masked)
+	}
+	return res
+}
+
+func maskDestinations(ds []db.NotificationDestination) []db.NotificationDestination {
+	res := slices.Clone(ds)
+	for i := range res {
+		if res[i].WebhookUrl != "" {
+			res[i].WebhookUrl = "<hidden>"
+		}
+		if res[i].PagerdutyIntegrationKey != "" {
+			res[i].PagerdutyIntegrationKey = "<hidden>"
+		}
+	}
+	return res
+}
+
+type MaintenanceWindowForm struct {
+	Action string `json:"action"`
+	model.MaintenanceWindow
+}
+
+func (f *MaintenanceWindowForm) Valid() bool {
+	switch f.Action {
+	case "delete":
+		return f.Id != ""
+	case "create", "update":
+		return f.MaintenanceWindow.Validate() == nil
+	}
+	return false
+}
+
 type ApplicationInstrumentationForm struct {
 	model.ApplicationInstrumentation
 }
@@ -209,6 +340,16 @@ func NewIntegrationForm(t db.IntegrationType, globalClickHouse *db.IntegrationCl
 		return &IntegrationFormOpsgenie{}
 	case db.IntegrationTypeWebhook:
 		return &IntegrationFormWebhook{}
+	case db.IntegrationTypeEmail:
+		return &IntegrationFormEmail{}
+	case db.IntegrationTypeTelegram:
+		return &IntegrationFormTelegram{}
+	case db.IntegrationTypeMattermost:
+		return &IntegrationFormMattermost{}
+	case db.IntegrationTypeGoogleChat:
+		return &IntegrationFormGoogleChat{}
+	case db.IntegrationTypeDiscord:
+		return &IntegrationFormDiscord{}
 	}
 	return nil
 }
@@ -219,19 +360,40 @@ type IntegrationFormPrometheus struct {
 }
 
 func (f *IntegrationFormPrometheus) Valid() bool {
-	if _, err := url.Parse(f.IntegrationsPrometheus.Url); err != nil {
+	if !validPrometheusSource(&f.IntegrationsPrometheus) {
+		return false
+	}
+	// the additional sources are told apart by their clusters, which are also a part of the application ids,
+	// the applications of the first source keep their ids
+	f.Cluster = ""
+	clusters := map[string]bool{}
+	for i := range f.AdditionalSources {
+		s := &f.AdditionalSources[i]
+		if s.Url == "" || len(s.AdditionalSources) > 0 || !validPrometheusSource(s) {
+			return false
+		}
+		if s.Cluster == "" || strings.ContainsAny(s.Cluster, "/:") || clusters[s.Cluster] {
+			return false
+		}
+		clusters[s.Cluster] = true
+	}
+	return true
+}
+
+func validPrometheusSource(s *db.IntegrationsPrometheus) bool {
+	if _, err := url.Parse(s.Url); err != nil {
 		return false
 	}
-	if !prom.IsSelectorValid(f.IntegrationsPrometheus.ExtraSelector) {
+	if !prom.IsSelectorValid(s.ExtraSelector) {
 		return false
 	}
 	var validHeaders []utils.Header
-	for _, h := range f.CustomHeaders {
+	for _, h := range s.CustomHeaders {
 		if h.Valid() {
 			validHeaders = append(validHeaders, h)
 		}
 	}
-	f.CustomHeaders = validHeaders
+	s.CustomHeaders = validHeaders
 	return true
 }
 
@@ -243,17 +405,25 @@ func (f *IntegrationFormPrometheus) Get(project *db.Project, masked bool) {
 	}
 	f.IntegrationsPrometheus = *cfg
 	if masked {
-		f.Url = "http://<hidden>"
-		if f.BasicAuth != nil {
-			f.BasicAuth.User = "<hidden>"
-			f.BasicAuth.Password = "<hidden>"
-		}
-		for i := range f.CustomHeaders {
-			f.CustomHeaders[i].Value = "<hidden>"
+		maskPrometheusSource(&f.IntegrationsPrometheus)
+		f.AdditionalSources = slices.Clone(f.AdditionalSources)
+		for i := range f.AdditionalSources {
+			maskPrometheusSource(&f.AdditionalSources[i])
 		}
 	}
 }
 
+func maskPrometheusSource(s *db.IntegrationsPrometheus) {
+	s.Url = "http://<hidden>"
+	if s.BasicAuth != nil {
+		s.BasicAuth = &utils.BasicAuth{User: "<hidden>", Password: "<hidden>"}
+	}
+	s.CustomHeaders = slices.Clone(s.CustomHeaders)
+	for i := range s.CustomHeaders {
+		s.CustomHeaders[i].Value = "<hidden>"
+	}
+}
+
 func (f *IntegrationFormPrometheus) Update(ctx context.Context, project *db.Project, clear bool) error {
 	if f.global != nil {
 		return fmt.Errorf("global Prometheus configuration is used and cannot be changed")
@@ -266,12 +436,8 @@ func (f *IntegrationFormPrometheus) Update(ctx context.Context, project *db.Proj
 }
 
 func (f *IntegrationFormPrometheus) Test(ctx context.Context, project *db.Project) error {
-	config := prom.NewClientConfig(f.Url, f.RefreshInterval)
-	config.BasicAuth = f.BasicAuth
-	config.TlsSkipVerify = f.TlsSkipVerify
-	config.ExtraSelector = f.ExtraSelector
-	config.CustomHeaders = f.CustomHeaders
-	client, err := prom.NewClient(config)
+	configs := f.ClientConfigs()
+	client, err := prom.NewClient(configs[0], configs[1:]...)
 	if err != nil {
 		return err
 	}
@@ -416,7 +582,7 @@ func (f *IntegrationFormSlack) Update(ctx context.Context, project *db.Project,
 }
 
 func (f *IntegrationFormSlack) Test(ctx context.Context, project *db.Project) error {
-	return notifications.NewSlack(f.Token, f.DefaultChannel).SendIncident(ctx, project.Settings.Integrations.BaseUrl, testIncidentNotification(project))
+	return notifications.NewSlack(f.Token, f.DefaultChannel).SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project))
 }
 
 type IntegrationFormTeams struct {
@@ -453,7 +619,7 @@ func (f *IntegrationFormTeams) Update(ctx context.Context, project *db.Project,
 }
 
 func (f *IntegrationFormTeams) Test(ctx context.Context, project *db.Project) error {
-	return notifications.NewTeams(f.WebhookUrl).SendIncident(ctx, project.Settings.Integrations.BaseUrl, testIncidentNotification(project))
+	return notifications.NewTeams(f.WebhookUrl).SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project))
 }
 
 type IntegrationFormPagerduty struct {
@@ -489,7 +655,7 @@ func (f *IntegrationFormPagerduty) Update(ctx context.Context, project *db.Proje
 }
 
 func (f *IntegrationFormPagerduty) Test(ctx context.Context, project *db.Project) error {
-	return notifications.NewPagerduty(f.IntegrationKey).SendIncident(ctx, project.Settings.Integrations.BaseUrl, testIncidentNotification(project))
+	return notifications.NewPagerduty(f.IntegrationKey).SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project))
 }
 
 type IntegrationFormOpsgenie struct {
@@ -525,7 +691,7 @@ func (f *IntegrationFormOpsgenie) Update(ctx context.Context, project *db.Projec
 }
 
 func (f *IntegrationFormOpsgenie) Test(ctx context.Context, project *db.Project) error {
-	return notifications.NewOpsgenie(f.ApiKey, f.EUInstance).SendIncident(ctx, project.Settings.Integrations.BaseUrl, testIncidentNotification(project))
+	return notifications.NewOpsgenie(f.ApiKey, f.EUInstance).SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project))
 }
 
 type IntegrationFormWebhook struct {
@@ -571,13 +737,13 @@ func (f *IntegrationFormWebhook) Test(ctx context.Context, project *db.Project)
 	cfg := &f.IntegrationWebhook
 	wh := notifications.NewWebhook(cfg)
 	if cfg.Incidents {
-		err := wh.SendIncident(ctx, project.Settings.Integrations.BaseUrl, testIncidentNotification(project))
+		err := wh.SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project))
 		if err != nil {
 			return err
 		}
 	}
 	if cfg.Deployments {
-		err := wh.SendDeployment(ctx, project, testDeploymentNotification())
+		err := wh.SendDeployment(ctx, project, notifications.TestDeploymentNotification())
 		if err != nil {
 			return err
 		}
@@ -585,38 +751,277 @@ func (f *IntegrationFormWebhook) Test(ctx context.Context, project *db.Project)
 	return nil
 }
 
-func testIncidentNotification(project *db.Project) *db.IncidentNotification {
-	return &db.IncidentNotification{
-		ProjectId:     project.Id,
-		ApplicationId: model.NewApplicationId("default", model.ApplicationKindDeployment, "test-alert-fake-app"),
-		IncidentKey:   "123ab456",
-		Status:        model.WARNING,
-		Details: &db.IncidentNotificationDetails{
-			Reports: []db.IncidentNotificationDetailsReport{
-				{Name: model.AuditReportSLO, Check: model.Checks.SLOLatency.Title, Message: "error budget burn rate is 20x within 1 hour"},
-				{Name: model.AuditReportNetwork, Check: model.Checks.NetworkRTT.Title, Message: "high network latency to 2 upstream services"},
-			},
-		},
+type IntegrationFormEmail struct {
+	db.IntegrationEmail
+}
+
+func (f *IntegrationFormEmail) Valid() bool {
+	if f.Host == "" || f.Port <= 0 || f.Port > 65535 || !emailRe.MatchString(f.From) {
+		return false
+	}
+	switch f.TLSMode {
+	case db.EmailTLSModeNone, db.EmailTLSModeStartTLS, db.EmailTLSModeTLS:
+	default:
+		return false
 	}
+	var err error
+	if f.Recipients, err = validEmails(f.Recipients); err != nil {
+		return false
+	}
+	for category, recipients := range f.CategoryRecipients {
+		if f.CategoryRecipients[category], err = validEmails(recipients); err != nil {
+			return false
+		}
+	}
+	return true
 }
 
-func testDeploymentNotification() model.ApplicationDeploymentStatus {
-	return model.ApplicationDeploymentStatus{
-		Status: model.OK,
-		State:  model.ApplicationDeploymentStateSummary,
-		Summary: []model.ApplicationDeploymentSummary{
-			{Report: model.AuditReportSLO, Ok: false, Message: "Availability: 87% (objective: 99%)"},
-			{Report: model.AuditReportCPU, Ok: false, Message: "CPU usage: +21% (+$37/mo) compared to the previous deployment"},
-			{Report: model.AuditReportCPU, Ok: true, Message: "Memory: looks like the memory leak has been fixed"},
-		},
-		Deployment: &model.ApplicationDeployment{
-			ApplicationId: model.NewApplicationId("default", model.ApplicationKindDeployment, "test-deployment-fake-app"),
-			Name:          "123ab456",
-			Details:       &model.ApplicationDeploymentDetails{ContainerImages: []string{"app:v1.8.2"}},
-		},
+func validEmails(emails []string) ([]string, error) {
+	var res []string
+	for _, e := range emails {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if !emailRe.MatchString(e) {
+			return nil, fmt.Errorf("invalid email: %s", e)
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (f *IntegrationFormEmail) Get(project *db.Project, masked bool) {
+	cfg := project.Settings.Integrations.Email
+	if cfg == nil {
+		f.Port = 587
+		f.TLSMode = db.EmailTLSModeStartTLS
+		f.Incidents = true
+		f.Deployments = true
+		return
+	}
+	f.IntegrationEmail = *cfg
+	if masked && cfg.Auth != nil {
+		f.Auth = &utils.BasicAuth{User: "<hidden>", Password: "<hidden>"}
 	}
 }
 
+func (f *IntegrationFormEmail) Update(ctx context.Context, project *db.Project, clear bool) error {
+	cfg := &f.IntegrationEmail
+	if clear {
+		cfg = nil
+	}
+	project.Settings.Integrations.Email = cfg
+	return nil
+}
+
+func (f *IntegrationFormEmail) Test(ctx context.Context, project *db.Project) error {
+	cfg := &f.IntegrationEmail
+	client := notifications.NewEmail(cfg, project.Settings.ApplicationCategories)
+	if cfg.Incidents {
+		if err := client.SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project)); err != nil {
+			return err
+		}
+	}
+	if cfg.Deployments {
+		if err := client.SendDeployment(ctx, project, notifications.TestDeploymentNotification()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type IntegrationFormTelegram struct {
+	db.IntegrationTelegram
+}
+
+func (f *IntegrationFormTelegram) Valid() bool {
+	f.BotToken = strings.TrimSpace(f.BotToken)
+	f.ChatId = strings.TrimSpace(f.ChatId)
+	return f.BotToken != "" && f.ChatId != ""
+}
+
+func (f *IntegrationFormTelegram) Get(project *db.Project, masked bool) {
+	cfg := project.Settings.Integrations.Telegram
+	if cfg == nil {
+		f.Incidents = true
+		f.Deployments = true
+		return
+	}
+	f.IntegrationTelegram = *cfg
+	if masked {
+		f.BotToken = "<hidden>"
+	}
+}
+
+func (f *IntegrationFormTelegram) Update(ctx context.Context, project *db.Project, clear bool) error {
+	cfg := &f.IntegrationTelegram
+	if clear {
+		cfg = nil
+	}
+	project.Settings.Integrations.Telegram = cfg
+	return nil
+}
+
+func (f *IntegrationFormTelegram) Test(ctx context.Context, project *db.Project) error {
+	client := notifications.NewTelegram(f.BotToken, f.ChatId)
+	if f.Incidents {
+		if err := client.SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project)); err != nil {
+			return err
+		}
+	}
+	if f.Deployments {
+		if err := client.SendDeployment(ctx, project, notifications.TestDeploymentNotification()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type IntegrationFormMattermost struct {
+	db.IntegrationMattermost
+}
+
+func (f *IntegrationFormMattermost) Valid() bool {
+	if _, err := url.ParseRequestURI(f.WebhookUrl); err != nil {
+		return false
+	}
+	return true
+}
+
+func (f *IntegrationFormMattermost) Get(project *db.Project, masked bool) {
+	cfg := project.Settings.Integrations.Mattermost
+	if cfg == nil {
+		f.Incidents = true
+		f.Deployments = true
+		return
+	}
+	f.IntegrationMattermost = *cfg
+	if masked {
+		f.WebhookUrl = "<hidden>"
+	}
+}
+
+func (f *IntegrationFormMattermost) Update(ctx context.Context, project *db.Project, clear bool) error {
+	cfg := &f.IntegrationMattermost
+	if clear {
+		cfg = nil
+	}
+	project.Settings.Integrations.Mattermost = cfg
+	return nil
+}
+
+func (f *IntegrationFormMattermost) Test(ctx context.Context, project *db.Project) error {
+	client := notifications.NewMattermost(f.WebhookUrl, f.Channel)
+	if f.Incidents {
+		if err := client.SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project)); err != nil {
+			return err
+		}
+	}
+	if f.Deployments {
+		if err := client.SendDeployment(ctx, project, notifications.TestDeploymentNotification()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type IntegrationFormGoogleChat struct {
+	db.IntegrationGoogleChat
+}
+
+func (f *IntegrationFormGoogleChat) Valid() bool {
+	if _, err := url.ParseRequestURI(f.WebhookUrl); err != nil {
+		return false
+	}
+	return true
+}
+
+func (f *IntegrationFormGoogleChat) Get(project *db.Project, masked bool) {
+	cfg := project.Settings.Integrations.GoogleChat
+	if cfg == nil {
+		f.Incidents = true
+		f.Deployments = true
+		return
+	}
+	f.IntegrationGoogleChat = *cfg
+	if masked {
+		f.WebhookUrl = "<hidden>"
+	}
+}
+
+func (f *IntegrationFormGoogleChat) Update(ctx context.Context, project *db.Project, clear bool) error {
+	cfg := &f.IntegrationGoogleChat
+	if clear {
+		cfg = nil
+	}
+	project.Settings.Integrations.GoogleChat = cfg
+	return nil
+}
+
+func (f *IntegrationFormGoogleChat) Test(ctx context.Context, project *db.Project) error {
+	client := notifications.NewGoogleChat(f.WebhookUrl)
+	if f.Incidents {
+		if err := client.SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project)); err != nil {
+			return err
+		}
+	}
+	if f.Deployments {
+		if err := client.SendDeployment(ctx, project, notifications.TestDeploymentNotification()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type IntegrationFormDiscord struct {
+	db.IntegrationDiscord
+}
+
+func (f *IntegrationFormDiscord) Valid() bool {
+	if _, err := url.ParseRequestURI(f.WebhookUrl); err != nil {
+		return false
+	}
+	return true
+}
+
+func (f *IntegrationFormDiscord) Get(project *db.Project, masked bool) {
+	cfg := project.Settings.Integrations.Discord
+	if cfg == nil {
+		f.Incidents = true
+		f.Deployments = true
+		return
+	}
+	f.IntegrationDiscord = *cfg
+	if masked {
+		f.WebhookUrl = "<hidden>"
+	}
+}
+
+func (f *IntegrationFormDiscord) Update(ctx context.Context, project *db.Project, clear bool) error {
+	cfg := &f.IntegrationDiscord
+	if clear {
+		cfg = nil
+	}
+	project.Settings.Integrations.Discord = cfg
+	return nil
+}
+
+func (f *IntegrationFormDiscord) Test(ctx context.Context, project *db.Project) error {
+	client := notifications.NewDiscord(f.WebhookUrl)
+	if f.Incidents {
+		if err := client.SendIncident(ctx, project.Settings.Integrations.BaseUrl, notifications.TestIncidentNotification(project)); err != nil {
+			return err
+		}
+	}
+	if f.Deployments {
+		if err := client.SendDeployment(ctx, project, notifications.TestDeploymentNotification()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type TrustDomainsForm struct {
 	Domains []string `json:"trust_domain"`
 }
@@ -667,3 +1072,19 @@ func (f *TrustDomainsForm) Update(ctx context.Context, project *db.Project, clea
 func isValidDomain(domain string) bool {
 	return !strings.Contains(domain, " ")
 }
+
+type CacheActionForm struct {
+	Action string          `json:"action"`
+	From   timeseries.Time `json:"from"`
+	To     timeseries.Time `json:"to"`
+}
+
+func (f *CacheActionForm) Valid() bool {
+	switch f.Action {
+	case "invalidate":
+		return f.From.IsZero() == f.To.IsZero() && f.From <= f.To
+	case "backfill":
+		return !f.From.IsZero() && f.From < f.To
+	}
+	return false
+}
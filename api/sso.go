@@ -0,0 +1,348 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"codexray/api/forms"
+	"codexray/db"
+	"codexray/rbac"
+	"codexray/sso"
+	"codexray/utils"
+
+	"github.com/gorilla/mux"
+	"k8s.io/klog"
+)
+
+const (
+	SSOStateCookieName = "codexray_sso_state"
+	SSOStateTTL        = 10 * time.Minute
+)
+
+// SSOState is kept in a signed cookie between redirecting the user to the identity provider and the callback.
+type SSOState struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"` // the ID of the authentication request for SAML
+	Verifier string `json:"verifier,omitempty"`
+	Expires  int64  `json:"expires"`
+}
+
+func (api *Api) SSO(w http.ResponseWriter, r *http.Request, u *db.User) {
+	isAllowed := api.IsAllowed(u, rbac.Actions.Users().Edit())
+	roles, err := api.roles.GetRoles()
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	settings, err := api.db.GetSSOSettings()
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !isAllowed {
+			http.Error(w, "You are not allowed to configure single sign-on.", http.StatusForbidden)
+			return
+		}
+		var form forms.SSOForm
+		if err := forms.ReadAndValidate(r, &form); err != nil {
+			klog.Warningln("bad request:", err)
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if err := form.Validate(roles); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if form.Enabled && form.Provider == db.SSOProviderOIDC {
+			ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+			defer cancel()
+			if _, err := sso.NewOIDC(ctx, form.OIDC, form.CallbackUrl()); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := api.db.SaveSSOSettings(&form.SSOSettings); err != nil {
+			klog.Errorln(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		api.auditLog(r, u, rbac.Actions.Users().Edit(), db.AuditOperationUpdate, "sso", settings, form.SSOSettings)
+		return
+	}
+
+	res := struct {
+		Editable    bool            `json:"editable"`
+		Roles       []rbac.RoleName `json:"roles"`
+		DefaultRole rbac.RoleName   `json:"default_role"`
+		Settings    *db.SSOSettings `json:"settings"`
+		CallbackUrl string          `json:"callback_url,omitempty"`
+	}{
+		Editable:    isAllowed,
+		DefaultRole: cmp.Or(settings.DefaultRole, rbac.RoleViewer),
+		Settings:    settings,
+	}
+	for _, role := range roles {
+		res.Roles = append(res.Roles, role.Name)
+	}
+	if settings.BaseUrl != "" && settings.Provider != "" {
+		res.CallbackUrl = settings.CallbackUrl()
+	}
+	if !isAllowed && settings.OIDC != nil {
+		settings.OIDC.ClientSecret = "<hidden>"
+	}
+	utils.WriteJson(w, res)
+}
+
+// SSOStatus tells the login page whether to offer single sign-on.
+func (api *Api) SSOStatus(w http.ResponseWriter, r *http.Request) {
+	res := struct {
+		Enabled  bool   `json:"enabled"`
+		Provider string `json:"provider,omitempty"`
+		// PasswordLogin is false if only the default admin can log in with a password.
+		PasswordLogin bool `json:"password_login"`
+	}{
+		PasswordLogin: api.authBackend.Type != sso.BackendSAML,
+	}
+	if api.authBackend.Type == sso.BackendSAML {
+		res.Enabled = true
+		res.Provider = string(sso.BackendSAML)
+		utils.WriteJson(w, res)
+		return
+	}
+	settings, err := api.db.GetSSOSettings()
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	if settings.Enabled {
+		res.Enabled = true
+		res.Provider = string(settings.Provider)
+	}
+	utils.WriteJson(w, res)
+}
+
+func (api *Api) SSOLogin(w http.ResponseWriter, r *http.Request) {
+	settings, provider := api.ssoSettings(w, r)
+	if settings == nil {
+		return
+	}
+	switch provider {
+	case db.SSOProviderOIDC:
+		oidc, err := sso.NewOIDC(r.Context(), settings.OIDC, settings.CallbackUrl())
+		if err != nil {
+			klog.Errorln(err)
+			http.Error(w, "The identity provider is unavailable.", http.StatusBadGateway)
+			return
+		}
+		state := SSOState{
+			Provider: string(provider),
+			State:    utils.RandomString(32),
+			Nonce:    utils.RandomString(32),
+			Verifier: sso.NewPKCEVerifier(),
+			Expires:  time.Now().Add(SSOStateTTL).Unix(),
+		}
+		if err := api.setSSOStateCookie(w, state, http.SameSiteLaxMode); err != nil {
+			klog.Errorln(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, oidc.AuthCodeUrl(state.State, state.Nonce, state.Verifier), http.StatusFound)
+	}
+}
+
+func (api *Api) SSOCallback(w http.ResponseWriter, r *http.Request) {
+	settings, provider := api.ssoSettings(w, r)
+	if settings == nil {
+		return
+	}
+	var identity *sso.Identity
+	switch provider {
+	case db.SSOProviderOIDC:
+		state := api.getSSOState(r)
+		q := r.URL.Query()
+		if state == nil || state.Provider != string(provider) || q.Get("state") != state.State {
+			http.Error(w, "Invalid or expired login session, please try again.", http.StatusBadRequest)
+			return
+		}
+		if e := q.Get("error"); e != "" {
+			http.Error(w, "The identity provider declined the login: "+e+" "+q.Get("error_description"), http.StatusUnauthorized)
+			return
+		}
+		oidc, err := sso.NewOIDC(r.Context(), settings.OIDC, settings.CallbackUrl())
+		if err != nil {
+			klog.Errorln(err)
+			http.Error(w, "The identity provider is unavailable.", http.StatusBadGateway)
+			return
+		}
+		identity, err = oidc.Exchange(r.Context(), q.Get("code"), state.Verifier, state.Nonce)
+		if err != nil {
+			klog.Warningln("OIDC login failed:", err)
+			http.Error(w, "Login failed.", http.StatusUnauthorized)
+			return
+		}
+	}
+	api.ssoLogin(w, r, identity, sso.RoleMapping{Rules: settings.RoleMapping, Default: settings.DefaultRole}, settings.BaseUrl)
+}
+
+func (api *Api) SAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	if api.authBackend.SAML == nil {
+		http.Error(w, "SAML is not configured.", http.StatusNotFound)
+		return
+	}
+	data, err := api.authBackend.SAML.Metadata()
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	_, _ = w.Write(data)
+}
+
+func (api *Api) SAMLLogin(w http.ResponseWriter, r *http.Request) {
+	s := api.authBackend.SAML
+	if s == nil {
+		http.Error(w, "SAML is not configured.", http.StatusNotFound)
+		return
+	}
+	state := SSOState{
+		Provider: string(sso.BackendSAML),
+		State:    utils.RandomString(32),
+		Expires:  time.Now().Add(SSOStateTTL).Unix(),
+	}
+	redirectUrl, requestId, err := s.AuthnRequestUrl(state.State)
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	state.Nonce = requestId
+	// the identity provider posts the response cross-site, so the cookie is only sent back if SameSite=None
+	sameSite := http.SameSiteDefaultMode
+	if strings.HasPrefix(s.BaseUrl(), "https://") {
+		sameSite = http.SameSiteNoneMode
+	}
+	if err := api.setSSOStateCookie(w, state, sameSite); err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, redirectUrl, http.StatusFound)
+}
+
+// SAMLACS is the Assertion Consumer Service the identity provider posts the response to.
+func (api *Api) SAMLACS(w http.ResponseWriter, r *http.Request) {
+	s := api.authBackend.SAML
+	if s == nil {
+		http.Error(w, "SAML is not configured.", http.StatusNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	state := api.getSSOState(r)
+	if state == nil || state.Provider != string(sso.BackendSAML) || r.PostForm.Get("RelayState") != state.State {
+		http.Error(w, "Invalid or expired login session, please try again.", http.StatusBadRequest)
+		return
+	}
+	identity, err := s.ParseResponse(r, state.Nonce)
+	if err != nil {
+		klog.Warningln("SAML login failed:", err)
+		http.Error(w, "Login failed.", http.StatusUnauthorized)
+		return
+	}
+	api.ssoLogin(w, r, identity, api.authBackend.RoleMapping, s.BaseUrl())
+}
+
+// ssoLogin provisions the user authenticated by the identity provider and starts a session.
+func (api *Api) ssoLogin(w http.ResponseWriter, r *http.Request, identity *sso.Identity, mapping sso.RoleMapping, redirectUrl string) {
+	roles, err := identity.Roles(mapping)
+	if err != nil {
+		klog.Warningf("SSO login of %s denied: %s", identity.Email, err)
+		http.Error(w, "You don't have access to this instance.", http.StatusForbidden)
+		return
+	}
+	userId, err := api.db.ProvisionSSOUser(identity.Email, identity.Name, roles)
+	if err != nil {
+		klog.Errorln(err)
+		if errors.Is(err, db.ErrConflict) {
+			http.Error(w, "This user cannot log in with single sign-on.", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: SSOStateCookieName, Path: "/", HttpOnly: true, MaxAge: -1})
+	if err := api.SetSessionCookie(w, userId, SessionCookieTTL); err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, redirectUrl, http.StatusFound)
+}
+
+func (api *Api) ssoSettings(w http.ResponseWriter, r *http.Request) (*db.SSOSettings, db.SSOProvider) {
+	settings, err := api.db.GetSSOSettings()
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return nil, ""
+	}
+	provider := db.SSOProvider(mux.Vars(r)["provider"])
+	if !settings.Enabled || settings.Provider != provider {
+		http.Error(w, "Single sign-on is not configured.", http.StatusNotFound)
+		return nil, ""
+	}
+	return settings, provider
+}
+
+func (api *Api) setSSOStateCookie(w http.ResponseWriter, state SSOState, sameSite http.SameSite) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SSOStateCookieName,
+		Value:    api.sign(data),
+		Path:     "/",
+		Expires:  time.Now().Add(SSOStateTTL),
+		HttpOnly: true,
+		Secure:   sameSite == http.SameSiteNoneMode,
+		// the identity provider redirects the user back with a cross-site top-level navigation
+		SameSite: sameSite,
+	})
+	return nil
+}
+
+func (api *Api) getSSOState(r *http.Request) *SSOState {
+	c, _ := r.Cookie(SSOStateCookieName)
+	if c == nil {
+		return nil
+	}
+	data, err := api.unsign(c.Value)
+	if err != nil {
+		klog.Warningln("invalid SSO state:", err)
+		return nil
+	}
+	var state SSOState
+	if err := json.Unmarshal(data, &state); err != nil {
+		klog.Warningln("invalid SSO state:", err)
+		return nil
+	}
+	if time.Now().Unix() > state.Expires {
+		return nil
+	}
+	return &state
+}
@@ -31,7 +31,7 @@ func Render(configs model.CheckConfigs) *View {
 	cs := model.Checks
 
 	v.addReport(model.AuditReportSLO, cs.SLOAvailability, cs.SLOLatency)
-	v.addReport(model.AuditReportInstances, cs.InstanceAvailability, cs.InstanceRestarts)
+	v.addReport(model.AuditReportInstances, cs.InstanceAvailability, cs.InstanceRestarts, cs.KubernetesEvents, cs.HPAMaxReplicas, cs.PDBRolloutBlocked, cs.ResourceQuotaExhausted)
 	v.addReport(model.AuditReportDeployments, cs.DeploymentStatus)
 	v.addReport(model.AuditReportCPU, cs.CPUNode, cs.CPUContainer)
 	v.addReport(model.AuditReportMemory, cs.MemoryOOM, cs.MemoryLeakPercent)
@@ -104,6 +104,9 @@ func renderNodes(w *model.World) *model.Table {
 
 func getNodeTags(n *model.Node) []string {
 	var tags []string
+	if c := n.Cluster.Value(); c != "" {
+		tags = append(tags, c)
+	}
 	if t := n.InstanceType.Value(); t != "" {
 		tags = append(tags, t)
 	}
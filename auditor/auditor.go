@@ -90,6 +90,7 @@ func (a *appAuditor) delReport(name model.AuditReportName) {
 
 func (a *appAuditor) enrichWidgets(widgets []*model.Widget, events []*model.ApplicationEvent) []*model.Widget {
 	annotations := model.EventsToAnnotations(events, a.w.Ctx)
+	annotations = append(annotations, model.MaintenanceWindowsToAnnotations(a.w.MaintenanceWindows, a.app, a.w.Ctx)...)
 	var res []*model.Widget
 	for _, w := range widgets {
 		if w.Chart != nil {
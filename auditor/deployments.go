@@ -1,6 +1,8 @@
 package auditor
 
 import (
+	"fmt"
+
 	"codexray/model"
 	"codexray/timeseries"
 	"codexray/utils"
@@ -53,6 +55,17 @@ func (a *appAuditor) deployments() {
 				Message: ds.Message,
 				Time:    ds.Deployment.StartedAt,
 			})
+			for _, e := range a.app.KubernetesEvents {
+				if !e.IsWarning() || e.LastSeen.Before(ds.Deployment.StartedAt) {
+					continue
+				}
+				summary.DeploymentSummaries = append(summary.DeploymentSummaries, model.ApplicationDeploymentSummary{
+					Report:  model.AuditReportInstances,
+					Ok:      false,
+					Message: fmt.Sprintf("%s %s: %s", e.Object(), e.Reason, e.Message),
+					Time:    e.LastSeen,
+				})
+			}
 		case model.ApplicationDeploymentStateInProgress, model.ApplicationDeploymentStateCancelled:
 			summary.SetStub(ds.Message)
 		}
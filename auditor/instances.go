@@ -3,6 +3,7 @@ package auditor
 import (
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -16,6 +17,11 @@ func (a *appAuditor) instances() {
 
 	availabilityCheck := report.CreateCheck(model.Checks.InstanceAvailability)
 	restartsCheck := report.CreateCheck(model.Checks.InstanceRestarts)
+	var eventsCheck *model.Check
+	if len(a.app.KubernetesEvents) > 0 {
+		eventsCheck = report.CreateCheck(model.Checks.KubernetesEvents)
+	}
+	podWarnings := lastPodWarnings(a.app.KubernetesEvents)
 
 	instancesChart := report.GetOrCreateChart("Instances", nil).Stacked()
 	restartsChart := report.GetOrCreateChart("Restarts", nil).Column()
@@ -72,6 +78,9 @@ func (a *appAuditor) instances() {
 						reasons.Add(c.Reason)
 					}
 				}
+				if reasons.Len() == 0 && podWarnings[i.Name] != nil {
+					reasons.Add(podWarnings[i.Name].Reason)
+				}
 				if reasons.Len() > 0 {
 					msg += fmt.Sprintf(" (%s)", strings.Join(reasons.Items(), ", "))
 				}
@@ -83,6 +92,8 @@ func (a *appAuditor) instances() {
 				msg := "failed"
 				if details := podContainerIssues(i); details != "" {
 					msg += fmt.Sprintf(" (%s)", details)
+				} else if e := podWarnings[i.Name]; e != nil {
+					msg += fmt.Sprintf(" (%s)", e.Reason)
 				}
 				status.SetStatus(model.WARNING, msg)
 			case "Running":
@@ -166,6 +177,19 @@ func (a *appAuditor) instances() {
 		}
 	}
 
+	if eventsCheck != nil {
+		a.kubernetesEvents(report, eventsCheck)
+	}
+	if a.app.HorizontalPodAutoscaler != nil {
+		a.horizontalPodAutoscaler(report)
+	}
+	if len(a.app.PodDisruptionBudgets) > 0 {
+		a.podDisruptionBudgets(report)
+	}
+	if quotas := a.w.ResourceQuotas[a.app.Id.Namespace]; len(quotas) > 0 {
+		a.resourceQuotas(report, quotas)
+	}
+
 	if a.app.Id.Kind == model.ApplicationKindExternalService {
 		availabilityCheck.SetStatus(model.UNKNOWN, "no data")
 		restartsCheck.SetStatus(model.UNKNOWN, "no data")
@@ -181,6 +205,134 @@ func (a *appAuditor) instances() {
 	}
 }
 
+func (a *appAuditor) kubernetesEvents(report *model.AuditReport, check *model.Check) {
+	var table *model.Table
+	if a.detailed {
+		table = model.NewTable("Object", "Reason", "Message", "Count", "Last seen").SetSorted()
+	}
+	events := make([]*model.KubernetesEvent, 0, len(a.app.KubernetesEvents))
+	for _, e := range a.app.KubernetesEvents {
+		if e.IsWarning() {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].LastSeen > events[j].LastSeen
+	})
+	now := a.w.Ctx.To
+	for _, e := range events {
+		check.Inc(e.Count)
+		table.AddRow(
+			model.NewTableCell(e.Object()),
+			model.NewTableCell().SetStatus(model.WARNING, e.Reason),
+			model.NewTableCell(e.Message),
+			model.NewTableCell(strconv.FormatInt(e.Count, 10)),
+			model.NewTableCell(utils.FormatDuration(now.Sub(e.LastSeen), 1)+" ago"),
+		)
+	}
+	if table != nil && len(table.Rows) > 0 {
+		report.AddWidget(&model.Widget{Table: table, Width: "100%"})
+	}
+}
+
+func (a *appAuditor) horizontalPodAutoscaler(report *model.AuditReport) {
+	check := report.CreateCheck(model.Checks.HPAMaxReplicas)
+	hpa := a.app.HorizontalPodAutoscaler
+
+	if chart := report.GetOrCreateChart("Autoscaling (HPA "+hpa.Name+"), replicas", nil); chart != nil {
+		chart.AddSeries("current", hpa.CurrentReplicas).AddSeries("desired", hpa.DesiredReplicas)
+		chart.SetThreshold("max", hpa.MaxReplicas)
+	}
+
+	if !hpa.AtMaxReplicas() {
+		return
+	}
+	var usage, requests, limits float32
+	for _, i := range a.app.Instances {
+		for _, c := range i.Containers {
+			if u := c.CpuUsage.Last(); !timeseries.IsNaN(u) {
+				usage += u
+			}
+			if r := c.CpuRequest.Last(); !timeseries.IsNaN(r) {
+				requests += r
+			}
+			if l := c.CpuLimit.Last(); !timeseries.IsNaN(l) {
+				limits += l
+			}
+		}
+	}
+	capacity := requests
+	if capacity == 0 {
+		capacity = limits
+	}
+	if capacity == 0 {
+		return
+	}
+	percentage := usage / capacity * 100
+	check.SetValue(percentage)
+	if percentage > check.Threshold {
+		check.Fire()
+	}
+}
+
+func (a *appAuditor) podDisruptionBudgets(report *model.AuditReport) {
+	check := report.CreateCheck(model.Checks.PDBRolloutBlocked)
+	if len(a.app.Deployments) == 0 {
+		return
+	}
+	if last := a.app.Deployments[len(a.app.Deployments)-1]; !last.FinishedAt.IsZero() {
+		return
+	}
+	for _, pdb := range a.app.PodDisruptionBudgets {
+		if pdb.BlocksDisruptions() {
+			check.Fire()
+			return
+		}
+	}
+}
+
+func (a *appAuditor) resourceQuotas(report *model.AuditReport, quotas []*model.ResourceQuota) {
+	check := report.CreateCheck(model.Checks.ResourceQuotaExhausted)
+	var table *model.Table
+	if a.detailed {
+		table = model.NewTable("Resource quota", "Resource", "Used", "Hard", "Usage").SetSorted()
+	}
+	for _, q := range quotas {
+		usage := q.UsagePercent()
+		if timeseries.IsNaN(usage) {
+			continue
+		}
+		status := model.NewTableCell(utils.FormatPercentage(usage))
+		if usage >= check.Threshold {
+			check.AddItem("%s/%s", q.Name, q.Resource)
+			status.SetStatus(model.WARNING, utils.FormatPercentage(usage))
+		}
+		table.AddRow(
+			model.NewTableCell(q.Name),
+			model.NewTableCell(q.Resource),
+			model.NewTableCell(utils.FormatFloat(q.Used.Last())),
+			model.NewTableCell(utils.FormatFloat(q.Hard.Last())),
+			status,
+		)
+	}
+	if table != nil && len(table.Rows) > 0 {
+		report.AddWidget(&model.Widget{Table: table, Width: "100%"})
+	}
+}
+
+func lastPodWarnings(events []*model.KubernetesEvent) map[string]*model.KubernetesEvent {
+	res := map[string]*model.KubernetesEvent{}
+	for _, e := range events {
+		if !e.IsWarning() || model.ApplicationKind(e.ObjectKind) != model.ApplicationKindPod {
+			continue
+		}
+		if last := res[e.ObjectName]; last == nil || last.LastSeen < e.LastSeen {
+			res[e.ObjectName] = e
+		}
+	}
+	return res
+}
+
 func podContainerIssues(i *model.Instance) string {
 	reasons := utils.NewStringSet()
 	containerStatus := ""
@@ -0,0 +1,62 @@
+package auditor
+
+import (
+	"testing"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/timeseries"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKubernetesResourceChecks(t *testing.T) {
+	now := timeseries.Now()
+	w := model.NewWorld(now.Add(-timeseries.Hour), now, timeseries.Minute, timeseries.Minute)
+	value := func(v float32) *timeseries.TimeSeries {
+		return timeseries.NewWithData(w.Ctx.From, w.Ctx.Step, []float32{v})
+	}
+	newApp := func(name string, cpuUsage float32) *model.Application {
+		app := w.GetOrCreateApplication(model.NewApplicationId("default", model.ApplicationKindDeployment, name), false)
+		c := model.NewContainer("/k8s/default/"+name+"-1/app", "app")
+		c.CpuUsage, c.CpuRequest = value(cpuUsage), value(1)
+		app.GetOrCreateInstance(name+"-1", nil).Containers = map[string]*model.Container{"app": c}
+		app.HorizontalPodAutoscaler = &model.HorizontalPodAutoscaler{Name: name, MaxReplicas: value(3), CurrentReplicas: value(3)}
+		app.PodDisruptionBudgets = []*model.PodDisruptionBudget{{Name: name, DisruptionsAllowed: value(0), ExpectedPods: value(3)}}
+		return app
+	}
+	busy := newApp("busy", 0.9)
+	busy.Deployments = []*model.ApplicationDeployment{{ApplicationId: busy.Id, StartedAt: now.Add(-5 * timeseries.Minute)}}
+	idle := newApp("idle", 0.5)
+	idle.Deployments = []*model.ApplicationDeployment{{ApplicationId: idle.Id, StartedAt: now.Add(-timeseries.Hour), FinishedAt: now.Add(-50 * timeseries.Minute)}}
+	w.ResourceQuotas["default"] = []*model.ResourceQuota{
+		{Name: "compute", Resource: "requests.cpu", Hard: value(4), Used: value(4)},
+		{Name: "compute", Resource: "pods", Hard: value(10), Used: value(5)},
+	}
+
+	Audit(w, &db.Project{Id: "p1"}, busy, false)
+
+	checks := func(app *model.Application) map[model.CheckId]*model.Check {
+		res := map[model.CheckId]*model.Check{}
+		for _, r := range app.Reports {
+			if r.Name == model.AuditReportInstances {
+				for _, ch := range r.Checks {
+					res[ch.Id] = ch
+				}
+			}
+		}
+		return res
+	}
+	cs := checks(busy)
+	require.Contains(t, cs, model.Checks.HPAMaxReplicas.Id)
+	assert.Equal(t, model.WARNING, cs[model.Checks.HPAMaxReplicas.Id].Status, "the HPA is at max replicas and CPU usage is 90% of the requested")
+	assert.Equal(t, model.WARNING, cs[model.Checks.PDBRolloutBlocked.Id].Status)
+	assert.Equal(t, model.WARNING, cs[model.Checks.ResourceQuotaExhausted.Id].Status)
+	assert.Equal(t, "1 namespace resource quota is exhausted", cs[model.Checks.ResourceQuotaExhausted.Id].Message, "pods are at 50% of the quota")
+
+	cs = checks(idle)
+	assert.Equal(t, model.OK, cs[model.Checks.HPAMaxReplicas.Id].Status, "CPU usage is below the threshold")
+	assert.Equal(t, model.OK, cs[model.Checks.PDBRolloutBlocked.Id].Status, "no rollout is in progress")
+	assert.Equal(t, model.WARNING, cs[model.Checks.ResourceQuotaExhausted.Id].Status, "the quotas are namespace-wide")
+}
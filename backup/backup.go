@@ -0,0 +1,192 @@
+// Package backup snapshots the metadata database along with the cache into a single archive and restores it.
+// Tables are stored as JSON lines, so a backup taken from SQLite can be restored into Postgres and vice versa.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"codexray/db"
+)
+
+const (
+	formatVersion = 1
+
+	manifestFile = "manifest.json"
+	tablesDir    = "db"
+	cacheDir     = "cache"
+
+	// cacheStateFile is the SQLite database holding the state of the cache, see cache.NewCache.
+	cacheStateFile = "db.sqlite"
+)
+
+type Manifest struct {
+	Format    int            `json:"format"`
+	CreatedAt time.Time      `json:"created_at"`
+	Database  db.Type        `json:"database"`
+	Tables    map[string]int `json:"tables"`
+	Cache     bool           `json:"cache"`
+}
+
+// Create writes a gzipped tar archive with the manifest, a file per table, and the cache if cachePath is set.
+// The tables are read within a single transaction. The cache state is copied before the chunks,
+// and chunks are written atomically, so every chunk the state refers to is in the archive.
+func Create(ctx context.Context, w io.Writer, database *db.DB, cachePath string) (*Manifest, error) {
+	tmp, err := os.MkdirTemp("", "codexray-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	files := map[string]*os.File{}
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	var encoder *json.Encoder
+	var current string
+	counts, err := database.Dump(ctx, func(table string, row db.Row) error {
+		if table != current {
+			f, err := os.Create(filepath.Join(tmp, table+".jsonl"))
+			if err != nil {
+				return err
+			}
+			files[table] = f
+			encoder = json.NewEncoder(f)
+			current = table
+		}
+		return encoder.Encode(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		Format:    formatVersion,
+		CreatedAt: time.Now().UTC(),
+		Database:  database.Type(),
+		Tables:    counts,
+	}
+
+	var stateFile string
+	if cachePath != "" {
+		if _, err = os.Stat(filepath.Join(cachePath, cacheStateFile)); err == nil {
+			stateFile = filepath.Join(tmp, cacheStateFile)
+			if err = snapshotSqlite(cachePath, stateFile); err != nil {
+				return nil, fmt.Errorf("failed to snapshot the cache state: %w", err)
+			}
+			m.Cache = true
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = writeEntry(tw, manifestFile, int64(len(manifest)), strings.NewReader(string(manifest))); err != nil {
+		return nil, err
+	}
+	for _, table := range database.Tables() {
+		if files[table] == nil {
+			continue
+		}
+		if err = writeFile(tw, path.Join(tablesDir, table+".jsonl"), files[table].Name()); err != nil {
+			return nil, err
+		}
+	}
+	if m.Cache {
+		if err = writeFile(tw, path.Join(cacheDir, cacheStateFile), stateFile); err != nil {
+			return nil, err
+		}
+		if err = writeChunks(tw, cachePath); err != nil {
+			return nil, err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	if err = gw.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// snapshotSqlite writes a consistent copy of the SQLite database in the directory to the destination file.
+func snapshotSqlite(dir, dst string) error {
+	state, err := db.Open(dir, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = state.DB().Close()
+	}()
+	_, err = state.DB().Exec("VACUUM INTO $1", dst)
+	return err
+}
+
+// writeChunks adds the chunk files of every project. Temporary files of chunks being written are skipped,
+// as are chunks removed by compaction while the backup is running.
+func writeChunks(tw *tar.Writer, cachePath string) error {
+	projects, err := os.ReadDir(cachePath)
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if !p.IsDir() {
+			continue
+		}
+		chunks, err := os.ReadDir(filepath.Join(cachePath, p.Name()))
+		if err != nil {
+			return err
+		}
+		for _, c := range chunks {
+			if c.IsDir() || !strings.HasSuffix(c.Name(), ".db") {
+				continue
+			}
+			err = writeFile(tw, path.Join(cacheDir, p.Name(), c.Name()), filepath.Join(cachePath, p.Name(), c.Name()))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeFile(tw *tar.Writer, name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return writeEntry(tw, name, info.Size(), f)
+}
+
+func writeEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: time.Now(), Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, r)
+	return err
+}
@@ -0,0 +1,90 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/rbac"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDB(t *testing.T) *db.DB {
+	database, err := db.Open(t.TempDir(), "")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	return database
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	src := testDB(t)
+	projectId, err := src.SaveProject(db.Project{Name: "prod"})
+	require.NoError(t, err)
+	require.NoError(t, src.SaveCheckConfig(projectId, model.ApplicationIdZero, model.Checks.CPUNode.Id, model.CheckConfigSimple{Threshold: 90}))
+	require.NoError(t, src.AddUser("alice@example.com", "secret", "Alice", rbac.RoleEditor))
+	require.NoError(t, src.AddAuditLogEntry(&db.AuditLogEntry{ProjectId: projectId, Actor: "alice@example.com", Operation: db.AuditOperationCreate}))
+
+	cachePath := t.TempDir()
+	state, err := db.Open(cachePath, "")
+	require.NoError(t, err)
+	_, err = state.DB().Exec("CREATE TABLE prometheus_query_state (project_id TEXT, query TEXT, last_ts INT, last_error TEXT)")
+	require.NoError(t, err)
+	_, err = state.DB().Exec("INSERT INTO prometheus_query_state VALUES ($1, 'up', 100, '')", projectId)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(cachePath, string(projectId)), 0755))
+	chunk := filepath.Join(string(projectId), string(projectId)+"-hash-0-10-15.db")
+	require.NoError(t, os.WriteFile(filepath.Join(cachePath, chunk), []byte("chunk"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(cachePath, chunk+"12345"), []byte("partial"), 0644))
+
+	var archive bytes.Buffer
+	m, err := Create(ctx, &archive, src, cachePath)
+	require.NoError(t, err)
+	assert.True(t, m.Cache)
+	assert.Equal(t, 1, m.Tables["project"])
+	assert.Equal(t, 1, m.Tables["users"])
+	assert.Equal(t, 1, m.Tables["audit_log"])
+	assert.Equal(t, 1, m.Tables["check_configs"])
+
+	dst := testDB(t)
+	dstCache := t.TempDir()
+	_, err = Restore(ctx, bytes.NewReader(archive.Bytes()), dst, dstCache, false)
+	require.NoError(t, err)
+	p, err := dst.GetProject(projectId)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", p.Name)
+	userId, err := dst.AuthUser("alice@example.com", "secret")
+	require.NoError(t, err)
+	user, err := dst.GetUser(userId)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", user.Name)
+	checkConfigs, err := dst.GetCheckConfigs(projectId)
+	require.NoError(t, err)
+	assert.Equal(t, float32(90), checkConfigs.GetSimple(model.Checks.CPUNode.Id, model.ApplicationIdZero).Threshold)
+	data, err := os.ReadFile(filepath.Join(dstCache, chunk))
+	require.NoError(t, err)
+	assert.Equal(t, "chunk", string(data))
+	assert.NoFileExists(t, filepath.Join(dstCache, chunk+"12345"))
+	restoredState, err := db.Open(dstCache, "")
+	require.NoError(t, err)
+	var lastTs int
+	require.NoError(t, restoredState.DB().QueryRow("SELECT last_ts FROM prometheus_query_state WHERE query = 'up'").Scan(&lastTs))
+	assert.Equal(t, 100, lastTs)
+
+	_, err = Restore(ctx, bytes.NewReader(archive.Bytes()), dst, "", false)
+	assert.ErrorContains(t, err, "the table is not empty")
+	_, err = Restore(ctx, bytes.NewReader(archive.Bytes()), dst, "", true)
+	require.NoError(t, err)
+
+	copied := testDB(t)
+	counts, err := src.CopyTo(ctx, copied)
+	require.NoError(t, err)
+	assert.Equal(t, m.Tables, counts)
+	require.NoError(t, copied.AddUser("bob@example.com", "secret", "Bob", rbac.RoleViewer))
+}
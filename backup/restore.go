@@ -0,0 +1,158 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"codexray/db"
+)
+
+// Restore loads the archive created by Create into the migrated database and replaces the cache at cachePath.
+// The tables must be empty unless replace is set. The database is loaded within a single transaction,
+// which is rolled back unless the number of rows matches the manifest. It must not be used while codexray is running.
+func Restore(ctx context.Context, r io.Reader, database *db.DB, cachePath string, replace bool) (*Manifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+	tr := tar.NewReader(gr)
+
+	h, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+	if h.Name != manifestFile {
+		return nil, fmt.Errorf("invalid archive: %s is expected first, got %s", manifestFile, h.Name)
+	}
+	var m Manifest
+	if err = json.NewDecoder(tr).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.Format > formatVersion {
+		return nil, fmt.Errorf("unsupported backup format %d, upgrade codexray to restore it", m.Format)
+	}
+
+	loader, err := database.NewLoader(ctx, replace)
+	if err != nil {
+		return nil, err
+	}
+	loaded := false
+	finishLoad := func() error {
+		if loaded {
+			return nil
+		}
+		loaded = true
+		return loader.Commit(ctx, m.Tables)
+	}
+	cacheCleared := false
+	for {
+		h, err = tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if !loaded {
+				loader.Rollback()
+			}
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		if !filepath.IsLocal(h.Name) || h.Typeflag != tar.TypeReg {
+			err = fmt.Errorf("invalid archive entry: %s", h.Name)
+		} else {
+			dir, file := path.Split(h.Name)
+			switch {
+			case dir == tablesDir+"/" && strings.HasSuffix(file, ".jsonl"):
+				if loaded {
+					return nil, fmt.Errorf("invalid archive: %s follows the cache", h.Name)
+				}
+				err = loadTable(ctx, loader, strings.TrimSuffix(file, ".jsonl"), tr)
+			case strings.HasPrefix(h.Name, cacheDir+"/"):
+				if err = finishLoad(); err != nil {
+					return nil, err
+				}
+				if cachePath == "" {
+					continue
+				}
+				if !cacheCleared {
+					if err = clearDir(cachePath); err != nil {
+						return nil, err
+					}
+					cacheCleared = true
+				}
+				err = extractFile(tr, filepath.Join(cachePath, filepath.FromSlash(strings.TrimPrefix(h.Name, cacheDir+"/"))))
+			default:
+				err = fmt.Errorf("unexpected archive entry: %s", h.Name)
+			}
+		}
+		if err != nil {
+			if !loaded {
+				loader.Rollback()
+			}
+			return nil, err
+		}
+	}
+	if err = finishLoad(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func loadTable(ctx context.Context, loader *db.Loader, table string, r io.Reader) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	decoder.UseNumber()
+	for {
+		var row db.Row
+		err := decoder.Decode(&row)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		for c, v := range row {
+			if n, ok := v.(json.Number); ok {
+				if i, err := n.Int64(); err == nil {
+					row[c] = i
+				} else if row[c], err = n.Float64(); err != nil {
+					return fmt.Errorf("%s: %w", table, err)
+				}
+			}
+		}
+		if err = loader.Insert(ctx, table, row); err != nil {
+			return err
+		}
+	}
+}
+
+// clearDir removes the content of the directory, creating it if needed.
+func clearDir(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+func extractFile(r io.Reader, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
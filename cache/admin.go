@@ -0,0 +1,313 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"codexray/cache/chunk"
+	"codexray/constructor"
+	"codexray/db"
+	"codexray/prom"
+	"codexray/timeseries"
+
+	promModel "github.com/prometheus/common/model"
+	"k8s.io/klog"
+)
+
+var (
+	ErrRecordingRule      = errors.New("recording rules are calculated by the updater and can't be re-fetched")
+	ErrBackfillInProgress = errors.New("the query is already being backfilled")
+)
+
+// QueryInfo describes what the cache holds for a query.
+type QueryInfo struct {
+	// Name is the name of the query in constructor.QUERIES or of the recording rule. It's empty for custom SLI queries.
+	Name       string              `json:"name,omitempty"`
+	Query      string              `json:"query"`
+	Hash       string              `json:"hash"`
+	Ranges     []CachedRange       `json:"ranges"`
+	Chunks     int                 `json:"chunks"`
+	Size       int64               `json:"size"`
+	LastUpdate timeseries.Time     `json:"last_update"`
+	LastError  string              `json:"last_error,omitempty"`
+	Lag        timeseries.Duration `json:"lag"`
+	Backfill   *BackfillStatus     `json:"backfill,omitempty"`
+}
+
+// CachedRange is a continuous range of the cached data having the same step.
+type CachedRange struct {
+	From timeseries.Time     `json:"from"`
+	To   timeseries.Time     `json:"to"`
+	Step timeseries.Duration `json:"step"`
+}
+
+type BackfillStatus struct {
+	From          timeseries.Time `json:"from"`
+	To            timeseries.Time `json:"to"`
+	ChunksTotal   int             `json:"chunks_total"`
+	ChunksFetched int             `json:"chunks_fetched"`
+	Error         string          `json:"error,omitempty"`
+	InProgress    bool            `json:"in_progress"`
+}
+
+type backfills struct {
+	lock    sync.Mutex
+	byQuery map[string]*BackfillStatus
+}
+
+// Queries lists the queries being updated along with the ones having chunks in the cache.
+func (c *Client) Queries() ([]QueryInfo, error) {
+	states, err := c.cache.loadStates(c.projectId)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	for name, q := range constructor.QUERIES {
+		names[q] = name
+	}
+	for q := range constructor.RecordingRules {
+		names[q] = q
+	}
+
+	byHash := map[string]*QueryInfo{}
+	now := timeseries.Now()
+	for q, s := range states {
+		hash := queryHash(q)
+		byHash[hash] = &QueryInfo{
+			Name:       names[q],
+			Query:      q,
+			Hash:       hash,
+			LastUpdate: s.LastTs,
+			LastError:  s.LastError,
+			Lag:        now.Sub(s.LastTs),
+		}
+	}
+
+	c.cache.lock.RLock()
+	if projData := c.cache.byProject[c.projectId]; projData != nil {
+		for hash, qData := range projData.queries {
+			qi := byHash[hash]
+			if qi == nil {
+				// an obsolete query, its chunks are kept until they expire
+				qi = &QueryInfo{Hash: hash}
+				byHash[hash] = qi
+			}
+			chunks := make([]*chunk.Meta, 0, len(qData.chunks))
+			for _, ch := range qData.chunks {
+				chunks = append(chunks, ch)
+				qi.Size += ch.Size
+			}
+			qi.Chunks = len(chunks)
+			qi.Ranges = cachedRanges(chunks)
+		}
+	}
+	c.cache.lock.RUnlock()
+
+	c.cache.backfills.lock.Lock()
+	for hash, qi := range byHash {
+		if s := c.cache.backfills.byQuery[backfillKey(c.projectId, hash)]; s != nil {
+			status := *s
+			qi.Backfill = &status
+		}
+	}
+	c.cache.backfills.lock.Unlock()
+
+	res := make([]QueryInfo, 0, len(byHash))
+	for _, qi := range byHash {
+		res = append(res, *qi)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].Query < res[j].Query
+	})
+	return res, nil
+}
+
+func cachedRanges(chunks []*chunk.Meta) []CachedRange {
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].From < chunks[j].From
+	})
+	var res []CachedRange
+	for _, ch := range chunks {
+		if l := len(res) - 1; l >= 0 && res[l].Step == ch.Step && ch.From <= res[l].To.Add(ch.Step) {
+			if to := ch.To(); to > res[l].To {
+				res[l].To = to
+			}
+			continue
+		}
+		res = append(res, CachedRange{From: ch.From, To: ch.To(), Step: ch.Step})
+	}
+	return res
+}
+
+// Invalidate deletes the chunks of the query overlapping the range, or all of them if the range is zero,
+// and rewinds the query state so that the updater re-fetches the part of the range within BackFillInterval.
+// It returns the range covered by the deleted chunks.
+func (c *Client) Invalidate(hash string, from, to timeseries.Time) (timeseries.Time, timeseries.Time, error) {
+	c.cache.lock.Lock()
+	var deletedFrom, deletedTo timeseries.Time
+	var qData *queryData
+	if projData := c.cache.byProject[c.projectId]; projData != nil {
+		qData = projData.queries[hash]
+	}
+	if qData != nil {
+		for name, ch := range qData.chunks {
+			if !from.IsZero() && !to.IsZero() && (ch.From > to || ch.To() < from) {
+				continue
+			}
+			if err := c.cache.store(ch).Delete(ch.Path); err != nil {
+				c.cache.lock.Unlock()
+				return 0, 0, err
+			}
+			delete(qData.chunks, name)
+			if deletedFrom.IsZero() || ch.From < deletedFrom {
+				deletedFrom = ch.From
+			}
+			if ch.To() > deletedTo {
+				deletedTo = ch.To()
+			}
+		}
+	}
+	c.cache.lock.Unlock()
+
+	if qData == nil {
+		if query, err := c.queryByHash(hash); err != nil {
+			return 0, 0, err
+		} else if query == "" {
+			return 0, 0, fmt.Errorf("%w: %s", constructor.ErrUnknownQuery, hash)
+		}
+	}
+	if deletedFrom.IsZero() {
+		return 0, 0, nil
+	}
+	// the updater doesn't look back further than BackFillInterval anyway
+	rewindTo := max(deletedFrom, timeseries.Now().Add(-BackFillInterval))
+	states, err := c.cache.loadStates(c.projectId)
+	if err != nil {
+		return 0, 0, err
+	}
+	for q, state := range states {
+		if queryHash(q) != hash || deletedTo < rewindTo || state.LastTs <= rewindTo {
+			continue
+		}
+		state.LastTs = rewindTo
+		state.LastError = ""
+		if err = c.cache.saveState(state); err != nil {
+			return 0, 0, err
+		}
+	}
+	klog.Infof("%s: invalidated query %s from %s to %s", c.projectId, hash, deletedFrom, deletedTo)
+	return deletedFrom, deletedTo, nil
+}
+
+// Backfill invalidates the range of the query and re-fetches it from Prometheus in the background.
+// The last BackFillInterval is left to the updater, which re-fetches it anyway.
+func (c *Client) Backfill(promClient *prom.Client, hash string, from, to timeseries.Time) error {
+	if rrHashes[hash] {
+		return ErrRecordingRule
+	}
+	query, err := c.queryByHash(hash)
+	if err != nil {
+		return err
+	}
+	if query == "" {
+		return fmt.Errorf("%w: %s", constructor.ErrUnknownQuery, hash)
+	}
+	c.cache.lock.RLock()
+	var step timeseries.Duration
+	if projData := c.cache.byProject[c.projectId]; projData != nil {
+		step = projData.step
+	}
+	c.cache.lock.RUnlock()
+	if step == 0 {
+		return fmt.Errorf("unknown project: %s", c.projectId)
+	}
+
+	key := backfillKey(c.projectId, hash)
+	c.cache.backfills.lock.Lock()
+	if s := c.cache.backfills.byQuery[key]; s != nil && s.InProgress {
+		c.cache.backfills.lock.Unlock()
+		return ErrBackfillInProgress
+	}
+	status := &BackfillStatus{From: from, To: to, InProgress: true}
+	c.cache.backfills.byQuery[key] = status
+	c.cache.backfills.lock.Unlock()
+
+	deletedFrom, deletedTo, err := c.Invalidate(hash, from, to)
+	if err != nil {
+		c.cache.backfills.lock.Lock()
+		delete(c.cache.backfills.byQuery, key)
+		c.cache.backfills.lock.Unlock()
+		return err
+	}
+	if !deletedFrom.IsZero() {
+		from, to = min(from, deletedFrom), max(to, deletedTo)
+	}
+	_, jitter := QueryId(c.projectId, query)
+	if limit := timeseries.Now().Add(-BackFillInterval - chunk.Size); to > limit {
+		to = limit
+	}
+	chunkEnd := to.Add(-jitter).Truncate(chunk.Size).Add(jitter).Add(chunk.Size)
+	intervals := calcIntervals(from.Add(-step), step, chunkEnd, jitter)
+
+	c.cache.backfills.lock.Lock()
+	status.ChunksTotal = len(intervals)
+	c.cache.backfills.lock.Unlock()
+
+	go func() {
+		err := c.backfill(promClient, query, hash, step, intervals, status)
+		c.cache.backfills.lock.Lock()
+		defer c.cache.backfills.lock.Unlock()
+		status.InProgress = false
+		if err != nil {
+			status.Error = err.Error()
+			klog.Errorf("%s: failed to backfill query %s: %s", c.projectId, hash, err)
+		}
+	}()
+	return nil
+}
+
+func (c *Client) backfill(promClient *prom.Client, query, hash string, step timeseries.Duration, intervals []interval, status *BackfillStatus) error {
+	pointsCount := int(chunk.Size / step)
+	for _, i := range intervals {
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+		vs, err := promClient.QueryRange(ctx, query, i.chunkTs, i.toTs, step)
+		cancel()
+		if err != nil {
+			return err
+		}
+		for _, v := range vs {
+			delete(v.Labels, promModel.MetricNameLabel)
+		}
+		if err = c.cache.writeChunk(c.projectId, hash, i.chunkTs, pointsCount, step, true, vs, false); err != nil {
+			return err
+		}
+		c.cache.backfills.lock.Lock()
+		status.ChunksFetched++
+		c.cache.backfills.lock.Unlock()
+	}
+	return nil
+}
+
+// queryByHash returns the query being updated having the hash, or an empty string.
+func (c *Client) queryByHash(hash string) (string, error) {
+	states, err := c.cache.loadStates(c.projectId)
+	if err != nil {
+		return "", err
+	}
+	for q := range states {
+		if queryHash(q) == hash {
+			return q, nil
+		}
+	}
+	return "", nil
+}
+
+func backfillKey(projectId db.ProjectId, hash string) string {
+	return string(projectId) + "/" + hash
+}
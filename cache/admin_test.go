@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"testing"
+
+	"codexray/constructor"
+	"codexray/db"
+	"codexray/model"
+	"codexray/timeseries"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueriesAndInvalidate(t *testing.T) {
+	projectId := db.ProjectId("p1")
+	step := 60 * timeseries.Second
+	points := int(timeseries.Hour / step)
+	query := constructor.QUERIES["node_info"]
+	hash, jitter := QueryId(projectId, query)
+	now := timeseries.Now()
+	from := now.Add(-9 * timeseries.Hour).Truncate(timeseries.Hour).Add(jitter)
+
+	c := testCache(t, projectId)
+	c.byProject[projectId].step = step
+	metrics := []model.MetricValues{{Labels: model.Labels{"job": "node"}, LabelsHash: 1, Values: timeseries.New(from, points, step)}}
+	for _, i := range []int{0, 1, 6, 7} {
+		chunkFrom := from.Add(timeseries.Duration(i) * timeseries.Hour)
+		require.NoError(t, c.writeChunk(projectId, hash, chunkFrom, points, step, true, metrics, false))
+	}
+	lastTs := from.Add(8*timeseries.Hour - step)
+	require.NoError(t, c.saveState(&PrometheusQueryState{ProjectId: projectId, Query: query, LastTs: lastTs, LastError: "timeout"}))
+
+	client := c.GetCacheClient(projectId)
+	queries, err := client.Queries()
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	q := queries[0]
+	assert.Equal(t, "node_info", q.Name)
+	assert.Equal(t, hash, q.Hash)
+	assert.Equal(t, 4, q.Chunks)
+	assert.Greater(t, q.Size, int64(0))
+	assert.Equal(t, lastTs, q.LastUpdate)
+	assert.Equal(t, "timeout", q.LastError)
+	assert.Equal(t, []CachedRange{
+		{From: from, To: from.Add(2*timeseries.Hour - step), Step: step},
+		{From: from.Add(6 * timeseries.Hour), To: lastTs, Step: step},
+	}, q.Ranges, "the gap is reported as two ranges")
+
+	deletedFrom, deletedTo, err := client.Invalidate(hash, from.Add(30*timeseries.Minute), from.Add(90*timeseries.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, from, deletedFrom)
+	assert.Equal(t, from.Add(2*timeseries.Hour-step), deletedTo)
+	queries, err = client.Queries()
+	require.NoError(t, err)
+	assert.Equal(t, 2, queries[0].Chunks)
+	assert.Equal(t, lastTs, queries[0].LastUpdate, "the state isn't rewound beyond BackFillInterval")
+
+	_, _, err = client.Invalidate(hash, 0, 0)
+	require.NoError(t, err)
+	queries, err = client.Queries()
+	require.NoError(t, err)
+	assert.Equal(t, 0, queries[0].Chunks)
+	assert.Equal(t, from.Add(6*timeseries.Hour), queries[0].LastUpdate, "the updater re-fetches the recent chunks")
+	assert.Empty(t, queries[0].LastError)
+
+	_, _, err = client.Invalidate("unknown", 0, 0)
+	assert.ErrorIs(t, err, constructor.ErrUnknownQuery)
+}
@@ -3,14 +3,12 @@ package cache
 import (
 	"database/sql"
 	"fmt"
-	"os"
-	"path"
-	"strings"
 	"sync"
 	"time"
 
 	"codexray/cache/chunk"
 	"codexray/db"
+	"codexray/model"
 	"codexray/prom"
 	"codexray/timeseries"
 	"codexray/utils"
@@ -27,12 +25,8 @@ func DefaultPrometheusClientFactory(p *db.Project, globalPrometheus *db.Integrat
 		return nil, fmt.Errorf("prometheus is not configured")
 	}
 
-	c := prom.NewClientConfig(cfg.Url, cfg.RefreshInterval)
-	c.BasicAuth = cfg.BasicAuth
-	c.TlsSkipVerify = cfg.TlsSkipVerify
-	c.ExtraSelector = cfg.ExtraSelector
-	c.CustomHeaders = cfg.CustomHeaders
-	client, err := prom.NewClient(c)
+	configs := cfg.ClientConfigs()
+	client, err := prom.NewClient(configs[0], configs[1:]...)
 	if err != nil {
 		return nil, err
 	}
@@ -47,11 +41,16 @@ type Cache struct {
 	state     *sql.DB
 	stateLock sync.Mutex
 
+	local  ChunkStore
+	remote ChunkStore
+
 	promClientFactory PrometheusClientFactory
 	globalPrometheus  *db.IntegrationsPrometheus
 
 	updates chan db.ProjectId
 
+	backfills backfills
+
 	pendingCompactions prometheus.Gauge
 	compactedChunks    *prometheus.CounterVec
 }
@@ -68,12 +67,12 @@ func newProjectData() *projectData {
 }
 
 type queryData struct {
-	chunksOnDisk map[string]*chunk.Meta
+	chunks map[string]*chunk.Meta
 }
 
 func newQueryData() *queryData {
 	return &queryData{
-		chunksOnDisk: map[string]*chunk.Meta{},
+		chunks: map[string]*chunk.Meta{},
 	}
 }
 
@@ -97,11 +96,16 @@ func NewCache(cfg Config, database *db.DB, promClientFactory PrometheusClientFac
 		db:        database,
 		state:     state.DB(),
 
+		local:  NewDiskStore(cfg.Path),
+		remote: cfg.RemoteStore,
+
 		promClientFactory: promClientFactory,
 		globalPrometheus:  globalPrometheus,
 
 		updates: make(chan db.ProjectId),
 
+		backfills: backfills{byQuery: map[string]*BackfillStatus{}},
+
 		pendingCompactions: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "codexray_pending_compactions",
@@ -114,7 +118,7 @@ func NewCache(cfg Config, database *db.DB, promClientFactory PrometheusClientFac
 			[]string{"src", "dst"},
 		),
 	}
-	if err := cache.initCacheIndexFromDir(); err != nil {
+	if err := cache.initCacheIndex(); err != nil {
 		return nil, err
 	}
 
@@ -131,52 +135,101 @@ func (c *Cache) Updates() <-chan db.ProjectId {
 	return c.updates
 }
 
-func (c *Cache) initCacheIndexFromDir() error {
+func (c *Cache) GetPrometheusClient(project *db.Project) (*prom.Client, error) {
+	return c.promClientFactory(project, c.globalPrometheus)
+}
+
+func (c *Cache) initCacheIndex() error {
 	t := time.Now()
-	files, err := os.ReadDir(c.cfg.Path)
+	sizes, err := c.local.List()
 	if err != nil {
 		return err
 	}
-	for _, f := range files {
-		if !f.IsDir() {
+	var metas []*chunk.Meta
+	for name, size := range sizes {
+		meta, err := c.readChunkMeta(name)
+		if err != nil {
+			klog.Errorln(err)
 			continue
 		}
-		projectId := f.Name()
-		projectDir := path.Join(c.cfg.Path, projectId)
-		projFiles, err := os.ReadDir(projectDir)
-		if err != nil {
+		meta.Size = size
+		metas = append(metas, meta)
+	}
+	if c.remote != nil {
+		// reading every object would take too long, so the meta of the remote chunks is taken from their names
+		if sizes, err = c.remote.List(); err != nil {
 			return err
 		}
-		projData := newProjectData()
-		c.byProject[db.ProjectId(projectId)] = projData
-
-		var metaFrom timeseries.Time
-		for _, chunkFile := range projFiles {
-			if !strings.HasSuffix(chunkFile.Name(), ".db") {
-				continue
-			}
-			parts := strings.Split(chunkFile.Name(), "-")
-			if len(parts) != 5 {
-				continue
+		for name, size := range sizes {
+			if _, _, meta, ok := parseChunkName(name); ok {
+				meta.Remote = true
+				meta.Size = size
+				metas = append(metas, meta)
 			}
-			queryId := parts[1]
-			meta, err := chunk.ReadMeta(path.Join(projectDir, chunkFile.Name()))
-			if err != nil {
-				klog.Errorln(err)
-				continue
-			}
-			if meta.From > metaFrom {
-				projData.step = meta.Step
-				metaFrom = meta.From
-			}
-			qData, ok := projData.queries[queryId]
-			if !ok {
-				qData = newQueryData()
-				projData.queries[queryId] = qData
-			}
-			qData.chunksOnDisk[meta.Path] = meta
 		}
 	}
-	klog.Infof("loaded from disk in %s", time.Since(t).Truncate(time.Millisecond))
+
+	metaFrom := map[db.ProjectId]timeseries.Time{}
+	for _, meta := range metas {
+		projectId, queryId, _, ok := parseChunkName(meta.Path)
+		if !ok {
+			continue
+		}
+		projData := c.byProject[projectId]
+		if projData == nil {
+			projData = newProjectData()
+			c.byProject[projectId] = projData
+		}
+		if meta.From > metaFrom[projectId] {
+			projData.step = meta.Step
+			metaFrom[projectId] = meta.From
+		}
+		qData, ok := projData.queries[queryId]
+		if !ok {
+			qData = newQueryData()
+			projData.queries[queryId] = qData
+		}
+		qData.chunks[meta.Path] = meta
+	}
+	klog.Infof("loaded %d chunks in %s", len(metas), time.Since(t).Truncate(time.Millisecond))
 	return nil
 }
+
+func (c *Cache) store(meta *chunk.Meta) ChunkStore {
+	if meta.Remote {
+		return c.remote
+	}
+	return c.local
+}
+
+func (c *Cache) readChunkMeta(name string) (*chunk.Meta, error) {
+	f, err := c.local.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	meta, err := chunk.ReadMetaFrom(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	meta.Path = name
+	return meta, nil
+}
+
+func (c *Cache) readChunk(meta *chunk.Meta, from timeseries.Time, pointsCount int, step timeseries.Duration, dest map[uint64]model.MetricValues, fillFunc timeseries.FillFunc) error {
+	f, err := c.store(meta).Open(meta.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return chunk.ReadFrom(f, from, pointsCount, step, dest, fillFunc)
+}
+
+func (c *Cache) readRollups(meta *chunk.Meta, from timeseries.Time, pointsCount int, step timeseries.Duration, sum bool, dest map[uint64]*chunk.Rollup) error {
+	f, err := c.store(meta).Open(meta.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return chunk.ReadRollupsFrom(f, from, pointsCount, step, sum, dest)
+}
@@ -19,6 +19,8 @@ const (
 	V1 uint8 = 1
 	V2 uint8 = 2
 	V3 uint8 = 3
+	// V4 chunks are downsampled: along with the value, each point keeps the min, max, and count of the raw points.
+	V4 uint8 = 4
 
 	Size = timeseries.Hour
 )
@@ -29,6 +31,11 @@ type Meta struct {
 	PointsCount uint32
 	Step        timeseries.Duration
 	Finalized   bool
+
+	// Remote is set if the chunk is kept in the object storage rather than on local disk.
+	Remote bool
+	// Size is the size of the chunk file in bytes.
+	Size int64
 }
 
 func (m *Meta) To() timeseries.Time {
@@ -129,12 +136,20 @@ func ReadMeta(path string) (*Meta, error) {
 		return nil, err
 	}
 	defer f.Close()
+	meta, err := ReadMetaFrom(f)
+	if err != nil {
+		return nil, err
+	}
+	meta.Path = path
+	return meta, nil
+}
 
+func ReadMetaFrom(r io.Reader) (*Meta, error) {
 	h := header{}
-	if err = binary.Read(f, binary.LittleEndian, &h); err != nil {
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
 		return nil, err
 	}
-	return &Meta{Path: path, From: h.From, PointsCount: h.PointsCount, Step: h.Step, Finalized: h.Finalized}, err
+	return &Meta{From: h.From, PointsCount: h.PointsCount, Step: h.Step, Finalized: h.Finalized}, nil
 }
 
 func Read(path string, from timeseries.Time, pointsCount int, step timeseries.Duration, dest map[uint64]model.MetricValues, fillFunc timeseries.FillFunc) error {
@@ -143,24 +158,61 @@ func Read(path string, from timeseries.Time, pointsCount int, step timeseries.Du
 		return err
 	}
 	defer f.Close()
+	return ReadFrom(f, from, pointsCount, step, dest, fillFunc)
+}
 
-	reader := bufio.NewReader(f)
+func ReadFrom(r io.Reader, from timeseries.Time, pointsCount int, step timeseries.Duration, dest map[uint64]model.MetricValues, fillFunc timeseries.FillFunc) error {
+	reader := bufio.NewReader(r)
 	h := header{}
-	if err = binary.Read(reader, binary.LittleEndian, &h); err != nil {
+	if err := binary.Read(reader, binary.LittleEndian, &h); err != nil {
 		return err
 	}
 	switch h.Version {
-	case V3:
-		return readV3(reader, &h, from, pointsCount, step, dest, fillFunc)
+	case V3, V4:
+		return readValues(reader, &h, from, pointsCount, step, dest, fillFunc)
 	default:
 		return fmt.Errorf("unknown version: %d", h.Version)
 	}
 }
 
-func readV3(reader io.Reader, header *header, from timeseries.Time, pointsCount int, step timeseries.Duration, dest map[uint64]model.MetricValues, fillFunc timeseries.FillFunc) error {
+func readValues(reader io.Reader, header *header, from timeseries.Time, pointsCount int, step timeseries.Duration, dest map[uint64]model.MetricValues, fillFunc timeseries.FillFunc) error {
+	return readMetrics(reader, header,
+		func(hash uint64, columns [][]float32) bool {
+			mv, ok := dest[hash]
+			if !ok {
+				mv.Values = timeseries.New(from, pointsCount, step)
+			}
+			if !fillFunc(mv.Values, header.From, header.Step, columns[0]) && !ok {
+				return false
+			}
+			dest[hash] = mv
+			return !ok
+		},
+		func(hash uint64, labels []byte) {
+			mv := dest[hash]
+			mv.LabelsHash = hash
+			mv.Labels = make(model.Labels)
+			readLabels(labels, &mv)
+			dest[hash] = mv
+		},
+	)
+}
+
+func columnsCount(version uint8) int {
+	if version == V4 {
+		return rollupColumnsCount
+	}
+	return 1
+}
+
+// readMetrics passes the values of each metric of the chunk to data, which reports whether the labels
+// of the metric are needed. The labels are passed to labels then.
+func readMetrics(reader io.Reader, header *header, data func(hash uint64, columns [][]float32) bool, labels func(hash uint64, labels []byte)) error {
 	r := lz4.NewDecompressReader(reader)
 	defer r.Close()
-	buf := make([]byte, metricMetaSize+4*header.PointsCount)
+	columnsCount := columnsCount(header.Version)
+	buf := make([]byte, metricMetaSize+4*int(header.PointsCount)*columnsCount)
+	columns := make([][]float32, columnsCount)
 	var labelsToRead []*metricMeta
 	var maxLabelSize uint32
 	var err error
@@ -173,27 +225,22 @@ func readV3(reader io.Reader, header *header, from timeseries.Time, pointsCount
 			MetaOffset: binary.LittleEndian.Uint32(buf[8:]),
 			MetaSize:   binary.LittleEndian.Uint32(buf[12:]),
 		}
-		mv, ok := dest[m.Hash]
-		if !ok {
-			mv.Values = timeseries.New(from, pointsCount, step)
-			labelsToRead = append(labelsToRead, &m)
-			if m.MetaSize > maxLabelSize {
-				maxLabelSize = m.MetaSize
-			}
+		values := asFloats32(buf[metricMetaSize:])
+		for c := range columns {
+			columns[c] = values[c*int(header.PointsCount) : (c+1)*int(header.PointsCount)]
 		}
-		if !fillFunc(mv.Values, header.From, header.Step, asFloats32(buf[metricMetaSize:])) && !ok {
+		if !data(m.Hash, columns) {
 			continue
 		}
-		dest[m.Hash] = mv
+		labelsToRead = append(labelsToRead, &m)
+		if m.MetaSize > maxLabelSize {
+			maxLabelSize = m.MetaSize
+		}
 	}
 	if len(labelsToRead) > 0 {
 		buf = make([]byte, maxLabelSize)
 		offset := uint32(0)
 		for _, m := range labelsToRead {
-			mv, ok := dest[m.Hash]
-			if !ok {
-				continue
-			}
 			toSkip := m.MetaOffset - offset
 			if toSkip > 0 {
 				if _, err := io.CopyN(io.Discard, r, int64(toSkip)); err != nil {
@@ -204,10 +251,7 @@ func readV3(reader io.Reader, header *header, from timeseries.Time, pointsCount
 				return err
 			}
 			offset = m.MetaOffset + m.MetaSize
-			mv.LabelsHash = m.Hash
-			mv.Labels = make(model.Labels)
-			readLabels(buf[:m.MetaSize], &mv)
-			dest[m.Hash] = mv
+			labels(m.Hash, buf[:m.MetaSize])
 		}
 	}
 	return nil
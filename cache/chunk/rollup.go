@@ -0,0 +1,156 @@
+package chunk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"codexray/model"
+	"codexray/timeseries"
+
+	lz4 "github.com/DataDog/golz4"
+)
+
+// value, min, max, count
+const rollupColumnsCount = 4
+
+// Rollup is a downsampled metric. Each point aggregates the raw points falling into its step:
+// Value is their average, or their sum if the metric is summed up, e.g., the number of log messages.
+type Rollup struct {
+	Metric model.MetricValues
+	Value  []float32
+	Min    []float32
+	Max    []float32
+	Count  []float32
+}
+
+func NewRollup(pointsCount int) *Rollup {
+	r := &Rollup{
+		Value: make([]float32, pointsCount),
+		Min:   make([]float32, pointsCount),
+		Max:   make([]float32, pointsCount),
+		Count: make([]float32, pointsCount),
+	}
+	for i := range r.Value {
+		r.Value[i] = timeseries.NaN
+		r.Min[i] = timeseries.NaN
+		r.Max[i] = timeseries.NaN
+	}
+	return r
+}
+
+func (r *Rollup) add(i int, value, lo, hi, count float32, sum bool) {
+	if r.Count[i] == 0 {
+		r.Value[i], r.Min[i], r.Max[i], r.Count[i] = value, lo, hi, count
+		return
+	}
+	if sum {
+		r.Value[i] += value
+	} else {
+		r.Value[i] = (r.Value[i]*r.Count[i] + value*count) / (r.Count[i] + count)
+	}
+	r.Min[i] = timeseries.Min(0, r.Min[i], lo)
+	r.Max[i] = timeseries.Max(0, r.Max[i], hi)
+	r.Count[i] += count
+}
+
+func WriteRollups(f io.Writer, from timeseries.Time, pointsCount int, step timeseries.Duration, finalized bool, rollups []*Rollup) error {
+	var err error
+	h := header{
+		Version:                V4,
+		From:                   from,
+		PointsCount:            uint32(pointsCount),
+		Step:                   step,
+		Finalized:              finalized,
+		DataSizeOrMetricsCount: uint32(len(rollups)),
+	}
+	if err = binary.Write(f, binary.LittleEndian, h); err != nil {
+		return err
+	}
+
+	zw := lz4.NewWriter(f)
+	w := bufio.NewWriter(zw)
+
+	var metaOffset, metaSize int
+	for _, r := range rollups {
+		metaSize = metadataSize(r.Metric)
+		m := metricMeta{
+			Hash:       r.Metric.LabelsHash,
+			MetaOffset: uint32(metaOffset),
+			MetaSize:   uint32(metaSize),
+		}
+		metaOffset += metaSize
+		if err = binary.Write(w, binary.LittleEndian, m); err != nil {
+			return err
+		}
+		for _, column := range [][]float32{r.Value, r.Min, r.Max, r.Count} {
+			if _, err = w.Write(asBytes32(column)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range rollups {
+		if err = writeLabels(w, r.Metric); err != nil {
+			return err
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadRollupsFrom downsamples the chunk into dest. The points of raw chunks are treated as rollups of a single point.
+func ReadRollupsFrom(r io.Reader, from timeseries.Time, pointsCount int, step timeseries.Duration, sum bool, dest map[uint64]*Rollup) error {
+	reader := bufio.NewReader(r)
+	h := header{}
+	if err := binary.Read(reader, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	switch h.Version {
+	case V3, V4:
+	default:
+		return fmt.Errorf("unknown version: %d", h.Version)
+	}
+	return readMetrics(reader, &h,
+		func(hash uint64, columns [][]float32) bool {
+			r, ok := dest[hash]
+			if !ok {
+				r = NewRollup(pointsCount)
+			}
+			changed := false
+			for i, v := range columns[0] {
+				if timeseries.IsNaN(v) {
+					continue
+				}
+				t := h.From.Add(timeseries.Duration(i) * h.Step)
+				if t < from {
+					continue
+				}
+				idx := int(t.Sub(from) / step)
+				if idx >= pointsCount {
+					break
+				}
+				lo, hi, count := v, v, float32(1)
+				if len(columns) == rollupColumnsCount {
+					lo, hi, count = columns[1][i], columns[2][i], columns[3][i]
+				}
+				r.add(idx, v, lo, hi, count, sum)
+				changed = true
+			}
+			if !changed && !ok {
+				return false
+			}
+			dest[hash] = r
+			return !ok
+		},
+		func(hash uint64, labels []byte) {
+			r := dest[hash]
+			r.Metric.LabelsHash = hash
+			r.Metric.Labels = make(model.Labels)
+			readLabels(labels, &r.Metric)
+		},
+	)
+}
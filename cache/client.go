@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"codexray/cache/chunk"
 	"codexray/constructor"
 	"codexray/db"
 	"codexray/model"
@@ -39,11 +38,11 @@ func (c *Client) QueryRange(ctx context.Context, query string, from, to timeseri
 	to = to.Truncate(step)
 	res := map[uint64]model.MetricValues{}
 	resPoints := int(to.Sub(from)/step + 1)
-	for _, ch := range qData.chunksOnDisk {
+	for _, ch := range qData.chunks {
 		if ch.From > to || ch.To() < from {
 			continue
 		}
-		err := chunk.Read(ch.Path, from, resPoints, step, res, fillFunc)
+		err := c.cache.readChunk(ch, from, resPoints, step, res, fillFunc)
 		if err != nil {
 			return nil, err
 		}
@@ -55,6 +54,8 @@ func (c *Client) QueryRange(ctx context.Context, query string, from, to timeseri
 	return r, nil
 }
 
+// GetStep returns the coarsest step of the chunks within the range, which is the cheapest resolution
+// the whole range can be read at, as its older part may be downsampled.
 func (c *Client) GetStep(from, to timeseries.Time) (timeseries.Duration, error) {
 	c.cache.lock.RLock()
 	defer c.cache.lock.RUnlock()
@@ -65,7 +66,7 @@ func (c *Client) GetStep(from, to timeseries.Time) (timeseries.Duration, error)
 
 	var step timeseries.Duration
 	for _, qData := range projData.queries {
-		for _, ch := range qData.chunksOnDisk {
+		for _, ch := range qData.chunks {
 			if ch.From > to || ch.To() < from {
 				continue
 			}
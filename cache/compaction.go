@@ -2,7 +2,6 @@ package cache
 
 import (
 	"fmt"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -35,7 +34,7 @@ func (ct CompactionTask) String() string {
 	)
 }
 
-func calcCompactionTasks(compactor Compactor, projectID db.ProjectId, queryHash string, chunks map[string]*chunk.Meta) []*CompactionTask {
+func calcCompactionTasks(compactor Compactor, projectID db.ProjectId, queryHash string, chunks map[string]*chunk.Meta, now timeseries.Time) []*CompactionTask {
 	tasks := map[timeseries.Time]*CompactionTask{}
 	for _, ch := range chunks {
 		if timeseries.Duration(ch.PointsCount)*ch.Step != compactor.SrcChunkDuration {
@@ -44,6 +43,12 @@ func calcCompactionTasks(compactor Compactor, projectID db.ProjectId, queryHash
 		if !ch.Finalized {
 			continue
 		}
+		if compactor.DstStep > 0 && ch.Step >= compactor.DstStep {
+			continue
+		}
+		if now.Sub(ch.To()) < compactor.MinAge {
+			continue
+		}
 		dstChunkTs := ch.From.Truncate(compactor.DstChunkDuration).Add(ch.Jitter())
 		task := tasks[dstChunkTs]
 		if task == nil {
@@ -92,6 +97,7 @@ func (c *Cache) compaction() {
 	for range time.Tick(cfg.Interval) {
 		klog.Infoln("compaction iteration started")
 		var tasks []*CompactionTask
+		now := timeseries.Now()
 		c.lock.RLock()
 
 		for projectID, projData := range c.byProject {
@@ -100,7 +106,7 @@ func (c *Cache) compaction() {
 			}
 			for hash, qData := range projData.queries {
 				for _, cfg := range cfg.Compactors {
-					tasks = append(tasks, calcCompactionTasks(cfg, projectID, hash, qData.chunksOnDisk)...)
+					tasks = append(tasks, calcCompactionTasks(cfg, projectID, hash, qData.chunks, now)...)
 				}
 			}
 		}
@@ -117,29 +123,34 @@ func (c *Cache) compact(t CompactionTask) error {
 		return fmt.Errorf("no src chunks")
 	}
 	start := time.Now()
-	metrics := map[uint64]model.MetricValues{}
 	sort.Slice(t.src, func(i, j int) bool {
 		return t.src[i].From < t.src[j].From
 	})
-	var step timeseries.Duration
-	for _, ch := range t.src {
-		if ch.Step > step {
-			step = ch.Step
+	if t.compactor.DstStep > 0 {
+		if err := c.downsample(t); err != nil {
+			return err
 		}
-	}
-	pointsCount := int(t.compactor.DstChunkDuration / step)
-	for _, i := range t.src {
-		if err := chunk.Read(i.Path, t.dstChunk, pointsCount, step, metrics, timeseries.FillAny); err != nil {
-			return fmt.Errorf("failed to read metrics from src chunk while compaction: %s", err)
+	} else {
+		var step timeseries.Duration
+		for _, ch := range t.src {
+			if ch.Step > step {
+				step = ch.Step
+			}
+		}
+		pointsCount := int(t.compactor.DstChunkDuration / step)
+		metrics := map[uint64]model.MetricValues{}
+		for _, i := range t.src {
+			if err := c.readChunk(i, t.dstChunk, pointsCount, step, metrics, timeseries.FillAny); err != nil {
+				return fmt.Errorf("failed to read metrics from src chunk while compaction: %s", err)
+			}
+		}
+		dst := make([]model.MetricValues, 0, len(metrics))
+		for _, m := range metrics {
+			dst = append(dst, m)
+		}
+		if err := c.writeChunk(t.projectID, t.queryHash, t.dstChunk, pointsCount, step, true, dst, true); err != nil {
+			return err
 		}
-	}
-
-	dst := make([]model.MetricValues, 0, len(metrics))
-	for _, m := range metrics {
-		dst = append(dst, m)
-	}
-	if err := c.writeChunk(t.projectID, t.queryHash, t.dstChunk, pointsCount, step, true, dst); err != nil {
-		return err
 	}
 
 	c.lock.Lock()
@@ -153,10 +164,10 @@ func (c *Cache) compact(t CompactionTask) error {
 			klog.Errorf("query data not found: %s-%s", t.projectID, t.queryHash)
 		} else {
 			for _, src := range t.src {
-				if err := os.Remove(src.Path); err != nil {
+				if err := c.store(src).Delete(src.Path); err != nil {
 					klog.Errorf("failed to delete chunk %s: %s", src.Path, err)
 				}
-				delete(qData.chunksOnDisk, src.Path)
+				delete(qData.chunks, src.Path)
 			}
 		}
 	}
@@ -167,3 +178,20 @@ func (c *Cache) compact(t CompactionTask) error {
 	klog.Infoln(t.String(), "done in", time.Since(start))
 	return nil
 }
+
+// downsample merges the src chunks into a chunk with a coarser step, keeping the min, max, and count of the raw points.
+func (c *Cache) downsample(t CompactionTask) error {
+	step := t.compactor.DstStep
+	pointsCount := int(t.compactor.DstChunkDuration / step)
+	rollups := map[uint64]*chunk.Rollup{}
+	for _, src := range t.src {
+		if err := c.readRollups(src, t.dstChunk, pointsCount, step, summedHashes[t.queryHash], rollups); err != nil {
+			return fmt.Errorf("failed to read metrics from src chunk while downsampling: %s", err)
+		}
+	}
+	dst := make([]*chunk.Rollup, 0, len(rollups))
+	for _, r := range rollups {
+		dst = append(dst, r)
+	}
+	return c.writeRollups(t.projectID, t.queryHash, t.dstChunk, pointsCount, step, dst, true)
+}
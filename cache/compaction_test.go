@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"codexray/cache/chunk"
+	"codexray/constructor"
+	"codexray/db"
+	"codexray/model"
+	"codexray/timeseries"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownsampling(t *testing.T) {
+	projectId := db.ProjectId("p1")
+	step := 60 * timeseries.Second
+	points := int(12 * timeseries.Hour / step)
+	compactor := Compactor{SrcChunkDuration: 12 * timeseries.Hour, DstChunkDuration: 24 * timeseries.Hour, DstStep: 5 * timeseries.Minute, MinAge: 7 * 24 * timeseries.Hour}
+
+	c := testCache(t, projectId)
+	c.compactedChunks = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_compacted_chunks_total"}, []string{"src", "dst"})
+
+	for _, query := range []string{"up", "rr_application_log_messages"} {
+		hash, jitter := QueryId(projectId, query)
+		from := timeseries.Time(1700000000).Truncate(24 * timeseries.Hour).Add(jitter)
+		for i := 0; i < 2; i++ {
+			chunkFrom := from.Add(timeseries.Duration(i) * 12 * timeseries.Hour)
+			values := timeseries.New(chunkFrom, points, step)
+			for p := 0; p < points; p++ {
+				values.Set(chunkFrom.Add(timeseries.Duration(p)*step), float32(p%5))
+			}
+			metrics := []model.MetricValues{{Labels: model.Labels{"job": "node"}, LabelsHash: 1, Values: values}}
+			require.NoError(t, c.writeChunk(projectId, hash, chunkFrom, points, step, true, metrics, false))
+		}
+		chunks := c.byProject[projectId].queries[hash].chunks
+		assert.Empty(t, calcCompactionTasks(compactor, projectId, hash, chunks, from.Add(7*24*timeseries.Hour)), "recent chunks are kept at full resolution")
+		tasks := calcCompactionTasks(compactor, projectId, hash, chunks, timeseries.Now())
+		require.Len(t, tasks, 1)
+		require.NoError(t, c.compact(*tasks[0]))
+		require.Len(t, chunks, 1)
+		assert.Empty(t, calcCompactionTasks(compactor, projectId, hash, chunks, timeseries.Now()), "downsampled chunks are not downsampled again")
+
+		dst := chunks[chunkName(projectId, hash, from, 288, compactor.DstStep)]
+		require.NotNil(t, dst)
+		rollups := map[uint64]*chunk.Rollup{}
+		require.NoError(t, c.readRollups(dst, from, 288, compactor.DstStep, false, rollups))
+		require.Contains(t, rollups, uint64(1))
+		r := rollups[1]
+		assert.Equal(t, model.Labels{"job": "node"}, r.Metric.Labels)
+		assert.Equal(t, float32(0), r.Min[0])
+		assert.Equal(t, float32(4), r.Max[0])
+		assert.Equal(t, float32(5), r.Count[0])
+
+		fillFunc := timeseries.FillAny
+		expected := float32(2)
+		if constructor.SummedQueries[query] {
+			fillFunc = timeseries.FillSum
+			expected = 10
+		}
+		res, err := c.GetCacheClient(projectId).QueryRange(context.Background(), query, from, from.Add(24*timeseries.Hour-compactor.DstStep), compactor.DstStep, fillFunc)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, 288, res[0].Values.Len())
+		assert.Equal(t, expected, res[0].Values.Reduce(timeseries.Max))
+	}
+
+	clientStep, err := c.GetCacheClient(projectId).GetStep(0, timeseries.Now())
+	require.NoError(t, err)
+	assert.Equal(t, compactor.DstStep, clientStep)
+}
@@ -10,6 +10,13 @@ type Config struct {
 	Path       string
 	GC         *GcConfig
 	Compaction *CompactionConfig
+
+	// RemoteStore, if set, keeps the compacted chunks, while the recent ones stay on local disk.
+	RemoteStore ChunkStore
+
+	// IsStandby reports whether the replica is a standby one, which copies finalized chunks from the primary
+	// instead of querying Prometheus.
+	IsStandby func() bool
 }
 
 type GcConfig struct {
@@ -27,6 +34,11 @@ type CompactionConfig struct {
 type Compactor struct {
 	SrcChunkDuration timeseries.Duration `yaml:"src_chunk_duration_seconds"`
 	DstChunkDuration timeseries.Duration `yaml:"dst_chunk_duration_seconds"`
+
+	// DstStep, if set, makes the compactor downsample the chunks having a finer step.
+	DstStep timeseries.Duration `yaml:"dst_step_seconds"`
+	// MinAge postpones the compaction of the chunks until they are older than that.
+	MinAge timeseries.Duration `yaml:"min_age_seconds"`
 }
 
 var DefaultCompactionConfig = CompactionConfig{
@@ -35,5 +47,7 @@ var DefaultCompactionConfig = CompactionConfig{
 	Compactors: []Compactor{
 		{SrcChunkDuration: 3600, DstChunkDuration: 4 * 3600},
 		{SrcChunkDuration: 4 * 3600, DstChunkDuration: 12 * 3600},
+		{SrcChunkDuration: 12 * 3600, DstChunkDuration: 24 * 3600, DstStep: 5 * 60, MinAge: 7 * 24 * 3600},
+		{SrcChunkDuration: 24 * 3600, DstChunkDuration: 24 * 3600, DstStep: 3600, MinAge: 30 * 24 * 3600},
 	},
 }
@@ -79,7 +79,20 @@ func (c *Cache) deleteState(state *PrometheusQueryState) error {
 	return err
 }
 
+// deleteProject removes the chunks and the state of the project. The caller must hold c.lock.
 func (c *Cache) deleteProject(projectId db.ProjectId) error {
+	if projData := c.byProject[projectId]; projData != nil && c.remote != nil {
+		for _, qData := range projData.queries {
+			for _, ch := range qData.chunks {
+				if !ch.Remote {
+					continue
+				}
+				if err := c.remote.Delete(ch.Path); err != nil {
+					return err
+				}
+			}
+		}
+	}
 	c.stateLock.Lock()
 	defer c.stateLock.Unlock()
 	projectDir := path.Join(c.cfg.Path, string(projectId))
@@ -1,9 +1,9 @@
 package cache
 
 import (
-	"os"
 	"time"
 
+	"codexray/cache/chunk"
 	"codexray/db"
 	"codexray/timeseries"
 
@@ -36,17 +36,17 @@ func (c *Cache) gc() {
 		}
 
 		minTs := timeseries.Time(now.Add(-c.cfg.GC.TTL).Unix())
-		toDelete := map[db.ProjectId]map[string][]string{}
+		toDelete := map[db.ProjectId]map[string][]*chunk.Meta{}
 		c.lock.RLock()
 		for projectId, projData := range c.byProject {
 			if projData == nil {
 				continue
 			}
-			toDeleteInProject := map[string][]string{}
+			toDeleteInProject := map[string][]*chunk.Meta{}
 			for hash, qData := range projData.queries {
-				for path, chunk := range qData.chunksOnDisk {
-					if chunk.To() < minTs {
-						toDeleteInProject[hash] = append(toDeleteInProject[hash], path)
+				for _, ch := range qData.chunks {
+					if ch.To() < minTs {
+						toDeleteInProject[hash] = append(toDeleteInProject[hash], ch)
 					}
 				}
 			}
@@ -64,15 +64,15 @@ func (c *Cache) gc() {
 			}
 			for hash, chunks := range toDeleteInProject {
 				qData := projData.queries[hash]
-				for _, path := range chunks {
-					klog.Infoln("deleting obsolete chunk:", path)
-					if err := os.Remove(path); err != nil {
-						klog.Errorf("failed to delete chunk %s: %s", path, err)
+				for _, ch := range chunks {
+					klog.Infoln("deleting obsolete chunk:", ch.Path)
+					if err := c.store(ch).Delete(ch.Path); err != nil {
+						klog.Errorf("failed to delete chunk %s: %s", ch.Path, err)
 					} else {
-						delete(qData.chunksOnDisk, path)
+						delete(qData.chunks, ch.Path)
 					}
 				}
-				if len(qData.chunksOnDisk) == 0 {
+				if len(qData.chunks) == 0 {
 					delete(projData.queries, hash)
 				}
 			}
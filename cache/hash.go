@@ -12,13 +12,17 @@ import (
 )
 
 var (
-	rrHashes = map[string]bool{}
+	rrHashes     = map[string]bool{}
+	summedHashes = map[string]bool{}
 )
 
 func init() {
 	for query := range constructor.RecordingRules {
 		rrHashes[queryHash(query)] = true
 	}
+	for query := range constructor.SummedQueries {
+		summedHashes[queryHash(query)] = true
+	}
 }
 
 func queryHash(query string) string {
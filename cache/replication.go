@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"codexray/cache/chunk"
+	"codexray/db"
+	"codexray/timeseries"
+
+	"k8s.io/klog"
+)
+
+// ReplicaQuery is a query along with its finalized chunks, the part of the cache a standby replica copies from the primary.
+// Chunks that are still being written are left out, since the primary rewrites them on every update.
+type ReplicaQuery struct {
+	ProjectId db.ProjectId   `json:"project_id"`
+	Query     string         `json:"query"`
+	Chunks    []ReplicaChunk `json:"chunks"`
+}
+
+type ReplicaChunk struct {
+	Name        string              `json:"name"`
+	From        timeseries.Time     `json:"from"`
+	PointsCount uint32              `json:"points_count"`
+	Step        timeseries.Duration `json:"step"`
+}
+
+func (ch ReplicaChunk) To() timeseries.Time {
+	return ch.From.Add(timeseries.Duration(ch.PointsCount-1) * ch.Step)
+}
+
+// FinalizedChunks returns the finalized chunks of the queries being updated.
+func (c *Cache) FinalizedChunks() ([]ReplicaQuery, error) {
+	c.lock.RLock()
+	projectIds := make([]db.ProjectId, 0, len(c.byProject))
+	for id := range c.byProject {
+		projectIds = append(projectIds, id)
+	}
+	c.lock.RUnlock()
+
+	var res []ReplicaQuery
+	for _, projectId := range projectIds {
+		states, err := c.loadStates(projectId)
+		if err != nil {
+			return nil, err
+		}
+		c.lock.RLock()
+		projData := c.byProject[projectId]
+		for q := range states {
+			if projData == nil {
+				break
+			}
+			hash, _ := QueryId(projectId, q)
+			qData := projData.queries[hash]
+			if qData == nil {
+				continue
+			}
+			rq := ReplicaQuery{ProjectId: projectId, Query: q}
+			for p, meta := range qData.chunks {
+				if meta.Finalized {
+					rq.Chunks = append(rq.Chunks, ReplicaChunk{Name: path.Base(p), From: meta.From, PointsCount: meta.PointsCount, Step: meta.Step})
+				}
+			}
+			if len(rq.Chunks) > 0 {
+				res = append(res, rq)
+			}
+		}
+		c.lock.RUnlock()
+	}
+	return res, nil
+}
+
+// OpenChunk opens a finalized chunk listed by FinalizedChunks.
+func (c *Cache) OpenChunk(projectId db.ProjectId, name string) (io.ReadCloser, error) {
+	c.lock.RLock()
+	var meta *chunk.Meta
+	if projData := c.byProject[projectId]; projData != nil {
+		for _, qData := range projData.queries {
+			if m := qData.chunks[path.Join(string(projectId), name)]; m != nil && m.Finalized {
+				meta = m
+				break
+			}
+		}
+	}
+	c.lock.RUnlock()
+	if meta == nil {
+		return nil, os.ErrNotExist
+	}
+	return c.store(meta).Open(meta.Path)
+}
+
+// MissingChunks returns the chunks of the primary replica that are neither covered by the local chunks
+// nor about to be deleted by GC.
+func (c *Cache) MissingChunks(queries []ReplicaQuery) []ReplicaQuery {
+	var minTs timeseries.Time
+	if c.cfg.GC != nil {
+		minTs = timeseries.Time(time.Now().Add(-c.cfg.GC.TTL).Unix())
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	var res []ReplicaQuery
+	for _, q := range queries {
+		var local []*chunk.Meta
+		if projData := c.byProject[q.ProjectId]; projData != nil {
+			hash, _ := QueryId(q.ProjectId, q.Query)
+			if qData := projData.queries[hash]; qData != nil {
+				for _, meta := range qData.chunks {
+					if meta.Finalized {
+						local = append(local, meta)
+					}
+				}
+			}
+		}
+		sort.Slice(local, func(i, j int) bool {
+			return local[i].From < local[j].From
+		})
+		missing := ReplicaQuery{ProjectId: q.ProjectId, Query: q.Query}
+		for _, ch := range q.Chunks {
+			if ch.To() >= minTs && !covered(local, ch) {
+				missing.Chunks = append(missing.Chunks, ch)
+			}
+		}
+		if len(missing.Chunks) > 0 {
+			res = append(res, missing)
+		}
+	}
+	return res
+}
+
+// covered checks whether the chunks sorted by From contain every point of the replica chunk.
+// The standby compacts its chunks on its own, so a compacted chunk of the primary is usually covered by several local ones.
+func covered(local []*chunk.Meta, ch ReplicaChunk) bool {
+	next := ch.From
+	for _, meta := range local {
+		if meta.From > next {
+			break
+		}
+		if meta.To() >= next {
+			next = meta.To().Add(meta.Step)
+		}
+		if next > ch.To() {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportChunk saves a chunk copied from the primary replica and advances the state of the query past it,
+// so that after a failover the updater continues from the last replicated chunk instead of backfilling.
+func (c *Cache) ImportChunk(projectId db.ProjectId, query string, ch ReplicaChunk, r io.Reader) error {
+	hash, _ := QueryId(projectId, query)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	meta, err := chunk.ReadMetaFrom(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid chunk %s: %w", ch.Name, err)
+	}
+	if !meta.Finalized || meta.From != ch.From || meta.PointsCount != ch.PointsCount || meta.Step != ch.Step {
+		return fmt.Errorf("chunk %s doesn't match its description", ch.Name)
+	}
+	meta.Path = chunkName(projectId, hash, ch.From, int(ch.PointsCount), ch.Step)
+	meta.Size = int64(len(data))
+	if err = c.local.Put(meta.Path, data); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	projData := c.byProject[projectId]
+	if projData == nil {
+		projData = newProjectData()
+		c.byProject[projectId] = projData
+	}
+	if projData.step == 0 {
+		projData.step = meta.Step
+	}
+	qData := projData.queries[hash]
+	if qData == nil {
+		qData = newQueryData()
+		projData.queries[hash] = qData
+	}
+	qData.chunks[meta.Path] = meta
+	c.lock.Unlock()
+
+	states, err := c.loadStates(projectId)
+	if err != nil {
+		return err
+	}
+	state := states[query]
+	if state == nil {
+		state = &PrometheusQueryState{ProjectId: projectId, Query: query}
+	}
+	if meta.To() > state.LastTs {
+		state.LastTs = meta.To()
+		state.LastError = ""
+		if err = c.saveState(state); err != nil {
+			return err
+		}
+	}
+	klog.Infoln("replicated chunk:", meta.Path)
+	return nil
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/timeseries"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCache(t *testing.T, projectId db.ProjectId) *Cache {
+	dir := t.TempDir()
+	state, err := db.Open(dir, "")
+	require.NoError(t, err)
+	require.NoError(t, state.Migrator().Migrate(&PrometheusQueryState{}))
+	require.NoError(t, os.MkdirAll(path.Join(dir, string(projectId)), 0755))
+	return &Cache{
+		cfg:       Config{Path: dir},
+		byProject: map[db.ProjectId]*projectData{projectId: newProjectData()},
+		state:     state.DB(),
+		local:     NewDiskStore(dir),
+		backfills: backfills{byQuery: map[string]*BackfillStatus{}},
+	}
+}
+
+func TestReplication(t *testing.T) {
+	projectId := db.ProjectId("p1")
+	step := 15 * timeseries.Second
+	points := int(timeseries.Hour / step)
+	hash, jitter := QueryId(projectId, "up")
+	from := timeseries.Time(1700000000).Truncate(timeseries.Hour).Add(jitter)
+	metrics := []model.MetricValues{{Labels: model.Labels{"job": "node"}, Values: timeseries.New(from, points, step)}}
+
+	primary := testCache(t, projectId)
+	for i := 0; i < 3; i++ {
+		chunkFrom := from.Add(timeseries.Duration(i) * timeseries.Hour)
+		require.NoError(t, primary.writeChunk(projectId, hash, chunkFrom, points, step, i < 2, metrics, false))
+	}
+	require.NoError(t, primary.saveState(&PrometheusQueryState{ProjectId: projectId, Query: "up", LastTs: from.Add(2*timeseries.Hour + 10*step)}))
+
+	queries, err := primary.FinalizedChunks()
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	assert.Len(t, queries[0].Chunks, 2, "the chunk being written is not replicated")
+
+	standby := testCache(t, projectId)
+	missing := standby.MissingChunks(queries)
+	require.Len(t, missing, 1)
+	for _, ch := range missing[0].Chunks {
+		f, err := primary.OpenChunk(projectId, ch.Name)
+		require.NoError(t, err)
+		require.NoError(t, standby.ImportChunk(projectId, "up", ch, f))
+		_ = f.Close()
+	}
+	assert.Empty(t, standby.MissingChunks(queries))
+	states, err := standby.loadStates(projectId)
+	require.NoError(t, err)
+	assert.Equal(t, from.Add(2*timeseries.Hour-step), states["up"].LastTs, "the updater continues after the last finalized chunk")
+
+	compacted := ReplicaChunk{Name: "compacted", From: from, PointsCount: uint32(2 * points), Step: step}
+	assert.Empty(t, standby.MissingChunks([]ReplicaQuery{{ProjectId: projectId, Query: "up", Chunks: []ReplicaChunk{compacted}}}),
+		"a compacted chunk is covered by the replicated ones")
+
+	_, err = primary.OpenChunk(projectId, "../db.sqlite")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
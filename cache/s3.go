@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type S3Config struct {
+	// Endpoint is the URL of an S3-compatible storage, e.g., http://minio:9000. AWS S3 is used if it's empty.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to the chunk names, so several installations can share a bucket.
+	Prefix string
+	// AccessKeyId and SecretAccessKey are optional: the default AWS credential chain is used if they are empty.
+	AccessKeyId     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket as a part of the path rather than the host, as most S3-compatible storages expect.
+	PathStyle bool
+}
+
+// S3Store keeps chunks in an S3-compatible bucket.
+type S3Store struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("the bucket is not specified")
+	}
+	awsCfg := aws.NewConfig().
+		WithRegion(cmp.Or(cfg.Region, "us-east-1")).
+		WithS3ForcePathStyle(cfg.PathStyle)
+	if cfg.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(cfg.Endpoint)
+	}
+	if cfg.AccessKeyId != "" {
+		awsCfg = awsCfg.WithCredentials(credentials.NewStaticCredentials(cfg.AccessKeyId, cfg.SecretAccessKey, ""))
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3Store{client: s3.New(sess), bucket: cfg.Bucket, prefix: prefix}, nil
+}
+
+func (s *S3Store) key(name string) *string {
+	return aws.String(s.prefix + name)
+}
+
+func (s *S3Store) Put(name string, data []byte) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    s.key(name),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *S3Store) Open(name string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    s.key(name),
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *S3Store) Delete(name string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    s.key(name),
+	})
+	return err
+}
+
+func (s *S3Store) List() (map[string]int64, error) {
+	res := map[string]int64{}
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, o := range page.Contents {
+			name := strings.TrimPrefix(aws.StringValue(o.Key), s.prefix)
+			if path.Ext(name) == ".db" {
+				res[name] = aws.Int64Value(o.Size)
+			}
+		}
+		return true
+	})
+	return res, err
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"codexray/cache/chunk"
+	"codexray/db"
+	"codexray/timeseries"
+)
+
+// ChunkStore keeps chunk files. Chunks are named <project>/<project>-<query hash>-<from>-<points count>-<step>.db.
+type ChunkStore interface {
+	// Put stores the chunk atomically, replacing the existing one.
+	Put(name string, data []byte) error
+	Open(name string) (io.ReadCloser, error)
+	// Delete removes the chunk. Deleting a missing chunk is not an error.
+	Delete(name string) error
+	// List returns the sizes of all the chunks by their names.
+	List() (map[string]int64, error)
+}
+
+func chunkName(projectId db.ProjectId, queryHash string, from timeseries.Time, pointsCount int, step timeseries.Duration) string {
+	return path.Join(string(projectId), fmt.Sprintf("%s-%s-%d-%d-%d.db", projectId, queryHash, from, pointsCount, step))
+}
+
+// parseChunkName returns the project, the query hash, and the meta of a chunk without reading it.
+// Since only finalized chunks are tiered out to the object storage, they are considered finalized.
+func parseChunkName(name string) (db.ProjectId, string, *chunk.Meta, bool) {
+	dir, file := path.Split(name)
+	parts := strings.Split(strings.TrimSuffix(file, ".db"), "-")
+	if !strings.HasSuffix(file, ".db") || len(parts) != 5 || dir != parts[0]+"/" {
+		return "", "", nil, false
+	}
+	from, err1 := strconv.ParseInt(parts[2], 10, 64)
+	pointsCount, err2 := strconv.ParseUint(parts[3], 10, 32)
+	step, err3 := strconv.ParseInt(parts[4], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return "", "", nil, false
+	}
+	meta := &chunk.Meta{
+		Path:        name,
+		From:        timeseries.Time(from),
+		PointsCount: uint32(pointsCount),
+		Step:        timeseries.Duration(step),
+		Finalized:   true,
+	}
+	return db.ProjectId(parts[0]), parts[1], meta, true
+}
+
+// DiskStore keeps chunks in a local directory.
+type DiskStore struct {
+	dir string
+}
+
+func NewDiskStore(dir string) *DiskStore {
+	return &DiskStore{dir: dir}
+}
+
+func (s *DiskStore) path(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
+
+func (s *DiskStore) Put(name string, data []byte) error {
+	p := s.path(name)
+	dir, file := filepath.Split(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (s *DiskStore) Open(name string) (io.ReadCloser, error) {
+	return os.Open(s.path(name))
+}
+
+func (s *DiskStore) Delete(name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *DiskStore) List() (map[string]int64, error) {
+	res := map[string]int64{}
+	projects, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if !p.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.dir, p.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !f.Type().IsRegular() || !strings.HasSuffix(f.Name(), ".db") {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			res[path.Join(p.Name(), f.Name())] = info.Size()
+		}
+	}
+	return res, nil
+}
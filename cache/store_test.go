@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/timeseries"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an in-process stand-in for an S3-compatible storage supporting the requests S3Store makes.
+type fakeS3 struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "chunks" {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		type object struct {
+			Key  string
+			Size int
+		}
+		res := struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Name     string
+			KeyCount int
+			Contents []object
+		}{Name: bucket}
+		for k, v := range s.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				res.Contents = append(res.Contents, object{Key: k, Size: len(v)})
+			}
+		}
+		slices.SortFunc(res.Contents, func(a, b object) int { return strings.Compare(a.Key, b.Key) })
+		res.KeyCount = len(res.Contents)
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(res)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[key] = data
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+			return
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+func testS3Store(t *testing.T) (*S3Store, *fakeS3) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	store, err := NewS3Store(S3Config{Endpoint: srv.URL, Bucket: "chunks", Prefix: "codexray", AccessKeyId: "key", SecretAccessKey: "secret", PathStyle: true})
+	require.NoError(t, err)
+	return store, fake
+}
+
+func TestS3Store(t *testing.T) {
+	store, fake := testS3Store(t)
+
+	require.NoError(t, store.Put("p1/a.db", []byte("a")))
+	require.NoError(t, store.Put("p1/b.db", []byte("b")))
+	assert.Contains(t, fake.objects, "codexray/p1/a.db")
+	sizes, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"p1/a.db": 1, "p1/b.db": 1}, sizes)
+
+	f, err := store.Open("p1/a.db")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+	_ = f.Close()
+
+	require.NoError(t, store.Delete("p1/a.db"))
+	_, err = store.Open("p1/a.db")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestTiering(t *testing.T) {
+	projectId := db.ProjectId("p1")
+	step := 60 * timeseries.Second
+	points := int(timeseries.Hour / step)
+	hash, jitter := QueryId(projectId, "up")
+	from := timeseries.Time(1700000000).Truncate(4 * timeseries.Hour).Add(jitter)
+
+	remote, fake := testS3Store(t)
+	c := testCache(t, projectId)
+	c.remote = remote
+	c.compactedChunks = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_compacted_chunks_total"}, []string{"src", "dst"})
+	for i := 0; i < 4; i++ {
+		chunkFrom := from.Add(timeseries.Duration(i) * timeseries.Hour)
+		values := timeseries.New(chunkFrom, points, step)
+		values.Set(chunkFrom, float32(i))
+		metrics := []model.MetricValues{{Labels: model.Labels{"job": "node"}, LabelsHash: 1, Values: values}}
+		require.NoError(t, c.writeChunk(projectId, hash, chunkFrom, points, step, true, metrics, false))
+	}
+	assert.Empty(t, fake.objects, "recent chunks are kept on local disk")
+
+	tasks := calcCompactionTasks(DefaultCompactionConfig.Compactors[0], projectId, hash, c.byProject[projectId].queries[hash].chunks, timeseries.Now())
+	require.Len(t, tasks, 1)
+	require.NoError(t, c.compact(*tasks[0]))
+
+	compacted := chunkName(projectId, hash, from, 4*points, step)
+	assert.Contains(t, fake.objects, "codexray/"+compacted, "compacted chunks are tiered out to the bucket")
+	local, err := c.local.List()
+	require.NoError(t, err)
+	assert.Empty(t, local)
+
+	// a restarted replica finds the remote chunks by their names
+	restarted := &Cache{cfg: c.cfg, byProject: map[db.ProjectId]*projectData{}, state: c.state, local: c.local, remote: remote}
+	require.NoError(t, restarted.initCacheIndex())
+	meta := restarted.byProject[projectId].queries[hash].chunks[compacted]
+	require.NotNil(t, meta)
+	assert.True(t, meta.Remote)
+
+	res, err := restarted.GetCacheClient(projectId).QueryRange(context.Background(), "up", from, from.Add(4*timeseries.Hour-step), step, timeseries.FillAny)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	values := map[timeseries.Time]float32{}
+	iter := res[0].Values.Iter()
+	for iter.Next() {
+		ts, v := iter.Value()
+		values[ts] = v
+	}
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, float32(i), values[from.Add(timeseries.Duration(i)*timeseries.Hour)])
+	}
+}
@@ -1,12 +1,11 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math"
-	"os"
 	"path"
-	"path/filepath"
 	"sync"
 	"time"
 
@@ -88,6 +87,10 @@ func (c *Cache) updaterWorker(projects *sync.Map, projectId db.ProjectId, promCl
 		}
 
 		project := p.(*db.Project)
+		if c.cfg.IsStandby != nil && c.cfg.IsStandby() {
+			time.Sleep(MinRefreshInterval.ToStandard())
+			continue
+		}
 		states, err := c.loadStates(projectId)
 		if err != nil {
 			klog.Errorln("could not get query states:", err)
@@ -217,7 +220,7 @@ func (c *Cache) download(to timeseries.Time, promClient *prom.Client, projectId
 		}
 		chunkEnd := i.chunkTs.Add(timeseries.Duration(pointsCount-1) * step)
 		finalized := chunkEnd == i.toTs
-		err = c.writeChunk(projectId, hash, i.chunkTs, pointsCount, step, finalized, vs)
+		err = c.writeChunk(projectId, hash, i.chunkTs, pointsCount, step, finalized, vs, false)
 		if err != nil {
 			klog.Errorln("failed to save chunk:", err)
 			return
@@ -233,10 +236,28 @@ func (c *Cache) download(to timeseries.Time, promClient *prom.Client, projectId
 	}
 }
 
-func (c *Cache) writeChunk(projectId db.ProjectId, queryHash string, from timeseries.Time, pointsCount int, step timeseries.Duration, finalized bool, metrics []model.MetricValues) error {
+// writeChunk stores the chunk on local disk, or in the remote store if remote is set and the store is configured.
+func (c *Cache) writeChunk(projectId db.ProjectId, queryHash string, from timeseries.Time, pointsCount int, step timeseries.Duration, finalized bool, metrics []model.MetricValues, remote bool) error {
+	var buf bytes.Buffer
+	if err := chunk.Write(&buf, from, pointsCount, step, finalized, metrics); err != nil {
+		return err
+	}
+	return c.putChunk(projectId, queryHash, from, pointsCount, step, finalized, buf.Bytes(), remote)
+}
+
+func (c *Cache) writeRollups(projectId db.ProjectId, queryHash string, from timeseries.Time, pointsCount int, step timeseries.Duration, rollups []*chunk.Rollup, remote bool) error {
+	var buf bytes.Buffer
+	if err := chunk.WriteRollups(&buf, from, pointsCount, step, true, rollups); err != nil {
+		return err
+	}
+	return c.putChunk(projectId, queryHash, from, pointsCount, step, true, buf.Bytes(), remote)
+}
+
+func (c *Cache) putChunk(projectId db.ProjectId, queryHash string, from timeseries.Time, pointsCount int, step timeseries.Duration, finalized bool, data []byte, remote bool) error {
 	c.lock.Lock()
 	projData := c.byProject[projectId]
 	if projData == nil {
+		c.lock.Unlock()
 		return fmt.Errorf("unknown project: %s", projectId)
 	}
 	qData := projData.queries[queryHash]
@@ -246,41 +267,27 @@ func (c *Cache) writeChunk(projectId db.ProjectId, queryHash string, from timese
 	}
 	c.lock.Unlock()
 
-	projectDir := path.Join(c.cfg.Path, string(projectId))
-	chunkFilePath := path.Join(projectDir, fmt.Sprintf(
-		"%s-%s-%d-%d-%d.db",
-		projectId, queryHash, from, pointsCount, step))
-	dir, file := filepath.Split(chunkFilePath)
-	if dir == "" {
-		dir = "."
-	}
-	f, err := os.CreateTemp(dir, file)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = f.Close()
-		_ = os.Remove(f.Name())
-	}()
-	if err = chunk.Write(f, from, pointsCount, step, finalized, metrics); err != nil {
-		return err
+	meta := &chunk.Meta{
+		Path:        chunkName(projectId, queryHash, from, pointsCount, step),
+		From:        from,
+		PointsCount: uint32(pointsCount),
+		Step:        step,
+		Finalized:   finalized,
+		Remote:      remote && c.remote != nil,
+		Size:        int64(len(data)),
 	}
-	if err = f.Close(); err != nil {
+	if err := c.store(meta).Put(meta.Path, data); err != nil {
 		return err
 	}
-
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	if err = os.Rename(f.Name(), chunkFilePath); err != nil {
-		return err
-	}
-	qData.chunksOnDisk[chunkFilePath] = &chunk.Meta{
-		Path:        chunkFilePath,
-		From:        from,
-		PointsCount: uint32(pointsCount),
-		Step:        step,
-		Finalized:   finalized,
+	if prev := qData.chunks[meta.Path]; prev != nil && prev.Remote != meta.Remote {
+		// the chunk has been moved to the other store
+		if err := c.store(prev).Delete(prev.Path); err != nil {
+			klog.Errorf("failed to delete chunk %s: %s", prev.Path, err)
+		}
 	}
+	qData.chunks[meta.Path] = meta
 	return nil
 }
 
@@ -316,7 +323,7 @@ func (c *Cache) processRecordingRules(to timeseries.Time, project *db.Project, s
 		for name, rule := range constructor.RecordingRules {
 			hash := queryHash(name)
 			mvs := rule(project, world)
-			err = c.writeChunk(project.Id, hash, i.chunkTs, pointsCount, step, finalized, mvs)
+			err = c.writeChunk(project.Id, hash, i.chunkTs, pointsCount, step, finalized, mvs, false)
 			if err != nil {
 				klog.Errorln("failed to save chunk:", err)
 				return
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"codexray/collector"
+	"codexray/db"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
@@ -78,6 +79,23 @@ func NewClient(config ClientConfig, distributed bool) (*Client, error) {
 	return &Client{config: config, conn: conn, useDistributedTables: distributed}, nil
 }
 
+func NewProjectClient(project *db.Project, globalClickHouse *db.IntegrationClickhouse, coll *collector.Collector) (*Client, error) {
+	cfg := project.ClickHouseConfig(globalClickHouse)
+	if cfg == nil {
+		return nil, nil
+	}
+	config := NewClientConfig(cfg.Addr, cfg.Auth.User, cfg.Auth.Password)
+	config.Protocol = cfg.Protocol
+	config.Database = cfg.Database
+	config.TlsEnable = cfg.TlsEnable
+	config.TlsSkipVerify = cfg.TlsSkipVerify
+	distributed, err := coll.IsClickhouseDistributed(project)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(config, distributed)
+}
+
 func (c *Client) Ping(ctx context.Context) error {
 	return c.conn.Ping(ctx)
 }
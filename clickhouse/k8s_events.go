@@ -0,0 +1,46 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"codexray/model"
+	"codexray/timeseries"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+const (
+	k8sEventsLimit = 10000
+)
+
+func (c *Client) GetKubernetesEvents(ctx context.Context, from, to timeseries.Time) ([]*model.KubernetesEvent, error) {
+	q := "SELECT Uid, min(Timestamp), max(Timestamp), any(Type), any(Reason), argMax(Message, Timestamp), max(Count), any(Namespace), any(ObjectKind), any(ObjectName)"
+	q += " FROM @@table_k8s_events@@"
+	q += " WHERE Timestamp BETWEEN @from AND @to"
+	q += " GROUP BY Uid"
+	q += " ORDER BY 3 DESC LIMIT " + fmt.Sprint(k8sEventsLimit)
+	rows, err := c.Query(ctx, q,
+		clickhouse.DateNamed("from", from.ToStandard(), clickhouse.NanoSeconds),
+		clickhouse.DateNamed("to", to.ToStandard(), clickhouse.NanoSeconds),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []*model.KubernetesEvent
+	var firstSeen, lastSeen time.Time
+	var typ string
+	for rows.Next() {
+		var e model.KubernetesEvent
+		if err = rows.Scan(&e.Uid, &firstSeen, &lastSeen, &typ, &e.Reason, &e.Message, &e.Count, &e.Namespace, &e.ObjectKind, &e.ObjectName); err != nil {
+			return nil, err
+		}
+		e.FirstSeen = timeseries.Time(firstSeen.Unix())
+		e.LastSeen = timeseries.Time(lastSeen.Unix())
+		e.Type = model.KubernetesEventType(typ)
+		res = append(res, &e)
+	}
+	return res, nil
+}
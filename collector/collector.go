@@ -15,6 +15,7 @@ import (
 	"github.com/ClickHouse/ch-go/chpool"
 	chproto "github.com/ClickHouse/ch-go/proto"
 	"github.com/jpillora/backoff"
+	"github.com/prometheus/prometheus/prompb"
 	"golang.org/x/exp/maps"
 	"k8s.io/klog"
 )
@@ -48,18 +49,23 @@ type Collector struct {
 	clickhouseClients     map[db.ProjectId]*chClient
 	clickhouseClientsLock sync.RWMutex
 
-	traceBatches       map[db.ProjectId]*TracesBatch
-	traceBatchesLock   sync.Mutex
-	logBatches         map[db.ProjectId]*LogsBatch
-	logBatchesLock     sync.Mutex
-	profileBatches     map[db.ProjectId]*ProfilesBatch
-	profileBatchesLock sync.Mutex
+	traceBatches        map[db.ProjectId]*TracesBatch
+	traceBatchesLock    sync.Mutex
+	logBatches          map[db.ProjectId]*LogsBatch
+	logBatchesLock      sync.Mutex
+	k8sEventBatches     map[db.ProjectId]*K8sEventsBatch
+	k8sEventBatchesLock sync.Mutex
+	profileBatches      map[db.ProjectId]*ProfilesBatch
+	profileBatchesLock  sync.Mutex
 
 	perfBatches     map[db.ProjectId]*PerfBatch
 	perfBatchesLock sync.Mutex
 
 	errLogBatches     map[db.ProjectId]*ErrLogBatch
 	errLogBatchesLock sync.Mutex
+
+	spanMetrics     map[db.ProjectId]*SpanMetrics
+	spanMetricsLock sync.Mutex
 }
 
 func New(database *db.DB, cache *cache.Cache, globalClickHouse *db.IntegrationClickhouse, globalPrometheus *db.IntegrationsPrometheus) *Collector {
@@ -72,8 +78,10 @@ func New(database *db.DB, cache *cache.Cache, globalClickHouse *db.IntegrationCl
 		traceBatches:      map[db.ProjectId]*TracesBatch{},
 		profileBatches:    map[db.ProjectId]*ProfilesBatch{},
 		logBatches:        map[db.ProjectId]*LogsBatch{},
+		k8sEventBatches:   map[db.ProjectId]*K8sEventsBatch{},
 		perfBatches:       map[db.ProjectId]*PerfBatch{},
 		errLogBatches:     map[db.ProjectId]*ErrLogBatch{},
+		spanMetrics:       map[db.ProjectId]*SpanMetrics{},
 	}
 
 	c.updateProjects()
@@ -166,11 +174,21 @@ func (c *Collector) Close() {
 	for _, b := range c.logBatches {
 		b.Close()
 	}
+	c.k8sEventBatchesLock.Lock()
+	defer c.k8sEventBatchesLock.Unlock()
+	for _, b := range c.k8sEventBatches {
+		b.Close()
+	}
 	c.profileBatchesLock.Lock()
 	defer c.profileBatchesLock.Unlock()
 	for _, b := range c.profileBatches {
 		b.Close()
 	}
+	c.spanMetricsLock.Lock()
+	defer c.spanMetricsLock.Unlock()
+	for _, m := range c.spanMetrics {
+		m.Close()
+	}
 
 	c.clickhouseClientsLock.Lock()
 	defer c.clickhouseClientsLock.Unlock()
@@ -323,6 +341,19 @@ func (c *Collector) getLogsBatch(project *db.Project) *LogsBatch {
 	return b
 }
 
+func (c *Collector) getK8sEventsBatch(project *db.Project) *K8sEventsBatch {
+	c.k8sEventBatchesLock.Lock()
+	defer c.k8sEventBatchesLock.Unlock()
+	b := c.k8sEventBatches[project.Id]
+	if b == nil {
+		b = NewK8sEventsBatch(batchLimit, batchTimeout, func(query ch.Query) error {
+			return c.clickhouseDo(context.TODO(), project, query)
+		})
+		c.k8sEventBatches[project.Id] = b
+	}
+	return b
+}
+
 func (c *Collector) getProfilesBatch(project *db.Project) *ProfilesBatch {
 	c.profileBatchesLock.Lock()
 	defer c.profileBatchesLock.Unlock()
@@ -361,6 +392,27 @@ func (c *Collector) getErrLogBatch(project *db.Project) *ErrLogBatch {
 	return b
 }
 
+func (c *Collector) getSpanMetrics(project *db.Project) *SpanMetrics {
+	c.spanMetricsLock.Lock()
+	defer c.spanMetricsLock.Unlock()
+	m := c.spanMetrics[project.Id]
+	if m == nil {
+		m = NewSpanMetrics(spanMetricsFlushInterval, project.PrometheusConfig(c.globalPrometheus).ExtraLabels, func(series []prompb.TimeSeries) error {
+			// the project is looked up on every flush to pick up the changes of the Prometheus integration
+			c.projectsLock.RLock()
+			p := c.projects[project.Id]
+			c.projectsLock.RUnlock()
+			if p == nil {
+				return ErrProjectNotFound
+			}
+			_, err := writeTimeseries(context.TODO(), p.PrometheusConfig(c.globalPrometheus), series)
+			return err
+		})
+		c.spanMetrics[project.Id] = m
+	}
+	return m
+}
+
 func (c *Collector) IsClickhouseDistributed(project *db.Project) (bool, error) {
 	client, err := c.getClickhouseClient(project)
 	if err != nil {
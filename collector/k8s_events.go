@@ -0,0 +1,173 @@
+package collector
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/ch-go"
+	chproto "github.com/ClickHouse/ch-go/proto"
+	semconv "go.opentelemetry.io/collector/semconv/v1.18.0"
+	v1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"k8s.io/klog"
+)
+
+const (
+	attributeK8sEventReason = "k8s.event.reason"
+	attributeK8sEventUid    = "k8s.event.uid"
+	attributeK8sEventCount  = "k8s.event.count"
+	attributeK8sObjectKind  = "k8s.object.kind"
+	attributeK8sObjectName  = "k8s.object.name"
+)
+
+// isKubernetesEvent reports whether a log record follows the OpenTelemetry semantics for Kubernetes events
+// (as emitted by the k8sevents and k8sobjects receivers).
+func isKubernetesEvent(resourceAttributes, logAttributes map[string]string) bool {
+	if logAttributes[semconv.AttributeEventDomain] == semconv.AttributeEventDomainK8S {
+		return true
+	}
+	return logAttributes[attributeK8sEventReason] != "" && resourceAttributes[attributeK8sObjectKind] != ""
+}
+
+func k8sEventAttribute(resourceAttributes, logAttributes map[string]string, name string) string {
+	if v := logAttributes[name]; v != "" {
+		return v
+	}
+	return resourceAttributes[name]
+}
+
+type K8sEventsBatch struct {
+	limit int
+	exec  func(query ch.Query) error
+
+	lock sync.Mutex
+	done chan struct{}
+
+	Timestamp  *chproto.ColDateTime64
+	Uid        *chproto.ColStr
+	Type       *chproto.ColLowCardinality[string]
+	Reason     *chproto.ColLowCardinality[string]
+	Namespace  *chproto.ColLowCardinality[string]
+	ObjectKind *chproto.ColLowCardinality[string]
+	ObjectName *chproto.ColStr
+	Count      *chproto.ColInt64
+	Message    *chproto.ColStr
+}
+
+func NewK8sEventsBatch(limit int, timeout time.Duration, exec func(query ch.Query) error) *K8sEventsBatch {
+	b := &K8sEventsBatch{
+		limit: limit,
+		exec:  exec,
+		done:  make(chan struct{}),
+
+		Timestamp:  new(chproto.ColDateTime64).WithPrecision(chproto.PrecisionNano),
+		Uid:        new(chproto.ColStr),
+		Type:       new(chproto.ColStr).LowCardinality(),
+		Reason:     new(chproto.ColStr).LowCardinality(),
+		Namespace:  new(chproto.ColStr).LowCardinality(),
+		ObjectKind: new(chproto.ColStr).LowCardinality(),
+		ObjectName: new(chproto.ColStr),
+		Count:      new(chproto.ColInt64),
+		Message:    new(chproto.ColStr),
+	}
+
+	go func() {
+		ticker := time.NewTicker(timeout)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.done:
+				return
+			case <-ticker.C:
+				b.lock.Lock()
+				b.save()
+				b.lock.Unlock()
+			}
+		}
+	}()
+
+	return b
+}
+
+func (b *K8sEventsBatch) Close() {
+	b.done <- struct{}{}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.save()
+}
+
+func (b *K8sEventsBatch) Add(req *v1.ExportLogsServiceRequest) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, l := range req.GetResourceLogs() {
+		resourceAttributes := attributesToMap(l.GetResource().GetAttributes())
+		for _, sl := range l.GetScopeLogs() {
+			for _, lr := range sl.GetLogRecords() {
+				logAttributes := attributesToMap(lr.GetAttributes())
+				if !isKubernetesEvent(resourceAttributes, logAttributes) {
+					continue
+				}
+				ts := lr.GetTimeUnixNano()
+				if ts == 0 {
+					ts = lr.GetObservedTimeUnixNano()
+				}
+				if int64(ts) <= 0 {
+					continue
+				}
+				attr := func(name string) string {
+					return k8sEventAttribute(resourceAttributes, logAttributes, name)
+				}
+				count, _ := strconv.ParseInt(attr(attributeK8sEventCount), 10, 64)
+				if count == 0 {
+					count = 1
+				}
+				typ := lr.GetSeverityText()
+				if typ == "" {
+					typ = "Normal"
+					if lr.GetSeverityNumber() >= 13 {
+						typ = "Warning"
+					}
+				}
+				b.Timestamp.Append(time.Unix(0, int64(ts)))
+				b.Uid.Append(attr(attributeK8sEventUid))
+				b.Type.Append(typ)
+				b.Reason.Append(attr(attributeK8sEventReason))
+				b.Namespace.Append(attr(semconv.AttributeK8SNamespaceName))
+				b.ObjectKind.Append(attr(attributeK8sObjectKind))
+				b.ObjectName.Append(attr(attributeK8sObjectName))
+				b.Count.Append(count)
+				b.Message.Append(lr.GetBody().GetStringValue())
+			}
+		}
+	}
+	if b.Timestamp.Rows() < b.limit {
+		return
+	}
+	b.save()
+}
+
+func (b *K8sEventsBatch) save() {
+	if b.Timestamp.Rows() == 0 {
+		return
+	}
+
+	input := chproto.Input{
+		chproto.InputColumn{Name: "Timestamp", Data: b.Timestamp},
+		chproto.InputColumn{Name: "Uid", Data: b.Uid},
+		chproto.InputColumn{Name: "Type", Data: b.Type},
+		chproto.InputColumn{Name: "Reason", Data: b.Reason},
+		chproto.InputColumn{Name: "Namespace", Data: b.Namespace},
+		chproto.InputColumn{Name: "ObjectKind", Data: b.ObjectKind},
+		chproto.InputColumn{Name: "ObjectName", Data: b.ObjectName},
+		chproto.InputColumn{Name: "Count", Data: b.Count},
+		chproto.InputColumn{Name: "Message", Data: b.Message},
+	}
+	err := b.exec(ch.Query{Body: input.Into("@@table_k8s_events@@"), Input: input})
+	if err != nil {
+		klog.Errorln(err)
+	}
+	for _, i := range input {
+		i.Data.(chproto.Resettable).Reset()
+	}
+}
@@ -0,0 +1,20 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsKubernetesEvent(t *testing.T) {
+	assert.True(t, isKubernetesEvent(nil, map[string]string{"event.domain": "k8s"}))
+	assert.True(t, isKubernetesEvent(
+		map[string]string{"k8s.object.kind": "Pod", "k8s.object.name": "catalog-5d8f7b9c4-x2x7z"},
+		map[string]string{"k8s.event.reason": "FailedScheduling"},
+	))
+	assert.False(t, isKubernetesEvent(
+		map[string]string{"service.name": "catalog"},
+		map[string]string{"k8s.event.reason": "FailedScheduling"},
+	))
+	assert.False(t, isKubernetesEvent(map[string]string{"k8s.object.kind": "Pod"}, map[string]string{}))
+}
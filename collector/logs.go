@@ -66,6 +66,7 @@ func (c *Collector) Logs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c.getLogsBatch(project).Add(req)
+	c.getK8sEventsBatch(project).Add(req)
 
 	resp := &v1.ExportLogsServiceResponse{}
 	w.Header().Set("Content-Type", contentType)
@@ -175,6 +176,9 @@ func (b *LogsBatch) Add(req *v1.ExportLogsServiceRequest) {
 			scopeVersion := sl.GetScope().GetVersion()
 			for _, lr := range sl.GetLogRecords() {
 				logAttributes := attributesToMap(lr.GetAttributes())
+				if isKubernetesEvent(resourceAttributes, logAttributes) {
+					continue
+				}
 				if scopeName != "" {
 					logAttributes[semconv.AttributeOtelScopeName] = scopeName
 				}
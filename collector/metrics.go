@@ -2,6 +2,7 @@ package collector
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -10,6 +11,8 @@ import (
 	"net/url"
 	"time"
 
+	"codexray/db"
+
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/prometheus/prometheus/prompb"
@@ -59,6 +62,7 @@ func addLabelsIfNeeded(r *http.Request, extraLabels map[string]string) (io.Reade
 	return bytes.NewBuffer(snappy.Encode(nil, decompressed)), nil
 }
 
+// Metrics accepts Prometheus remote-write requests and OTLP metrics. Both are forwarded to the project's Prometheus via remote-write.
 func (c *Collector) Metrics(w http.ResponseWriter, r *http.Request) {
 	project, err := c.getProject(r.Header.Get(ApiKeyHeader))
 	if err != nil {
@@ -71,19 +75,12 @@ func (c *Collector) Metrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	cfg := project.PrometheusConfig(c.globalPrometheus)
-	u, err := url.Parse(cfg.Url)
-	if err != nil {
-		klog.Errorln(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 
-	if cfg.BasicAuth != nil {
-		u.User = url.UserPassword(cfg.BasicAuth.User, cfg.BasicAuth.Password)
+	if isOtlpMetricsRequest(r) {
+		c.otlpMetrics(w, r, cfg)
+		return
 	}
 
-	u = u.JoinPath("/api/v1/write")
-
 	body, err := addLabelsIfNeeded(r, cfg.ExtraLabels)
 	if err != nil {
 		klog.Errorln(err)
@@ -91,17 +88,42 @@ func (c *Collector) Metrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	req, err := http.NewRequestWithContext(r.Context(), r.Method, u.String(), body)
+	res, err := remoteWrite(r.Context(), cfg, r.Method, body, r.Header)
 	if err != nil {
 		klog.Errorln(err)
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	for k, vs := range res.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(res.StatusCode)
+	_, _ = io.Copy(w, res.Body)
+}
+
+func remoteWrite(ctx context.Context, cfg *db.IntegrationsPrometheus, method string, body io.Reader, header http.Header) (*http.Response, error) {
+	u, err := url.Parse(cfg.Url)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.BasicAuth != nil {
+		u.User = url.UserPassword(cfg.BasicAuth.User, cfg.BasicAuth.Password)
+	}
+	u = u.JoinPath("/api/v1/write")
 
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
 	for _, h := range cfg.CustomHeaders {
 		req.Header.Add(h.Key, h.Value)
 	}
-	for k, vs := range r.Header {
+	for k, vs := range header {
 		if k == ApiKeyHeader {
 			continue
 		}
@@ -114,18 +136,5 @@ func (c *Collector) Metrics(w http.ResponseWriter, r *http.Request) {
 	if cfg.TlsSkipVerify {
 		httpClient = insecureClient
 	}
-	res, err := httpClient.Do(req)
-	if err != nil {
-		klog.Errorln(err)
-		http.Error(w, "", http.StatusInternalServerError)
-		return
-	}
-	for k, vs := range res.Header {
-		for _, v := range vs {
-			w.Header().Add(k, v)
-		}
-	}
-	w.WriteHeader(res.StatusCode)
-	_, _ = io.Copy(w, r.Body)
-	_ = res.Body.Close()
+	return httpClient.Do(req)
 }
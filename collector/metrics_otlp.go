@@ -0,0 +1,338 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"codexray/db"
+
+	gogoproto "github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	semconv "go.opentelemetry.io/collector/semconv/v1.18.0"
+	v1 "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog"
+)
+
+// isOtlpMetricsRequest distinguishes OTLP requests from Prometheus remote-write ones,
+// which are always snappy-compressed protobuf messages.
+func isOtlpMetricsRequest(r *http.Request) bool {
+	switch r.Header.Get("Content-Type") {
+	case "application/json":
+		return true
+	case "application/x-protobuf":
+		return r.Header.Get("Content-Encoding") != "snappy" && r.Header.Get("X-Prometheus-Remote-Write-Version") == ""
+	}
+	return false
+}
+
+func (c *Collector) otlpMetrics(w http.ResponseWriter, r *http.Request, cfg *db.IntegrationsPrometheus) {
+	contentType := r.Header.Get("Content-Type")
+	decoder, err := getDecoder(r.Header.Get("Content-Encoding"), r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(decoder)
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	req := &v1.ExportMetricsServiceRequest{}
+	switch contentType {
+	case "application/x-protobuf":
+		err = proto.Unmarshal(data, req)
+	case "application/json":
+		err = protojson.Unmarshal(data, req)
+	}
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	series, rejected := otlpToTimeseries(req, cfg.ExtraLabels)
+	if len(series) > 0 {
+		status, err := writeTimeseries(r.Context(), cfg, series)
+		if err != nil {
+			klog.Errorln(err)
+			if status == 0 {
+				http.Error(w, "", http.StatusBadGateway)
+				return
+			}
+			// OTLP clients retry on 429 and 5xx, so the status is passed through
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+
+	resp := &v1.ExportMetricsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &v1.ExportMetricsPartialSuccess{
+			RejectedDataPoints: rejected,
+			ErrorMessage:       "only cumulative sums and histograms are supported",
+		}
+	}
+	switch contentType {
+	case "application/x-protobuf":
+		data, err = proto.Marshal(resp)
+	case "application/json":
+		data, err = protojson.Marshal(resp)
+	}
+	if err != nil {
+		klog.Errorln(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(data)
+}
+
+// writeTimeseries sends the series via remote-write. It returns the response status, if any, along with the error.
+func writeTimeseries(ctx context.Context, cfg *db.IntegrationsPrometheus, series []prompb.TimeSeries) (int, error) {
+	data, err := gogoproto.Marshal(&prompb.WriteRequest{Timeseries: series})
+	if err != nil {
+		return 0, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-protobuf")
+	header.Set("Content-Encoding", "snappy")
+	header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	res, err := remoteWrite(ctx, cfg, http.MethodPost, bytes.NewReader(snappy.Encode(nil, data)), header)
+	if err != nil {
+		return 0, err
+	}
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	_ = res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return res.StatusCode, fmt.Errorf("remote-write failed: %s %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return res.StatusCode, nil
+}
+
+// otlpToTimeseries converts OTLP metrics to Prometheus samples the way the Prometheus OTLP receiver does:
+// service.name and service.instance.id become the job and instance labels, the other resource attributes
+// are exposed via target_info, and histograms are converted to the _bucket, _sum, and _count series.
+// Exponential histograms are converted to classic ones, so native histograms support is not required.
+// Delta sums and histograms cannot be represented as Prometheus samples, so they are counted as rejected.
+func otlpToTimeseries(req *v1.ExportMetricsServiceRequest, extraLabels map[string]string) ([]prompb.TimeSeries, int64) {
+	var res []prompb.TimeSeries
+	var rejected int64
+	for _, rm := range req.GetResourceMetrics() {
+		resource := attributesToMap(rm.GetResource().GetAttributes())
+		target := map[string]string{}
+		if name := resource[semconv.AttributeServiceName]; name != "" {
+			if ns := resource[semconv.AttributeServiceNamespace]; ns != "" {
+				name = ns + "/" + name
+			}
+			target["job"] = name
+		}
+		if instance := resource[semconv.AttributeServiceInstanceID]; instance != "" {
+			target["instance"] = instance
+		}
+		s := &otlpSeries{target: target, extraLabels: extraLabels}
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				rejected += s.addMetric(m)
+			}
+		}
+		if len(s.series) == 0 {
+			continue
+		}
+		info := map[string]string{}
+		for k, v := range resource {
+			switch k {
+			case semconv.AttributeServiceName, semconv.AttributeServiceNamespace, semconv.AttributeServiceInstanceID:
+			default:
+				info[k] = v
+			}
+		}
+		if len(info) > 0 {
+			s.add("target_info", info, nil, 1, s.lastTs)
+		}
+		res = append(res, s.series...)
+	}
+	return res, rejected
+}
+
+type otlpSeries struct {
+	target      map[string]string
+	extraLabels map[string]string
+	series      []prompb.TimeSeries
+	lastTs      int64
+}
+
+func (s *otlpSeries) addMetric(m *metricsv1.Metric) int64 {
+	name := prometheusName(m.GetName(), false)
+	switch data := m.Data.(type) {
+	case *metricsv1.Metric_Gauge:
+		for _, p := range data.Gauge.GetDataPoints() {
+			s.add(name, attributesToMap(p.GetAttributes()), nil, numberValue(p), pointTs(p.GetTimeUnixNano(), p.GetFlags()))
+		}
+	case *metricsv1.Metric_Sum:
+		if data.Sum.GetIsMonotonic() {
+			if data.Sum.GetAggregationTemporality() == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+				return int64(len(data.Sum.GetDataPoints()))
+			}
+			if !strings.HasSuffix(name, "_total") {
+				name += "_total"
+			}
+		}
+		for _, p := range data.Sum.GetDataPoints() {
+			s.add(name, attributesToMap(p.GetAttributes()), nil, numberValue(p), pointTs(p.GetTimeUnixNano(), p.GetFlags()))
+		}
+	case *metricsv1.Metric_Histogram:
+		if data.Histogram.GetAggregationTemporality() == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+			return int64(len(data.Histogram.GetDataPoints()))
+		}
+		for _, p := range data.Histogram.GetDataPoints() {
+			attrs := attributesToMap(p.GetAttributes())
+			ts := pointTs(p.GetTimeUnixNano(), p.GetFlags())
+			var cumulative uint64
+			for i, bound := range p.GetExplicitBounds() {
+				if i < len(p.GetBucketCounts()) {
+					cumulative += p.GetBucketCounts()[i]
+				}
+				s.addBucket(name, attrs, bound, cumulative, ts)
+			}
+			s.addHistogramTotals(name, attrs, p.Sum, p.GetCount(), ts)
+		}
+	case *metricsv1.Metric_ExponentialHistogram:
+		if data.ExponentialHistogram.GetAggregationTemporality() == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+			return int64(len(data.ExponentialHistogram.GetDataPoints()))
+		}
+		for _, p := range data.ExponentialHistogram.GetDataPoints() {
+			attrs := attributesToMap(p.GetAttributes())
+			ts := pointTs(p.GetTimeUnixNano(), p.GetFlags())
+			// the bucket with the index i covers (base^i, base^(i+1)] for positive values and [-base^(i+1), -base^i) for negative ones
+			base := math.Pow(2, math.Pow(2, -float64(p.GetScale())))
+			var cumulative uint64
+			negative := p.GetNegative()
+			for i := len(negative.GetBucketCounts()) - 1; i >= 0; i-- {
+				cumulative += negative.GetBucketCounts()[i]
+				s.addBucket(name, attrs, -math.Pow(base, float64(negative.GetOffset())+float64(i)), cumulative, ts)
+			}
+			cumulative += p.GetZeroCount()
+			s.addBucket(name, attrs, p.GetZeroThreshold(), cumulative, ts)
+			positive := p.GetPositive()
+			for i, count := range positive.GetBucketCounts() {
+				cumulative += count
+				s.addBucket(name, attrs, math.Pow(base, float64(positive.GetOffset())+float64(i)+1), cumulative, ts)
+			}
+			s.addHistogramTotals(name, attrs, p.Sum, p.GetCount(), ts)
+		}
+	case *metricsv1.Metric_Summary:
+		for _, p := range data.Summary.GetDataPoints() {
+			attrs := attributesToMap(p.GetAttributes())
+			ts := pointTs(p.GetTimeUnixNano(), p.GetFlags())
+			for _, q := range p.GetQuantileValues() {
+				s.add(name, attrs, map[string]string{"quantile": formatFloat(q.GetQuantile())}, q.GetValue(), ts)
+			}
+			s.add(name+"_sum", attrs, nil, p.GetSum(), ts)
+			s.add(name+"_count", attrs, nil, float64(p.GetCount()), ts)
+		}
+	}
+	return 0
+}
+
+func (s *otlpSeries) addBucket(name string, attrs map[string]string, le float64, count uint64, ts int64) {
+	s.add(name+"_bucket", attrs, map[string]string{"le": formatFloat(le)}, float64(count), ts)
+}
+
+func (s *otlpSeries) addHistogramTotals(name string, attrs map[string]string, sum *float64, count uint64, ts int64) {
+	s.addBucket(name, attrs, math.Inf(1), count, ts)
+	if sum != nil {
+		s.add(name+"_sum", attrs, nil, *sum, ts)
+	}
+	s.add(name+"_count", attrs, nil, float64(count), ts)
+}
+
+// add appends a sample. The attributes are sanitized, while the special labels, like le, are added as is.
+// Points having the no recorded value flag get ts = -1 and are written as stale markers.
+func (s *otlpSeries) add(name string, attrs, special map[string]string, v float64, ts int64) {
+	if ts < 0 {
+		ts = -ts
+		v = math.Float64frombits(value.StaleNaN)
+	}
+	s.lastTs = max(s.lastTs, ts)
+	labels := map[string]string{}
+	for k, v := range attrs {
+		labels[prometheusName(k, true)] = v
+	}
+	for _, ls := range []map[string]string{s.target, special, s.extraLabels} {
+		for k, v := range ls {
+			labels[k] = v
+		}
+	}
+	labels["__name__"] = name
+	s.series = append(s.series, newSeries(labels, v, ts))
+}
+
+// newSeries returns a series with a single sample and the labels sorted by name, as remote-write requires.
+func newSeries(labels map[string]string, v float64, ts int64) prompb.TimeSeries {
+	series := prompb.TimeSeries{Samples: []prompb.Sample{{Value: v, Timestamp: ts}}}
+	for k, v := range labels {
+		if v != "" {
+			series.Labels = append(series.Labels, prompb.Label{Name: k, Value: v})
+		}
+	}
+	slices.SortFunc(series.Labels, func(a, b prompb.Label) int { return strings.Compare(a.Name, b.Name) })
+	return series
+}
+
+func numberValue(p *metricsv1.NumberDataPoint) float64 {
+	if _, ok := p.Value.(*metricsv1.NumberDataPoint_AsInt); ok {
+		return float64(p.GetAsInt())
+	}
+	return p.GetAsDouble()
+}
+
+// pointTs returns the timestamp in milliseconds, negative for the points having no recorded value.
+func pointTs(unixNano uint64, flags uint32) int64 {
+	ts := time.Now().UnixMilli()
+	if unixNano > 0 {
+		ts = int64(unixNano / uint64(time.Millisecond))
+	}
+	if flags&uint32(metricsv1.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0 {
+		return -ts
+	}
+	return ts
+}
+
+// prometheusName replaces the characters not allowed in Prometheus metric or label names with underscores.
+func prometheusName(name string, label bool) string {
+	res := []byte(name)
+	for i, c := range res {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		case c == ':' && !label:
+		default:
+			res[i] = '_'
+		}
+	}
+	if len(name) > 0 && name[0] >= '0' && name[0] <= '9' {
+		return "_" + string(res)
+	}
+	return string(res)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
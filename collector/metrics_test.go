@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	v1 "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func TestIsOtlpMetricsRequest(t *testing.T) {
+	r := func(contentType, encoding string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "/v1/metrics", nil)
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("Content-Encoding", encoding)
+		return r
+	}
+	assert.True(t, isOtlpMetricsRequest(r("application/json", "gzip")))
+	assert.True(t, isOtlpMetricsRequest(r("application/x-protobuf", "gzip")))
+	assert.True(t, isOtlpMetricsRequest(r("application/x-protobuf", "")))
+	assert.False(t, isOtlpMetricsRequest(r("application/x-protobuf", "snappy")))
+	assert.False(t, isOtlpMetricsRequest(r("", "")))
+}
+
+func TestOtlpToTimeseries(t *testing.T) {
+	attr := func(k, v string) *commonv1.KeyValue {
+		return &commonv1.KeyValue{Key: k, Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: v}}}
+	}
+	ts := uint64(1700000000123000000)
+	cumulative := metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	sum := 7.5
+	req := &v1.ExportMetricsServiceRequest{ResourceMetrics: []*metricsv1.ResourceMetrics{{
+		Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{
+			attr("service.name", "catalog"), attr("service.namespace", "shop"), attr("service.instance.id", "catalog-1"), attr("k8s.pod.name", "catalog-5d8f7"),
+		}},
+		ScopeMetrics: []*metricsv1.ScopeMetrics{{Metrics: []*metricsv1.Metric{
+			{Name: "process.cpu.utilization", Data: &metricsv1.Metric_Gauge{Gauge: &metricsv1.Gauge{DataPoints: []*metricsv1.NumberDataPoint{
+				{TimeUnixNano: ts, Value: &metricsv1.NumberDataPoint_AsDouble{AsDouble: 0.5}, Attributes: []*commonv1.KeyValue{attr("cpu.state", "user")}},
+				{TimeUnixNano: ts, Flags: uint32(metricsv1.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK), Attributes: []*commonv1.KeyValue{attr("cpu.state", "system")}},
+			}}}},
+			{Name: "http.requests", Data: &metricsv1.Metric_Sum{Sum: &metricsv1.Sum{IsMonotonic: true, AggregationTemporality: cumulative, DataPoints: []*metricsv1.NumberDataPoint{
+				{TimeUnixNano: ts, Value: &metricsv1.NumberDataPoint_AsInt{AsInt: 42}},
+			}}}},
+			{Name: "http.errors", Data: &metricsv1.Metric_Sum{Sum: &metricsv1.Sum{IsMonotonic: true, AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, DataPoints: []*metricsv1.NumberDataPoint{
+				{TimeUnixNano: ts, Value: &metricsv1.NumberDataPoint_AsInt{AsInt: 1}},
+			}}}},
+			{Name: "http.duration", Data: &metricsv1.Metric_Histogram{Histogram: &metricsv1.Histogram{AggregationTemporality: cumulative, DataPoints: []*metricsv1.HistogramDataPoint{
+				{TimeUnixNano: ts, Count: 6, Sum: &sum, ExplicitBounds: []float64{0.1, 1}, BucketCounts: []uint64{3, 2, 1}},
+			}}}},
+			{Name: "db.duration", Data: &metricsv1.Metric_ExponentialHistogram{ExponentialHistogram: &metricsv1.ExponentialHistogram{AggregationTemporality: cumulative, DataPoints: []*metricsv1.ExponentialHistogramDataPoint{
+				{TimeUnixNano: ts, Count: 4, Scale: 0, ZeroCount: 1, Positive: &metricsv1.ExponentialHistogramDataPoint_Buckets{Offset: 1, BucketCounts: []uint64{2, 1}}},
+			}}}},
+		}}},
+	}}}
+
+	series, rejected := otlpToTimeseries(req, map[string]string{"codexray_project_id": "p1"})
+	assert.Equal(t, int64(1), rejected, "delta sums are rejected")
+
+	samples := map[string]float64{}
+	for _, s := range series {
+		var labels []string
+		for _, l := range s.Labels {
+			if l.Name != "job" && l.Name != "instance" && l.Name != "codexray_project_id" {
+				labels = append(labels, l.Name+"="+l.Value)
+			}
+		}
+		assert.Contains(t, s.Labels, prompb.Label{Name: "job", Value: "shop/catalog"})
+		assert.Contains(t, s.Labels, prompb.Label{Name: "instance", Value: "catalog-1"})
+		assert.Contains(t, s.Labels, prompb.Label{Name: "codexray_project_id", Value: "p1"})
+		assert.Equal(t, int64(1700000000123), s.Samples[0].Timestamp)
+		samples[strings.Join(labels, ",")] = s.Samples[0].Value
+	}
+	assert.True(t, value.IsStaleNaN(samples["__name__=process_cpu_utilization,cpu_state=system"]))
+	delete(samples, "__name__=process_cpu_utilization,cpu_state=system")
+	assert.Equal(t, map[string]float64{
+		"__name__=process_cpu_utilization,cpu_state=user": 0.5,
+		"__name__=http_requests_total":                    42,
+		"__name__=http_duration_bucket,le=0.1":            3,
+		"__name__=http_duration_bucket,le=1":              5,
+		"__name__=http_duration_bucket,le=+Inf":           6,
+		"__name__=http_duration_sum":                      7.5,
+		"__name__=http_duration_count":                    6,
+		"__name__=db_duration_bucket,le=0":                1,
+		"__name__=db_duration_bucket,le=4":                3,
+		"__name__=db_duration_bucket,le=8":                4,
+		"__name__=db_duration_bucket,le=+Inf":             4,
+		"__name__=db_duration_count":                      4,
+		"__name__=target_info,k8s_pod_name=catalog-5d8f7": 1,
+	}, samples)
+}
@@ -263,6 +263,24 @@ ORDER BY (ServiceName, PageName, toUnixTimestamp(Timestamp))
 SETTINGS index_granularity=8192, ttl_only_drop_parts = 1
 `,
 
+		`
+CREATE TABLE IF NOT EXISTS k8s_events @on_cluster (
+     Timestamp DateTime64(9) CODEC(Delta, ZSTD(1)),
+     Uid String CODEC(ZSTD(1)),
+     Type LowCardinality(String) CODEC(ZSTD(1)),
+     Reason LowCardinality(String) CODEC(ZSTD(1)),
+     Namespace LowCardinality(String) CODEC(ZSTD(1)),
+     ObjectKind LowCardinality(String) CODEC(ZSTD(1)),
+     ObjectName String CODEC(ZSTD(1)),
+     Count Int64 CODEC(ZSTD(1)),
+     Message String CODEC(ZSTD(1)),
+     INDEX idx_object_name ObjectName TYPE bloom_filter(0.01) GRANULARITY 1
+) ENGINE @merge_tree
+TTL toDateTime(Timestamp) + toIntervalDay(@ttl_days)
+PARTITION BY toDate(Timestamp)
+ORDER BY (Namespace, ObjectKind, Type, toUnixTimestamp(Timestamp))
+SETTINGS index_granularity=8192, ttl_only_drop_parts = 1`,
+
 		`
 CREATE TABLE IF NOT EXISTS err_log_data (
     UniqueId      String CODEC(ZSTD(1)),
@@ -305,6 +323,9 @@ SETTINGS index_granularity = 8192;
 		`CREATE TABLE IF NOT EXISTS otel_traces_trace_id_ts_distributed ON CLUSTER @cluster AS otel_traces_trace_id_ts
 			ENGINE = Distributed(@cluster, currentDatabase(), otel_traces_trace_id_ts)`,
 
+		`CREATE TABLE IF NOT EXISTS k8s_events_distributed ON CLUSTER @cluster AS k8s_events
+			ENGINE = Distributed(@cluster, currentDatabase(), k8s_events, rand())`,
+
 		`CREATE TABLE IF NOT EXISTS profiling_stacks_distributed ON CLUSTER @cluster AS profiling_stacks
 		ENGINE = Distributed(@cluster, currentDatabase(), profiling_stacks, Hash)`,
 
@@ -317,7 +338,7 @@ SETTINGS index_granularity = 8192;
 )
 
 func ReplaceTables(query string, distributed bool) string {
-	tbls := []string{"otel_logs", "otel_traces", "otel_traces_trace_id_ts", "profiling_stacks", "profiling_samples", "profiling_profiles"}
+	tbls := []string{"otel_logs", "otel_traces", "otel_traces_trace_id_ts", "profiling_stacks", "profiling_samples", "profiling_profiles", "k8s_events"}
 	for _, t := range tbls {
 		placeholder := "@@table_" + t + "@@"
 		if distributed {
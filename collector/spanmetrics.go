@@ -0,0 +1,218 @@
+package collector
+
+import (
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"codexray/utils"
+
+	"github.com/prometheus/prometheus/prompb"
+	semconv "go.opentelemetry.io/collector/semconv/v1.18.0"
+	v1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"k8s.io/klog"
+)
+
+const (
+	spanMetricsFlushInterval = 15 * time.Second
+	// spanMetricsServiceTTL is how long the series of a service that has stopped sending spans are still written
+	spanMetricsServiceTTL = 15 * time.Minute
+	// spanMetricsMaxOperations limits the number of span names per service,
+	// the spans of the other operations are accounted under spanMetricsOtherOperation
+	spanMetricsMaxOperations  = 100
+	spanMetricsOtherOperation = "other"
+
+	// the names are used by the constructor's span SLI queries
+	spanRequestsMetric = "codexray_span_requests_total"
+	spanDurationMetric = "codexray_span_duration_seconds"
+
+	// spanMetricsInstanceLabel tells apart the series of the codexray replicas receiving spans,
+	// since each of them has its own counters
+	spanMetricsInstanceLabel = "codexray_instance"
+)
+
+// spanDurationBuckets are the same as the buckets of the request duration histograms of the agent,
+// so the latency objectives are applicable to both sources
+var spanDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// SpanMetrics derives request, error, and latency metrics from the inbound spans (server and consumer ones),
+// so the services the agent doesn't cover, such as Fargate tasks or serverless functions, get SLIs too.
+// The metrics are cumulative, like the ones of the OpenTelemetry spanmetrics connector,
+// and are written via remote-write every flush interval.
+type SpanMetrics struct {
+	extraLabels map[string]string
+	write       func(series []prompb.TimeSeries) error
+
+	lock     sync.Mutex
+	done     chan struct{}
+	services map[string]*spanService
+}
+
+type spanService struct {
+	operations map[string]*spanStats
+	lastSeen   time.Time
+}
+
+type spanStats struct {
+	ok, failed float64
+	// buckets are the non-cumulative counts of the spans by spanDurationBuckets, the last one is +Inf
+	buckets []float64
+	sum     float64
+}
+
+func NewSpanMetrics(interval time.Duration, extraLabels map[string]string, write func(series []prompb.TimeSeries) error) *SpanMetrics {
+	labels := maps.Clone(extraLabels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[spanMetricsInstanceLabel] = spanMetricsInstance()
+	m := &SpanMetrics{
+		extraLabels: labels,
+		write:       write,
+		done:        make(chan struct{}),
+		services:    map[string]*spanService{},
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-ticker.C:
+				m.flush()
+			}
+		}
+	}()
+
+	return m
+}
+
+func (m *SpanMetrics) Close() {
+	m.done <- struct{}{}
+	m.flush()
+}
+
+func (m *SpanMetrics) Add(req *v1.ExportTraceServiceRequest) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, rs := range req.GetResourceSpans() {
+		serviceName := attributesToMap(rs.GetResource().GetAttributes())[semconv.AttributeServiceName]
+		if serviceName == "" {
+			continue
+		}
+		service := m.services[serviceName]
+		if service == nil {
+			service = &spanService{operations: map[string]*spanStats{}}
+			m.services[serviceName] = service
+		}
+		service.lastSeen = time.Now()
+		operations := service.operations
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				switch s.GetKind() {
+				case tracev1.Span_SPAN_KIND_SERVER, tracev1.Span_SPAN_KIND_CONSUMER:
+				default:
+					continue
+				}
+				operation := s.GetName()
+				if operations[operation] == nil && len(operations) >= spanMetricsMaxOperations {
+					operation = spanMetricsOtherOperation
+				}
+				stats := operations[operation]
+				if stats == nil {
+					stats = &spanStats{buckets: make([]float64, len(spanDurationBuckets)+1)}
+					operations[operation] = stats
+				}
+				if s.GetStatus().GetCode() == tracev1.Status_STATUS_CODE_ERROR {
+					stats.failed++
+				} else {
+					stats.ok++
+				}
+				var duration float64
+				if end, start := s.GetEndTimeUnixNano(), s.GetStartTimeUnixNano(); end > start {
+					duration = time.Duration(end - start).Seconds()
+				}
+				i, _ := slices.BinarySearch(spanDurationBuckets, duration)
+				stats.buckets[i]++
+				stats.sum += duration
+			}
+		}
+	}
+}
+
+func (m *SpanMetrics) flush() {
+	now := time.Now()
+	m.expire(now.Add(-spanMetricsServiceTTL))
+	series := m.series(now.UnixMilli())
+	if len(series) == 0 {
+		return
+	}
+	if err := m.write(series); err != nil {
+		klog.Errorln("failed to write span metrics:", err)
+	}
+}
+
+// expire drops the services that haven't sent spans since the deadline, so their series are no longer written.
+func (m *SpanMetrics) expire(deadline time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for name, service := range m.services {
+		if service.lastSeen.Before(deadline) {
+			delete(m.services, name)
+		}
+	}
+}
+
+func (m *SpanMetrics) series(ts int64) []prompb.TimeSeries {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var res []prompb.TimeSeries
+	for service, s := range m.services {
+		for operation, stats := range s.operations {
+			labels := func(name string, kv ...string) map[string]string {
+				ls := map[string]string{"__name__": name, "service_name": service, "span_name": operation}
+				for k, v := range m.extraLabels {
+					ls[k] = v
+				}
+				for i := 0; i+1 < len(kv); i += 2 {
+					ls[kv[i]] = kv[i+1]
+				}
+				return ls
+			}
+			res = append(res,
+				newSeries(labels(spanRequestsMetric, "status", "ok"), stats.ok, ts),
+				newSeries(labels(spanRequestsMetric, "status", "failed"), stats.failed, ts),
+			)
+			var cumulative float64
+			for i, count := range stats.buckets {
+				cumulative += count
+				le := math.Inf(1)
+				if i < len(spanDurationBuckets) {
+					le = spanDurationBuckets[i]
+				}
+				res = append(res, newSeries(labels(spanDurationMetric+"_bucket", "le", formatFloat(le)), cumulative, ts))
+			}
+			res = append(res,
+				newSeries(labels(spanDurationMetric+"_sum"), stats.sum, ts),
+				newSeries(labels(spanDurationMetric+"_count"), cumulative, ts),
+			)
+		}
+	}
+	return res
+}
+
+// spanMetricsInstance returns the value of spanMetricsInstanceLabel identifying this process.
+func spanMetricsInstance() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return utils.NanoId(8)
+}
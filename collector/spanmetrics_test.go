@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	v1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestSpanMetrics(t *testing.T) {
+	m := NewSpanMetrics(time.Hour, map[string]string{"codexray_project_id": "p1"}, func(series []prompb.TimeSeries) error { return nil })
+	defer m.Close()
+
+	start := uint64(1700000000000000000)
+	span := func(kind tracev1.Span_SpanKind, name string, duration time.Duration, status tracev1.Status_StatusCode) *tracev1.Span {
+		return &tracev1.Span{
+			Kind: kind, Name: name, StartTimeUnixNano: start, EndTimeUnixNano: start + uint64(duration),
+			Status: &tracev1.Status{Code: status},
+		}
+	}
+	m.Add(&v1.ExportTraceServiceRequest{ResourceSpans: []*tracev1.ResourceSpans{{
+		Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{
+			{Key: "service.name", Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: "checkout"}}},
+		}},
+		ScopeSpans: []*tracev1.ScopeSpans{{Spans: []*tracev1.Span{
+			span(tracev1.Span_SPAN_KIND_SERVER, "POST /checkout", 3*time.Millisecond, tracev1.Status_STATUS_CODE_OK),
+			span(tracev1.Span_SPAN_KIND_SERVER, "POST /checkout", 100*time.Millisecond, tracev1.Status_STATUS_CODE_UNSET),
+			span(tracev1.Span_SPAN_KIND_SERVER, "POST /checkout", 20*time.Second, tracev1.Status_STATUS_CODE_ERROR),
+			span(tracev1.Span_SPAN_KIND_CLIENT, "SELECT orders", time.Millisecond, tracev1.Status_STATUS_CODE_ERROR),
+		}}},
+	}}})
+
+	samples := map[string]float64{}
+	for _, s := range m.series(1700000015000) {
+		var labels []string
+		for _, l := range s.Labels {
+			switch l.Name {
+			case "codexray_project_id", "service_name", "span_name":
+				assert.Contains(t, []string{"p1", "checkout", "POST /checkout"}, l.Value)
+			case spanMetricsInstanceLabel:
+				assert.NotEmpty(t, l.Value, "the series of different replicas don't overlap")
+			default:
+				labels = append(labels, fmt.Sprintf("%s=%s", l.Name, l.Value))
+			}
+		}
+		assert.Equal(t, int64(1700000015000), s.Samples[0].Timestamp)
+		samples[strings.Join(labels, ",")] = s.Samples[0].Value
+	}
+	assert.Len(t, samples, 2+len(spanDurationBuckets)+1+2, "client spans are not accounted")
+	assert.Equal(t, 2., samples["__name__=codexray_span_requests_total,status=ok"])
+	assert.Equal(t, 1., samples["__name__=codexray_span_requests_total,status=failed"])
+	assert.Equal(t, 1., samples["__name__=codexray_span_duration_seconds_bucket,le=0.005"])
+	assert.Equal(t, 2., samples["__name__=codexray_span_duration_seconds_bucket,le=0.1"])
+	assert.Equal(t, 2., samples["__name__=codexray_span_duration_seconds_bucket,le=10"])
+	assert.Equal(t, 3., samples["__name__=codexray_span_duration_seconds_bucket,le=+Inf"])
+	assert.Equal(t, 3., samples["__name__=codexray_span_duration_seconds_count"])
+	assert.InDelta(t, 20.103, samples["__name__=codexray_span_duration_seconds_sum"], 1e-9)
+
+	m.expire(time.Now().Add(-time.Minute))
+	assert.NotEmpty(t, m.series(1700000030000))
+	m.expire(time.Now().Add(time.Minute))
+	assert.Empty(t, m.series(1700000045000), "the services that stopped sending spans are expired")
+}
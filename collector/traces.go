@@ -65,6 +65,9 @@ func (c *Collector) Traces(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c.getTracesBatch(project).Add(req)
+	if project.PrometheusConfig(c.globalPrometheus).Url != "" {
+		c.getSpanMetrics(project).Add(req)
+	}
 
 	resp := &v1.ExportTraceServiceResponse{}
 	w.Header().Set("Content-Type", contentType)
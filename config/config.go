@@ -0,0 +1,148 @@
+// Package config exports project settings as a declarative YAML document and applies such documents,
+// so the configuration can be reviewed in Git and promoted from one codexray instance to another.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"codexray/db"
+	"codexray/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultAppKey is the key of the project-wide check configs that apply to applications without their own ones.
+const defaultAppKey = "default"
+
+// Project is the declarative configuration of a project.
+// A nil section is left untouched on apply, while an empty one removes everything it covers.
+type Project struct {
+	Name               string                                  `json:"name"`
+	Categories         map[model.ApplicationCategory]*Category `json:"categories"`
+	CustomApplications map[string][]string                     `json:"custom_applications"`
+	SLOs               map[string]map[model.CheckId]any        `json:"slos"`
+	Inspections        map[string]map[model.CheckId]any        `json:"inspections"`
+	Integrations       *Integrations                           `json:"integrations"`
+	ApiKeys            []db.ApiKey                             `json:"api_keys"`
+}
+
+type Category struct {
+	CustomPatterns      []string `json:"custom_patterns"`
+	NotifyOfDeployments bool     `json:"notify_of_deployments"`
+}
+
+// Integrations include the Prometheus integration, which is stored apart from the rest of the project settings.
+type Integrations struct {
+	db.Integrations
+	Prometheus *db.IntegrationsPrometheus `json:"prometheus,omitempty"`
+}
+
+// Export returns the current configuration of the project.
+func Export(database *db.DB, project *db.Project) (*Project, error) {
+	checkConfigs, err := database.GetCheckConfigs(project.Id)
+	if err != nil {
+		return nil, err
+	}
+	return export(project, checkConfigs)
+}
+
+func export(project *db.Project, checkConfigs model.CheckConfigs) (*Project, error) {
+	prometheus := project.Prometheus
+	res := &Project{
+		Name:               project.Name,
+		Categories:         map[model.ApplicationCategory]*Category{},
+		CustomApplications: map[string][]string{},
+		SLOs:               map[string]map[model.CheckId]any{},
+		Inspections:        map[string]map[model.CheckId]any{},
+		Integrations:       &Integrations{Integrations: project.Settings.Integrations, Prometheus: &prometheus},
+		ApiKeys:            append([]db.ApiKey{}, project.Settings.ApiKeys...),
+	}
+	category := func(c model.ApplicationCategory) *Category {
+		if res.Categories[c] == nil {
+			res.Categories[c] = &Category{}
+		}
+		return res.Categories[c]
+	}
+	for c, patterns := range project.Settings.ApplicationCategories {
+		category(c).CustomPatterns = patterns
+	}
+	for c, s := range project.Settings.ApplicationCategorySettings {
+		category(c).NotifyOfDeployments = s.NotifyOfDeployments
+	}
+	for name, app := range project.Settings.CustomApplications {
+		res.CustomApplications[name] = app.InstancePattens
+	}
+	for appId, configs := range checkConfigs {
+		key := defaultAppKey
+		if !appId.IsZero() {
+			key = appId.String()
+		}
+		for checkId, raw := range configs {
+			var cfg any
+			if err := json.Unmarshal(raw, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid config of %s for %s: %w", checkId, key, err)
+			}
+			section := res.Inspections
+			if isSLO(checkId) {
+				section = res.SLOs
+			}
+			if section[key] == nil {
+				section[key] = map[model.CheckId]any{}
+			}
+			section[key][checkId] = cfg
+		}
+	}
+	return res, nil
+}
+
+// Marshal renders the configuration as YAML. Credentials are replaced with references to environment variables,
+// so the document can be safely stored in Git.
+func Marshal(p *Project) ([]byte, error) {
+	tree, err := toTree(p)
+	if err != nil {
+		return nil, err
+	}
+	_ = walkSecrets(nil, tree, func(path []string, value string) (string, error) {
+		return secretRef(path), nil
+	})
+	var buf bytes.Buffer
+	e := yaml.NewEncoder(&buf)
+	e.SetIndent(2)
+	if err = e.Encode(tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// toTree converts the value into generic maps and slices keyed by the JSON field names.
+func toTree(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tree any
+	if err = json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func isSLO(id model.CheckId) bool {
+	return id == model.Checks.SLOAvailability.Id || id == model.Checks.SLOLatency.Id
+}
+
+func cloneCheckConfigs(cc model.CheckConfigs) model.CheckConfigs {
+	res := model.CheckConfigs{}
+	for appId, configs := range cc {
+		res[appId] = maps.Clone(configs)
+	}
+	return res
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testProject(t *testing.T) (*db.DB, *db.Project) {
+	database, err := db.Open(t.TempDir(), "")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	id, err := database.SaveProject(db.Project{Name: "staging"})
+	require.NoError(t, err)
+	p, err := database.GetProject(id)
+	require.NoError(t, err)
+	p.Settings.ApplicationCategories = map[model.ApplicationCategory][]string{"databases": {"db/*"}}
+	p.Settings.CustomApplications = map[string]model.CustomApplication{"gateway": {InstancePattens: []string{"gw-*"}}}
+	p.Settings.Integrations.Slack = &db.IntegrationSlack{Token: "xoxb-secret", DefaultChannel: "ops", Enabled: true}
+	p.Settings.ApiKeys = []db.ApiKey{{Key: "staging-key", Description: "default"}}
+	require.NoError(t, database.SaveProjectSettings(p))
+	appId := model.NewApplicationId("default", model.ApplicationKindDeployment, "api")
+	require.NoError(t, database.SaveCheckConfig(id, appId, model.Checks.SLOAvailability.Id,
+		[]model.CheckConfigSLOAvailability{{Custom: false, ObjectivePercentage: 99.5}}))
+	require.NoError(t, database.SaveCheckConfig(id, model.ApplicationIdZero, model.Checks.CPUNode.Id, model.CheckConfigSimple{Threshold: 90}))
+	p, err = database.GetProject(id)
+	require.NoError(t, err)
+	return database, p
+}
+
+func TestExportApply(t *testing.T) {
+	database, project := testProject(t)
+
+	cfg, err := Export(database, project)
+	require.NoError(t, err)
+	data, err := Marshal(cfg)
+	require.NoError(t, err)
+	doc := string(data)
+	assert.Contains(t, doc, "token: ${INTEGRATIONS_SLACK_TOKEN}")
+	assert.Contains(t, doc, "key: ${API_KEYS_0_KEY}")
+	assert.NotContains(t, doc, "xoxb-secret")
+	assert.NotContains(t, doc, "staging-key")
+	assert.Contains(t, doc, "default:Deployment:api:")
+
+	// unset references keep the current secrets, so an exported document changes nothing
+	plan, err := NewPlan(database, project, data, true)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Changes)
+
+	// the document creates an identical project elsewhere once the secrets are provided
+	_, err = NewPlan(database, nil, []byte(doc+"\n"), true)
+	require.Error(t, err)
+	t.Setenv("INTEGRATIONS_SLACK_TOKEN", "xoxb-prod")
+	t.Setenv("API_KEYS_0_KEY", "prod-key")
+	plan, err = NewPlan(database, nil, []byte(replaceName(doc, "prod")), true)
+	require.NoError(t, err)
+	id, err := plan.Apply(database)
+	require.NoError(t, err)
+	prod, err := database.GetProject(id)
+	require.NoError(t, err)
+	assert.Equal(t, "xoxb-prod", prod.Settings.Integrations.Slack.Token)
+	assert.Equal(t, []db.ApiKey{{Key: "prod-key", Description: "default"}}, prod.Settings.ApiKeys)
+	assert.Equal(t, []string{"db/*"}, prod.Settings.ApplicationCategories["databases"])
+	checkConfigs, err := database.GetCheckConfigs(id)
+	require.NoError(t, err)
+	cpu := checkConfigs.GetSimple(model.Checks.CPUNode.Id, model.ApplicationIdZero)
+	assert.Equal(t, float32(90), cpu.Threshold)
+
+	plan, err = NewPlan(database, prod, []byte(replaceName(doc, "prod")), true)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Changes, plan.String())
+}
+
+func TestPlanChanges(t *testing.T) {
+	database, project := testProject(t)
+
+	plan, err := NewPlan(database, project, []byte(`
+categories:
+  application:
+    notify_of_deployments: true
+custom_applications: {}
+inspections:
+  default:
+    CPUNode:
+      threshold: 80
+integrations:
+  slack:
+    token: xoxb-new
+    default_channel: ops
+    enabled: true
+`), true)
+	require.NoError(t, err)
+	assert.Equal(t, `- categories.databases.custom_patterns.0: "db/*"
+- categories.databases.notify_of_deployments: false
++ custom_applications: {}
+- custom_applications.gateway.0: "gw-*"
+~ inspections.default.CPUNode.threshold: 90 -> 80
+~ integrations.slack.token: "<hidden>" -> "<hidden>"
+`, plan.String())
+
+	_, err = plan.Apply(database)
+	require.NoError(t, err)
+	project, err = database.GetProject(project.Id)
+	require.NoError(t, err)
+	assert.Empty(t, project.Settings.CustomApplications)
+	assert.Equal(t, "xoxb-new", project.Settings.Integrations.Slack.Token)
+	assert.Equal(t, []db.ApiKey{{Key: "staging-key", Description: "default"}}, project.Settings.ApiKeys, "sections not in the document are kept")
+	checkConfigs, err := database.GetCheckConfigs(project.Id)
+	require.NoError(t, err)
+	assert.Len(t, checkConfigs.GetByCheck(model.Checks.SLOAvailability.Id), 1)
+
+	_, err = NewPlan(database, project, []byte(`
+slos:
+  default:
+    CPUNode: {threshold: 80}
+inspections:
+  not-an-app-id: {}
+unknown: 1
+`), true)
+	assert.ErrorContains(t, err, `unknown field "unknown"`)
+	_, err = NewPlan(database, project, []byte(`
+slos:
+  default:
+    CPUNode: {threshold: 80}
+inspections:
+  not-an-app-id: {}
+`), true)
+	assert.ErrorContains(t, err, "slos.default.CPUNode: the check doesn't belong to this section")
+	assert.ErrorContains(t, err, "inspections.not-an-app-id: invalid application id")
+}
+
+func TestSecretRefsWithoutEnv(t *testing.T) {
+	database, project := testProject(t)
+	t.Setenv("PG_CONNECTION_STRING", "postgres://secret")
+
+	// the reference an export puts in place of the secret keeps the current value
+	plan, err := NewPlan(database, project, []byte(`
+integrations:
+  slack:
+    token: ${INTEGRATIONS_SLACK_TOKEN}
+    default_channel: ops
+    enabled: true
+`), false)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Changes)
+
+	// any other reference would leak the server's environment
+	_, err = NewPlan(database, project, []byte(`
+integrations:
+  slack:
+    token: ${PG_CONNECTION_STRING}
+    default_channel: ops
+    enabled: true
+`), false)
+	assert.ErrorContains(t, err, "integrations.slack.token: environment variables are only resolved by the config apply command")
+
+	t.Setenv("INTEGRATIONS_SLACK_TOKEN", "xoxb-env")
+	plan, err = NewPlan(database, project, []byte(`
+integrations:
+  slack:
+    token: ${INTEGRATIONS_SLACK_TOKEN}
+    default_channel: ops
+    enabled: true
+`), false)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Changes, "the environment is not looked up")
+
+	project.Settings.Integrations.Webhook = &db.IntegrationWebhook{
+		Url:           "https://hooks.example.com/T0/secret",
+		CustomHeaders: []utils.Header{{Key: "Authorization", Value: "Bearer secret"}},
+		Incidents:     true,
+	}
+	require.NoError(t, database.SaveProjectSettings(project))
+	project, err = database.GetProject(project.Id)
+	require.NoError(t, err)
+	cfg, err := Export(database, project)
+	require.NoError(t, err)
+	data, err := Marshal(cfg)
+	require.NoError(t, err)
+	doc := string(data)
+	assert.Contains(t, doc, "url: ${INTEGRATIONS_WEBHOOK_URL}")
+	assert.Contains(t, doc, "key: Authorization")
+	assert.Contains(t, doc, "value: ${INTEGRATIONS_WEBHOOK_CUSTOM_HEADERS_0_VALUE}")
+	assert.NotContains(t, doc, "hooks.example.com")
+	assert.NotContains(t, doc, "Bearer secret")
+	plan, err = NewPlan(database, project, data, false)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Changes, plan.String())
+}
+
+func replaceName(doc, name string) string {
+	return strings.Replace(doc, "name: staging", "name: "+name, 1)
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+var categoryNameRe = regexp.MustCompile("^[-_0-9a-z]{3,}$")
+
+// Plan is the difference between the current configuration of a project and a configuration document.
+type Plan struct {
+	// Project is nil if the project doesn't exist and is to be created.
+	Project *db.Project
+
+	Current *Project
+	Planned *Project
+	Changes []db.AuditLogChange
+
+	desired *Project
+}
+
+// NewPlan parses the document and compares it with the current configuration of the project.
+// The project is nil if the document is to be applied to a new project named after the document.
+// resolveEnv allows the secret references to be resolved from the environment, see resolveSecrets.
+func NewPlan(database *db.DB, project *db.Project, data []byte, resolveEnv bool) (*Plan, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if _, ok := doc.(map[string]any); !ok {
+		return nil, errors.New("the document must be a mapping")
+	}
+
+	res := &Plan{Project: project}
+	target := &db.Project{}
+	checkConfigs := model.CheckConfigs{}
+	if project != nil {
+		var err error
+		// a fresh copy, since the settings are changed in place
+		if target, err = database.GetProject(project.Id); err != nil {
+			return nil, err
+		}
+		if checkConfigs, err = database.GetCheckConfigs(project.Id); err != nil {
+			return nil, err
+		}
+	}
+	target.ApplyDefaults()
+	current, err := export(target, checkConfigs)
+	if err != nil {
+		return nil, err
+	}
+	currentTree, err := toTree(current)
+	if err != nil {
+		return nil, err
+	}
+	if err = resolveSecrets(doc, currentTree, resolveEnv); err != nil {
+		return nil, err
+	}
+	if res.desired, err = decode(doc); err != nil {
+		return nil, err
+	}
+	if project == nil {
+		if res.desired.Name == "" {
+			return nil, errors.New("name: the project name is required to create a project")
+		}
+		target.Name = res.desired.Name
+		current.Name = res.desired.Name
+	}
+
+	planned, err := res.desired.applyTo(target, checkConfigs)
+	if err != nil {
+		return nil, err
+	}
+	res.Current = current
+	if res.Planned, err = export(target, planned); err != nil {
+		return nil, err
+	}
+	if res.Changes, err = db.AuditLogDiff(res.Current, res.Planned); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Apply makes the changes, creating the project if needed. Applying the same document again changes nothing.
+func (p *Plan) Apply(database *db.DB) (db.ProjectId, error) {
+	var id db.ProjectId
+	if p.Project != nil {
+		id = p.Project.Id
+		if len(p.Changes) == 0 {
+			return id, nil
+		}
+	} else {
+		var err error
+		if id, err = database.SaveProject(db.Project{Name: p.desired.Name}); err != nil {
+			return "", err
+		}
+	}
+	project, err := database.GetProject(id)
+	if err != nil {
+		return "", err
+	}
+	checkConfigs, err := database.GetCheckConfigs(id)
+	if err != nil {
+		return "", err
+	}
+	planned, err := p.desired.applyTo(project, checkConfigs)
+	if err != nil {
+		return "", err
+	}
+	if err = database.SaveProjectSettings(project); err != nil {
+		return "", err
+	}
+	if err = database.SaveProjectIntegration(project, db.IntegrationTypePrometheus); err != nil {
+		return "", err
+	}
+	for appId, configs := range planned {
+		for checkId, raw := range configs {
+			if !bytes.Equal(checkConfigs[appId][checkId], raw) {
+				if err = database.SaveCheckConfig(id, appId, checkId, raw); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	for appId, configs := range checkConfigs {
+		for checkId := range configs {
+			if _, ok := planned[appId][checkId]; !ok {
+				if err = database.SaveCheckConfig(id, appId, checkId, nil); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	return id, nil
+}
+
+// String renders the changes as a diff, one changed field per line.
+func (p *Plan) String() string {
+	if len(p.Changes) == 0 {
+		return "No changes.\n"
+	}
+	format := func(v any) string {
+		var buf bytes.Buffer
+		e := json.NewEncoder(&buf)
+		e.SetEscapeHTML(false)
+		_ = e.Encode(v)
+		return strings.TrimSpace(buf.String())
+	}
+	var b strings.Builder
+	for _, c := range p.Changes {
+		switch {
+		case c.Before == nil:
+			fmt.Fprintf(&b, "+ %s: %s\n", c.Path, format(c.After))
+		case c.After == nil:
+			fmt.Fprintf(&b, "- %s: %s\n", c.Path, format(c.Before))
+		default:
+			fmt.Fprintf(&b, "~ %s: %s -> %s\n", c.Path, format(c.Before), format(c.After))
+		}
+	}
+	return b.String()
+}
+
+func decode(doc any) (*Project, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var p Project
+	if err = decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	if err = p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *Project) validate() error {
+	var errs []error
+	for _, c := range sortedKeys(p.Categories) {
+		if !categoryNameRe.MatchString(string(c)) {
+			errs = append(errs, fmt.Errorf("categories.%s: invalid category name", c))
+		}
+		if p.Categories[c] == nil {
+			continue
+		}
+		patterns := p.Categories[c].CustomPatterns
+		if !utils.GlobValidate(patterns) || slices.ContainsFunc(patterns, func(p string) bool { return strings.Count(p, "/") != 1 || strings.Index(p, "/") < 1 }) {
+			errs = append(errs, fmt.Errorf("categories.%s: patterns must be in the <namespace>/<name> format", c))
+		}
+	}
+	for _, name := range sortedKeys(p.CustomApplications) {
+		if name == "" || !utils.GlobValidate(p.CustomApplications[name]) {
+			errs = append(errs, fmt.Errorf("custom_applications.%s: invalid patterns", name))
+		}
+	}
+	for _, slo := range []bool{true, false} {
+		section, configs := "inspections", p.Inspections
+		if slo {
+			section, configs = "slos", p.SLOs
+		}
+		for _, key := range sortedKeys(configs) {
+			if key != defaultAppKey {
+				if _, err := model.NewApplicationIdFromString(key); err != nil {
+					errs = append(errs, fmt.Errorf("%s.%s: invalid application id", section, key))
+					continue
+				}
+			}
+			for _, checkId := range sortedKeys(configs[key]) {
+				if isSLO(checkId) != slo {
+					errs = append(errs, fmt.Errorf("%s.%s.%s: the check doesn't belong to this section", section, key, checkId))
+					continue
+				}
+				raw, err := json.Marshal(configs[key][checkId])
+				if err == nil {
+					err = model.ValidateCheckConfig(checkId, raw)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s.%s.%s: %w", section, key, checkId, err))
+				}
+			}
+		}
+	}
+	keys := map[string]bool{}
+	for i, k := range p.ApiKeys {
+		switch {
+		case k.Key == "":
+			errs = append(errs, fmt.Errorf("api_keys.%d.key: the key is required", i))
+		case keys[k.Key]:
+			errs = append(errs, fmt.Errorf("api_keys.%d.key: duplicate key", i))
+		}
+		keys[k.Key] = true
+	}
+	return errors.Join(errs...)
+}
+
+// applyTo replaces the settings covered by the non-nil sections and returns the resulting check configs.
+func (p *Project) applyTo(project *db.Project, checkConfigs model.CheckConfigs) (model.CheckConfigs, error) {
+	s := &project.Settings
+	if p.Categories != nil {
+		s.ApplicationCategories = map[model.ApplicationCategory][]string{}
+		s.ApplicationCategorySettings = map[model.ApplicationCategory]db.ApplicationCategorySettings{}
+		for c, cfg := range p.Categories {
+			if cfg == nil {
+				cfg = &Category{}
+			}
+			if len(cfg.CustomPatterns) > 0 {
+				s.ApplicationCategories[c] = cfg.CustomPatterns
+			}
+			s.ApplicationCategorySettings[c] = db.ApplicationCategorySettings{NotifyOfDeployments: cfg.NotifyOfDeployments}
+		}
+	}
+	if p.CustomApplications != nil {
+		s.CustomApplications = map[string]model.CustomApplication{}
+		for name, patterns := range p.CustomApplications {
+			s.CustomApplications[name] = model.CustomApplication{InstancePattens: patterns}
+		}
+	}
+	if p.Integrations != nil {
+		s.Integrations = p.Integrations.Integrations
+		if p.Integrations.Prometheus != nil {
+			project.Prometheus = *p.Integrations.Prometheus
+		}
+	}
+	if p.ApiKeys != nil {
+		s.ApiKeys = p.ApiKeys
+	}
+	project.ApplyDefaults()
+
+	res := cloneCheckConfigs(checkConfigs)
+	for _, slo := range []bool{true, false} {
+		configs := p.Inspections
+		if slo {
+			configs = p.SLOs
+		}
+		if configs == nil {
+			continue
+		}
+		for appId := range res {
+			for checkId := range res[appId] {
+				if isSLO(checkId) == slo {
+					delete(res[appId], checkId)
+				}
+			}
+		}
+		for key, checks := range configs {
+			var appId model.ApplicationId
+			if key != defaultAppKey {
+				appId, _ = model.NewApplicationIdFromString(key)
+			}
+			for checkId, cfg := range checks {
+				raw, err := json.Marshal(cfg)
+				if err != nil {
+					return nil, err
+				}
+				if res[appId] == nil {
+					res[appId] = map[model.CheckId]json.RawMessage{}
+				}
+				res[appId][checkId] = raw
+			}
+		}
+	}
+	for appId, configs := range res {
+		if len(configs) == 0 {
+			delete(res, appId)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"codexray/db"
+)
+
+var (
+	secretRefRe     = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)}$`)
+	secretRefCharRe = regexp.MustCompile(`[^A-Z0-9_]`)
+)
+
+// secretRef returns the reference to the environment variable expected to hold the secret at the path,
+// e.g., ${INTEGRATIONS_SLACK_TOKEN}.
+func secretRef(path []string) string {
+	name := secretRefCharRe.ReplaceAllString(strings.ToUpper(strings.Join(path, "_")), "_")
+	return "${" + name + "}"
+}
+
+// resolveSecrets replaces the secret references of the document with the values of the environment variables.
+// If a variable is not set, the current value of the field is kept, so a document exported from the same
+// instance can be applied as is.
+// Unless resolveEnv is set, which only the config apply command does, the environment is not looked up:
+// a document received via the API must not be able to read the server's variables. In this case, only the
+// reference an export puts in place of the field's secret is accepted, and it keeps the current value.
+func resolveSecrets(doc, current any, resolveEnv bool) error {
+	return walkSecrets(nil, doc, func(path []string, value string) (string, error) {
+		m := secretRefRe.FindStringSubmatch(value)
+		if m == nil {
+			return value, nil
+		}
+		if !resolveEnv && value != secretRef(path) {
+			return "", fmt.Errorf("%s: environment variables are only resolved by the config apply command, use %s to keep the current value", strings.Join(path, "."), secretRef(path))
+		}
+		if resolveEnv {
+			if v, ok := os.LookupEnv(m[1]); ok {
+				return v, nil
+			}
+		}
+		if v, ok := lookup(current, path).(string); ok && v != "" {
+			return v, nil
+		}
+		if !resolveEnv {
+			return "", fmt.Errorf("%s: there is no current value to keep", strings.Join(path, "."))
+		}
+		return "", fmt.Errorf("%s: the environment variable %s is not set", strings.Join(path, "."), m[1])
+	})
+}
+
+// walkSecrets replaces each non-empty string value of a field that looks like a credential with the result of f.
+func walkSecrets(path []string, v any, f func(path []string, value string) (string, error)) error {
+	var errs []error
+	switch vv := v.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(vv) {
+			p := append(append([]string{}, path...), k)
+			if s, ok := vv[k].(string); ok && s != "" && db.IsSecretField(strings.Join(p, ".")) {
+				s, err := f(p, s)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				vv[k] = s
+				continue
+			}
+			errs = append(errs, walkSecrets(p, vv[k], f))
+		}
+	case []any:
+		for i, item := range vv {
+			errs = append(errs, walkSecrets(append(append([]string{}, path...), strconv.Itoa(i)), item, f))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func lookup(tree any, path []string) any {
+	for _, k := range path {
+		switch t := tree.(type) {
+		case map[string]any:
+			tree = t[k]
+		case []any:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil
+			}
+			tree = t[i]
+		default:
+			return nil
+		}
+	}
+	return tree
+}
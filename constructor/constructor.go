@@ -12,6 +12,7 @@ import (
 	pricing "codexray/cloud-pricing"
 	"codexray/db"
 	"codexray/model"
+	"codexray/prom"
 	"codexray/timeseries"
 	"codexray/utils"
 
@@ -36,12 +37,17 @@ type Cache interface {
 	GetStep(from, to timeseries.Time) (timeseries.Duration, error)
 }
 
+type KubernetesEventsSource interface {
+	GetKubernetesEvents(ctx context.Context, from, to timeseries.Time) ([]*model.KubernetesEvent, error)
+}
+
 type Constructor struct {
-	db      *db.DB
-	project *db.Project
-	cache   Cache
-	pricing *pricing.Manager
-	options map[Option]bool
+	db        *db.DB
+	project   *db.Project
+	cache     Cache
+	pricing   *pricing.Manager
+	k8sEvents KubernetesEventsSource
+	options   map[Option]bool
 }
 
 func New(db *db.DB, project *db.Project, cache Cache, pricing *pricing.Manager, options ...Option) *Constructor {
@@ -52,6 +58,10 @@ func New(db *db.DB, project *db.Project, cache Cache, pricing *pricing.Manager,
 	return c
 }
 
+func (c *Constructor) SetKubernetesEventsSource(src KubernetesEventsSource) {
+	c.k8sEvents = src
+}
+
 type QueryStats struct {
 	MetricsCount int     `json:"metrics_count"`
 	QueryTime    float32 `json:"query_time"`
@@ -85,6 +95,8 @@ func (c *Constructor) LoadWorld(ctx context.Context, from, to timeseries.Time, s
 	if rawStep == 0 {
 		return model.NewWorld(from, to, step, step), nil
 	}
+	// the older part of a long range may be downsampled to a step coarser than the requested one
+	step = max(step, rawStep)
 	w := model.NewWorld(from, to, step, rawStep)
 	w.CustomApplications = c.project.Settings.CustomApplications
 	w.Categories = maps.Keys(c.project.Settings.ApplicationCategories)
@@ -144,6 +156,8 @@ func (c *Constructor) LoadWorld(ctx context.Context, from, to timeseries.Time, s
 	prof.stage("load_app_logs", func() { c.loadApplicationLogs(w, metrics) })
 	prof.stage("load_app_deployments", func() { c.loadApplicationDeployments(w) })
 	prof.stage("load_app_incidents", func() { c.loadApplicationIncidents(w) })
+	prof.stage("load_maintenance_windows", func() { c.loadMaintenanceWindows(w) })
+	prof.stage("load_k8s_events", func() { c.loadKubernetesEvents(ctx, w) })
 	prof.stage("calc_app_events", func() { calcAppEvents(w) })
 
 	klog.Infof("%s: got %d nodes, %d apps in %s", c.project.Id, len(w.Nodes), len(w.Applications), time.Since(start).Truncate(time.Millisecond))
@@ -209,6 +223,9 @@ func (c *Constructor) queryCache(ctx context.Context, from, to timeseries.Time,
 
 	addQuery(qRecordingRuleInboundRequestsTotal, qRecordingRuleInboundRequestsTotal, qRecordingRuleInboundRequestsTotal, true)
 	addQuery(qRecordingRuleInboundRequestsHistogram, qRecordingRuleInboundRequestsHistogram, qRecordingRuleInboundRequestsHistogram, true)
+	for n, q := range spanQueries {
+		addQuery(n, n, q, true)
+	}
 
 	for appId := range checkConfigs {
 		qName := fmt.Sprintf("%s/%s/", qApplicationCustomSLI, appId)
@@ -291,6 +308,19 @@ func (c *Constructor) loadApplicationDeployments(w *model.World) {
 	}
 }
 
+func (c *Constructor) loadMaintenanceWindows(w *model.World) {
+	windows, err := c.db.GetMaintenanceWindows(c.project.Id)
+	if err != nil {
+		klog.Errorln(err)
+		return
+	}
+	for _, mw := range windows {
+		if len(mw.Intervals(w.Ctx.From, w.Ctx.To)) > 0 {
+			w.MaintenanceWindows = append(w.MaintenanceWindows, mw)
+		}
+	}
+}
+
 func (c *Constructor) loadApplicationIncidents(w *model.World) {
 	byApp, err := c.db.GetApplicationIncidents(c.project.Id, w.Ctx.From, w.Ctx.To)
 	if err != nil {
@@ -324,7 +354,7 @@ func loadPromJobStatuses(metrics map[string][]model.MetricValues, statuses promJ
 }
 
 type podId struct {
-	name, ns string
+	name, ns, cluster string
 }
 
 func enrichInstances(w *model.World, metrics map[string][]model.MetricValues, rdsInstancesById map[string]*model.Instance, ecInstanceById map[string]*model.Instance) {
@@ -333,7 +363,7 @@ func enrichInstances(w *model.World, metrics map[string][]model.MetricValues, rd
 	for _, app := range w.Applications {
 		for _, i := range app.Instances {
 			if i.Pod != nil {
-				instancesByPod[podId{name: i.Name, ns: app.Id.Namespace}] = i
+				instancesByPod[podId{name: i.Name, ns: app.Id.Namespace, cluster: app.Id.Cluster}] = i
 			}
 			for l := range i.TcpListens {
 				instancesByListen[l] = i
@@ -402,7 +432,7 @@ func joinDBClusterComponents(w *model.World) {
 			if instance.ClusterName.Value() == "" {
 				continue
 			}
-			id := model.NewApplicationId(app.Id.Namespace, model.ApplicationKindDatabaseCluster, instance.ClusterName.Value())
+			id := model.NewApplicationId(app.Id.Namespace, model.ApplicationKindDatabaseCluster, instance.ClusterName.Value()).InCluster(app.Id.Cluster)
 			cluster := clusters[id]
 			if cluster == nil {
 				cluster = model.NewApplication(id)
@@ -474,7 +504,7 @@ func findInstance(instancesByPod map[podId]*model.Instance, instancesByListen ma
 		}
 	}
 	if ns, pod := guessNamespace(ls), guessPod(ls); ns != "" && pod != "" {
-		return getActualServiceInstance(instancesByPod[podId{name: pod, ns: ns}], applicationTypes...)
+		return getActualServiceInstance(instancesByPod[podId{name: pod, ns: ns, cluster: ls[prom.ClusterLabel]}], applicationTypes...)
 	}
 	return nil
 }
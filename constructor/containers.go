@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"codexray/model"
+	"codexray/prom"
 	"codexray/timeseries"
 	"codexray/utils"
 
@@ -14,12 +15,17 @@ import (
 )
 
 type instanceId struct {
-	ns   string
-	name string
-	node model.NodeId
+	ns      string
+	name    string
+	node    model.NodeId
+	cluster string
 }
 
-func (c *Constructor) getInstanceAndContainer(w *model.World, node *model.Node, instances map[instanceId]*model.Instance, containerId string) (*model.Instance, *model.Container) {
+func newInstanceId(instance *model.Instance) instanceId {
+	return instanceId{ns: instance.Owner.Id.Namespace, name: instance.Name, node: instance.NodeId(), cluster: instance.Owner.Id.Cluster}
+}
+
+func (c *Constructor) getInstanceAndContainer(w *model.World, node *model.Node, instances map[instanceId]*model.Instance, containerId, cluster string) (*model.Instance, *model.Container) {
 	var nodeId model.NodeId
 	var nodeName string
 	if node != nil {
@@ -37,7 +43,7 @@ func (c *Constructor) getInstanceAndContainer(w *model.World, node *model.Node,
 		w.IntegrationStatus.KubeStateMetrics.Required = true
 		ns, pod := parts[2], parts[3]
 		containerName = parts[4]
-		instance = instances[instanceId{ns: ns, name: pod, node: nodeId}]
+		instance = instances[instanceId{ns: ns, name: pod, node: nodeId, cluster: cluster}]
 		if instance == nil {
 			return nil, nil
 		}
@@ -80,13 +86,14 @@ func (c *Constructor) getInstanceAndContainer(w *model.World, node *model.Node,
 		id.ns = "_"
 	}
 	id.node = nodeId
+	id.cluster = cluster
 	instance = instances[id]
 	if instance == nil {
 		customApp := c.project.GetCustomApplicationName(id.name)
 		if customApp != "" {
 			appId.Name = customApp
 		}
-		instance = w.GetOrCreateApplication(appId, customApp != "").GetOrCreateInstance(id.name, node)
+		instance = w.GetOrCreateApplication(appId.InCluster(cluster), customApp != "").GetOrCreateInstance(id.name, node)
 		instances[id] = instance
 	}
 	return instance, instance.GetOrCreateContainer(containerId, containerName)
@@ -101,11 +108,7 @@ func (c *Constructor) loadContainers(w *model.World, metrics map[string][]model.
 	instances := map[instanceId]*model.Instance{}
 	for _, a := range w.Applications {
 		for _, i := range a.Instances {
-			var nodeId model.NodeId
-			if i.Node != nil {
-				nodeId = i.Node.Id
-			}
-			instances[instanceId{ns: a.Id.Namespace, name: i.Name, node: nodeId}] = i
+			instances[newInstanceId(i)] = i
 		}
 	}
 
@@ -118,7 +121,7 @@ func (c *Constructor) loadContainers(w *model.World, metrics map[string][]model.
 			v, ok := containers[m.NodeContainerId]
 			if !ok {
 				nodeId := model.NewNodeIdFromLabels(m)
-				v.instance, v.container = c.getInstanceAndContainer(w, nodesByID[nodeId], instances, m.ContainerId)
+				v.instance, v.container = c.getInstanceAndContainer(w, nodesByID[nodeId], instances, m.ContainerId, m.Labels[prom.ClusterLabel])
 				containers[m.NodeContainerId] = v
 			}
 			if v.instance == nil || v.container == nil {
@@ -171,7 +174,7 @@ func (c *Constructor) loadContainers(w *model.World, metrics map[string][]model.
 		container.Restarts = merge(container.Restarts, timeseries.Increase(metric.Values, pjs.get(metric.Labels)), timeseries.Any)
 	})
 	loadContainer("container_net_latency", func(instance *model.Instance, container *model.Container, metric model.MetricValues) {
-		id := instanceId{ns: instance.Owner.Id.Namespace, name: instance.Name, node: instance.NodeId()}
+		id := newInstanceId(instance)
 		rtts := rttByInstance[id]
 		if rtts == nil {
 			rtts = map[string]*timeseries.TimeSeries{}
@@ -363,7 +366,7 @@ func (c *Constructor) loadContainers(w *model.World, metrics map[string][]model.
 						u.RemoteInstance = instancesByListen[l]
 					}
 				}
-				if upstreams, ok := rttByInstance[instanceId{ns: instance.Owner.Id.Namespace, name: instance.Name, node: instance.NodeId()}]; ok {
+				if upstreams, ok := rttByInstance[newInstanceId(instance)]; ok {
 					u.Rtt = merge(u.Rtt, upstreams[u.ActualRemoteIP], timeseries.Any)
 				}
 				if svc := servicesByClusterIP[u.ServiceRemoteIP]; svc != nil {
@@ -1,6 +1,7 @@
 package constructor
 
 import (
+	"fmt"
 	"sort"
 
 	"codexray/model"
@@ -12,6 +13,7 @@ func calcAppEvents(w *model.World) {
 		var events []*model.ApplicationEvent
 		events = append(events, calcClusterSwitchovers(app)...)
 		events = append(events, calcUpDownEvents(app)...)
+		events = append(events, calcKubernetesWarningEvents(app)...)
 		for _, d := range app.Deployments {
 			if d.StartedAt.Before(w.Ctx.From) || d.StartedAt.After(w.Ctx.To) {
 				continue
@@ -33,6 +35,22 @@ func calcAppEvents(w *model.World) {
 	}
 }
 
+func calcKubernetesWarningEvents(app *model.Application) []*model.ApplicationEvent {
+	var events []*model.ApplicationEvent
+	for _, e := range app.KubernetesEvents {
+		if !e.IsWarning() {
+			continue
+		}
+		events = append(events, &model.ApplicationEvent{
+			Start:   e.FirstSeen,
+			End:     e.LastSeen,
+			Type:    model.ApplicationEventTypeKubernetesWarning,
+			Details: fmt.Sprintf("%s: %s", e.Reason, e.Object()),
+		})
+	}
+	return events
+}
+
 func calcUpDownEvents(app *model.Application) []*model.ApplicationEvent {
 	var events []*model.ApplicationEvent
 	for _, instance := range app.Instances {
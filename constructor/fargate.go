@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"codexray/model"
+	"codexray/prom"
 	"codexray/timeseries"
 )
 
@@ -67,12 +68,12 @@ func loadFargateContainers(w *model.World, metrics map[string][]model.MetricValu
 				for _, a := range w.Applications {
 					for _, i := range a.Instances {
 						if n := i.NodeName(); n != "" {
-							instances[instanceId{ns: a.Id.Namespace, name: i.Name, node: model.NewNodeId(n, n)}] = i
+							instances[instanceId{ns: a.Id.Namespace, name: i.Name, node: model.NewNodeId(n, n), cluster: a.Id.Cluster}] = i
 						}
 					}
 				}
 			}
-			instance := instances[instanceId{ns: ns, name: pod, node: model.NewNodeId(nodeName, nodeName)}]
+			instance := instances[instanceId{ns: ns, name: pod, node: model.NewNodeId(nodeName, nodeName), cluster: m.Labels[prom.ClusterLabel]}]
 			if instance == nil {
 				continue
 			}
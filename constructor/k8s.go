@@ -1,12 +1,14 @@
 package constructor
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"regexp"
 	"strings"
 
 	"codexray/model"
+	"codexray/prom"
 	"codexray/timeseries"
 
 	"k8s.io/klog"
@@ -16,6 +18,10 @@ var (
 	jobSuffixRe = regexp.MustCompile(`-([a-z0-9]{5}|\d{10,})$`)
 )
 
+type k8sObjectId struct {
+	ns, name, cluster string
+}
+
 func loadKubernetesMetadata(w *model.World, metrics map[string][]model.MetricValues, servicesByClusterIP map[string]*model.Service) {
 	loadServices(metrics["kube_service_info"], servicesByClusterIP)
 	pods := podInfo(w, metrics["kube_pod_info"])
@@ -32,6 +38,9 @@ func loadKubernetesMetadata(w *model.World, metrics map[string][]model.MetricVal
 		}
 	}
 	loadApplications(w, metrics)
+	loadHorizontalPodAutoscalers(w, metrics)
+	loadPodDisruptionBudgets(w, metrics)
+	loadResourceQuotas(w, metrics)
 }
 
 func loadServices(metrics []model.MetricValues, servicesByClusterIP map[string]*model.Service) {
@@ -70,7 +79,7 @@ func loadApplications(w *model.World, metrics map[string][]model.MetricValues) {
 			continue
 		}
 		for _, m := range metrics[queryName] {
-			app := w.GetApplication(model.NewApplicationId(m.Labels["namespace"], kind, m.Labels[nameLabel]))
+			app := w.GetApplication(model.NewApplicationId(m.Labels["namespace"], kind, m.Labels[nameLabel]).InCluster(m.Labels[prom.ClusterLabel]))
 			if app == nil {
 				continue
 			}
@@ -82,6 +91,113 @@ func loadApplications(w *model.World, metrics map[string][]model.MetricValues) {
 	}
 }
 
+func loadHorizontalPodAutoscalers(w *model.World, metrics map[string][]model.MetricValues) {
+	hpas := map[k8sObjectId]*model.HorizontalPodAutoscaler{}
+	for _, m := range metrics["kube_horizontalpodautoscaler_info"] {
+		ns, name, cluster := m.Labels["namespace"], m.Labels["horizontalpodautoscaler"], m.Labels[prom.ClusterLabel]
+		kind := model.ApplicationKind(m.Labels["scaletargetref_kind"])
+		app := w.GetApplication(model.NewApplicationId(ns, kind, m.Labels["scaletargetref_name"]).InCluster(cluster))
+		if app == nil {
+			continue
+		}
+		hpa := &model.HorizontalPodAutoscaler{Name: name}
+		app.HorizontalPodAutoscaler = hpa
+		hpas[k8sObjectId{ns: ns, name: name, cluster: cluster}] = hpa
+	}
+	for queryName := range metrics {
+		if !strings.HasPrefix(queryName, "kube_horizontalpodautoscaler_") {
+			continue
+		}
+		for _, m := range metrics[queryName] {
+			hpa := hpas[k8sObjectId{ns: m.Labels["namespace"], name: m.Labels["horizontalpodautoscaler"], cluster: m.Labels[prom.ClusterLabel]}]
+			if hpa == nil {
+				continue
+			}
+			switch queryName {
+			case "kube_horizontalpodautoscaler_spec_min_replicas":
+				hpa.MinReplicas = merge(hpa.MinReplicas, m.Values, timeseries.Any)
+			case "kube_horizontalpodautoscaler_spec_max_replicas":
+				hpa.MaxReplicas = merge(hpa.MaxReplicas, m.Values, timeseries.Any)
+			case "kube_horizontalpodautoscaler_status_current_replicas":
+				hpa.CurrentReplicas = merge(hpa.CurrentReplicas, m.Values, timeseries.Any)
+			case "kube_horizontalpodautoscaler_status_desired_replicas":
+				hpa.DesiredReplicas = merge(hpa.DesiredReplicas, m.Values, timeseries.Any)
+			}
+		}
+	}
+}
+
+// kube-state-metrics doesn't expose PDB selectors, so a budget is attributed to an application
+// by name: either the same name as the Deployment/StatefulSet or the name with a "-pdb" suffix.
+func loadPodDisruptionBudgets(w *model.World, metrics map[string][]model.MetricValues) {
+	pdbs := map[k8sObjectId]*model.PodDisruptionBudget{}
+	getPdb := func(ns, name, cluster string) *model.PodDisruptionBudget {
+		id := k8sObjectId{ns: ns, name: name, cluster: cluster}
+		if pdb, ok := pdbs[id]; ok {
+			return pdb
+		}
+		var app *model.Application
+		for _, kind := range []model.ApplicationKind{model.ApplicationKindDeployment, model.ApplicationKindStatefulSet} {
+			if app = w.GetApplication(model.NewApplicationId(ns, kind, strings.TrimSuffix(name, "-pdb")).InCluster(cluster)); app != nil {
+				break
+			}
+		}
+		if app == nil {
+			pdbs[id] = nil
+			return nil
+		}
+		pdb := &model.PodDisruptionBudget{Name: name}
+		app.PodDisruptionBudgets = append(app.PodDisruptionBudgets, pdb)
+		pdbs[id] = pdb
+		return pdb
+	}
+	for queryName := range metrics {
+		if !strings.HasPrefix(queryName, "kube_poddisruptionbudget_") {
+			continue
+		}
+		for _, m := range metrics[queryName] {
+			pdb := getPdb(m.Labels["namespace"], m.Labels["poddisruptionbudget"], m.Labels[prom.ClusterLabel])
+			if pdb == nil {
+				continue
+			}
+			switch queryName {
+			case "kube_poddisruptionbudget_status_pod_disruptions_allowed":
+				pdb.DisruptionsAllowed = merge(pdb.DisruptionsAllowed, m.Values, timeseries.Any)
+			case "kube_poddisruptionbudget_status_current_healthy":
+				pdb.CurrentHealthy = merge(pdb.CurrentHealthy, m.Values, timeseries.Any)
+			case "kube_poddisruptionbudget_status_desired_healthy":
+				pdb.DesiredHealthy = merge(pdb.DesiredHealthy, m.Values, timeseries.Any)
+			case "kube_poddisruptionbudget_status_expected_pods":
+				pdb.ExpectedPods = merge(pdb.ExpectedPods, m.Values, timeseries.Any)
+			}
+		}
+	}
+}
+
+func loadResourceQuotas(w *model.World, metrics map[string][]model.MetricValues) {
+	type quotaId struct {
+		ns, name, resource string
+	}
+	quotas := map[quotaId]*model.ResourceQuota{}
+	for _, queryName := range []string{"kube_resourcequota_hard", "kube_resourcequota_used"} {
+		for _, m := range metrics[queryName] {
+			id := quotaId{ns: m.Labels["namespace"], name: m.Labels["resourcequota"], resource: m.Labels["resource"]}
+			q := quotas[id]
+			if q == nil {
+				q = &model.ResourceQuota{Name: id.name, Resource: id.resource}
+				quotas[id] = q
+				w.ResourceQuotas[id.ns] = append(w.ResourceQuotas[id.ns], q)
+			}
+			switch queryName {
+			case "kube_resourcequota_hard":
+				q.Hard = merge(q.Hard, m.Values, timeseries.Any)
+			case "kube_resourcequota_used":
+				q.Used = merge(q.Used, m.Values, timeseries.Any)
+			}
+		}
+	}
+}
+
 func podInfo(w *model.World, metrics []model.MetricValues) map[string]*model.Instance {
 	pods := map[string]*model.Instance{}
 	podOwners := map[podId]model.ApplicationId{}
@@ -93,12 +209,13 @@ func podInfo(w *model.World, metrics []model.MetricValues) map[string]*model.Ins
 		ownerName := m.Labels["created_by_name"]
 		ownerKind := model.ApplicationKind(m.Labels["created_by_kind"])
 		nodeName := m.Labels["node"]
+		cluster := m.Labels[prom.ClusterLabel]
 		uid := m.Labels["uid"]
 		if uid == "" {
 			klog.Errorln("invalid 'kube_pod_info' metric: 'uid' label is empty")
 			continue
 		}
-		node := w.GetNode(nodeName)
+		node := w.GetClusterNode(cluster, nodeName)
 		var appId model.ApplicationId
 
 		switch {
@@ -111,7 +228,8 @@ func podInfo(w *model.World, metrics []model.MetricValues) map[string]*model.Ins
 		default:
 			continue
 		}
-		podOwners[podId{name: pod, ns: ns}] = appId
+		appId = appId.InCluster(cluster)
+		podOwners[podId{name: pod, ns: ns, cluster: cluster}] = appId
 		instance := pods[uid]
 		if instance == nil {
 			app := w.GetOrCreateApplication(appId, false)
@@ -146,7 +264,7 @@ func podInfo(w *model.World, metrics []model.MetricValues) map[string]*model.Ins
 		}
 	}
 	for _, instance := range podsOwnedByPods {
-		id := podId{name: instance.Owner.Id.Name, ns: instance.Owner.Id.Namespace}
+		id := podId{name: instance.Owner.Id.Name, ns: instance.Owner.Id.Namespace, cluster: instance.Owner.Id.Cluster}
 		if ownerOfOwner, ok := podOwners[id]; ok {
 			if app := w.GetApplication(ownerOfOwner); app != nil {
 				delete(w.Applications, instance.Owner.Id)
@@ -313,3 +431,39 @@ func podContainer(queryName string, metrics []model.MetricValues, pods map[strin
 		}
 	}
 }
+
+func (c *Constructor) loadKubernetesEvents(ctx context.Context, w *model.World) {
+	if c.k8sEvents == nil {
+		return
+	}
+	events, err := c.k8sEvents.GetKubernetesEvents(ctx, w.Ctx.From, w.Ctx.To)
+	if err != nil {
+		klog.Errorln(err)
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+	appsByPod := map[podId]*model.Application{}
+	for _, app := range w.Applications {
+		for _, i := range app.Instances {
+			if i.Pod != nil {
+				appsByPod[podId{name: i.Name, ns: app.Id.Namespace, cluster: app.Id.Cluster}] = app
+			}
+		}
+	}
+	for _, e := range events {
+		var app *model.Application
+		switch kind := model.ApplicationKind(e.ObjectKind); kind {
+		case model.ApplicationKindPod:
+			app = appsByPod[podId{name: e.ObjectName, ns: e.Namespace}]
+		case model.ApplicationKindDeployment, model.ApplicationKindReplicaSet, model.ApplicationKindStatefulSet,
+			model.ApplicationKindDaemonSet, model.ApplicationKindJob, model.ApplicationKindCronJob:
+			app = w.GetApplication(model.NewApplicationId(e.Namespace, kind, e.ObjectName))
+		}
+		if app == nil {
+			continue
+		}
+		app.KubernetesEvents = append(app.KubernetesEvents, e)
+	}
+}
@@ -0,0 +1,74 @@
+package constructor
+
+import (
+	"testing"
+
+	"codexray/model"
+	"codexray/prom"
+	"codexray/timeseries"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadKubernetesResources(t *testing.T) {
+	now := timeseries.Now()
+	w := model.NewWorld(now.Add(-timeseries.Hour), now, timeseries.Minute, timeseries.Minute)
+	api := w.GetOrCreateApplication(model.NewApplicationId("default", model.ApplicationKindDeployment, "api"), false)
+	pg := w.GetOrCreateApplication(model.NewApplicationId("default", model.ApplicationKindStatefulSet, "pg"), false)
+	remote := w.GetOrCreateApplication(model.NewApplicationId("default", model.ApplicationKindDeployment, "api").InCluster("eu"), false)
+
+	mv := func(v float32, kv ...string) model.MetricValues {
+		ls := model.Labels{}
+		for i := 0; i+1 < len(kv); i += 2 {
+			ls[kv[i]] = kv[i+1]
+		}
+		return model.MetricValues{Labels: ls, Values: timeseries.NewWithData(w.Ctx.From, w.Ctx.Step, []float32{v})}
+	}
+
+	hpa := func(v float32, kv ...string) model.MetricValues {
+		return mv(v, append([]string{"namespace", "default", "horizontalpodautoscaler", "api-hpa"}, kv...)...)
+	}
+	loadHorizontalPodAutoscalers(w, map[string][]model.MetricValues{
+		"kube_horizontalpodautoscaler_info": {
+			hpa(1, "scaletargetref_kind", "Deployment", "scaletargetref_name", "api"),
+			hpa(1, "scaletargetref_kind", "Deployment", "scaletargetref_name", "api", prom.ClusterLabel, "eu"),
+			hpa(1, "scaletargetref_kind", "Deployment", "scaletargetref_name", "unknown", "horizontalpodautoscaler", "unknown"),
+		},
+		"kube_horizontalpodautoscaler_spec_max_replicas":        {hpa(5), hpa(10, prom.ClusterLabel, "eu")},
+		"kube_horizontalpodautoscaler_status_current_replicas": {hpa(5), hpa(3, prom.ClusterLabel, "eu")},
+	})
+	require.NotNil(t, api.HorizontalPodAutoscaler)
+	assert.Equal(t, "api-hpa", api.HorizontalPodAutoscaler.Name)
+	assert.True(t, api.HorizontalPodAutoscaler.AtMaxReplicas())
+	require.NotNil(t, remote.HorizontalPodAutoscaler)
+	assert.False(t, remote.HorizontalPodAutoscaler.AtMaxReplicas(), "the HPAs of different clusters are not mixed up")
+	assert.Nil(t, pg.HorizontalPodAutoscaler)
+
+	pdb := func(name string, v float32) model.MetricValues {
+		return mv(v, "namespace", "default", "poddisruptionbudget", name)
+	}
+	loadPodDisruptionBudgets(w, map[string][]model.MetricValues{
+		"kube_poddisruptionbudget_status_pod_disruptions_allowed": {pdb("api-pdb", 0), pdb("pg", 1), pdb("other", 0)},
+		"kube_poddisruptionbudget_status_expected_pods":           {pdb("api-pdb", 3), pdb("pg", 3), pdb("other", 3)},
+	})
+	require.Len(t, api.PodDisruptionBudgets, 1)
+	assert.Equal(t, "api-pdb", api.PodDisruptionBudgets[0].Name)
+	assert.True(t, api.PodDisruptionBudgets[0].BlocksDisruptions())
+	require.Len(t, pg.PodDisruptionBudgets, 1)
+	assert.False(t, pg.PodDisruptionBudgets[0].BlocksDisruptions())
+	assert.Empty(t, remote.PodDisruptionBudgets)
+
+	quota := func(resource string, v float32) model.MetricValues {
+		return mv(v, "namespace", "default", "resourcequota", "compute", "resource", resource)
+	}
+	loadResourceQuotas(w, map[string][]model.MetricValues{
+		"kube_resourcequota_hard": {quota("requests.cpu", 4), quota("pods", 10)},
+		"kube_resourcequota_used": {quota("requests.cpu", 4), quota("pods", 5)},
+	})
+	usage := map[string]float32{}
+	for _, q := range w.ResourceQuotas["default"] {
+		usage[q.Name+"/"+q.Resource] = q.UsagePercent()
+	}
+	assert.Equal(t, map[string]float32{"compute/requests.cpu": 100, "compute/pods": 50}, usage)
+}
@@ -7,6 +7,7 @@ import (
 	"k8s.io/klog"
 
 	"codexray/model"
+	"codexray/prom"
 	"codexray/timeseries"
 )
 
@@ -29,6 +30,7 @@ func initNodesList(w *model.World, metrics map[string][]model.MetricValues, node
 		}
 		node.Name.Update(m.Values, name)
 		node.KernelVersion.Update(m.Values, m.Labels["kernel_version"])
+		node.Cluster.Update(m.Values, m.Labels[prom.ClusterLabel])
 	}
 	for _, m := range metrics["kube_node_info"] {
 		name := m.Labels["node"]
@@ -45,6 +47,9 @@ func initNodesList(w *model.World, metrics map[string][]model.MetricValues, node
 			nodesBySystemUUID[node.Id.SystemUUID] = node
 		}
 		node.K8sName.Update(m.Values, name)
+		if node.Cluster.Value() == "" {
+			node.Cluster.Update(m.Values, m.Labels[prom.ClusterLabel])
+		}
 		if node.KernelVersion.Value() == "" {
 			node.KernelVersion.Update(m.Values, m.Labels["kernel_version"])
 		}
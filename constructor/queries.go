@@ -16,8 +16,18 @@ const (
 	qRecordingRuleInboundRequestsTotal     = "rr_application_inbound_requests_total"
 	qRecordingRuleInboundRequestsHistogram = "rr_application_inbound_requests_histogram"
 	qRecordingRuleApplicationLogMessages   = "rr_application_log_messages"
+
+	// the span metrics are derived from the OpenTelemetry spans by the collector
+	qSpanRequestsTotal     = "span_requests_total"
+	qSpanRequestsHistogram = "span_requests_histogram"
 )
 
+// spanQueries sum up the series written by every codexray replica receiving spans
+var spanQueries = map[string]string{
+	qSpanRequestsTotal:     `sum by(service_name, status) (rate(codexray_span_requests_total[$RANGE]))`,
+	qSpanRequestsHistogram: `sum by(service_name, le) (rate(codexray_span_duration_seconds_bucket[$RANGE]))`,
+}
+
 var QUERIES = map[string]string{
 	"node_agent_info": `node_agent_info`,
 
@@ -138,6 +148,18 @@ var QUERIES = map[string]string{
 	"kube_daemonset_status_desired_number_scheduled":   `kube_daemonset_status_desired_number_scheduled`,
 	"kube_statefulset_replicas":                        `kube_statefulset_replicas`,
 
+	"kube_horizontalpodautoscaler_info":                       `kube_horizontalpodautoscaler_info`,
+	"kube_horizontalpodautoscaler_spec_min_replicas":          `kube_horizontalpodautoscaler_spec_min_replicas`,
+	"kube_horizontalpodautoscaler_spec_max_replicas":          `kube_horizontalpodautoscaler_spec_max_replicas`,
+	"kube_horizontalpodautoscaler_status_current_replicas":    `kube_horizontalpodautoscaler_status_current_replicas`,
+	"kube_horizontalpodautoscaler_status_desired_replicas":    `kube_horizontalpodautoscaler_status_desired_replicas`,
+	"kube_poddisruptionbudget_status_pod_disruptions_allowed": `kube_poddisruptionbudget_status_pod_disruptions_allowed`,
+	"kube_poddisruptionbudget_status_current_healthy":         `kube_poddisruptionbudget_status_current_healthy`,
+	"kube_poddisruptionbudget_status_desired_healthy":         `kube_poddisruptionbudget_status_desired_healthy`,
+	"kube_poddisruptionbudget_status_expected_pods":           `kube_poddisruptionbudget_status_expected_pods`,
+	"kube_resourcequota_hard":                                 `kube_resourcequota{type="hard"}`,
+	"kube_resourcequota_used":                                 `kube_resourcequota{type="used"}`,
+
 	"aws_discovery_error": `aws_discovery_error`,
 
 	"aws_rds_info":                        `aws_rds_info`,
@@ -234,6 +256,12 @@ var QUERIES = map[string]string{
 	"container_python_thread_lock_wait_time_seconds": `rate(container_python_thread_lock_wait_time_seconds[$RANGE])`,
 }
 
+// SummedQueries return increments rather than rates or gauges,
+// so their values are summed up rather than averaged when aggregated over time.
+var SummedQueries = map[string]bool{
+	qRecordingRuleApplicationLogMessages: true,
+}
+
 var RecordingRules = map[string]func(p *db.Project, w *model.World) []model.MetricValues{
 
 	qRecordingRuleInboundRequestsTotal: func(p *db.Project, w *model.World) []model.MetricValues {
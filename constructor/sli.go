@@ -14,12 +14,30 @@ import (
 func (c *Constructor) loadSLIs(w *model.World, metrics map[string][]model.MetricValues) {
 	builtinAvailabilityRaw := builtinAvailability(metrics[qRecordingRuleInboundRequestsTotal+"_raw"])
 	builtinLatencyRaw := builtinLatency(metrics[qRecordingRuleInboundRequestsHistogram+"_raw"])
+	spanAvailabilityRaw := spanAvailability(metrics[qSpanRequestsTotal+"_raw"])
+	spanLatencyRaw := spanLatency(metrics[qSpanRequestsHistogram+"_raw"])
+	spanServices := make([]string, 0, len(spanAvailabilityRaw))
+	for s := range spanAvailabilityRaw {
+		spanServices = append(spanServices, s)
+	}
+	sort.Strings(spanServices)
 
 	customAvailabilityRaw := map[model.ApplicationId]availabilitySlis{}
 	customLatencyRaw := map[model.ApplicationId][]model.HistogramBucket{}
 	loadCustomSLIs(metrics, customAvailabilityRaw, customLatencyRaw)
 
 	for _, app := range w.Applications {
+		// the services not covered by the agent, such as Fargate tasks or serverless functions,
+		// fall back to the metrics derived from their OpenTelemetry spans
+		spanService := ""
+		if len(spanServices) > 0 {
+			if app.Settings != nil && app.Settings.Tracing != nil {
+				spanService = app.Settings.Tracing.Service
+			} else {
+				spanService = model.GuessService(spanServices, app.Id)
+			}
+		}
+
 		availabilityCfg, _ := w.CheckConfigs.GetAvailability(app.Id)
 		if availabilityCfg.Custom {
 			raw := customAvailabilityRaw[app.Id]
@@ -30,6 +48,9 @@ func (c *Constructor) loadSLIs(w *model.World, metrics map[string][]model.Metric
 			})
 		} else {
 			raw := builtinAvailabilityRaw[app.Id]
+			if raw.total.IsEmpty() && spanService != "" {
+				raw = spanAvailabilityRaw[spanService]
+			}
 			if !raw.total.IsEmpty() {
 				app.AvailabilitySLIs = append(app.AvailabilitySLIs, &model.AvailabilitySLI{
 					Config:        availabilityCfg,
@@ -48,6 +69,9 @@ func (c *Constructor) loadSLIs(w *model.World, metrics map[string][]model.Metric
 			})
 		} else {
 			raw := builtinLatencyRaw[app.Id]
+			if len(raw) == 0 && spanService != "" {
+				raw = spanLatencyRaw[spanService]
+			}
 			if len(raw) > 0 {
 				app.LatencySLIs = append(app.LatencySLIs, &model.LatencySLI{
 					Config:    latencyCfg,
@@ -110,15 +134,48 @@ func builtinAvailability(values []model.MetricValues) map[model.ApplicationId]av
 	}
 	res := map[model.ApplicationId]availabilitySlis{}
 	for appId, byStatus := range byApp {
-		total := timeseries.NewAggregate(timeseries.NanSum)
-		failed := timeseries.NewAggregate(timeseries.NanSum)
-		for status, ts := range byStatus {
-			total.Add(ts)
-			if model.IsRequestStatusFailed(status) {
-				failed.Add(ts)
-			}
+		res[appId] = availabilityByStatus(byStatus)
+	}
+	return res
+}
+
+func availabilityByStatus(byStatus map[string]*timeseries.TimeSeries) availabilitySlis {
+	total := timeseries.NewAggregate(timeseries.NanSum)
+	failed := timeseries.NewAggregate(timeseries.NanSum)
+	for status, ts := range byStatus {
+		total.Add(ts)
+		if model.IsRequestStatusFailed(status) {
+			failed.Add(ts)
 		}
-		res[appId] = availabilitySlis{total: total.Get(), failed: failed.Get()}
+	}
+	return availabilitySlis{total: total.Get(), failed: failed.Get()}
+}
+
+func spanAvailability(values []model.MetricValues) map[string]availabilitySlis {
+	byService := map[string]map[string]*timeseries.TimeSeries{}
+	for _, mv := range values {
+		service := mv.Labels["service_name"]
+		if byService[service] == nil {
+			byService[service] = map[string]*timeseries.TimeSeries{}
+		}
+		byService[service][mv.Labels["status"]] = mv.Values
+	}
+	res := map[string]availabilitySlis{}
+	for service, byStatus := range byService {
+		res[service] = availabilityByStatus(byStatus)
+	}
+	return res
+}
+
+func spanLatency(values []model.MetricValues) map[string][]model.HistogramBucket {
+	byService := map[string][]model.MetricValues{}
+	for _, mv := range values {
+		service := mv.Labels["service_name"]
+		byService[service] = append(byService[service], mv)
+	}
+	res := map[string][]model.HistogramBucket{}
+	for service, mvs := range byService {
+		res[service] = histogramBuckets(mvs)
 	}
 	return res
 }
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"codexray/rbac"
+	"codexray/timeseries"
+	"codexray/utils"
+)
+
+const AccessTokenPrefix = "cxp_"
+
+// AccessToken is a personal access token used by scripts to call the API on behalf of a user.
+// Only the SHA-256 hash of the token is stored, so it is shown to the user only once upon creation.
+type AccessToken struct {
+	Id     string `json:"id"`
+	UserId int    `json:"user_id"`
+	Name   string `json:"name"`
+	// Hint is the beginning of the token that helps users to recognize it.
+	Hint string `json:"hint"`
+	// Permissions narrow down the permissions of the user's roles; if empty, the token has all of them.
+	Permissions rbac.PermissionSet `json:"permissions"`
+	CreatedAt   timeseries.Time    `json:"created_at"`
+	ExpiresAt   timeseries.Time    `json:"expires_at"`
+	LastUsedAt  timeseries.Time    `json:"last_used_at"`
+}
+
+func (t *AccessToken) Migrate(m *Migrator) error {
+	return m.Exec(`
+	CREATE TABLE IF NOT EXISTS access_token (
+		id TEXT NOT NULL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		hash TEXT NOT NULL UNIQUE,
+		hint TEXT NOT NULL,
+		permissions TEXT,
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL,
+		last_used_at INTEGER NOT NULL DEFAULT 0
+	)`)
+}
+
+// Allows reports whether the token doesn't restrict the action; the user's roles must allow it as well.
+func (t *AccessToken) Allows(action rbac.Action) bool {
+	return len(t.Permissions) == 0 || t.Permissions.Allows(action)
+}
+
+func hashAccessToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateAccessToken issues a new token and returns it in plain text; it can't be retrieved later.
+func (db *DB) CreateAccessToken(t *AccessToken) (string, error) {
+	token := AccessTokenPrefix + utils.RandomString(40)
+	t.Id = utils.NanoId(8)
+	t.Hint = token[:len(AccessTokenPrefix)+4]
+	t.CreatedAt = timeseries.Now()
+	var permissions *string
+	if len(t.Permissions) > 0 {
+		var err error
+		if permissions, err = marshal(&t.Permissions); err != nil {
+			return "", err
+		}
+	}
+	_, err := db.db.Exec(`
+		INSERT INTO access_token (id, user_id, name, hash, hint, permissions, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		t.Id, t.UserId, t.Name, hashAccessToken(token), t.Hint, permissions, t.CreatedAt, t.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetAccessTokens returns the tokens of the user, or the tokens of all users if userId is 0.
+func (db *DB) GetAccessTokens(userId int) ([]*AccessToken, error) {
+	q := "SELECT id, user_id, name, hint, permissions, created_at, expires_at, last_used_at FROM access_token"
+	var args []any
+	if userId != 0 {
+		q += " WHERE user_id = $1"
+		args = append(args, userId)
+	}
+	rows, err := db.db.Query(q+" ORDER BY created_at", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []*AccessToken
+	for rows.Next() {
+		var t AccessToken
+		var permissions sql.NullString
+		if err := rows.Scan(&t.Id, &t.UserId, &t.Name, &t.Hint, &permissions, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt); err != nil {
+			return nil, err
+		}
+		if err := t.unmarshalPermissions(permissions); err != nil {
+			return nil, err
+		}
+		res = append(res, &t)
+	}
+	return res, nil
+}
+
+// RevokeAccessToken deletes the token if it belongs to the user, or regardless of its owner if userId is 0.
+func (db *DB) RevokeAccessToken(userId int, id string) error {
+	q := "DELETE FROM access_token WHERE id = $1"
+	args := []any{id}
+	if userId != 0 {
+		q += " AND user_id = $2"
+		args = append(args, userId)
+	}
+	res, err := db.db.Exec(q, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AuthAccessToken returns the unexpired token matching the plain-text one and records its usage.
+func (db *DB) AuthAccessToken(token string) (*AccessToken, error) {
+	if !strings.HasPrefix(token, AccessTokenPrefix) {
+		return nil, ErrNotFound
+	}
+	var t AccessToken
+	var permissions sql.NullString
+	err := db.db.QueryRow(
+		"SELECT id, user_id, name, hint, permissions, created_at, expires_at, last_used_at FROM access_token WHERE hash = $1",
+		hashAccessToken(token),
+	).Scan(&t.Id, &t.UserId, &t.Name, &t.Hint, &permissions, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := timeseries.Now()
+	if t.ExpiresAt.Before(now) {
+		return nil, ErrNotFound
+	}
+	if err := t.unmarshalPermissions(permissions); err != nil {
+		return nil, err
+	}
+	if now.Sub(t.LastUsedAt) >= timeseries.Minute { // avoid a write on every request
+		t.LastUsedAt = now
+		if _, err := db.db.Exec("UPDATE access_token SET last_used_at = $1 WHERE id = $2", now, t.Id); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+func (t *AccessToken) unmarshalPermissions(s sql.NullString) error {
+	var ps *rbac.PermissionSet
+	if err := unmarshal(s.String, &ps); err != nil {
+		return err
+	}
+	if ps != nil {
+		t.Permissions = *ps
+	}
+	return nil
+}
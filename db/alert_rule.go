@@ -0,0 +1,113 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"codexray/model"
+	"codexray/timeseries"
+	"codexray/utils"
+
+	"k8s.io/klog"
+)
+
+type AlertRule struct {
+	Id            string              `json:"id"`
+	Name          string              `json:"name"`
+	Expr          string              `json:"expr"`
+	For           timeseries.Duration `json:"for"`
+	Severity      model.Status        `json:"severity"`
+	Labels        map[string]string   `json:"labels"`
+	ApplicationId model.ApplicationId `json:"application_id"`
+	Disabled      bool                `json:"disabled"`
+}
+
+func (r *AlertRule) Migrate(m *Migrator) error {
+	return m.Exec(`
+	CREATE TABLE IF NOT EXISTS alert_rule (
+		project_id TEXT NOT NULL REFERENCES project(id),
+		id TEXT NOT NULL,
+		rule TEXT NOT NULL,
+		PRIMARY KEY (project_id, id)
+	)`)
+}
+
+func (r *AlertRule) Valid() bool {
+	if strings.TrimSpace(r.Name) == "" || strings.TrimSpace(r.Expr) == "" {
+		return false
+	}
+	if r.For < 0 {
+		return false
+	}
+	switch r.Severity {
+	case model.WARNING, model.CRITICAL:
+	default:
+		return false
+	}
+	return true
+}
+
+// IncidentApplicationId returns the application the rule's incidents are attached to.
+// Rules that are not bound to an application get a synthetic id, so their incidents don't mix with SLO-based ones.
+// The id is built from the rule's id rather than the name, so renaming a rule doesn't orphan its open incident.
+func (r *AlertRule) IncidentApplicationId() model.ApplicationId {
+	if !r.ApplicationId.IsZero() {
+		return r.ApplicationId
+	}
+	return model.NewApplicationId("", model.ApplicationKindAlertRule, r.Id)
+}
+
+func (db *DB) GetAlertRules(projectId ProjectId) ([]*AlertRule, error) {
+	rows, err := db.db.Query("SELECT rule FROM alert_rule WHERE project_id = $1", projectId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []*AlertRule
+	var rule sql.NullString
+	for rows.Next() {
+		if err := rows.Scan(&rule); err != nil {
+			return nil, err
+		}
+		var r *AlertRule
+		if err := unmarshal(rule.String, &r); err != nil {
+			klog.Warningln(err)
+			continue
+		}
+		if r != nil {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+func (db *DB) SaveAlertRule(projectId ProjectId, rule *AlertRule) error {
+	insert := false
+	if rule.Id == "" {
+		insert = true
+		rule.Id = utils.NanoId(8)
+	}
+	data, err := marshal(rule)
+	if err != nil {
+		return err
+	}
+	if insert {
+		_, err = db.db.Exec("INSERT INTO alert_rule (project_id, id, rule) VALUES ($1, $2, $3)", projectId, rule.Id, data)
+		return err
+	}
+	res, err := db.db.Exec("UPDATE alert_rule SET rule = $1 WHERE project_id = $2 AND id = $3", data, projectId, rule.Id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (db *DB) DeleteAlertRule(projectId ProjectId, id string) error {
+	_, err := db.db.Exec("DELETE FROM alert_rule WHERE project_id = $1 AND id = $2", projectId, id)
+	return err
+}
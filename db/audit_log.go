@@ -0,0 +1,299 @@
+package db
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"codexray/rbac"
+	"codexray/timeseries"
+)
+
+const (
+	AuditLogSettingName      = "audit_log"
+	AuditLogDefaultRetention = 90 * timeseries.Day
+
+	auditLogHiddenValue = "<hidden>"
+)
+
+type AuditOperation string
+
+const (
+	AuditOperationCreate AuditOperation = "create"
+	AuditOperationUpdate AuditOperation = "update"
+	AuditOperationDelete AuditOperation = "delete"
+)
+
+// AuditLogEntry records a change made through the API. The log is append-only: entries are only deleted upon retention.
+type AuditLogEntry struct {
+	Id        int             `json:"id"`
+	Timestamp timeseries.Time `json:"timestamp"`
+	ProjectId ProjectId       `json:"project_id"`
+	UserId    int             `json:"user_id"`
+	Actor     string          `json:"actor"`
+	// Scope, Action, and Object describe the change in terms of the rbac.Action that allowed it.
+	Scope     rbac.Scope     `json:"scope"`
+	Action    rbac.Verb      `json:"action"`
+	Object    rbac.Object    `json:"object"`
+	Operation AuditOperation `json:"operation"`
+	// Target identifies the changed entity within the scope, e.g., the integration type or the alert rule id.
+	Target   string           `json:"target"`
+	Changes  []AuditLogChange `json:"changes"`
+	SourceIp string           `json:"source_ip"`
+}
+
+type AuditLogChange struct {
+	Path   string `json:"path"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+type AuditLogFilter struct {
+	From      timeseries.Time
+	To        timeseries.Time
+	ProjectId ProjectId
+	UserId    int
+	Scope     rbac.Scope
+	Operation AuditOperation
+	Limit     int
+}
+
+type AuditLogSettings struct {
+	Retention timeseries.Duration `json:"retention"`
+}
+
+func (e *AuditLogEntry) Migrate(m *Migrator) error {
+	err := m.Exec(`
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		timestamp INTEGER NOT NULL,
+		project_id TEXT NOT NULL,
+		user_id INTEGER NOT NULL,
+		actor TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		action TEXT NOT NULL,
+		object TEXT,
+		operation TEXT NOT NULL,
+		target TEXT NOT NULL,
+		changes TEXT,
+		source_ip TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	return m.Exec("CREATE INDEX IF NOT EXISTS audit_log_timestamp ON audit_log (timestamp)")
+}
+
+func (db *DB) AddAuditLogEntry(e *AuditLogEntry) error {
+	object, err := marshal(&e.Object)
+	if err != nil {
+		return err
+	}
+	changes, err := marshal(&e.Changes)
+	if err != nil {
+		return err
+	}
+	_, err = db.db.Exec(`
+		INSERT INTO audit_log (timestamp, project_id, user_id, actor, scope, action, object, operation, target, changes, source_ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		e.Timestamp, e.ProjectId, e.UserId, e.Actor, e.Scope, e.Action, object, e.Operation, e.Target, changes, e.SourceIp)
+	return err
+}
+
+// GetAuditLog returns the entries matching the filter, the most recent first.
+func (db *DB) GetAuditLog(f AuditLogFilter) ([]*AuditLogEntry, error) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if !f.From.IsZero() {
+		add("timestamp >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("timestamp <= $%d", f.To)
+	}
+	if f.ProjectId != "" {
+		add("project_id = $%d", f.ProjectId)
+	}
+	if f.UserId != 0 {
+		add("user_id = $%d", f.UserId)
+	}
+	if f.Scope != "" {
+		add("scope = $%d", f.Scope)
+	}
+	if f.Operation != "" {
+		add("operation = $%d", f.Operation)
+	}
+	q := "SELECT id, timestamp, project_id, user_id, actor, scope, action, object, operation, target, changes, source_ip FROM audit_log"
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		q += " LIMIT " + strconv.Itoa(f.Limit)
+	}
+	rows, err := db.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []*AuditLogEntry
+	for rows.Next() {
+		var e AuditLogEntry
+		var object, changes sql.NullString
+		err := rows.Scan(&e.Id, &e.Timestamp, &e.ProjectId, &e.UserId, &e.Actor, &e.Scope, &e.Action, &object, &e.Operation, &e.Target, &changes, &e.SourceIp)
+		if err != nil {
+			return nil, err
+		}
+		if object.Valid {
+			if err = json.Unmarshal([]byte(object.String), &e.Object); err != nil {
+				return nil, err
+			}
+		}
+		if changes.Valid {
+			if err = json.Unmarshal([]byte(changes.String), &e.Changes); err != nil {
+				return nil, err
+			}
+		}
+		res = append(res, &e)
+	}
+	return res, nil
+}
+
+// DeleteAuditLogEntriesBefore enforces the retention period.
+func (db *DB) DeleteAuditLogEntriesBefore(t timeseries.Time) (int64, error) {
+	res, err := db.db.Exec("DELETE FROM audit_log WHERE timestamp < $1", t)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (db *DB) GetAuditLogSettings() (AuditLogSettings, error) {
+	s := AuditLogSettings{Retention: AuditLogDefaultRetention}
+	err := db.GetSetting(AuditLogSettingName, &s)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return s, err
+	}
+	return s, nil
+}
+
+func (db *DB) SaveAuditLogSettings(s AuditLogSettings) error {
+	return db.SetSetting(AuditLogSettingName, s)
+}
+
+// AuditLogDiff compares the JSON representations of the states of an entity and returns the changed fields.
+// Values of fields that look like credentials are hidden, so only the fact of the change is recorded.
+func AuditLogDiff(before, after any) ([]AuditLogChange, error) {
+	b, err := flattenJson(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := flattenJson(after)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for p := range b {
+		paths = append(paths, p)
+	}
+	for p := range a {
+		if _, ok := b[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+	var res []AuditLogChange
+	for _, p := range paths {
+		bv, av := b[p], a[p]
+		if reflect.DeepEqual(bv, av) {
+			continue
+		}
+		if IsSecretField(p) {
+			bv, av = hideValue(bv), hideValue(av)
+		}
+		res = append(res, AuditLogChange{Path: p, Before: bv, After: av})
+	}
+	return res, nil
+}
+
+func flattenJson(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	res := map[string]any{}
+	flatten("", decoded, res)
+	return res, nil
+}
+
+func flatten(prefix string, v any, res map[string]any) {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	switch vv := v.(type) {
+	case nil:
+		if prefix != "" {
+			res[prefix] = nil
+		}
+	case map[string]any:
+		if len(vv) == 0 && prefix != "" {
+			res[prefix] = vv
+		}
+		for k, i := range vv {
+			flatten(join(k), i, res)
+		}
+	case []any:
+		if len(vv) == 0 && prefix != "" {
+			res[prefix] = vv
+		}
+		for i, item := range vv {
+			flatten(join(strconv.Itoa(i)), item, res)
+		}
+	default:
+		res[cmp.Or(prefix, "value")] = vv
+	}
+}
+
+// IsSecretField reports whether the field at the dotted path looks like a credential.
+// Custom header values (e.g., Authorization) and URLs, which may embed tokens or user:password, count as credentials too.
+// The base URL is the address of codexray itself, not a credential.
+func IsSecretField(path string) bool {
+	parts := strings.Split(strings.ToLower(path), ".")
+	name := parts[len(parts)-1]
+	if len(parts) >= 3 && strings.HasSuffix(parts[len(parts)-3], "headers") {
+		return name == "value"
+	}
+	switch {
+	case name == "key", strings.HasSuffix(name, "_key"), strings.HasSuffix(name, "apikey"):
+	case strings.Contains(name, "password"), strings.Contains(name, "secret"), strings.Contains(name, "token"):
+	case name == "url", strings.HasSuffix(name, "_url") && name != "base_url":
+	default:
+		return false
+	}
+	return true
+}
+
+func hideValue(v any) any {
+	if v == nil || v == "" {
+		return v
+	}
+	return auditLogHiddenValue
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Row is a table row keyed by the column names.
+type Row map[string]any
+
+// Tables returns the names of the tables created by Migrate in the order that satisfies the foreign keys.
+func (db *DB) Tables() []string {
+	res := make([]string, 0, len(db.tables))
+	for _, t := range db.tables {
+		res = append(res, t.name)
+	}
+	return res
+}
+
+// Dump reads every table within a single read-only transaction, so the rows are a consistent snapshot,
+// and passes them to f table by table in the order of Tables. It returns the number of rows per table.
+func (db *DB) Dump(ctx context.Context, f func(table string, row Row) error) (map[string]int, error) {
+	if len(db.tables) == 0 {
+		return nil, fmt.Errorf("the database is not migrated")
+	}
+	var opts *sql.TxOptions
+	if db.typ == TypePostgres {
+		opts = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	tx, err := db.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	counts := map[string]int{}
+	for _, t := range db.tables {
+		n, err := dumpTable(ctx, tx, t.name, func(row Row) error {
+			return f(t.name, row)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.name, err)
+		}
+		counts[t.name] = n
+	}
+	return counts, nil
+}
+
+func dumpTable(ctx context.Context, tx *sql.Tx, table string, f func(row Row) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT * FROM "+table)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	n := 0
+	for rows.Next() {
+		if err = rows.Scan(ptrs...); err != nil {
+			return 0, err
+		}
+		row := make(Row, len(columns))
+		for i, c := range columns {
+			// there are no binary columns, so bytes are text the driver didn't convert
+			if b, ok := values[i].([]byte); ok {
+				row[c] = string(b)
+			} else {
+				row[c] = values[i]
+			}
+		}
+		if err = f(row); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+// Loader inserts rows read by Dump within a single transaction, so a failed load leaves the database intact.
+type Loader struct {
+	db      *DB
+	tx      *sql.Tx
+	columns map[string][]string
+}
+
+// NewLoader starts loading rows into the database. The tables must be empty unless replace is set,
+// in which case the existing rows are deleted.
+func (db *DB) NewLoader(ctx context.Context, replace bool) (*Loader, error) {
+	if len(db.tables) == 0 {
+		return nil, fmt.Errorf("the database is not migrated")
+	}
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	l := &Loader{db: db, tx: tx, columns: map[string][]string{}}
+	for i := len(db.tables) - 1; i >= 0; i-- {
+		t := db.tables[i].name
+		if replace {
+			_, err = tx.ExecContext(ctx, "DELETE FROM "+t)
+		} else {
+			var n int
+			if err = tx.QueryRowContext(ctx, "SELECT count(*) FROM "+t).Scan(&n); err == nil && n > 0 {
+				err = fmt.Errorf("the table is not empty")
+			}
+		}
+		if err == nil {
+			l.columns[t], err = tableColumns(ctx, tx, t)
+		}
+		if err != nil {
+			l.Rollback()
+			return nil, fmt.Errorf("%s: %w", t, err)
+		}
+	}
+	return l, nil
+}
+
+func tableColumns(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT * FROM "+table+" LIMIT 0")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	return rows.Columns()
+}
+
+// Insert adds the row to the table. Columns missing in the row get their default values,
+// so rows dumped before a migration added a column can still be loaded.
+func (l *Loader) Insert(ctx context.Context, table string, row Row) error {
+	columns, ok := l.columns[table]
+	if !ok {
+		return fmt.Errorf("unknown table %s", table)
+	}
+	var names, placeholders []string
+	var args []any
+	for c, v := range row {
+		if !slices.Contains(columns, c) {
+			return fmt.Errorf("%s: unknown column %s", table, c)
+		}
+		names = append(names, c)
+		args = append(args, v)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+	q := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(names, ", "), strings.Join(placeholders, ", "))
+	if _, err := l.tx.ExecContext(ctx, q, args...); err != nil {
+		return fmt.Errorf("%s: %w", table, err)
+	}
+	return nil
+}
+
+// Commit finishes the load if the tables have the expected number of rows, and rolls it back otherwise.
+// The Postgres sequences are advanced past the loaded ids, so the rows created afterward don't conflict with them.
+func (l *Loader) Commit(ctx context.Context, expected map[string]int) error {
+	for _, t := range l.db.tables {
+		var n int
+		if err := l.tx.QueryRowContext(ctx, "SELECT count(*) FROM "+t.name).Scan(&n); err != nil {
+			l.Rollback()
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+		if n != expected[t.name] {
+			l.Rollback()
+			return fmt.Errorf("%s: expected %d rows, got %d", t.name, expected[t.name], n)
+		}
+	}
+	if l.db.typ == TypePostgres {
+		for _, t := range l.db.tables {
+			if !t.serial {
+				continue
+			}
+			q := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %s", t.name, t.name)
+			if _, err := l.tx.ExecContext(ctx, q); err != nil {
+				l.Rollback()
+				return fmt.Errorf("%s: %w", t.name, err)
+			}
+		}
+	}
+	return l.tx.Commit()
+}
+
+func (l *Loader) Rollback() {
+	_ = l.tx.Rollback()
+}
+
+// CopyTo copies every table into the migrated and empty target database and verifies the number of rows.
+func (db *DB) CopyTo(ctx context.Context, target *DB) (map[string]int, error) {
+	l, err := target.NewLoader(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := db.Dump(ctx, func(table string, row Row) error {
+		return l.Insert(ctx, table, row)
+	})
+	if err != nil {
+		l.Rollback()
+		return nil, err
+	}
+	if err = l.Commit(ctx, counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -6,7 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
@@ -25,6 +28,8 @@ var (
 	ErrNotFound = errors.New("not found")
 	ErrConflict = errors.New("conflict")
 	ErrInvalid  = errors.New("invalid")
+
+	createTableRe = regexp.MustCompile(`CREATE TABLE IF NOT EXISTS (\w+)`)
 )
 
 type DB struct {
@@ -32,6 +37,9 @@ type DB struct {
 	db  *sql.DB
 
 	primaryLockConn *sql.Conn
+	primaryLockMu   sync.Mutex
+
+	tables []migratedTable
 }
 
 func Open(dataDir string, pgConnString string) (*DB, error) {
@@ -73,12 +81,24 @@ func (db *DB) Migrate(extraTables ...Table) error {
 		&CheckConfigs{},
 		&Incident{},
 		&IncidentNotification{},
+		&IncidentEvent{},
+		&EscalationPolicy{},
+		&AlertRule{},
+		&MaintenanceWindow{},
 		&ApplicationDeployment{},
 		&ApplicationSettings{},
 		&Setting{},
 		&User{},
+		&CustomRole{},
+		&AccessToken{},
+		&AuditLogEntry{},
+	}
+	m := db.Migrator()
+	if err := m.Migrate(append(defaultTables, extraTables...)...); err != nil {
+		return err
 	}
-	return db.Migrator().Migrate(append(defaultTables, extraTables...)...)
+	db.tables = m.tables
+	return nil
 }
 
 func (db *DB) IsUniqueViolationError(err error) bool {
@@ -115,6 +135,15 @@ type Table interface {
 type Migrator struct {
 	typ Type
 	db  *sql.DB
+
+	tables []migratedTable
+}
+
+// migratedTable is a table created by the Migrator. Tables are recorded in the order of creation,
+// which satisfies the foreign keys since a table can only reference the tables created before it.
+type migratedTable struct {
+	name   string
+	serial bool
 }
 
 func NewMigrator(t Type, db *sql.DB) *Migrator {
@@ -132,6 +161,11 @@ func (m *Migrator) Migrate(tables ...Table) error {
 }
 
 func (m *Migrator) Exec(query string, args ...any) error {
+	for _, match := range createTableRe.FindAllStringSubmatch(query, -1) {
+		if !slices.ContainsFunc(m.tables, func(t migratedTable) bool { return t.name == match[1] }) {
+			m.tables = append(m.tables, migratedTable{name: match[1], serial: strings.Contains(query, "AUTOINCREMENT")})
+		}
+	}
 	switch m.typ {
 	case TypePostgres:
 		query = strings.ReplaceAll(query, "INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT", "SERIAL PRIMARY KEY")
@@ -143,7 +177,7 @@ func (m *Migrator) Exec(query string, args ...any) error {
 func (m *Migrator) AddColumnIfNotExists(table, column, dataType string) error {
 	switch m.typ {
 	case TypeSqlite:
-		rows, err := m.db.Query("SELECT name FROM pragma_table_info('project');")
+		rows, err := m.db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s');", table))
 		if err != nil {
 			return nil
 		}
@@ -2,7 +2,10 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"codexray/model"
 	"codexray/timeseries"
@@ -125,6 +128,30 @@ func (db *DB) GetApplicationDeployments(projectId ProjectId) (map[model.Applicat
 	return res, rows.Err()
 }
 
+// ApplicationDeploymentExists checks if the application has the deployment with the id (see model.ApplicationDeployment.Id).
+func (db *DB) ApplicationDeploymentExists(projectId ProjectId, appId model.ApplicationId, id string) (bool, error) {
+	hash, startedAt, ok := strings.Cut(id, ":")
+	if !ok {
+		return false, nil
+	}
+	ts, err := strconv.ParseInt(startedAt, 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	var name string
+	err = db.db.QueryRow(
+		"SELECT name FROM application_deployment WHERE project_id = $1 AND application_id = $2 AND started_at = $3",
+		projectId, appId, ts).Scan(&name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	d := model.ApplicationDeployment{Name: name}
+	return d.Hash() == hash, nil
+}
+
 func (db *DB) SaveApplicationDeploymentMetricsSnapshot(projectId ProjectId, d *model.ApplicationDeployment) error {
 	data, err := marshal(d.MetricsSnapshot)
 	if err != nil {
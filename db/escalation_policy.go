@@ -0,0 +1,184 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"codexray/timeseries"
+	"codexray/utils"
+
+	"k8s.io/klog"
+)
+
+// EscalationRepeat marks notifications re-sent while an incident stays open and critical.
+const EscalationRepeat = -1
+
+type EscalationPolicy struct {
+	Id       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Match    NotificationRouteMatch `json:"match"`
+	Steps    []EscalationStep       `json:"steps"`
+	Disabled bool                   `json:"disabled"`
+	// RepeatInterval re-sends notifications to every destination notified so far while the incident stays open,
+	// critical and unacknowledged. Zero disables repeating.
+	RepeatInterval timeseries.Duration `json:"repeat_interval"`
+}
+
+// EscalationStep notifies its destinations if the incident is still unacknowledged Delay after it was opened.
+type EscalationStep struct {
+	Delay        timeseries.Duration       `json:"delay"`
+	Destinations []NotificationDestination `json:"destinations"`
+}
+
+func (p *EscalationPolicy) Migrate(m *Migrator) error {
+	return m.Exec(`
+	CREATE TABLE IF NOT EXISTS escalation_policy (
+		project_id TEXT NOT NULL REFERENCES project(id),
+		id TEXT NOT NULL,
+		policy TEXT NOT NULL,
+		PRIMARY KEY (project_id, id)
+	)`)
+}
+
+func (p *EscalationPolicy) Valid() bool {
+	if strings.TrimSpace(p.Name) == "" {
+		return false
+	}
+	if !utils.GlobValidate(p.Match.Applications) || !utils.GlobValidate(p.Match.Namespaces) {
+		return false
+	}
+	if len(p.Steps) == 0 || p.RepeatInterval < 0 {
+		return false
+	}
+	var prevDelay timeseries.Duration
+	for _, s := range p.Steps {
+		if s.Delay <= prevDelay || len(s.Destinations) == 0 {
+			return false
+		}
+		for _, d := range s.Destinations {
+			if d.Validate() != nil {
+				return false
+			}
+		}
+		prevDelay = s.Delay
+	}
+	return true
+}
+
+func (p *EscalationPolicy) Matches(s NotificationSubject) bool {
+	return !p.Disabled && p.Match.matches(s)
+}
+
+func (db *DB) GetEscalationPolicies(projectId ProjectId) ([]*EscalationPolicy, error) {
+	rows, err := db.db.Query("SELECT policy FROM escalation_policy WHERE project_id = $1", projectId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []*EscalationPolicy
+	var policy sql.NullString
+	for rows.Next() {
+		if err := rows.Scan(&policy); err != nil {
+			return nil, err
+		}
+		var p *EscalationPolicy
+		if err := unmarshal(policy.String, &p); err != nil {
+			klog.Warningln(err)
+			continue
+		}
+		if p != nil {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+func (db *DB) SaveEscalationPolicy(projectId ProjectId, policy *EscalationPolicy) error {
+	insert := false
+	if policy.Id == "" {
+		insert = true
+		policy.Id = utils.NanoId(8)
+	}
+	data, err := marshal(policy)
+	if err != nil {
+		return err
+	}
+	if insert {
+		_, err = db.db.Exec("INSERT INTO escalation_policy (project_id, id, policy) VALUES ($1, $2, $3)", projectId, policy.Id, data)
+		return err
+	}
+	res, err := db.db.Exec("UPDATE escalation_policy SET policy = $1 WHERE project_id = $2 AND id = $3", data, projectId, policy.Id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (db *DB) DeleteEscalationPolicy(projectId ProjectId, id string) error {
+	_, err := db.db.Exec("DELETE FROM escalation_policy WHERE project_id = $1 AND id = $2", projectId, id)
+	return err
+}
+
+type IncidentEscalationState struct {
+	// LastSteps is the number of escalation steps already taken by each policy, keyed by the policy id.
+	LastSteps map[string]int
+	// LastNotifiedAt is the time of the latest notification, including repeats.
+	LastNotifiedAt timeseries.Time
+	// Details are the details of the latest notification with any.
+	Details *IncidentNotificationDetails
+}
+
+// GetIncidentEscalationState derives the incident's escalation progress from its notifications.
+func (db *DB) GetIncidentEscalationState(projectId ProjectId, incidentKey string) (*IncidentEscalationState, error) {
+	s := IncidentEscalationState{LastSteps: map[string]int{}}
+	var lastNotifiedAt sql.NullInt64
+	err := db.db.QueryRow(
+		"SELECT max(timestamp) FROM incident_notification WHERE project_id = $1 AND incident_key = $2 AND suppressed_by = ''",
+		projectId, incidentKey).Scan(&lastNotifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.LastNotifiedAt = timeseries.Time(lastNotifiedAt.Int64)
+
+	rows, err := db.db.Query(
+		"SELECT escalation_policy_id, max(escalation_step) FROM incident_notification WHERE project_id = $1 AND incident_key = $2 AND suppressed_by = '' AND escalation_policy_id != '' GROUP BY escalation_policy_id",
+		projectId, incidentKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var policyId string
+		var step int
+		if err := rows.Scan(&policyId, &step); err != nil {
+			return nil, err
+		}
+		s.LastSteps[policyId] = max(step, 0)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var details sql.NullString
+	err = db.db.QueryRow(
+		"SELECT details FROM incident_notification WHERE project_id = $1 AND incident_key = $2 AND details IS NOT NULL AND details != '' AND details != 'null' ORDER BY timestamp DESC LIMIT 1",
+		projectId, incidentKey).Scan(&details)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, err
+	case details.String != "":
+		if err := unmarshal(details.String, &s.Details); err != nil {
+			klog.Warningln(err)
+		}
+	}
+	return &s, nil
+}
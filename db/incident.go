@@ -13,19 +13,96 @@ import (
 
 type Incident model.ApplicationIncident
 
-func (i *Incident) Migrate(m *Migrator) error {
-	return m.Exec(`
-	CREATE TABLE IF NOT EXISTS incident (
+// incidentColumns are the columns of the incident table, the ones added after the table was introduced are at the end.
+const incidentColumns = `
 		project_id TEXT NOT NULL REFERENCES project(id),
 		application_id TEXT NOT NULL,
 		key TEXT NOT NULL,
 		opened_at INT NOT NULL,
 		resolved_at INT NOT NULL DEFAULT 0,
 		severity INT NOT NULL,
-		PRIMARY KEY (project_id, application_id, opened_at)
+		alert_rule_id TEXT NOT NULL DEFAULT '',
+		acknowledged_at INT NOT NULL DEFAULT 0,
+		acknowledged_by INT NOT NULL DEFAULT 0,
+		assigned_to INT NOT NULL DEFAULT 0,
+		deployment_id TEXT NOT NULL DEFAULT '',
+		root_cause TEXT NOT NULL DEFAULT '',
+		group_key TEXT NOT NULL DEFAULT '',
+		group_root TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (project_id, application_id, alert_rule_id, opened_at)`
+
+func (i *Incident) Migrate(m *Migrator) error {
+	err := m.Exec(`
+	CREATE TABLE IF NOT EXISTS incident (` + incidentColumns + `
 	);
 	CREATE UNIQUE INDEX IF NOT EXISTS incident_key ON incident (project_id, key);
 `)
+	if err != nil {
+		return err
+	}
+	for _, c := range []struct{ name, typ string }{
+		{"alert_rule_id", "TEXT NOT NULL DEFAULT ''"},
+		{"acknowledged_at", "INT NOT NULL DEFAULT 0"},
+		{"acknowledged_by", "INT NOT NULL DEFAULT 0"},
+		{"assigned_to", "INT NOT NULL DEFAULT 0"},
+		{"deployment_id", "TEXT NOT NULL DEFAULT ''"},
+		{"root_cause", "TEXT NOT NULL DEFAULT ''"},
+		{"group_key", "TEXT NOT NULL DEFAULT ''"},
+		{"group_root", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		if err := m.AddColumnIfNotExists("incident", c.name, c.typ); err != nil {
+			return err
+		}
+	}
+	return i.migratePrimaryKey(m)
+}
+
+// migratePrimaryKey adds alert_rule_id to the primary key of the tables created before alert rules could open incidents,
+// so an alert rule incident and an SLO incident of the same application can be opened at the same time.
+func (i *Incident) migratePrimaryKey(m *Migrator) error {
+	switch m.typ {
+	case TypeSqlite:
+		var pk int
+		if err := m.db.QueryRow("SELECT pk FROM pragma_table_info('incident') WHERE name = 'alert_rule_id'").Scan(&pk); err != nil {
+			return err
+		}
+		if pk > 0 {
+			return nil
+		}
+		// SQLite can't alter the primary key, so the table is rebuilt
+		tx, err := m.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = tx.Rollback()
+		}()
+		columns := "project_id, application_id, key, opened_at, resolved_at, severity, " + incidentWorkflowColumns
+		for _, q := range []string{
+			"ALTER TABLE incident RENAME TO incident_old",
+			"CREATE TABLE incident (" + incidentColumns + ")",
+			"INSERT INTO incident (" + columns + ") SELECT " + columns + " FROM incident_old",
+			"DROP TABLE incident_old",
+			"CREATE UNIQUE INDEX incident_key ON incident (project_id, key)",
+		} {
+			if _, err = tx.Exec(q); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	case TypePostgres:
+		var n int
+		err := m.db.QueryRow(`
+			SELECT count(*) FROM information_schema.key_column_usage 
+			WHERE table_schema = current_schema() AND table_name = 'incident' AND constraint_name = 'incident_pkey' AND column_name = 'alert_rule_id'`,
+		).Scan(&n)
+		if err != nil || n > 0 {
+			return err
+		}
+		_, err = m.db.Exec("ALTER TABLE incident DROP CONSTRAINT incident_pkey, ADD PRIMARY KEY (project_id, application_id, alert_rule_id, opened_at)")
+		return err
+	}
+	return nil
 }
 
 type IncidentNotification struct {
@@ -38,10 +115,19 @@ type IncidentNotification struct {
 	SentAt        timeseries.Time
 	ExternalKey   string
 	Details       *IncidentNotificationDetails
+	SuppressedBy  string
+	DeferredUntil timeseries.Time
+	Target        string // the routed destination address, empty for the integration's default one
+	// EscalationStep is zero for regular notifications, the escalation policy step number for escalations,
+	// or EscalationRepeat for repeated ones.
+	EscalationStep int
+	// EscalationPolicyId is the policy that sent the escalation or the repeat.
+	EscalationPolicyId string
+	Delivery           NotificationDelivery
 }
 
 func (n *IncidentNotification) Migrate(m *Migrator) error {
-	return m.Exec(`
+	err := m.Exec(`
 	CREATE TABLE IF NOT EXISTS incident_notification (
 		project_id TEXT NOT NULL REFERENCES project(id),
 		application_id TEXT NOT NULL,
@@ -54,10 +140,55 @@ func (n *IncidentNotification) Migrate(m *Migrator) error {
 		details TEXT
 	);
 `)
+	if err != nil {
+		return err
+	}
+	if err := m.AddColumnIfNotExists("incident_notification", "suppressed_by", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := m.AddColumnIfNotExists("incident_notification", "deferred_until", "INT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := m.AddColumnIfNotExists("incident_notification", "target", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := m.AddColumnIfNotExists("incident_notification", "escalation_step", "INT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := m.AddColumnIfNotExists("incident_notification", "escalation_policy_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	for _, c := range []struct{ name, typ string }{
+		{"delivery_attempts", "INT NOT NULL DEFAULT 0"},
+		{"delivery_attempted_at", "INT NOT NULL DEFAULT 0"},
+		{"delivery_status_code", "INT NOT NULL DEFAULT 0"},
+		{"delivery_error", "TEXT NOT NULL DEFAULT ''"},
+		{"delivery_latency", "INT NOT NULL DEFAULT 0"},
+	} {
+		if err := m.AddColumnIfNotExists("incident_notification", c.name, c.typ); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type IncidentNotificationDetails struct {
-	Reports []IncidentNotificationDetailsReport `json:"reports"`
+	Reports   []IncidentNotificationDetailsReport   `json:"reports"`
+	AlertRule *IncidentNotificationDetailsAlertRule `json:"alert_rule,omitempty"`
+	Group     *IncidentNotificationDetailsGroup     `json:"group,omitempty"`
+}
+
+// IncidentNotificationDetailsGroup lists the applications affected by a shared dependency.
+type IncidentNotificationDetailsGroup struct {
+	Root         model.ApplicationId   `json:"root"`
+	Applications []model.ApplicationId `json:"applications"`
+}
+
+type IncidentNotificationDetailsAlertRule struct {
+	Id     string            `json:"id"`
+	Name   string            `json:"name"`
+	Expr   string            `json:"expr"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 type IncidentNotificationDetailsReport struct {
@@ -69,14 +200,14 @@ type IncidentNotificationDetailsReport struct {
 func (db *DB) GetIncidentByKey(projectId ProjectId, key string) (*model.ApplicationIncident, error) {
 	i := &model.ApplicationIncident{Key: key}
 	err := db.db.QueryRow(
-		"SELECT application_id, opened_at, resolved_at, severity FROM incident WHERE project_id = $1 AND key = $2 LIMIT 1",
-		projectId, key).Scan(&i.ApplicationId, &i.OpenedAt, &i.ResolvedAt, &i.Severity)
+		"SELECT application_id, opened_at, resolved_at, severity, "+incidentWorkflowColumns+" FROM incident WHERE project_id = $1 AND key = $2 LIMIT 1",
+		projectId, key).Scan(append([]any{&i.ApplicationId, &i.OpenedAt, &i.ResolvedAt, &i.Severity}, incidentWorkflowFields(i)...)...)
 	return i, err
 }
 
 func (db *DB) GetApplicationIncidents(projectId ProjectId, from, to timeseries.Time) (map[model.ApplicationId][]*model.ApplicationIncident, error) {
 	rows, err := db.db.Query(
-		"SELECT application_id, key, opened_at, resolved_at, severity FROM incident WHERE project_id = $1 AND opened_at <= $2 AND (resolved_at = 0 OR resolved_at >= $3)",
+		"SELECT application_id, key, opened_at, resolved_at, severity, "+incidentWorkflowColumns+" FROM incident WHERE project_id = $1 AND opened_at <= $2 AND (resolved_at = 0 OR resolved_at >= $3)",
 		projectId, to, from)
 	if err != nil {
 		return nil, err
@@ -87,7 +218,7 @@ func (db *DB) GetApplicationIncidents(projectId ProjectId, from, to timeseries.T
 	res := map[model.ApplicationId][]*model.ApplicationIncident{}
 	for rows.Next() {
 		var i model.ApplicationIncident
-		if err := rows.Scan(&i.ApplicationId, &i.Key, &i.OpenedAt, &i.ResolvedAt, &i.Severity); err != nil {
+		if err := rows.Scan(append([]any{&i.ApplicationId, &i.Key, &i.OpenedAt, &i.ResolvedAt, &i.Severity}, incidentWorkflowFields(&i)...)...); err != nil {
 			return nil, err
 		}
 		res[i.ApplicationId] = append(res[i.ApplicationId], &i)
@@ -95,23 +226,92 @@ func (db *DB) GetApplicationIncidents(projectId ProjectId, from, to timeseries.T
 	return res, err
 }
 
+const incidentWorkflowColumns = "alert_rule_id, acknowledged_at, acknowledged_by, assigned_to, deployment_id, root_cause, group_key, group_root"
+
+func incidentWorkflowFields(i *model.ApplicationIncident) []any {
+	return []any{&i.AlertRuleId, &i.AcknowledgedAt, &i.AcknowledgedBy, &i.AssignedTo, &i.DeploymentId, &i.RootCause, &i.GroupKey, optionalApplicationId{&i.GroupRoot}}
+}
+
+// optionalApplicationId scans an empty string as a zero application id.
+type optionalApplicationId struct {
+	id *model.ApplicationId
+}
+
+func (o optionalApplicationId) Scan(src any) error {
+	if s, ok := src.(string); ok && s == "" {
+		*o.id = model.ApplicationId{}
+		return nil
+	}
+	return o.id.Scan(src)
+}
+
+func (db *DB) SetIncidentGroup(projectId ProjectId, key string, groupKey string, groupRoot model.ApplicationId) error {
+	_, err := db.db.Exec(
+		"UPDATE incident SET group_key = $1, group_root = $2 WHERE project_id = $3 AND key = $4",
+		groupKey, groupRoot.String(), projectId, key)
+	return err
+}
+
 func (db *DB) CreateOrUpdateIncident(projectId ProjectId, appId model.ApplicationId, now timeseries.Time, severity model.Status) (*model.ApplicationIncident, error) {
+	return db.createOrUpdateIncident(projectId, appId, "", now, severity)
+}
+
+func (db *DB) CreateOrUpdateAlertRuleIncident(projectId ProjectId, appId model.ApplicationId, alertRuleId string, now timeseries.Time, severity model.Status) (*model.ApplicationIncident, error) {
+	return db.createOrUpdateIncident(projectId, appId, alertRuleId, now, severity)
+}
+
+// ResolveAlertRuleIncidents resolves the open incidents of the alert rule, except the one attached to the keep application.
+// It's used when the rule is deleted or disabled, or when its incidents are attached to another application.
+func (db *DB) ResolveAlertRuleIncidents(projectId ProjectId, alertRuleId string, keep model.ApplicationId, now timeseries.Time) ([]*model.ApplicationIncident, error) {
+	rows, err := db.db.Query(
+		"SELECT application_id, key, opened_at, resolved_at, severity, "+incidentWorkflowColumns+" FROM incident WHERE project_id = $1 AND alert_rule_id = $2 AND resolved_at = 0",
+		projectId, alertRuleId)
+	if err != nil {
+		return nil, err
+	}
+	var res []*model.ApplicationIncident
+	for rows.Next() {
+		var i model.ApplicationIncident
+		if err := rows.Scan(append([]any{&i.ApplicationId, &i.Key, &i.OpenedAt, &i.ResolvedAt, &i.Severity}, incidentWorkflowFields(&i)...)...); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if i.ApplicationId != keep {
+			res = append(res, &i)
+		}
+	}
+	_ = rows.Close()
+	for _, i := range res {
+		i.ResolvedAt = now
+		if _, err := db.db.Exec("UPDATE incident SET resolved_at = $1 WHERE project_id = $2 AND key = $3", now, projectId, i.Key); err != nil {
+			return nil, err
+		}
+		db.recordIncidentStatusChange(projectId, i, IncidentEventResolved, now)
+	}
+	return res, nil
+}
+
+func (db *DB) createOrUpdateIncident(projectId ProjectId, appId model.ApplicationId, alertRuleId string, now timeseries.Time, severity model.Status) (*model.ApplicationIncident, error) {
 	appIdStr := appId.String()
-	var last model.ApplicationIncident
+	last := model.ApplicationIncident{ApplicationId: appId, AlertRuleId: alertRuleId}
 	err := db.db.QueryRow(
-		"SELECT key, opened_at, resolved_at, severity FROM incident WHERE project_id = $1 AND application_id = $2 ORDER BY opened_at DESC LIMIT 1",
-		projectId, appIdStr).Scan(&last.Key, &last.OpenedAt, &last.ResolvedAt, &last.Severity)
+		"SELECT key, opened_at, resolved_at, severity, "+incidentWorkflowColumns+" FROM incident WHERE project_id = $1 AND application_id = $2 AND alert_rule_id = $3 ORDER BY opened_at DESC LIMIT 1",
+		projectId, appIdStr, alertRuleId).Scan(append([]any{&last.Key, &last.OpenedAt, &last.ResolvedAt, &last.Severity}, incidentWorkflowFields(&last)...)...)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return nil, err
 	}
 
 	if last.OpenedAt.IsZero() || last.Resolved() {
 		if severity > model.OK { // open
-			i := model.ApplicationIncident{Key: utils.NanoId(8), OpenedAt: now, Severity: severity}
+			i := model.ApplicationIncident{ApplicationId: appId, Key: utils.NanoId(8), OpenedAt: now, Severity: severity, AlertRuleId: alertRuleId}
 			_, err := db.db.Exec(
-				"INSERT INTO incident (project_id, application_id, key, opened_at, severity) VALUES ($1, $2, $3, $4, $5)",
-				projectId, appIdStr, i.Key, i.OpenedAt, i.Severity)
-			return &i, err
+				"INSERT INTO incident (project_id, application_id, key, opened_at, severity, alert_rule_id) VALUES ($1, $2, $3, $4, $5, $6)",
+				projectId, appIdStr, i.Key, i.OpenedAt, i.Severity, i.AlertRuleId)
+			if err != nil {
+				return nil, err
+			}
+			db.recordIncidentStatusChange(projectId, &i, IncidentEventOpened, now)
+			return &i, nil
 		}
 		return nil, nil
 	}
@@ -119,17 +319,25 @@ func (db *DB) CreateOrUpdateIncident(projectId ProjectId, appId model.Applicatio
 	if severity == model.OK { // close
 		last.ResolvedAt = now
 		_, err := db.db.Exec(
-			"UPDATE incident SET resolved_at = $1 WHERE project_id = $2 AND application_id = $3 AND opened_at = $4",
-			last.ResolvedAt, projectId, appIdStr, last.OpenedAt)
-		return &last, err
+			"UPDATE incident SET resolved_at = $1 WHERE project_id = $2 AND key = $3",
+			last.ResolvedAt, projectId, last.Key)
+		if err != nil {
+			return nil, err
+		}
+		db.recordIncidentStatusChange(projectId, &last, IncidentEventResolved, now)
+		return &last, nil
 	}
 
 	if severity != last.Severity { // update severity
 		last.Severity = severity
 		_, err := db.db.Exec(
-			"UPDATE incident SET severity = $1 WHERE project_id = $2 AND application_id = $3 AND opened_at = $4",
-			last.Severity, projectId, appIdStr, last.OpenedAt)
-		return &last, err
+			"UPDATE incident SET severity = $1 WHERE project_id = $2 AND key = $3",
+			last.Severity, projectId, last.Key)
+		if err != nil {
+			return nil, err
+		}
+		db.recordIncidentStatusChange(projectId, &last, IncidentEventSeverityChanged, now)
+		return &last, nil
 	}
 
 	return nil, nil
@@ -142,8 +350,8 @@ func (db *DB) PutIncidentNotification(n IncidentNotification) {
 		return
 	}
 	_, err = db.db.Exec(
-		"INSERT INTO incident_notification (project_id, application_id, incident_key, status, destination, timestamp, external_key, details) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
-		n.ProjectId, n.ApplicationId, n.IncidentKey, n.Status, n.Destination, n.Timestamp, n.ExternalKey, details,
+		"INSERT INTO incident_notification (project_id, application_id, incident_key, status, destination, timestamp, external_key, details, suppressed_by, deferred_until, target, escalation_step, escalation_policy_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)",
+		n.ProjectId, n.ApplicationId, n.IncidentKey, n.Status, n.Destination, n.Timestamp, n.ExternalKey, details, n.SuppressedBy, n.DeferredUntil, n.Target, n.EscalationStep, n.EscalationPolicyId,
 	)
 	if err != nil {
 		klog.Errorln(err)
@@ -152,19 +360,28 @@ func (db *DB) PutIncidentNotification(n IncidentNotification) {
 
 func (db *DB) UpdateIncidentNotification(n IncidentNotification) error {
 	_, err := db.db.Exec(
-		"UPDATE incident_notification SET sent_at = $1, external_key = $2 WHERE project_id = $3 AND application_id = $4 AND incident_key = $5 AND timestamp = $6 AND destination = $7",
-		n.SentAt, n.ExternalKey, n.ProjectId, n.ApplicationId, n.IncidentKey, n.Timestamp, n.Destination,
+		"UPDATE incident_notification SET sent_at = $1, external_key = $2 WHERE project_id = $3 AND application_id = $4 AND incident_key = $5 AND timestamp = $6 AND destination = $7 AND target = $8",
+		n.SentAt, n.ExternalKey, n.ProjectId, n.ApplicationId, n.IncidentKey, n.Timestamp, n.Destination, n.Target,
+	)
+	return err
+}
+
+func (db *DB) SuppressIncidentNotification(n IncidentNotification) error {
+	_, err := db.db.Exec(
+		"UPDATE incident_notification SET suppressed_by = $1 WHERE project_id = $2 AND application_id = $3 AND incident_key = $4 AND timestamp = $5 AND destination = $6 AND target = $7",
+		n.SuppressedBy, n.ProjectId, n.ApplicationId, n.IncidentKey, n.Timestamp, n.Destination, n.Target,
 	)
 	return err
 }
 
-func (db *DB) GetNotSentIncidentNotifications(from timeseries.Time) ([]IncidentNotification, error) {
+// GetNotSentIncidentNotifications returns notifications created after `from` (or deferred past it) that are due at `now`.
+func (db *DB) GetNotSentIncidentNotifications(from, now timeseries.Time) ([]IncidentNotification, error) {
 	rows, err := db.db.Query(`
-		SELECT project_id, application_id, incident_key, status, destination, timestamp, external_key, details 
+		SELECT project_id, application_id, incident_key, status, destination, timestamp, external_key, details, deferred_until, target 
 		FROM incident_notification 
-		WHERE timestamp >= $1 AND sent_at = 0 
+		WHERE (timestamp >= $1 OR deferred_until >= $1) AND sent_at = 0 AND suppressed_by = '' AND deferred_until <= $2 
 		ORDER BY project_id, application_id, incident_key, timestamp
-	`, from)
+	`, from, now)
 	if err != nil {
 		return nil, err
 	}
@@ -175,7 +392,7 @@ func (db *DB) GetNotSentIncidentNotifications(from timeseries.Time) ([]IncidentN
 	var details sql.NullString
 	for rows.Next() {
 		var n IncidentNotification
-		if err := rows.Scan(&n.ProjectId, &n.ApplicationId, &n.IncidentKey, &n.Status, &n.Destination, &n.Timestamp, &n.ExternalKey, &details); err != nil {
+		if err := rows.Scan(&n.ProjectId, &n.ApplicationId, &n.IncidentKey, &n.Status, &n.Destination, &n.Timestamp, &n.ExternalKey, &details, &n.DeferredUntil, &n.Target); err != nil {
 			return nil, err
 		}
 		if details.String != "" {
@@ -190,11 +407,11 @@ func (db *DB) GetNotSentIncidentNotifications(from timeseries.Time) ([]IncidentN
 
 func (db *DB) GetPreviousIncidentNotifications(n IncidentNotification) ([]IncidentNotification, error) {
 	rows, err := db.db.Query(`
-		SELECT project_id, application_id, incident_key, status, destination, timestamp, external_key, details 
+		SELECT project_id, application_id, incident_key, status, destination, timestamp, external_key, details, deferred_until, target 
 		FROM incident_notification 
-		WHERE project_id = $1 AND application_id = $2 AND incident_key = $3 AND destination = $4 AND timestamp < $5 
+		WHERE project_id = $1 AND application_id = $2 AND incident_key = $3 AND destination = $4 AND target = $5 AND timestamp < $6 AND suppressed_by = '' 
 		ORDER BY timestamp
-	`, n.ProjectId, n.ApplicationId, n.IncidentKey, n.Destination, n.Timestamp,
+	`, n.ProjectId, n.ApplicationId, n.IncidentKey, n.Destination, n.Target, n.Timestamp,
 	)
 	if err != nil {
 		return nil, err
@@ -206,7 +423,7 @@ func (db *DB) GetPreviousIncidentNotifications(n IncidentNotification) ([]Incide
 	var details sql.NullString
 	for rows.Next() {
 		var n IncidentNotification
-		if err := rows.Scan(&n.ProjectId, &n.ApplicationId, &n.IncidentKey, &n.Status, &n.Destination, &n.Timestamp, &n.ExternalKey, &details); err != nil {
+		if err := rows.Scan(&n.ProjectId, &n.ApplicationId, &n.IncidentKey, &n.Status, &n.Destination, &n.Timestamp, &n.ExternalKey, &details, &n.DeferredUntil, &n.Target); err != nil {
 			return nil, err
 		}
 		if details.String != "" {
@@ -219,6 +436,38 @@ func (db *DB) GetPreviousIncidentNotifications(n IncidentNotification) ([]Incide
 	return res, nil
 }
 
+// IsIncidentOpenNotificationSent reports whether a notification of the open incident has been delivered to the destination.
+func (db *DB) IsIncidentOpenNotificationSent(n IncidentNotification) (bool, error) {
+	var count int
+	err := db.db.QueryRow(
+		"SELECT count(*) FROM incident_notification WHERE project_id = $1 AND application_id = $2 AND incident_key = $3 AND destination = $4 AND target = $5 AND status > $6 AND sent_at > 0",
+		n.ProjectId, n.ApplicationId, n.IncidentKey, n.Destination, n.Target, model.OK).Scan(&count)
+	return count > 0, err
+}
+
+// GetIncidentNotificationTargets returns the distinct targets the incident's notifications were routed to, by destination.
+func (db *DB) GetIncidentNotificationTargets(projectId ProjectId, incidentKey string) (map[IntegrationType][]string, error) {
+	rows, err := db.db.Query(
+		"SELECT DISTINCT destination, target FROM incident_notification WHERE project_id = $1 AND incident_key = $2 AND suppressed_by = ''",
+		projectId, incidentKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := map[IntegrationType][]string{}
+	var destination IntegrationType
+	var target string
+	for rows.Next() {
+		if err := rows.Scan(&destination, &target); err != nil {
+			return nil, err
+		}
+		res[destination] = append(res[destination], target)
+	}
+	return res, nil
+}
+
 func (db *DB) GetSentIncidentNotificationsStat(from timeseries.Time) map[IntegrationType]int {
 	rows, err := db.db.Query("SELECT destination, count(*) FROM incident_notification WHERE timestamp >= $1 AND sent_at > 0 GROUP BY destination", from)
 	if err != nil {
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+
+	"codexray/model"
+	"codexray/timeseries"
+
+	"k8s.io/klog"
+)
+
+type IncidentEventType string
+
+const (
+	IncidentEventOpened           IncidentEventType = "opened"
+	IncidentEventSeverityChanged  IncidentEventType = "severity_changed"
+	IncidentEventResolved         IncidentEventType = "resolved"
+	IncidentEventAcknowledged     IncidentEventType = "acknowledged"
+	IncidentEventAssigned         IncidentEventType = "assigned"
+	IncidentEventNote             IncidentEventType = "note"
+	IncidentEventDeploymentLinked IncidentEventType = "deployment_linked"
+	IncidentEventRootCause        IncidentEventType = "root_cause"
+	IncidentEventNotificationSent IncidentEventType = "notification_sent"
+)
+
+type IncidentEvent struct {
+	ProjectId   ProjectId         `json:"-"`
+	IncidentKey string            `json:"-"`
+	Timestamp   timeseries.Time   `json:"timestamp"`
+	Type        IncidentEventType `json:"type"`
+	UserId      int               `json:"user_id,omitempty"`
+	Details     string            `json:"details"`
+}
+
+func (e *IncidentEvent) Migrate(m *Migrator) error {
+	return m.Exec(`
+	CREATE TABLE IF NOT EXISTS incident_event (
+		project_id TEXT NOT NULL REFERENCES project(id),
+		incident_key TEXT NOT NULL,
+		timestamp INT NOT NULL,
+		type TEXT NOT NULL,
+		user_id INT NOT NULL DEFAULT 0,
+		details TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS incident_event_incident ON incident_event (project_id, incident_key);
+`)
+}
+
+func (db *DB) addIncidentEvent(e IncidentEvent) error {
+	_, err := db.db.Exec(
+		"INSERT INTO incident_event (project_id, incident_key, timestamp, type, user_id, details) VALUES ($1, $2, $3, $4, $5, $6)",
+		e.ProjectId, e.IncidentKey, e.Timestamp, e.Type, e.UserId, e.Details,
+	)
+	return err
+}
+
+func (db *DB) updateIncident(projectId ProjectId, key string, e IncidentEvent, query string, args ...any) error {
+	res, err := db.db.Exec(query, append(args, projectId, key)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	e.ProjectId, e.IncidentKey = projectId, key
+	return db.addIncidentEvent(e)
+}
+
+func (db *DB) AcknowledgeIncident(projectId ProjectId, key string, userId int, now timeseries.Time) error {
+	return db.updateIncident(projectId, key,
+		IncidentEvent{Timestamp: now, Type: IncidentEventAcknowledged, UserId: userId},
+		"UPDATE incident SET acknowledged_at = $1, acknowledged_by = $2 WHERE project_id = $3 AND key = $4 AND acknowledged_at = 0",
+		now, userId,
+	)
+}
+
+func (db *DB) AssignIncident(projectId ProjectId, key string, assignee *User, userId int, now timeseries.Time) error {
+	return db.updateIncident(projectId, key,
+		IncidentEvent{Timestamp: now, Type: IncidentEventAssigned, UserId: userId, Details: assignee.Name},
+		"UPDATE incident SET assigned_to = $1 WHERE project_id = $2 AND key = $3",
+		assignee.Id,
+	)
+}
+
+func (db *DB) LinkIncidentDeployment(projectId ProjectId, key string, deploymentId string, userId int, now timeseries.Time) error {
+	return db.updateIncident(projectId, key,
+		IncidentEvent{Timestamp: now, Type: IncidentEventDeploymentLinked, UserId: userId, Details: deploymentId},
+		"UPDATE incident SET deployment_id = $1 WHERE project_id = $2 AND key = $3",
+		deploymentId,
+	)
+}
+
+func (db *DB) SetIncidentRootCause(projectId ProjectId, key string, rootCause string, userId int, now timeseries.Time) error {
+	return db.updateIncident(projectId, key,
+		IncidentEvent{Timestamp: now, Type: IncidentEventRootCause, UserId: userId, Details: rootCause},
+		"UPDATE incident SET root_cause = $1 WHERE project_id = $2 AND key = $3",
+		rootCause,
+	)
+}
+
+func (db *DB) AddIncidentNote(projectId ProjectId, key string, note string, userId int, now timeseries.Time) error {
+	return db.addIncidentEvent(IncidentEvent{ProjectId: projectId, IncidentKey: key, Timestamp: now, Type: IncidentEventNote, UserId: userId, Details: note})
+}
+
+// GetIncidentTimeline returns the incident's events merged with the notifications sent about it, oldest first.
+func (db *DB) GetIncidentTimeline(projectId ProjectId, key string) ([]IncidentEvent, error) {
+	rows, err := db.db.Query(
+		"SELECT timestamp, type, user_id, details FROM incident_event WHERE project_id = $1 AND incident_key = $2",
+		projectId, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []IncidentEvent
+	for rows.Next() {
+		e := IncidentEvent{ProjectId: projectId, IncidentKey: key}
+		if err := rows.Scan(&e.Timestamp, &e.Type, &e.UserId, &e.Details); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+
+	nRows, err := db.db.Query(
+		"SELECT status, destination, sent_at FROM incident_notification WHERE project_id = $1 AND incident_key = $2 AND sent_at > 0",
+		projectId, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = nRows.Close()
+	}()
+	var status model.Status
+	var destination IntegrationType
+	var sentAt timeseries.Time
+	for nRows.Next() {
+		if err := nRows.Scan(&status, &destination, &sentAt); err != nil {
+			return nil, err
+		}
+		res = append(res, IncidentEvent{
+			ProjectId:   projectId,
+			IncidentKey: key,
+			Timestamp:   sentAt,
+			Type:        IncidentEventNotificationSent,
+			Details:     fmt.Sprintf("%s: %s", destination, status),
+		})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Timestamp < res[j].Timestamp
+	})
+	return res, nil
+}
+
+func (db *DB) recordIncidentStatusChange(projectId ProjectId, i *model.ApplicationIncident, typ IncidentEventType, now timeseries.Time) {
+	e := IncidentEvent{ProjectId: projectId, IncidentKey: i.Key, Timestamp: now, Type: typ}
+	if typ != IncidentEventResolved {
+		e.Details = i.Severity.String()
+	}
+	if err := db.addIncidentEvent(e); err != nil {
+		klog.Errorln(err)
+	}
+}
@@ -2,8 +2,10 @@ package db
 
 import (
 	"fmt"
+	"strings"
 
 	"codexray/model"
+	"codexray/prom"
 
 	"codexray/timeseries"
 	"codexray/utils"
@@ -20,16 +22,26 @@ const (
 	IntegrationTypeTeams      IntegrationType = "teams"
 	IntegrationTypeOpsgenie   IntegrationType = "opsgenie"
 	IntegrationTypeWebhook    IntegrationType = "webhook"
+	IntegrationTypeEmail      IntegrationType = "email"
+	IntegrationTypeTelegram   IntegrationType = "telegram"
+	IntegrationTypeMattermost IntegrationType = "mattermost"
+	IntegrationTypeGoogleChat IntegrationType = "googlechat"
+	IntegrationTypeDiscord    IntegrationType = "discord"
 )
 
 type Integrations struct {
 	BaseUrl string `json:"base_url"`
 
-	Slack     *IntegrationSlack     `json:"slack,omitempty"`
-	Pagerduty *IntegrationPagerduty `json:"pagerduty,omitempty"`
-	Teams     *IntegrationTeams     `json:"teams,omitempty"`
-	Opsgenie  *IntegrationOpsgenie  `json:"opsgenie,omitempty"`
-	Webhook   *IntegrationWebhook   `json:"webhook,omitempty"`
+	Slack      *IntegrationSlack      `json:"slack,omitempty"`
+	Pagerduty  *IntegrationPagerduty  `json:"pagerduty,omitempty"`
+	Teams      *IntegrationTeams      `json:"teams,omitempty"`
+	Opsgenie   *IntegrationOpsgenie   `json:"opsgenie,omitempty"`
+	Webhook    *IntegrationWebhook    `json:"webhook,omitempty"`
+	Email      *IntegrationEmail      `json:"email,omitempty"`
+	Telegram   *IntegrationTelegram   `json:"telegram,omitempty"`
+	Mattermost *IntegrationMattermost `json:"mattermost,omitempty"`
+	GoogleChat *IntegrationGoogleChat `json:"googlechat,omitempty"`
+	Discord    *IntegrationDiscord    `json:"discord,omitempty"`
 
 	Clickhouse *IntegrationClickhouse `json:"clickhouse,omitempty"`
 
@@ -92,6 +104,51 @@ func (integrations Integrations) GetInfo() []IntegrationInfo {
 	}
 	res = append(res, i)
 
+	i = IntegrationInfo{Type: IntegrationTypeEmail, Title: "Email"}
+	if cfg := integrations.Email; cfg != nil {
+		i.Configured = true
+		i.Incidents = cfg.Incidents
+		i.Deployments = cfg.Deployments
+		i.Details = fmt.Sprintf("smtp: %s:%d", cfg.Host, cfg.Port)
+	}
+	res = append(res, i)
+
+	i = IntegrationInfo{Type: IntegrationTypeTelegram, Title: "Telegram"}
+	if cfg := integrations.Telegram; cfg != nil {
+		i.Configured = true
+		i.Incidents = cfg.Incidents
+		i.Deployments = cfg.Deployments
+		i.Details = fmt.Sprintf("chat: %s", cfg.ChatId)
+	}
+	res = append(res, i)
+
+	i = IntegrationInfo{Type: IntegrationTypeMattermost, Title: "Mattermost"}
+	if cfg := integrations.Mattermost; cfg != nil {
+		i.Configured = true
+		i.Incidents = cfg.Incidents
+		i.Deployments = cfg.Deployments
+		if cfg.Channel != "" {
+			i.Details = fmt.Sprintf("channel: %s", cfg.Channel)
+		}
+	}
+	res = append(res, i)
+
+	i = IntegrationInfo{Type: IntegrationTypeGoogleChat, Title: "Google Chat"}
+	if cfg := integrations.GoogleChat; cfg != nil {
+		i.Configured = true
+		i.Incidents = cfg.Incidents
+		i.Deployments = cfg.Deployments
+	}
+	res = append(res, i)
+
+	i = IntegrationInfo{Type: IntegrationTypeDiscord, Title: "Discord"}
+	if cfg := integrations.Discord; cfg != nil {
+		i.Configured = true
+		i.Incidents = cfg.Incidents
+		i.Deployments = cfg.Deployments
+	}
+	res = append(res, i)
+
 	return res
 }
 
@@ -104,6 +161,32 @@ type IntegrationsPrometheus struct {
 	ExtraSelector   string              `json:"extra_selector"`
 	CustomHeaders   []utils.Header      `json:"custom_headers"`
 	ExtraLabels     map[string]string   `json:"-"`
+
+	// Cluster tells an additional source apart from the others:
+	// it's added as the `cluster` label to the metrics of the source and to the ids of the applications it monitors.
+	Cluster string `json:"cluster"`
+	// AdditionalSources are queried along with this one, e.g., the Prometheus servers of other clusters.
+	AdditionalSources []IntegrationsPrometheus `json:"additional_sources,omitempty"`
+}
+
+// ClientConfigs returns the client configs of the source followed by the additional ones.
+// All the sources are queried with the refresh interval of the first one.
+// Only the additional sources get clusters, so adding a source doesn't change the application ids
+// the settings, incidents, and alert rules of the first one are stored under.
+func (p *IntegrationsPrometheus) ClientConfigs() []prom.ClientConfig {
+	var res []prom.ClientConfig
+	for i, s := range append([]IntegrationsPrometheus{*p}, p.AdditionalSources...) {
+		cfg := prom.NewClientConfig(s.Url, p.RefreshInterval)
+		cfg.BasicAuth = s.BasicAuth
+		cfg.TlsSkipVerify = s.TlsSkipVerify
+		cfg.ExtraSelector = s.ExtraSelector
+		cfg.CustomHeaders = s.CustomHeaders
+		if i > 0 {
+			cfg.Cluster = s.Cluster
+		}
+		res = append(res, cfg)
+	}
+	return res
 }
 
 type IntegrationClickhouse struct {
@@ -153,6 +236,83 @@ type IntegrationWebhook struct {
 	DeploymentTemplate string           `json:"deployment_template"`
 }
 
+type EmailTLSMode string
+
+const (
+	EmailTLSModeNone     EmailTLSMode = "none"
+	EmailTLSModeStartTLS EmailTLSMode = "starttls"
+	EmailTLSModeTLS      EmailTLSMode = "tls"
+)
+
+type IntegrationEmail struct {
+	Host          string           `json:"host"`
+	Port          int              `json:"port"`
+	TLSMode       EmailTLSMode     `json:"tls_mode"`
+	TlsSkipVerify bool             `json:"tls_skip_verify"`
+	Auth          *utils.BasicAuth `json:"auth"`
+	From          string           `json:"from"`
+	// Recipients receive notifications of applications whose category has no dedicated recipients.
+	Recipients         []string                               `json:"recipients"`
+	CategoryRecipients map[model.ApplicationCategory][]string `json:"category_recipients"`
+	Incidents          bool                                   `json:"incidents"`
+	Deployments        bool                                   `json:"deployments"`
+}
+
+type IntegrationTelegram struct {
+	BotToken    string `json:"bot_token"`
+	ChatId      string `json:"chat_id"`
+	Incidents   bool   `json:"incidents"`
+	Deployments bool   `json:"deployments"`
+}
+
+type IntegrationMattermost struct {
+	WebhookUrl  string `json:"webhook_url"`
+	Channel     string `json:"channel"` // overrides the webhook's default channel if set
+	Incidents   bool   `json:"incidents"`
+	Deployments bool   `json:"deployments"`
+}
+
+type IntegrationGoogleChat struct {
+	WebhookUrl  string `json:"webhook_url"`
+	Incidents   bool   `json:"incidents"`
+	Deployments bool   `json:"deployments"`
+}
+
+type IntegrationDiscord struct {
+	WebhookUrl  string `json:"webhook_url"`
+	Incidents   bool   `json:"incidents"`
+	Deployments bool   `json:"deployments"`
+}
+
+func (cfg *IntegrationEmail) GetRecipients(category model.ApplicationCategory) []string {
+	if r := cfg.CategoryRecipients[category]; len(r) > 0 {
+		return r
+	}
+	return cfg.Recipients
+}
+
+// WithUrl returns a copy of the config pointing at the routed URL, or the config itself if url is empty.
+func (cfg *IntegrationWebhook) WithUrl(url string) *IntegrationWebhook {
+	if url == "" {
+		return cfg
+	}
+	res := *cfg
+	res.Url = url
+	return &res
+}
+
+// WithRecipients returns a copy of the config sending everything to the routed comma-separated recipients,
+// or the config itself if recipients is empty.
+func (cfg *IntegrationEmail) WithRecipients(recipients string) *IntegrationEmail {
+	if recipients == "" {
+		return cfg
+	}
+	res := *cfg
+	res.Recipients = strings.Split(recipients, ",")
+	res.CategoryRecipients = nil
+	return &res
+}
+
 func (db *DB) SaveIntegrationsBaseUrl(id ProjectId, baseUrl string) error {
 	p, err := db.GetProject(id)
 	if err != nil {
@@ -11,6 +11,8 @@ func (db *DB) GetPrimaryLock(ctx context.Context) bool {
 		return true
 	}
 
+	db.primaryLockMu.Lock()
+	defer db.primaryLockMu.Unlock()
 	if db.primaryLockConn == nil {
 		c, err := db.db.Conn(ctx)
 		if err != nil {
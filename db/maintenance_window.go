@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+
+	"codexray/model"
+	"codexray/timeseries"
+	"codexray/utils"
+
+	"k8s.io/klog"
+)
+
+type MaintenanceWindow struct{}
+
+func (mw *MaintenanceWindow) Migrate(m *Migrator) error {
+	return m.Exec(`
+	CREATE TABLE IF NOT EXISTS maintenance_window (
+		project_id TEXT NOT NULL REFERENCES project(id),
+		id TEXT NOT NULL,
+		config TEXT NOT NULL,
+		PRIMARY KEY (project_id, id)
+	)`)
+}
+
+func (db *DB) GetMaintenanceWindows(projectId ProjectId) ([]*model.MaintenanceWindow, error) {
+	rows, err := db.db.Query("SELECT config FROM maintenance_window WHERE project_id = $1", projectId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []*model.MaintenanceWindow
+	var window sql.NullString
+	for rows.Next() {
+		if err := rows.Scan(&window); err != nil {
+			return nil, err
+		}
+		var mw *model.MaintenanceWindow
+		if err := unmarshal(window.String, &mw); err != nil {
+			klog.Warningln(err)
+			continue
+		}
+		if mw != nil {
+			res = append(res, mw)
+		}
+	}
+	return res, nil
+}
+
+func (db *DB) SaveMaintenanceWindow(projectId ProjectId, mw *model.MaintenanceWindow) error {
+	insert := false
+	if mw.Id == "" {
+		insert = true
+		mw.Id = utils.NanoId(8)
+	}
+	data, err := marshal(mw)
+	if err != nil {
+		return err
+	}
+	if insert {
+		_, err = db.db.Exec("INSERT INTO maintenance_window (project_id, id, config) VALUES ($1, $2, $3)", projectId, mw.Id, data)
+		return err
+	}
+	res, err := db.db.Exec("UPDATE maintenance_window SET config = $1 WHERE project_id = $2 AND id = $3", data, projectId, mw.Id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (db *DB) DeleteMaintenanceWindow(projectId ProjectId, id string) error {
+	_, err := db.db.Exec("DELETE FROM maintenance_window WHERE project_id = $1 AND id = $2", projectId, id)
+	return err
+}
+
+func (db *DB) GetSuppressedIncidentNotifications(projectId ProjectId, from timeseries.Time) ([]IncidentNotification, error) {
+	rows, err := db.db.Query(`
+		SELECT project_id, application_id, incident_key, status, destination, timestamp, external_key, suppressed_by
+		FROM incident_notification
+		WHERE project_id = $1 AND timestamp >= $2 AND suppressed_by != ''
+		ORDER BY timestamp
+	`, projectId, from)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []IncidentNotification
+	for rows.Next() {
+		var n IncidentNotification
+		if err := rows.Scan(&n.ProjectId, &n.ApplicationId, &n.IncidentKey, &n.Status, &n.Destination, &n.Timestamp, &n.ExternalKey, &n.SuppressedBy); err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"codexray/timeseries"
+)
+
+// NotificationDelivery is the result of the latest attempt to send a notification.
+type NotificationDelivery struct {
+	Attempts    int
+	AttemptedAt timeseries.Time
+	// StatusCode is the HTTP status code of the destination's response, or 0 if unknown (e.g., for emails or network errors).
+	StatusCode int
+	Error      string
+	Latency    time.Duration
+}
+
+// UpdateIncidentNotificationDelivery records an attempt to send the notification.
+func (db *DB) UpdateIncidentNotificationDelivery(n IncidentNotification, d NotificationDelivery) error {
+	_, err := db.db.Exec(`
+		UPDATE incident_notification
+		SET delivery_attempts = delivery_attempts + 1, delivery_attempted_at = $1, delivery_status_code = $2, delivery_error = $3, delivery_latency = $4
+		WHERE project_id = $5 AND application_id = $6 AND incident_key = $7 AND timestamp = $8 AND destination = $9 AND target = $10`,
+		d.AttemptedAt, d.StatusCode, d.Error, d.Latency.Milliseconds(),
+		n.ProjectId, n.ApplicationId, n.IncidentKey, n.Timestamp, n.Destination, n.Target,
+	)
+	return err
+}
+
+// GetIncidentNotificationDeliveries returns the notifications sent or attempted to be sent to the destination since `from`, latest first.
+func (db *DB) GetIncidentNotificationDeliveries(projectId ProjectId, destination IntegrationType, from timeseries.Time, limit int) ([]IncidentNotification, error) {
+	rows, err := db.db.Query(`
+		SELECT application_id, incident_key, status, timestamp, sent_at, target, escalation_step,
+			delivery_attempts, delivery_attempted_at, delivery_status_code, delivery_error, delivery_latency
+		FROM incident_notification
+		WHERE project_id = $1 AND destination = $2 AND delivery_attempted_at >= $3
+		ORDER BY delivery_attempted_at DESC
+		LIMIT $4`,
+		projectId, destination, from, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []IncidentNotification
+	var latency sql.NullInt64
+	for rows.Next() {
+		n := IncidentNotification{ProjectId: projectId, Destination: destination}
+		err := rows.Scan(&n.ApplicationId, &n.IncidentKey, &n.Status, &n.Timestamp, &n.SentAt, &n.Target, &n.EscalationStep,
+			&n.Delivery.Attempts, &n.Delivery.AttemptedAt, &n.Delivery.StatusCode, &n.Delivery.Error, &latency)
+		if err != nil {
+			return nil, err
+		}
+		n.Delivery.Latency = time.Duration(latency.Int64) * time.Millisecond
+		res = append(res, n)
+	}
+	return res, nil
+}
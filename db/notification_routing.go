@@ -0,0 +1,183 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"codexray/model"
+	"codexray/utils"
+)
+
+// NotificationRoutes is a project's notification routing tree.
+// Routes are evaluated in order: the deepest matching route determines the destinations,
+// and evaluation stops at the first matching top-level route unless it has Continue set.
+// If no route matches, notifications go to the integrations' default destinations.
+type NotificationRoutes []*NotificationRoute
+
+type NotificationRoute struct {
+	Name         string                    `json:"name"`
+	Match        NotificationRouteMatch    `json:"match"`
+	Destinations []NotificationDestination `json:"destinations"`
+	Continue     bool                      `json:"continue"`
+	Routes       NotificationRoutes        `json:"routes,omitempty"`
+}
+
+// NotificationRouteMatch matches notifications by all the specified criteria; empty criteria match everything.
+type NotificationRouteMatch struct {
+	Applications []string                    `json:"applications"`
+	Categories   []model.ApplicationCategory `json:"categories"`
+	Severities   []model.Status              `json:"severities"`
+	Namespaces   []string                    `json:"namespaces"`
+	Labels       map[string]string           `json:"labels"`
+}
+
+type NotificationDestination struct {
+	Type IntegrationType `json:"type"`
+
+	SlackChannel            string   `json:"slack_channel,omitempty"`
+	WebhookUrl              string   `json:"webhook_url,omitempty"` // a generic webhook or an incoming webhook of Teams, Mattermost, Google Chat or Discord
+	TelegramChatId          string   `json:"telegram_chat_id,omitempty"`
+	PagerdutyIntegrationKey string   `json:"pagerduty_integration_key,omitempty"`
+	EmailRecipients         []string `json:"email_recipients,omitempty"`
+}
+
+type NotificationSubject struct {
+	ApplicationId model.ApplicationId
+	Category      model.ApplicationCategory
+	Severity      model.Status
+	Labels        model.Labels
+}
+
+// NewNotificationDestination is the inverse of Target.
+func NewNotificationDestination(t IntegrationType, target string) NotificationDestination {
+	d := NotificationDestination{Type: t}
+	switch t {
+	case IntegrationTypeSlack:
+		d.SlackChannel = target
+	case IntegrationTypeTeams, IntegrationTypeWebhook, IntegrationTypeMattermost, IntegrationTypeGoogleChat, IntegrationTypeDiscord:
+		d.WebhookUrl = target
+	case IntegrationTypeTelegram:
+		d.TelegramChatId = target
+	case IntegrationTypePagerduty:
+		d.PagerdutyIntegrationKey = target
+	case IntegrationTypeEmail:
+		if target != "" {
+			d.EmailRecipients = strings.Split(target, ",")
+		}
+	}
+	return d
+}
+
+// Target returns the destination-specific address stored with each notification.
+func (d NotificationDestination) Target() string {
+	switch d.Type {
+	case IntegrationTypeSlack:
+		return d.SlackChannel
+	case IntegrationTypeTeams, IntegrationTypeWebhook, IntegrationTypeMattermost, IntegrationTypeGoogleChat, IntegrationTypeDiscord:
+		return d.WebhookUrl
+	case IntegrationTypeTelegram:
+		return d.TelegramChatId
+	case IntegrationTypePagerduty:
+		return d.PagerdutyIntegrationKey
+	case IntegrationTypeEmail:
+		return strings.Join(d.EmailRecipients, ",")
+	}
+	return ""
+}
+
+func (d NotificationDestination) Validate() error {
+	switch d.Type {
+	case IntegrationTypeSlack, IntegrationTypeTeams, IntegrationTypeWebhook, IntegrationTypePagerduty, IntegrationTypeEmail,
+		IntegrationTypeTelegram, IntegrationTypeMattermost, IntegrationTypeGoogleChat, IntegrationTypeDiscord:
+		if d.Target() == "" {
+			return fmt.Errorf("%s destination has no target", d.Type)
+		}
+	case IntegrationTypeOpsgenie:
+	default:
+		return fmt.Errorf("unknown destination: %s", d.Type)
+	}
+	return nil
+}
+
+func (rs NotificationRoutes) Validate() error {
+	for _, r := range rs {
+		if strings.TrimSpace(r.Name) == "" {
+			return fmt.Errorf("route name is required")
+		}
+		if !utils.GlobValidate(r.Match.Applications) || !utils.GlobValidate(r.Match.Namespaces) {
+			return fmt.Errorf("%s: invalid pattern", r.Name)
+		}
+		if len(r.Destinations) == 0 && len(r.Routes) == 0 {
+			return fmt.Errorf("%s: no destinations", r.Name)
+		}
+		for _, d := range r.Destinations {
+			if err := d.Validate(); err != nil {
+				return fmt.Errorf("%s: %w", r.Name, err)
+			}
+		}
+		if err := r.Routes.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Route returns the destinations for the subject, or nil if no route matches.
+func (rs NotificationRoutes) Route(s NotificationSubject) []NotificationDestination {
+	var res []NotificationDestination
+	for _, r := range rs {
+		if !r.Match.matches(s) {
+			continue
+		}
+		if ds := r.Routes.Route(s); len(ds) > 0 {
+			res = append(res, ds...)
+		} else {
+			res = append(res, r.Destinations...)
+		}
+		if !r.Continue {
+			break
+		}
+	}
+	return res
+}
+
+func (m NotificationRouteMatch) matches(s NotificationSubject) bool {
+	if len(m.Applications) > 0 && !utils.GlobMatch(s.ApplicationId.Name, m.Applications...) && !utils.GlobMatch(s.ApplicationId.String(), m.Applications...) {
+		return false
+	}
+	if len(m.Categories) > 0 && !slices.Contains(m.Categories, s.Category) {
+		return false
+	}
+	if len(m.Severities) > 0 && !slices.Contains(m.Severities, s.Severity) {
+		return false
+	}
+	if len(m.Namespaces) > 0 && !utils.GlobMatch(s.ApplicationId.Namespace, m.Namespaces...) {
+		return false
+	}
+	for k, v := range m.Labels {
+		if s.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// NotificationSubjectForIncident describes an incident for matching it against routes and escalation policies.
+func NotificationSubjectForIncident(project *Project, app *model.Application, appId model.ApplicationId, severity model.Status, details *IncidentNotificationDetails) NotificationSubject {
+	subject := NotificationSubject{ApplicationId: appId, Severity: severity, Labels: model.Labels{}}
+	if app != nil {
+		subject.Category = app.Category
+		for k, v := range app.Labels() {
+			subject.Labels[k] = v
+		}
+	} else {
+		subject.Category = model.CalcApplicationCategory(appId, project.Settings.ApplicationCategories)
+	}
+	if details != nil && details.AlertRule != nil {
+		for k, v := range details.AlertRule.Labels {
+			subject.Labels[k] = v
+		}
+	}
+	return subject
+}
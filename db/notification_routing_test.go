@@ -0,0 +1,63 @@
+package db
+
+import (
+	"testing"
+
+	"codexray/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationRoutes(t *testing.T) {
+	slack := func(channel string) []NotificationDestination {
+		return []NotificationDestination{{Type: IntegrationTypeSlack, SlackChannel: channel}}
+	}
+	routes := NotificationRoutes{
+		{
+			Name:         "databases",
+			Match:        NotificationRouteMatch{Categories: []model.ApplicationCategory{"databases"}},
+			Destinations: slack("dba"),
+			Routes: NotificationRoutes{
+				{Name: "critical", Match: NotificationRouteMatch{Severities: []model.Status{model.CRITICAL}}, Destinations: slack("dba-oncall")},
+			},
+		},
+		{Name: "audit", Match: NotificationRouteMatch{Labels: map[string]string{"team": "payments"}}, Destinations: slack("audit"), Continue: true},
+		{Name: "payments", Match: NotificationRouteMatch{Namespaces: []string{"payments-*"}}, Destinations: slack("payments")},
+		{Name: "checkout", Match: NotificationRouteMatch{Applications: []string{"*:Deployment:checkout"}}, Destinations: slack("checkout")},
+		{Name: "shadowed", Match: NotificationRouteMatch{Namespaces: []string{"payments-eu"}}, Destinations: slack("shadowed")},
+	}
+	subject := func(ns, name string, category model.ApplicationCategory, severity model.Status, labels model.Labels) NotificationSubject {
+		return NotificationSubject{
+			ApplicationId: model.NewApplicationId(ns, model.ApplicationKindDeployment, name),
+			Category:      category,
+			Severity:      severity,
+			Labels:        labels,
+		}
+	}
+	channels := func(ds []NotificationDestination) []string {
+		var res []string
+		for _, d := range ds {
+			res = append(res, d.SlackChannel)
+		}
+		return res
+	}
+
+	for _, c := range []struct {
+		name     string
+		subject  NotificationSubject
+		expected []string
+	}{
+		{"the deepest matching route wins", subject("db", "pg", "databases", model.CRITICAL, nil), []string{"dba-oncall"}},
+		{"the parent route if no child matches", subject("db", "pg", "databases", model.WARNING, nil), []string{"dba"}},
+		{"a matching route stops the evaluation", subject("payments-eu", "api", "", model.WARNING, nil), []string{"payments"}},
+		{"a route with Continue doesn't", subject("payments-eu", "api", "", model.WARNING, model.Labels{"team": "payments"}), []string{"audit", "payments"}},
+		{"by the application id", subject("shop", "checkout", "", model.WARNING, nil), []string{"checkout"}},
+		{"all the criteria must match", subject("db", "pg", "monitoring", model.CRITICAL, model.Labels{"team": "dba"}), nil},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, channels(routes.Route(c.subject)))
+		})
+	}
+
+	assert.Nil(t, NotificationRoutes(nil).Route(subject("db", "pg", "databases", model.CRITICAL, nil)))
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"codexray/model"
+	"codexray/timeseries"
 	"codexray/utils"
 )
 
@@ -32,6 +33,20 @@ type ProjectSettings struct {
 	CustomApplications          map[string]model.CustomApplication                        `json:"custom_applications"`
 	ApiKeys                     []ApiKey                                                  `json:"api_keys"`
 	TrustDomains                map[string]struct{}                                       `json:"trust_domain"`
+	NotificationRoutes          NotificationRoutes                                        `json:"notification_routes"`
+	Incidents                   IncidentSettings                                          `json:"incidents"`
+}
+
+// IncidentSettings reduce the noise caused by SLOs flapping around their thresholds and by failures of shared dependencies.
+type IncidentSettings struct {
+	// MinOpenDuration is how long an SLO must be violated before an incident is opened.
+	MinOpenDuration timeseries.Duration `json:"min_open_duration"`
+	// MinResolveDuration is how long an incident stays open at least before it can be resolved.
+	MinResolveDuration timeseries.Duration `json:"min_resolve_duration"`
+	// ResolveHysteresis is how long an SLO must be met before an incident is considered resolved.
+	ResolveHysteresis timeseries.Duration `json:"resolve_hysteresis"`
+	// GroupByDependency sends a single notification for incidents of applications sharing an unhealthy dependency.
+	GroupByDependency bool `json:"group_by_dependency"`
 }
 
 type ApplicationCategorySettings struct {
@@ -59,7 +74,8 @@ func (p *Project) Migrate(m *Migrator) error {
 	return nil
 }
 
-func (p *Project) applyDefaults() {
+// ApplyDefaults fills in the settings that have never been configured.
+func (p *Project) ApplyDefaults() {
 	if p.Prometheus.RefreshInterval == 0 {
 		p.Prometheus.RefreshInterval = DefaultRefreshInterval
 	}
@@ -136,7 +152,7 @@ func (db *DB) GetProjects() ([]*Project, error) {
 				return nil, err
 			}
 		}
-		p.applyDefaults()
+		p.ApplyDefaults()
 		res = append(res, &p)
 	}
 	return res, nil
@@ -182,7 +198,7 @@ func (db *DB) GetProject(id ProjectId) (*Project, error) {
 			return nil, err
 		}
 	}
-	p.applyDefaults()
+	p.ApplyDefaults()
 	return &p, nil
 }
 
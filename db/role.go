@@ -0,0 +1,131 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"codexray/rbac"
+)
+
+// CustomRole is a role defined in addition to the built-in ones; DB implements rbac.EditableRoleManager.
+type CustomRole rbac.Role
+
+func (r *CustomRole) Migrate(m *Migrator) error {
+	return m.Exec(`
+	CREATE TABLE IF NOT EXISTS role (
+		name TEXT NOT NULL PRIMARY KEY,
+		permissions TEXT NOT NULL
+	)`)
+}
+
+func (db *DB) GetRoles() ([]rbac.Role, error) {
+	rows, err := db.db.Query("SELECT name, permissions FROM role ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := slices.Clone(rbac.Roles)
+	var permissions string
+	for rows.Next() {
+		var r rbac.Role
+		if err := rows.Scan(&r.Name, &permissions); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(permissions), &r.Permissions); err != nil {
+			return nil, err
+		}
+		if r.Name.Builtin() {
+			continue
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (db *DB) CreateRole(role rbac.Role) error {
+	if role.Name.Builtin() {
+		return ErrConflict
+	}
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return err
+	}
+	_, err = db.db.Exec("INSERT INTO role (name, permissions) VALUES ($1, $2)", role.Name, string(permissions))
+	if db.IsUniqueViolationError(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// UpdateRole changes the permissions of the role; if the role is renamed, its users are reassigned to the new name.
+func (db *DB) UpdateRole(name rbac.RoleName, role rbac.Role) error {
+	if name.Builtin() {
+		return fmt.Errorf("%s is a built-in role", name)
+	}
+	if role.Name != name && role.Name.Builtin() {
+		return ErrConflict
+	}
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return err
+	}
+	users, err := db.GetUsers()
+	if err != nil {
+		return err
+	}
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	res, err := tx.Exec("UPDATE role SET name = $1, permissions = $2 WHERE name = $3", role.Name, string(permissions), name)
+	if db.IsUniqueViolationError(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if role.Name != name {
+		for _, u := range users {
+			i := slices.Index(u.Roles, name)
+			if i < 0 {
+				continue
+			}
+			u.Roles[i] = role.Name
+			roles, err := json.Marshal(u.Roles)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE users SET roles = $1 WHERE id = $2", string(roles), u.Id); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteRole deletes the role unless it is assigned to a user.
+func (db *DB) DeleteRole(name rbac.RoleName) error {
+	if name.Builtin() {
+		return fmt.Errorf("%s is a built-in role", name)
+	}
+	users, err := db.GetUsers()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if slices.Contains(u.Roles, name) {
+			return ErrConflict
+		}
+	}
+	_, err = db.db.Exec("DELETE FROM role WHERE name = $1", name)
+	return err
+}
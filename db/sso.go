@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"codexray/rbac"
+)
+
+const SSOSettingName = "sso"
+
+type SSOProvider string
+
+const (
+	SSOProviderOIDC SSOProvider = "oidc"
+)
+
+type SSOSettings struct {
+	Enabled  bool        `json:"enabled"`
+	Provider SSOProvider `json:"provider"`
+	// BaseUrl is the public URL of the instance used to build the callback URLs, e.g., https://codexray.example.com/
+	BaseUrl string `json:"base_url"`
+	// DefaultRole is assigned to users none of the role mappings apply to; if empty, such users cannot log in.
+	DefaultRole rbac.RoleName `json:"default_role"`
+	// RoleMapping assigns roles based on the values of the identity provider's claim or attribute (e.g., groups).
+	RoleMapping []SSORoleMapping `json:"role_mapping"`
+
+	OIDC *SSOSettingsOIDC `json:"oidc,omitempty"`
+}
+
+type SSOSettingsOIDC struct {
+	Issuer       string   `json:"issuer"`
+	ClientId     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	// RoleClaim is the ID token claim holding the values to map to roles; nested claims are addressed with dots, e.g., realm_access.roles.
+	RoleClaim string `json:"role_claim"`
+}
+
+type SSORoleMapping struct {
+	Value string        `json:"value"` // a glob pattern
+	Role  rbac.RoleName `json:"role"`
+}
+
+func (s *SSOSettings) Validate(roles []rbac.Role) error {
+	if !s.Enabled {
+		return nil
+	}
+	if u, err := url.Parse(s.BaseUrl); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid base URL")
+	}
+	if s.DefaultRole != "" && !s.DefaultRole.Valid(roles) {
+		return fmt.Errorf("unknown role: %s", s.DefaultRole)
+	}
+	for _, m := range s.RoleMapping {
+		if m.Value == "" || !m.Role.Valid(roles) {
+			return fmt.Errorf("invalid role mapping: %s -> %s", m.Value, m.Role)
+		}
+	}
+	switch s.Provider {
+	case SSOProviderOIDC:
+		if s.OIDC == nil || s.OIDC.Issuer == "" || s.OIDC.ClientId == "" {
+			return fmt.Errorf("issuer and client id are required")
+		}
+	default:
+		return fmt.Errorf("unknown provider: %s", s.Provider)
+	}
+	return nil
+}
+
+// CallbackUrl returns the URL the identity provider redirects users back to after authentication.
+func (s *SSOSettings) CallbackUrl() string {
+	return strings.TrimRight(s.BaseUrl, "/") + "/api/sso/" + string(s.Provider) + "/callback"
+}
+
+func (db *DB) GetSSOSettings() (*SSOSettings, error) {
+	var s SSOSettings
+	err := db.GetSetting(SSOSettingName, &s)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (db *DB) SaveSSOSettings(s *SSOSettings) error {
+	return db.SetSetting(SSOSettingName, s)
+}
+
+// ProvisionSSOUser creates a user authenticated by the identity provider or updates the existing one.
+// The roles are synced with the identity provider on every login. SSO users have no password, so they can't log in with one.
+// A local user with a password is never linked to an SSO identity, unless an admin has allowed it by AllowSSOLogin.
+func (db *DB) ProvisionSSOUser(email, name string, roles []rbac.RoleName) (int, error) {
+	data, err := json.Marshal(roles)
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	var password string
+	err = db.db.QueryRow("SELECT id, password FROM users WHERE email = $1", email).Scan(&id, &password)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if _, err = db.db.Exec("INSERT INTO users(email, name, password, roles) VALUES($1, $2, '', $3)", email, name, string(data)); err != nil {
+			return 0, err
+		}
+		err = db.db.QueryRow("SELECT id FROM users WHERE email = $1", email).Scan(&id)
+		return id, err
+	case err != nil:
+		return 0, err
+	}
+	if email == AdminUserLogin || password != "" {
+		return 0, ErrConflict
+	}
+	_, err = db.db.Exec("UPDATE users SET name = $1, roles = $2 WHERE id = $3", name, string(data), id)
+	return id, err
+}
+
+// AllowSSOLogin turns the local user into an SSO one by removing its password,
+// so the next SSO login with the same email is linked to the user.
+func (db *DB) AllowSSOLogin(id int) error {
+	_, err := db.db.Exec("UPDATE users SET password = '' WHERE id = $1 AND email != $2", id, AdminUserLogin)
+	return err
+}
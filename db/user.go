@@ -23,6 +23,8 @@ type User struct {
 	Name      string
 	Roles     []rbac.RoleName
 	Anonymous bool
+	// AccessToken is set if the user is authenticated by a personal access token.
+	AccessToken *AccessToken
 }
 
 func (u *User) Migrate(m *Migrator) error {
@@ -0,0 +1,197 @@
+// Package ha implements the active/standby mode of Postgres-backed deployments. The replica holding the primary lock
+// runs the watchers and queries Prometheus, while the standby ones copy its finalized cache chunks,
+// so a failover doesn't start with an empty cache.
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"codexray/cache"
+	"codexray/db"
+
+	"k8s.io/klog"
+)
+
+type Role string
+
+const (
+	RolePrimary Role = "primary"
+	RoleStandby Role = "standby"
+
+	primarySetting = "ha_primary"
+	roleInterval   = 10 * time.Second
+)
+
+type Config struct {
+	// AdvertiseUrl is the URL other replicas reach this one at, without the URL base path.
+	AdvertiseUrl string
+	// Token authenticates the replicas to each other. Cache replication is disabled if it's not set.
+	Token string
+	// ReplicationInterval is how often a standby replica checks the primary one for new chunks.
+	ReplicationInterval time.Duration
+}
+
+type ReplicationStatus struct {
+	PrimaryUrl     string    `json:"primary_url,omitempty"`
+	LastSync       time.Time `json:"last_sync,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	ReplicatedSize int64     `json:"replicated_bytes"`
+}
+
+type primaryInfo struct {
+	Url   string    `json:"url"`
+	Since time.Time `json:"since"`
+}
+
+type Node struct {
+	db         *db.DB
+	cfg        Config
+	cache      *cache.Cache
+	httpClient *http.Client
+
+	lock        sync.RWMutex
+	role        Role
+	replication ReplicationStatus
+
+	advertised bool
+}
+
+func NewNode(database *db.DB, cfg Config) *Node {
+	n := &Node{
+		db:         database,
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+	n.updateRole()
+	return n
+}
+
+// Start tracks the role of the replica and, if the replica is a standby one, replicates the cache from the primary.
+func (n *Node) Start(c *cache.Cache) {
+	n.cache = c
+	go func() {
+		for range time.Tick(roleInterval) {
+			n.updateRole()
+		}
+	}()
+	if !n.replicationEnabled() {
+		return
+	}
+	go func() {
+		for range time.Tick(n.cfg.ReplicationInterval) {
+			if n.IsStandby() {
+				n.replicate()
+			}
+		}
+	}()
+}
+
+func (n *Node) Role() Role {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.role
+}
+
+// IsStandby reports whether the replica copies the cache from the primary instead of querying Prometheus.
+func (n *Node) IsStandby() bool {
+	return n.replicationEnabled() && n.Role() == RoleStandby
+}
+
+func (n *Node) replicationEnabled() bool {
+	return n.cfg.Token != "" && n.db.Type() == db.TypePostgres
+}
+
+func (n *Node) updateRole() {
+	role := RoleStandby
+	if n.db.GetPrimaryLock(context.TODO()) {
+		role = RolePrimary
+	}
+	n.lock.Lock()
+	prev := n.role
+	n.role = role
+	n.lock.Unlock()
+	if role != prev {
+		klog.Infoln("replica role:", role)
+	}
+	if role != RolePrimary {
+		n.advertised = false
+		return
+	}
+	if !n.advertised && n.cfg.AdvertiseUrl != "" {
+		if err := n.db.SetSetting(primarySetting, primaryInfo{Url: n.cfg.AdvertiseUrl, Since: time.Now()}); err != nil {
+			klog.Errorln("failed to advertise the primary replica:", err)
+			return
+		}
+		n.advertised = true
+	}
+}
+
+func (n *Node) replicate() {
+	var primary primaryInfo
+	var size int64
+	err := n.db.GetSetting(primarySetting, &primary)
+	switch {
+	case errors.Is(err, db.ErrNotFound) || (err == nil && primary.Url == ""):
+		err = fmt.Errorf("the primary replica hasn't advertised its URL")
+	case err == nil:
+		size, err = n.replicateFrom(primary.Url)
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.replication.PrimaryUrl = primary.Url
+	n.replication.ReplicatedSize += size
+	if err != nil {
+		klog.Errorln("cache replication failed:", err)
+		n.replication.Error = err.Error()
+		return
+	}
+	n.replication.Error = ""
+	n.replication.LastSync = time.Now()
+}
+
+func (n *Node) replicateFrom(primaryUrl string) (int64, error) {
+	var queries []cache.ReplicaQuery
+	if err := n.get(primaryUrl+"/replication/chunks", func(resp *http.Response) error {
+		return json.NewDecoder(resp.Body).Decode(&queries)
+	}); err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, q := range n.cache.MissingChunks(queries) {
+		for _, ch := range q.Chunks {
+			err := n.get(fmt.Sprintf("%s/replication/chunks/%s/%s", primaryUrl, q.ProjectId, ch.Name), func(resp *http.Response) error {
+				size += max(resp.ContentLength, 0)
+				return n.cache.ImportChunk(q.ProjectId, q.Query, ch, resp.Body)
+			})
+			if err != nil {
+				return size, err
+			}
+		}
+	}
+	return size, nil
+}
+
+func (n *Node) get(url string, f func(resp *http.Response) error) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+n.cfg.Token)
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return f(resp)
+}
@@ -0,0 +1,97 @@
+package ha
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"codexray/cache"
+	"codexray/db"
+	"codexray/utils"
+
+	"github.com/gorilla/mux"
+	"k8s.io/klog"
+)
+
+// Health reports the role of the replica. With ?role=primary or ?role=standby it responds with 503
+// if the replica has a different role, so a load balancer can route traffic to the primary only.
+func (n *Node) Health(w http.ResponseWriter, r *http.Request) {
+	res := struct {
+		Role        Role               `json:"role"`
+		Replication *ReplicationStatus `json:"replication,omitempty"`
+	}{
+		Role: n.Role(),
+	}
+	if n.IsStandby() {
+		n.lock.RLock()
+		status := n.replication
+		n.lock.RUnlock()
+		res.Replication = &status
+	}
+	if role := r.URL.Query().Get("role"); role != "" && Role(role) != res.Role {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	utils.WriteJson(w, res)
+}
+
+// Chunks lists the finalized chunks of the primary replica.
+func (n *Node) Chunks(w http.ResponseWriter, r *http.Request) {
+	if !n.authorizeReplica(w, r) {
+		return
+	}
+	queries, err := n.cache.FinalizedChunks()
+	if err != nil {
+		klog.Errorln("failed to list chunks:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	if queries == nil {
+		queries = []cache.ReplicaQuery{}
+	}
+	utils.WriteJson(w, queries)
+}
+
+// Chunk sends a finalized chunk of the primary replica.
+func (n *Node) Chunk(w http.ResponseWriter, r *http.Request) {
+	if !n.authorizeReplica(w, r) {
+		return
+	}
+	vars := mux.Vars(r)
+	f, err := n.cache.OpenChunk(db.ProjectId(vars["project"]), vars["chunk"])
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "Chunk not found", http.StatusNotFound)
+			return
+		}
+		klog.Errorln("failed to open chunk:", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err = io.Copy(w, f); err != nil {
+		klog.Errorln("failed to send chunk:", err)
+	}
+}
+
+func (n *Node) authorizeReplica(w http.ResponseWriter, r *http.Request) bool {
+	if !n.replicationEnabled() {
+		http.Error(w, "Cache replication is disabled", http.StatusNotFound)
+		return false
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(n.cfg.Token)) != 1 {
+		http.Error(w, "", http.StatusUnauthorized)
+		return false
+	}
+	if n.Role() != RolePrimary {
+		http.Error(w, "Not the primary replica", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
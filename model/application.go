@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"codexray/timeseries"
 	"codexray/utils"
@@ -21,12 +22,16 @@ type Application struct {
 
 	DesiredInstances *timeseries.TimeSeries
 
+	HorizontalPodAutoscaler *HorizontalPodAutoscaler
+	PodDisruptionBudgets    []*PodDisruptionBudget
+
 	LatencySLIs      []*LatencySLI
 	AvailabilitySLIs []*AvailabilitySLI
 
-	Events      []*ApplicationEvent
-	Deployments []*ApplicationDeployment
-	Incidents   []*ApplicationIncident
+	Events           []*ApplicationEvent
+	KubernetesEvents []*KubernetesEvent
+	Deployments      []*ApplicationDeployment
+	Incidents        []*ApplicationIncident
 
 	LogMessages map[LogLevel]*LogMessages
 
@@ -86,6 +91,17 @@ func (app *Application) GetOrCreateInstance(name string, node *Node) *Instance {
 	return instance
 }
 
+// Clusters returns the clusters the instances of the application are running in.
+func (app *Application) Clusters() []string {
+	clusters := utils.NewStringSet()
+	for _, i := range app.Instances {
+		if c := i.Cluster(); c != "" {
+			clusters.Add(c)
+		}
+	}
+	return clusters.Items()
+}
+
 func (app *Application) Labels() Labels {
 	res := Labels{}
 	switch app.Id.Kind {
@@ -118,6 +134,9 @@ func (app *Application) Labels() Labels {
 	default:
 		res["ns"] = app.Id.Namespace
 	}
+	if clusters := app.Clusters(); len(clusters) > 0 {
+		res["cluster"] = strings.Join(clusters, ",")
+	}
 	for _, i := range app.Instances {
 		for _, c := range i.Containers {
 			for t := range c.ApplicationTypes {
@@ -100,6 +100,21 @@ type ApplicationDeploymentNotifications struct {
 	Webhook struct {
 		State ApplicationDeploymentState `json:"state"`
 	} `json:"webhook"`
+	Email struct {
+		State ApplicationDeploymentState `json:"state"`
+	} `json:"email"`
+	Telegram struct {
+		State ApplicationDeploymentState `json:"state"`
+	} `json:"telegram"`
+	Mattermost struct {
+		State ApplicationDeploymentState `json:"state"`
+	} `json:"mattermost"`
+	GoogleChat struct {
+		State ApplicationDeploymentState `json:"state"`
+	} `json:"googlechat"`
+	Discord struct {
+		State ApplicationDeploymentState `json:"state"`
+	} `json:"discord"`
 }
 
 type ApplicationDeploymentSummary struct {
@@ -13,6 +13,7 @@ const (
 	ApplicationEventTypeRollout
 	ApplicationEventTypeInstanceDown
 	ApplicationEventTypeInstanceUp
+	ApplicationEventTypeKubernetesWarning
 )
 
 type ApplicationEvent struct {
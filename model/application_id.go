@@ -17,6 +17,9 @@ type ApplicationId struct {
 	Namespace string
 	Kind      ApplicationKind
 	Name      string
+	// Cluster tells apart the same-named applications of different clusters,
+	// it's only set for the applications of the project's additional metric sources.
+	Cluster string
 }
 
 func NewApplicationId(ns string, kind ApplicationKind, name string) ApplicationId {
@@ -47,14 +50,28 @@ func NewApplicationIdFromString(src string) (ApplicationId, error) {
 	if len(parts) < 3 {
 		return ApplicationId{}, fmt.Errorf("invalid application id: %s", src)
 	}
-	return ApplicationId{Namespace: parts[0], Kind: ApplicationKind(parts[1]), Name: parts[2]}, nil
+	id := ApplicationId{Namespace: parts[0], Kind: ApplicationKind(parts[1]), Name: parts[2]}
+	if cluster, ns, ok := strings.Cut(id.Namespace, "/"); ok {
+		id.Cluster, id.Namespace = cluster, ns
+	}
+	return id, nil
+}
+
+// InCluster returns the id of the application in the cluster.
+func (a ApplicationId) InCluster(cluster string) ApplicationId {
+	a.Cluster = cluster
+	return a
 }
 
 func (a ApplicationId) IsZero() bool {
 	return a == ApplicationIdZero
 }
 
+// String returns the id in the form of namespace:kind:name, the namespace is prefixed with the cluster if it is set.
 func (a ApplicationId) String() string {
+	if a.Cluster != "" {
+		return fmt.Sprintf("%s/%s:%s:%s", a.Cluster, a.Namespace, a.Kind, a.Name)
+	}
 	return fmt.Sprintf("%s:%s:%s", a.Namespace, a.Kind, a.Name)
 }
 
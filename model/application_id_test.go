@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplicationIdString(t *testing.T) {
+	for _, s := range []string{
+		"default:Deployment:api",
+		"_:ExternalService:pg:5432",
+		"eu-west/default:Deployment:api",
+		"eu-west/_:Pod:api-7d9f",
+	} {
+		id, err := NewApplicationIdFromString(s)
+		require.NoError(t, err)
+		assert.Equal(t, s, id.String())
+		text, err := id.MarshalText()
+		require.NoError(t, err)
+		var parsed ApplicationId
+		require.NoError(t, parsed.UnmarshalText(text))
+		assert.Equal(t, id, parsed)
+	}
+
+	id := NewApplicationId("default", ApplicationKindDeployment, "api")
+	assert.Equal(t, "default:Deployment:api", id.String(), "the ids of a single cluster are not qualified")
+	assert.Equal(t, "eu-west/default:Deployment:api", id.InCluster("eu-west").String())
+	parsed, err := NewApplicationIdFromString("eu-west/default:Deployment:api")
+	require.NoError(t, err)
+	assert.Equal(t, ApplicationId{Namespace: "default", Kind: ApplicationKindDeployment, Name: "api", Cluster: "eu-west"}, parsed)
+
+	_, err = NewApplicationIdFromString("default:api")
+	assert.Error(t, err)
+}
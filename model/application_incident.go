@@ -8,8 +8,28 @@ type ApplicationIncident struct {
 	OpenedAt      timeseries.Time `json:"opened_at"`
 	ResolvedAt    timeseries.Time `json:"resolved_at"`
 	Severity      Status          `json:"severity"`
+	AlertRuleId   string          `json:"alert_rule_id,omitempty"`
+
+	AcknowledgedAt timeseries.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy int             `json:"acknowledged_by,omitempty"`
+	AssignedTo     int             `json:"assigned_to,omitempty"`
+	DeploymentId   string          `json:"deployment_id,omitempty"`
+	RootCause      string          `json:"root_cause,omitempty"`
+
+	// GroupKey is the key of the incident leading the group of incidents caused by a shared dependency GroupRoot.
+	GroupKey  string        `json:"group_key,omitempty"`
+	GroupRoot ApplicationId `json:"group_root,omitempty"`
 }
 
 func (i *ApplicationIncident) Resolved() bool {
 	return !i.ResolvedAt.IsZero()
 }
+
+// Grouped reports whether the incident's notifications are sent by another incident of its group.
+func (i *ApplicationIncident) Grouped() bool {
+	return i.GroupKey != "" && i.GroupKey != i.Key
+}
+
+func (i *ApplicationIncident) Acknowledged() bool {
+	return !i.AcknowledgedAt.IsZero()
+}
@@ -380,6 +380,9 @@ func EventsToAnnotations(events []*ApplicationEvent, ctx timeseries.Context) []A
 			case ApplicationEventTypeInstanceDown:
 				msgs = append(msgs, e.Details+" is down")
 				i = "mdi-alert-octagon-outline"
+			case ApplicationEventTypeKubernetesWarning:
+				msgs = append(msgs, e.Details)
+				i = "mdi-kubernetes"
 			}
 			if icon == "" {
 				icon = i
@@ -77,6 +77,10 @@ var Checks = struct {
 	InstanceAvailability   CheckConfig
 	DeploymentStatus       CheckConfig
 	InstanceRestarts       CheckConfig
+	KubernetesEvents       CheckConfig
+	HPAMaxReplicas         CheckConfig
+	PDBRolloutBlocked      CheckConfig
+	ResourceQuotaExhausted CheckConfig
 	RedisAvailability      CheckConfig
 	RedisLatency           CheckConfig
 	MongodbAvailability    CheckConfig
@@ -200,6 +204,36 @@ var Checks = struct {
 		MessageTemplate:         `app containers have been restarted {{.Count "time"}}`,
 		ConditionFormatTemplate: "the number of container restarts > <threshold>",
 	},
+	KubernetesEvents: CheckConfig{
+		Type:                    CheckTypeEventBased,
+		Title:                   "Kubernetes events",
+		DefaultThreshold:        0,
+		MessageTemplate:         `Kubernetes has reported {{.Count "warning event"}}`,
+		ConditionFormatTemplate: "the number of Warning events (FailedScheduling, BackOff, Evicted, FailedMount, etc.) > <threshold>",
+	},
+	HPAMaxReplicas: CheckConfig{
+		Type:                    CheckTypeManual,
+		Title:                   "HPA at max replicas",
+		DefaultThreshold:        80,
+		Unit:                    CheckUnitPercent,
+		MessageTemplate:         `the HorizontalPodAutoscaler has reached its max replicas while CPU usage is {{.Value}} of the requested`,
+		ConditionFormatTemplate: "the HPA is at max replicas and CPU usage > <threshold> of the requested CPU",
+	},
+	PDBRolloutBlocked: CheckConfig{
+		Type:                    CheckTypeManual,
+		Title:                   "Rollout blocked by PDB",
+		DefaultThreshold:        0,
+		MessageTemplate:         `the rollout is blocked by a PodDisruptionBudget that allows no disruptions`,
+		ConditionFormatTemplate: "a rollout is in progress while a PodDisruptionBudget allows no disruptions",
+	},
+	ResourceQuotaExhausted: CheckConfig{
+		Type:                    CheckTypeItemBased,
+		Title:                   "Resource quota",
+		DefaultThreshold:        100,
+		Unit:                    CheckUnitPercent,
+		MessageTemplate:         `{{.ItemsWithToBe "namespace resource quota"}} exhausted`,
+		ConditionFormatTemplate: "the usage of a namespace resource quota >= <threshold>",
+	},
 	DeploymentStatus: CheckConfig{
 		Type:                    CheckTypeValueBased,
 		Title:                   "Deployment status",
@@ -688,6 +722,23 @@ func (cc CheckConfigs) GetLatency(appId ApplicationId, category ApplicationCateg
 	return res[0], false
 }
 
+// ValidateCheckConfig checks that the raw config can be used for the check.
+func ValidateCheckConfig(checkId CheckId, raw json.RawMessage) error {
+	var err error
+	switch checkId {
+	case Checks.SLOAvailability.Id:
+		_, err = unmarshal[[]CheckConfigSLOAvailability](raw)
+	case Checks.SLOLatency.Id:
+		_, err = unmarshal[[]CheckConfigSLOLatency](raw)
+	default:
+		if Checks.index[checkId] == nil {
+			return fmt.Errorf("unknown check: %s", checkId)
+		}
+		_, err = unmarshal[CheckConfigSimple](raw)
+	}
+	return err
+}
+
 func unmarshal[T any](raw json.RawMessage) (T, error) {
 	var cfg T
 	if err := json.Unmarshal(raw, &cfg); err != nil {
@@ -117,6 +117,13 @@ func (instance *Instance) NodeName() string {
 	return ""
 }
 
+func (instance *Instance) Cluster() string {
+	if instance.Node != nil {
+		return instance.Node.Cluster.Value()
+	}
+	return ""
+}
+
 func (instance *Instance) NodeId() NodeId {
 	if instance.Node != nil {
 		return instance.Node.Id
@@ -24,6 +24,7 @@ const (
 	ApplicationKindNomadJobGroup      ApplicationKind = "NomadJobGroup"
 	ApplicationKindArgoWorkflow       ApplicationKind = "Workflow"
 	ApplicationKindSparkApplication   ApplicationKind = "SparkApplication"
+	ApplicationKindAlertRule          ApplicationKind = "AlertRule"
 )
 
 type Job struct{}
@@ -53,6 +54,81 @@ type StatefulSet struct {
 	ReplicasUpdated *timeseries.TimeSeries
 }
 
+type HorizontalPodAutoscaler struct {
+	Name            string
+	MinReplicas     *timeseries.TimeSeries
+	MaxReplicas     *timeseries.TimeSeries
+	CurrentReplicas *timeseries.TimeSeries
+	DesiredReplicas *timeseries.TimeSeries
+}
+
+func (hpa *HorizontalPodAutoscaler) AtMaxReplicas() bool {
+	current, max := hpa.CurrentReplicas.Last(), hpa.MaxReplicas.Last()
+	if timeseries.IsNaN(current) || timeseries.IsNaN(max) || max == 0 {
+		return false
+	}
+	return current >= max
+}
+
+type PodDisruptionBudget struct {
+	Name               string
+	DisruptionsAllowed *timeseries.TimeSeries
+	CurrentHealthy     *timeseries.TimeSeries
+	DesiredHealthy     *timeseries.TimeSeries
+	ExpectedPods       *timeseries.TimeSeries
+}
+
+func (pdb *PodDisruptionBudget) BlocksDisruptions() bool {
+	allowed, expected := pdb.DisruptionsAllowed.Last(), pdb.ExpectedPods.Last()
+	if timeseries.IsNaN(allowed) || timeseries.IsNaN(expected) || expected == 0 {
+		return false
+	}
+	return allowed <= 0
+}
+
+type ResourceQuota struct {
+	Name     string
+	Resource string
+	Hard     *timeseries.TimeSeries
+	Used     *timeseries.TimeSeries
+}
+
+func (q *ResourceQuota) UsagePercent() float32 {
+	hard, used := q.Hard.Last(), q.Used.Last()
+	if timeseries.IsNaN(hard) || timeseries.IsNaN(used) || hard == 0 {
+		return timeseries.NaN
+	}
+	return used / hard * 100
+}
+
+type KubernetesEventType string
+
+const (
+	KubernetesEventTypeNormal  KubernetesEventType = "Normal"
+	KubernetesEventTypeWarning KubernetesEventType = "Warning"
+)
+
+type KubernetesEvent struct {
+	Uid        string
+	FirstSeen  timeseries.Time
+	LastSeen   timeseries.Time
+	Type       KubernetesEventType
+	Reason     string
+	Message    string
+	Count      int64
+	Namespace  string
+	ObjectKind string
+	ObjectName string
+}
+
+func (e *KubernetesEvent) IsWarning() bool {
+	return e.Type == KubernetesEventTypeWarning
+}
+
+func (e *KubernetesEvent) Object() string {
+	return e.ObjectKind + "/" + e.ObjectName
+}
+
 type Service struct {
 	Name      string
 	Namespace string
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"codexray/timeseries"
+	"codexray/utils"
+)
+
+type MaintenanceWindowAction string
+
+const (
+	// MaintenanceWindowActionSuppress drops notifications of incidents that change state during the window.
+	MaintenanceWindowActionSuppress MaintenanceWindowAction = "suppress"
+	// MaintenanceWindowActionDefer holds notifications until the window ends and sends them only if the incident is still open.
+	MaintenanceWindowActionDefer MaintenanceWindowAction = "defer"
+)
+
+type MaintenanceWindow struct {
+	Id     string                  `json:"id"`
+	Name   string                  `json:"name"`
+	Action MaintenanceWindowAction `json:"action"`
+
+	// one-off windows
+	Start timeseries.Time `json:"start"`
+	End   timeseries.Time `json:"end"`
+
+	Recurrence *MaintenanceWindowRecurrence `json:"recurrence,omitempty"`
+
+	// an empty scope means the whole project
+	Applications []string              `json:"applications"`
+	Categories   []ApplicationCategory `json:"categories"`
+	Nodes        []string              `json:"nodes"`
+}
+
+type MaintenanceWindowRecurrence struct {
+	Weekdays  []time.Weekday      `json:"weekdays"` // empty means every day
+	StartTime string              `json:"start_time"`
+	Duration  timeseries.Duration `json:"duration"`
+	Timezone  string              `json:"timezone"`
+}
+
+type MaintenanceWindowInterval struct {
+	Start timeseries.Time
+	End   timeseries.Time
+}
+
+func (mw *MaintenanceWindow) Validate() error {
+	if mw.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch mw.Action {
+	case MaintenanceWindowActionSuppress, MaintenanceWindowActionDefer:
+	default:
+		return fmt.Errorf("unknown action: %s", mw.Action)
+	}
+	if !utils.GlobValidate(mw.Applications) || !utils.GlobValidate(mw.Nodes) {
+		return fmt.Errorf("invalid pattern")
+	}
+	if r := mw.Recurrence; r != nil {
+		if _, err := time.Parse("15:04", r.StartTime); err != nil {
+			return fmt.Errorf("invalid start time: %s", r.StartTime)
+		}
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %s", r.Timezone)
+		}
+		if r.Duration <= 0 {
+			return fmt.Errorf("invalid duration")
+		}
+		return nil
+	}
+	if mw.Start.IsZero() || !mw.End.After(mw.Start) {
+		return fmt.Errorf("invalid time range")
+	}
+	return nil
+}
+
+// Intervals returns the periods when the window is active that overlap with [from, to].
+func (mw *MaintenanceWindow) Intervals(from, to timeseries.Time) []MaintenanceWindowInterval {
+	r := mw.Recurrence
+	if r == nil {
+		if mw.End.Before(from) || mw.Start.After(to) {
+			return nil
+		}
+		return []MaintenanceWindowInterval{{Start: mw.Start, End: mw.End}}
+	}
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return nil
+	}
+	startTime, err := time.Parse("15:04", r.StartTime)
+	if err != nil {
+		return nil
+	}
+	var res []MaintenanceWindowInterval
+	day := from.Add(-r.Duration).ToStandard().In(loc)
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	for ; !day.After(to.ToStandard()); day = day.AddDate(0, 0, 1) {
+		if len(r.Weekdays) > 0 && !slices.Contains(r.Weekdays, day.Weekday()) {
+			continue
+		}
+		s := time.Date(day.Year(), day.Month(), day.Day(), startTime.Hour(), startTime.Minute(), 0, 0, loc)
+		i := MaintenanceWindowInterval{Start: timeseries.Time(s.Unix())}
+		i.End = i.Start.Add(r.Duration)
+		if i.End.Before(from) || i.Start.After(to) {
+			continue
+		}
+		res = append(res, i)
+	}
+	return res
+}
+
+// ActiveAt returns the end of the window's interval containing t, or zero if the window isn't active at t.
+func (mw *MaintenanceWindow) ActiveAt(t timeseries.Time) timeseries.Time {
+	for _, i := range mw.Intervals(t, t) {
+		if !t.Before(i.Start) && t.Before(i.End) {
+			return i.End
+		}
+	}
+	return 0
+}
+
+// Matches reports whether the window covers the application. A nil application is covered only by project-wide windows.
+func (mw *MaintenanceWindow) Matches(app *Application) bool {
+	if len(mw.Applications) == 0 && len(mw.Categories) == 0 && len(mw.Nodes) == 0 {
+		return true
+	}
+	if app == nil {
+		return false
+	}
+	if utils.GlobMatch(app.Id.Name, mw.Applications...) || utils.GlobMatch(app.Id.String(), mw.Applications...) {
+		return true
+	}
+	if slices.Contains(mw.Categories, app.Category) {
+		return true
+	}
+	if len(mw.Nodes) > 0 {
+		for _, i := range app.Instances {
+			if name := i.NodeName(); name != "" && utils.GlobMatch(name, mw.Nodes...) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func MaintenanceWindowsToAnnotations(windows []*MaintenanceWindow, app *Application, ctx timeseries.Context) []Annotation {
+	var res []Annotation
+	for _, mw := range windows {
+		if !mw.Matches(app) {
+			continue
+		}
+		for _, i := range mw.Intervals(ctx.From, ctx.To) {
+			res = append(res, Annotation{Name: "maintenance: " + mw.Name, X1: i.Start, X2: i.End, Icon: "mdi-wrench-clock"})
+		}
+	}
+	return res
+}
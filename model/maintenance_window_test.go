@@ -0,0 +1,77 @@
+package model
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"codexray/timeseries"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindowActiveAt(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	at := func(s string) timeseries.Time {
+		tt, err := time.ParseInLocation("2006-01-02 15:04", s, berlin)
+		require.NoError(t, err)
+		return timeseries.Time(tt.Unix())
+	}
+	weekends := &MaintenanceWindow{Recurrence: &MaintenanceWindowRecurrence{
+		Weekdays:  []time.Weekday{time.Saturday, time.Sunday},
+		StartTime: "23:00",
+		Duration:  2 * timeseries.Hour,
+		Timezone:  "Europe/Berlin",
+	}}
+	daily := &MaintenanceWindow{Recurrence: &MaintenanceWindowRecurrence{
+		StartTime: "09:00",
+		Duration:  timeseries.Hour,
+		Timezone:  "Europe/Berlin",
+	}}
+	oneOff := &MaintenanceWindow{Start: at("2024-03-09 10:00"), End: at("2024-03-09 12:00")}
+
+	for _, c := range []struct {
+		name   string
+		window *MaintenanceWindow
+		t      string
+		end    string // empty if the window isn't active
+	}{
+		{"Saturday's window", weekends, "2024-03-09 23:30", "2024-03-10 01:00"},
+		{"Saturday's window past midnight", weekends, "2024-03-10 00:30", "2024-03-10 01:00"},
+		{"the end is exclusive", weekends, "2024-03-10 01:00", ""},
+		{"Friday is not in the weekdays", weekends, "2024-03-08 23:30", ""},
+		{"Sunday's window past midnight on Monday", weekends, "2024-03-11 00:59", "2024-03-11 01:00"},
+		{"the start is inclusive", weekends, "2024-03-16 23:00", "2024-03-17 01:00"},
+		{"before the window", daily, "2024-03-30 08:59", ""},
+		{"every day in standard time", daily, "2024-03-30 09:30", "2024-03-30 10:00"},
+		{"the local time is kept in daylight saving time", daily, "2024-04-01 09:30", "2024-04-01 10:00"},
+		{"the UTC offset changes during the day", daily, "2024-03-31 09:00", "2024-03-31 10:00"},
+		{"after the DST change back", daily, "2024-10-27 09:59", "2024-10-27 10:00"},
+		{"a one-off window", oneOff, "2024-03-09 11:00", "2024-03-09 12:00"},
+		{"after a one-off window", oneOff, "2024-03-09 12:00", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var expected timeseries.Time
+			if c.end != "" {
+				expected = at(c.end)
+			}
+			assert.Equal(t, expected, c.window.ActiveAt(at(c.t)))
+		})
+	}
+
+	intervals := weekends.Intervals(at("2024-03-04 00:00"), at("2024-03-18 00:00"))
+	var starts []timeseries.Time
+	for _, i := range intervals {
+		starts = append(starts, i.Start)
+		assert.Equal(t, 2*timeseries.Hour, i.End.Sub(i.Start))
+	}
+	assert.Equal(t, []timeseries.Time{
+		at("2024-03-03 23:00"), // overlaps with the start of the range
+		at("2024-03-09 23:00"), at("2024-03-10 23:00"), at("2024-03-16 23:00"), at("2024-03-17 23:00"),
+	}, starts)
+
+	invalid := &MaintenanceWindow{Recurrence: &MaintenanceWindowRecurrence{StartTime: "09:00", Duration: timeseries.Hour, Timezone: "Mars/Olympus"}}
+	assert.Empty(t, invalid.Intervals(at("2024-03-04 00:00"), at("2024-03-18 00:00")))
+}
@@ -61,6 +61,8 @@ type Node struct {
 	Name    LabelLastValue
 	K8sName LabelLastValue
 	Id      NodeId
+	// Cluster is the cluster label of the metric source the node is monitored by.
+	Cluster LabelLastValue
 	Uptime  *timeseries.TimeSeries
 
 	CpuCapacity     *timeseries.TimeSeries
@@ -31,6 +31,21 @@ func (s Status) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.String())
 }
 
+func (s *Status) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	for _, st := range []Status{OK, INFO, WARNING, CRITICAL} {
+		if st.String() == str {
+			*s = st
+			return nil
+		}
+	}
+	*s = UNKNOWN
+	return nil
+}
+
 func (s Status) Color() string {
 	switch s {
 	case OK:
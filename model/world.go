@@ -2,6 +2,7 @@ package model
 
 import (
 	"codexray/timeseries"
+	"codexray/utils"
 )
 
 type IntegrationStatus struct {
@@ -25,6 +26,10 @@ type World struct {
 	Applications    map[ApplicationId]*Application
 	appsByNsAndName map[nsAndName]*Application
 
+	ResourceQuotas map[string][]*ResourceQuota
+
+	MaintenanceWindows []*MaintenanceWindow
+
 	AWS AWS
 
 	IntegrationStatus IntegrationStatus
@@ -39,11 +44,23 @@ func NewWorld(from, to timeseries.Time, step, rawStep timeseries.Duration) *Worl
 	return &World{
 		Ctx:                timeseries.Context{From: from, To: to, Step: step, RawStep: rawStep},
 		Applications:       map[ApplicationId]*Application{},
+		ResourceQuotas:     map[string][]*ResourceQuota{},
 		AWS:                AWS{DiscoveryErrors: map[string]bool{}},
 		CustomApplications: map[string]CustomApplication{},
 	}
 }
 
+// Clusters returns the clusters of the nodes, which are known if the project has several metric sources.
+func (w *World) Clusters() []string {
+	clusters := utils.NewStringSet()
+	for _, n := range w.Nodes {
+		if c := n.Cluster.Value(); c != "" {
+			clusters.Add(c)
+		}
+	}
+	return clusters.Items()
+}
+
 func (w *World) GetApplication(id ApplicationId) *Application {
 	return w.Applications[id]
 }
@@ -76,3 +93,13 @@ func (w *World) GetNode(name string) *Node {
 	}
 	return nil
 }
+
+// GetClusterNode is GetNode limited to the nodes of the cluster, the same node names may be used in different clusters.
+func (w *World) GetClusterNode(cluster, name string) *Node {
+	for _, n := range w.Nodes {
+		if n.Cluster.Value() == cluster && (n.Name.Value() == name || n.K8sName.Value() == name) {
+			return n
+		}
+	}
+	return nil
+}
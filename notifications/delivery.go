@@ -0,0 +1,136 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/timeseries"
+
+	"github.com/PagerDuty/go-pagerduty"
+	"github.com/opsgenie/opsgenie-go-sdk-v2/client"
+)
+
+type httpStatusError struct {
+	code   int
+	status string
+	body   string
+}
+
+func newHttpStatusError(resp *http.Response, body []byte, readErr error) error {
+	e := &httpStatusError{code: resp.StatusCode, status: resp.Status}
+	if readErr == nil {
+		e.body = string(body)
+	}
+	return e
+}
+
+func (e *httpStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("response status: %s", e.status)
+	}
+	return fmt.Sprintf("%s: %s", e.status, e.body)
+}
+
+func (e *httpStatusError) HTTPStatusCode() int {
+	return e.code
+}
+
+// deliveryResult describes an attempt to send a notification to the destination.
+func deliveryResult(destination db.IntegrationType, start time.Time, err error) db.NotificationDelivery {
+	d := db.NotificationDelivery{AttemptedAt: timeseries.Now(), Latency: time.Since(start)}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	switch {
+	case destination == db.IntegrationTypeEmail:
+	case err == nil:
+		d.StatusCode = http.StatusOK
+	default:
+		d.StatusCode = httpStatusCode(err)
+	}
+	return d
+}
+
+func httpStatusCode(err error) int {
+	var withCode interface{ HTTPStatusCode() int } // our clients and Slack
+	if errors.As(err, &withCode) {
+		return withCode.HTTPStatusCode()
+	}
+	var pdErr pagerduty.EventsAPIV2Error
+	if errors.As(err, &pdErr) {
+		return pdErr.StatusCode
+	}
+	var ogErr *client.ApiError
+	if errors.As(err, &ogErr) {
+		return ogErr.StatusCode
+	}
+	return 0
+}
+
+type TestDelivery struct {
+	Notification string `json:"notification"`
+	StatusCode   int    `json:"status_code"`
+	Error        string `json:"error"`
+	LatencyMs    int64  `json:"latency_ms"`
+}
+
+// SendTestNotifications sends a sample incident and, if the destination supports it, a sample deployment
+// using the stored configuration of the integration.
+func SendTestNotifications(ctx context.Context, project *db.Project, destination db.IntegrationType) ([]TestDelivery, error) {
+	c := newClient(destination, project, "")
+	if c == nil {
+		return nil, fmt.Errorf("%s is not configured", destination)
+	}
+	var res []TestDelivery
+	start := time.Now()
+	err := c.SendIncident(ctx, project.Settings.Integrations.BaseUrl, TestIncidentNotification(project))
+	res = append(res, testDelivery("incident", destination, start, err))
+	if dc, ok := c.(DeploymentNotificationClient); ok {
+		start = time.Now()
+		err = dc.SendDeployment(ctx, project, TestDeploymentNotification())
+		res = append(res, testDelivery("deployment", destination, start, err))
+	}
+	return res, nil
+}
+
+func testDelivery(notification string, destination db.IntegrationType, start time.Time, err error) TestDelivery {
+	d := deliveryResult(destination, start, err)
+	return TestDelivery{Notification: notification, StatusCode: d.StatusCode, Error: d.Error, LatencyMs: d.Latency.Milliseconds()}
+}
+
+func TestIncidentNotification(project *db.Project) *db.IncidentNotification {
+	return &db.IncidentNotification{
+		ProjectId:     project.Id,
+		ApplicationId: model.NewApplicationId("default", model.ApplicationKindDeployment, "test-alert-fake-app"),
+		IncidentKey:   "123ab456",
+		Status:        model.WARNING,
+		Details: &db.IncidentNotificationDetails{
+			Reports: []db.IncidentNotificationDetailsReport{
+				{Name: model.AuditReportSLO, Check: model.Checks.SLOLatency.Title, Message: "error budget burn rate is 20x within 1 hour"},
+				{Name: model.AuditReportNetwork, Check: model.Checks.NetworkRTT.Title, Message: "high network latency to 2 upstream services"},
+			},
+		},
+	}
+}
+
+func TestDeploymentNotification() model.ApplicationDeploymentStatus {
+	return model.ApplicationDeploymentStatus{
+		Status: model.OK,
+		State:  model.ApplicationDeploymentStateSummary,
+		Summary: []model.ApplicationDeploymentSummary{
+			{Report: model.AuditReportSLO, Ok: false, Message: "Availability: 87% (objective: 99%)"},
+			{Report: model.AuditReportCPU, Ok: false, Message: "CPU usage: +21% (+$37/mo) compared to the previous deployment"},
+			{Report: model.AuditReportCPU, Ok: true, Message: "Memory: looks like the memory leak has been fixed"},
+		},
+		Deployment: &model.ApplicationDeployment{
+			ApplicationId: model.NewApplicationId("default", model.ApplicationKindDeployment, "test-deployment-fake-app"),
+			Name:          "123ab456",
+			Details:       &model.ApplicationDeploymentDetails{ContainerImages: []string{"app:v1.8.2"}},
+		},
+	}
+}
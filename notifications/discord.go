@@ -0,0 +1,101 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"codexray/db"
+	"codexray/model"
+)
+
+type Discord struct {
+	webhookUrl string
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Url         string              `json:"url,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Color       int64               `json:"color"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func NewDiscord(webhookUrl string) *Discord {
+	return &Discord{webhookUrl: webhookUrl}
+}
+
+func (d *Discord) SendIncident(ctx context.Context, baseUrl string, n *db.IncidentNotification) error {
+	var title string
+	if n.Status == model.OK {
+		title = fmt.Sprintf("%s incident resolved", n.ApplicationId.Name)
+	} else {
+		title = fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status.String()), incidentSummary(n, plain))
+	}
+	var text strings.Builder
+	if n.Details != nil {
+		for _, r := range n.Details.Reports {
+			text.WriteString(fmt.Sprintf("• **%s** / %s: %s\n", r.Name, r.Check, r.Message))
+		}
+	}
+	return d.send(ctx, discordEmbed{
+		Title:       title,
+		Url:         incidentUrl(baseUrl, n),
+		Description: text.String(),
+		Color:       discordColor(n.Status),
+	})
+}
+
+func (d *Discord) SendDeployment(ctx context.Context, project *db.Project, ds model.ApplicationDeploymentStatus) error {
+	dep := ds.Deployment
+
+	status := "Deployed"
+	switch ds.State {
+	case model.ApplicationDeploymentStateInProgress:
+		return nil
+	case model.ApplicationDeploymentStateStuck:
+		status = "Stuck"
+	case model.ApplicationDeploymentStateCancelled:
+		status = "Cancelled"
+	}
+
+	e := discordEmbed{
+		Title: fmt.Sprintf("Deployment of %s to %s", dep.ApplicationId.Name, project.Name),
+		Url:   deploymentUrl(project.Settings.Integrations.BaseUrl, project.Id, dep),
+		Color: discordColor(ds.Status),
+		Fields: []discordEmbedField{
+			{Name: "Status", Value: status, Inline: true},
+			{Name: "Version", Value: dep.Version(), Inline: true},
+		},
+	}
+	if ds.State == model.ApplicationDeploymentStateSummary {
+		summary := "No notable changes"
+		if len(ds.Summary) > 0 {
+			var lines []string
+			for _, s := range ds.Summary {
+				lines = append(lines, fmt.Sprintf("%s %s", s.Emoji(), s.Message))
+			}
+			summary = strings.Join(lines, "\n")
+		}
+		e.Fields = append(e.Fields, discordEmbedField{Name: "Summary", Value: summary})
+	}
+	return d.send(ctx, e)
+}
+
+func (d *Discord) send(ctx context.Context, e discordEmbed) error {
+	_, err := postJson(ctx, d.webhookUrl, map[string]any{"embeds": []discordEmbed{e}})
+	return err
+}
+
+// discordColor converts the status color to the integer Discord expects.
+func discordColor(s model.Status) int64 {
+	c, _ := strconv.ParseInt(strings.TrimPrefix(s.Color(), "#"), 16, 64)
+	return c
+}
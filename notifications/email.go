@@ -0,0 +1,194 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"codexray/db"
+	"codexray/model"
+)
+
+type Email struct {
+	cfg                   *db.IntegrationEmail
+	applicationCategories map[model.ApplicationCategory][]string
+}
+
+func NewEmail(cfg *db.IntegrationEmail, applicationCategories map[model.ApplicationCategory][]string) *Email {
+	return &Email{cfg: cfg, applicationCategories: applicationCategories}
+}
+
+func (e *Email) SendIncident(ctx context.Context, baseUrl string, n *db.IncidentNotification) error {
+	var subject string
+	if n.Status == model.OK {
+		subject = fmt.Sprintf("%s incident resolved", n.ApplicationId.Name)
+	} else {
+		subject = fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status.String()), incidentSummary(n, plain))
+	}
+	url := incidentUrl(baseUrl, n)
+
+	var text, htm strings.Builder
+	htm.WriteString("<h3>" + html.EscapeString(subject) + "</h3>")
+	text.WriteString(subject + "\n\n")
+	if n.Details != nil && len(n.Details.Reports) > 0 {
+		htm.WriteString("<ul>")
+		for _, r := range n.Details.Reports {
+			text.WriteString(fmt.Sprintf("• %s / %s: %s\n", r.Name, r.Check, r.Message))
+			htm.WriteString(fmt.Sprintf("<li><b>%s</b> / %s: %s</li>",
+				html.EscapeString(string(r.Name)), html.EscapeString(r.Check), html.EscapeString(r.Message)))
+		}
+		htm.WriteString("</ul>")
+		text.WriteString("\n")
+	}
+	text.WriteString("View incident: " + url + "\n")
+	htm.WriteString(fmt.Sprintf(`<p><a href="%s">View incident</a></p>`, html.EscapeString(url)))
+
+	category := model.CalcApplicationCategory(n.ApplicationId, e.applicationCategories)
+	return e.send(ctx, e.cfg.GetRecipients(category), subject, text.String(), htm.String())
+}
+
+func (e *Email) SendDeployment(ctx context.Context, project *db.Project, ds model.ApplicationDeploymentStatus) error {
+	d := ds.Deployment
+
+	status := "Deployed"
+	switch ds.State {
+	case model.ApplicationDeploymentStateInProgress:
+		return nil
+	case model.ApplicationDeploymentStateStuck:
+		status = "Stuck"
+	case model.ApplicationDeploymentStateCancelled:
+		status = "Cancelled"
+	}
+	subject := fmt.Sprintf("Deployment of %s to %s: %s", d.ApplicationId.Name, project.Name, status)
+	url := deploymentUrl(project.Settings.Integrations.BaseUrl, project.Id, d)
+
+	var text, htm strings.Builder
+	htm.WriteString("<h3>" + html.EscapeString(subject) + "</h3>")
+	htm.WriteString("<p>Version: " + html.EscapeString(d.Version()) + "</p>")
+	text.WriteString(subject + "\n\nVersion: " + d.Version() + "\n\n")
+	if ds.State == model.ApplicationDeploymentStateSummary {
+		htm.WriteString("<p><b>Summary</b></p><ul>")
+		text.WriteString("Summary:\n")
+		if len(ds.Summary) == 0 {
+			htm.WriteString("<li>No notable changes</li>")
+			text.WriteString("No notable changes\n")
+		}
+		for _, s := range ds.Summary {
+			htm.WriteString(fmt.Sprintf("<li>%s %s</li>", s.Emoji(), html.EscapeString(s.Message)))
+			text.WriteString(fmt.Sprintf("%s %s\n", s.Emoji(), s.Message))
+		}
+		htm.WriteString("</ul>")
+		text.WriteString("\n")
+	}
+	text.WriteString("View deployment: " + url + "\n")
+	htm.WriteString(fmt.Sprintf(`<p><a href="%s">View deployment</a></p>`, html.EscapeString(url)))
+
+	category := model.CalcApplicationCategory(d.ApplicationId, project.Settings.ApplicationCategories)
+	return e.send(ctx, e.cfg.GetRecipients(category), subject, text.String(), htm.String())
+}
+
+func (e *Email) send(ctx context.Context, to []string, subject, text, html string) error {
+	if len(to) == 0 {
+		return nil
+	}
+	msg, err := composeEmail(e.cfg.From, to, subject, text, html)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(e.cfg.Host, strconv.Itoa(e.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: e.cfg.Host, InsecureSkipVerify: e.cfg.TlsSkipVerify}
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if e.cfg.TLSMode == db.EmailTLSModeTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, e.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if e.cfg.TLSMode == db.EmailTLSModeStartTLS {
+		if err = c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if a := e.cfg.Auth; a != nil && a.User != "" {
+		if err = c.Auth(smtp.PlainAuth("", a.User, a.Password, e.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(e.cfg.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err = c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// composeEmail builds a multipart/alternative message with plain-text and HTML bodies.
+func composeEmail(from string, to []string, subject, text, html string) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err = pw.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: multipart/alternative; boundary=" + mw.Boundary() + "\r\n\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
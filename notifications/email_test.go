@@ -0,0 +1,199 @@
+package notifications
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type smtpMessage struct {
+	tls  bool
+	auth string
+	from string
+	to   []string
+	data []byte
+}
+
+// serveSMTP accepts a single SMTP session on l, a minimal stand-in for a mail server.
+// With tlsConfig set, it advertises STARTTLS.
+func serveSMTP(t *testing.T, l net.Listener, tlsConfig *tls.Config) <-chan smtpMessage {
+	res := make(chan smtpMessage, 1)
+	go func() {
+		defer close(res)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msg smtpMessage
+		_, msg.tls = conn.(*tls.Conn)
+		tp := textproto.NewConn(conn)
+		reply := func(lines ...string) {
+			_ = tp.PrintfLine("%s", strings.Join(lines, "\r\n"))
+		}
+		reply("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			cmd, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(cmd) {
+			case "EHLO", "HELO":
+				if tlsConfig != nil && !msg.tls {
+					reply("250-localhost", "250-STARTTLS", "250 AUTH PLAIN")
+				} else {
+					reply("250-localhost", "250 AUTH PLAIN")
+				}
+			case "STARTTLS":
+				reply("220 ready to start TLS")
+				tlsConn := tls.Server(conn, tlsConfig)
+				if err = tlsConn.Handshake(); err != nil {
+					t.Error(err)
+					return
+				}
+				tp = textproto.NewConn(tlsConn)
+				msg.tls = true
+			case "AUTH":
+				msg.auth = arg
+				reply("235 authenticated")
+			case "MAIL":
+				msg.from = arg
+				reply("250 ok")
+			case "RCPT":
+				msg.to = append(msg.to, arg)
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				if msg.data, err = tp.ReadDotBytes(); err != nil {
+					t.Error(err)
+					return
+				}
+				reply("250 ok")
+			case "QUIT":
+				reply("221 bye")
+				res <- msg
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return res
+}
+
+func selfSignedCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestEmailSendIncident(t *testing.T) {
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t)}}
+	n := &db.IncidentNotification{
+		ProjectId:     "p1",
+		ApplicationId: model.NewApplicationId("default", model.ApplicationKindDeployment, "checkout"),
+		IncidentKey:   "abc",
+		Status:        model.CRITICAL,
+		Details: &db.IncidentNotificationDetails{Reports: []db.IncidentNotificationDetailsReport{
+			{Name: model.AuditReportSLO, Check: "Latency", Message: "p99 latency > 500ms"},
+		}},
+	}
+
+	for _, mode := range []db.EmailTLSMode{db.EmailTLSModeNone, db.EmailTLSModeStartTLS, db.EmailTLSModeTLS} {
+		t.Run(string(mode), func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer l.Close()
+			var received <-chan smtpMessage
+			switch mode {
+			case db.EmailTLSModeStartTLS:
+				received = serveSMTP(t, l, tlsConfig)
+			case db.EmailTLSModeTLS:
+				received = serveSMTP(t, tls.NewListener(l, tlsConfig), nil)
+			default:
+				received = serveSMTP(t, l, nil)
+			}
+
+			cfg := &db.IntegrationEmail{
+				Host:          "127.0.0.1",
+				Port:          l.Addr().(*net.TCPAddr).Port,
+				TLSMode:       mode,
+				TlsSkipVerify: true,
+				From:          "codexray@example.com",
+				Recipients:    []string{"oncall@example.com"},
+			}
+			if mode != db.EmailTLSModeNone {
+				cfg.Auth = &utils.BasicAuth{User: "user", Password: "secret"}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			require.NoError(t, NewEmail(cfg, nil).SendIncident(ctx, "http://codexray", n))
+
+			msg := <-received
+			assert.Equal(t, mode != db.EmailTLSModeNone, msg.tls)
+			if mode != db.EmailTLSModeNone {
+				assert.True(t, strings.HasPrefix(msg.auth, "PLAIN"), "the credentials are sent over the encrypted connection")
+			}
+			assert.Equal(t, "FROM:<codexray@example.com>", msg.from)
+			assert.Equal(t, []string{"TO:<oncall@example.com>"}, msg.to)
+
+			m, err := mail.ReadMessage(strings.NewReader(string(msg.data)))
+			require.NoError(t, err)
+			subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+			require.NoError(t, err)
+			assert.Contains(t, subject, "[CRITICAL]")
+			mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+			require.NoError(t, err)
+			assert.Equal(t, "multipart/alternative", mediaType)
+
+			bodies := map[string]string{}
+			mr := multipart.NewReader(m.Body, params["boundary"])
+			for {
+				p, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				body, err := io.ReadAll(p)
+				require.NoError(t, err)
+				bodies[p.Header.Get("Content-Type")] = string(body)
+			}
+			require.Len(t, bodies, 2)
+			assert.Contains(t, bodies["text/plain; charset=UTF-8"], "p99 latency > 500ms")
+			assert.Contains(t, bodies["text/plain; charset=UTF-8"], "http://codexray/p/p1/incidents?incident=abc")
+			assert.Contains(t, bodies["text/html; charset=UTF-8"], "p99 latency &gt; 500ms")
+		})
+	}
+}
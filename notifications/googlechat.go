@@ -0,0 +1,116 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"codexray/db"
+	"codexray/model"
+)
+
+// GoogleChat sends messages to a Google Chat space through its incoming webhook.
+// Cards support a subset of HTML, so the text is escaped.
+type GoogleChat struct {
+	webhookUrl string
+}
+
+type googleChatCard struct {
+	Header   googleChatCardHeader    `json:"header"`
+	Sections []googleChatCardSection `json:"sections"`
+}
+
+type googleChatCardHeader struct {
+	Title string `json:"title"`
+}
+
+type googleChatCardSection struct {
+	Header  string             `json:"header,omitempty"`
+	Widgets []googleChatWidget `json:"widgets"`
+}
+
+type googleChatWidget map[string]any
+
+func NewGoogleChat(webhookUrl string) *GoogleChat {
+	return &GoogleChat{webhookUrl: webhookUrl}
+}
+
+func (g *GoogleChat) SendIncident(ctx context.Context, baseUrl string, n *db.IncidentNotification) error {
+	var title string
+	if n.Status == model.OK {
+		title = fmt.Sprintf("%s incident resolved", n.ApplicationId.Name)
+	} else {
+		title = fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status.String()), incidentSummary(n, plain))
+	}
+	card := googleChatCard{Header: googleChatCardHeader{Title: title}}
+	if n.Details != nil && len(n.Details.Reports) > 0 {
+		var lines []string
+		for _, r := range n.Details.Reports {
+			lines = append(lines, fmt.Sprintf("• <b>%s</b> / %s: %s", html.EscapeString(string(r.Name)), html.EscapeString(r.Check), html.EscapeString(r.Message)))
+		}
+		card.Sections = append(card.Sections, googleChatCardSection{Widgets: []googleChatWidget{googleChatText(strings.Join(lines, "<br>"))}})
+	}
+	card.Sections = append(card.Sections, googleChatCardSection{Widgets: []googleChatWidget{googleChatButton("View incident", incidentUrl(baseUrl, n))}})
+	return g.send(ctx, card)
+}
+
+func (g *GoogleChat) SendDeployment(ctx context.Context, project *db.Project, ds model.ApplicationDeploymentStatus) error {
+	d := ds.Deployment
+
+	status := "Deployed"
+	switch ds.State {
+	case model.ApplicationDeploymentStateInProgress:
+		return nil
+	case model.ApplicationDeploymentStateStuck:
+		status = "Stuck"
+	case model.ApplicationDeploymentStateCancelled:
+		status = "Cancelled"
+	}
+
+	title := fmt.Sprintf("Deployment of %s to %s", d.ApplicationId.Name, project.Name)
+	card := googleChatCard{Header: googleChatCardHeader{Title: title}}
+	card.Sections = append(card.Sections, googleChatCardSection{Widgets: []googleChatWidget{
+		googleChatKeyValue("Status", status),
+		googleChatKeyValue("Version", d.Version()),
+	}})
+	if ds.State == model.ApplicationDeploymentStateSummary {
+		summary := "No notable changes"
+		if len(ds.Summary) > 0 {
+			var lines []string
+			for _, s := range ds.Summary {
+				lines = append(lines, fmt.Sprintf("%s %s", s.Emoji(), html.EscapeString(s.Message)))
+			}
+			summary = strings.Join(lines, "<br>")
+		}
+		card.Sections = append(card.Sections, googleChatCardSection{Header: "Summary", Widgets: []googleChatWidget{googleChatText(summary)}})
+	}
+	url := deploymentUrl(project.Settings.Integrations.BaseUrl, project.Id, d)
+	card.Sections = append(card.Sections, googleChatCardSection{Widgets: []googleChatWidget{googleChatButton("View deployment", url)}})
+	return g.send(ctx, card)
+}
+
+func (g *GoogleChat) send(ctx context.Context, card googleChatCard) error {
+	payload := map[string]any{
+		"cards": []googleChatCard{card},
+	}
+	_, err := postJson(ctx, g.webhookUrl, payload)
+	return err
+}
+
+func googleChatText(text string) googleChatWidget {
+	return googleChatWidget{"textParagraph": map[string]string{"text": text}}
+}
+
+func googleChatKeyValue(key, value string) googleChatWidget {
+	return googleChatWidget{"keyValue": map[string]string{"topLabel": key, "content": html.EscapeString(value)}}
+}
+
+func googleChatButton(text, url string) googleChatWidget {
+	return googleChatWidget{"buttons": []any{
+		map[string]any{"textButton": map[string]any{
+			"text":    text,
+			"onClick": map[string]any{"openLink": map[string]string{"url": url}},
+		}},
+	}}
+}
@@ -3,6 +3,7 @@ package notifications
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"codexray/db"
@@ -27,17 +28,262 @@ func NewIncidentNotifier(db *db.DB) *IncidentNotifier {
 }
 
 func (n *IncidentNotifier) Enqueue(project *db.Project, app *model.Application, incident *model.ApplicationIncident, now timeseries.Time) {
-	integrations := project.Settings.Integrations
-	for _, i := range integrations.GetInfo() {
+	n.enqueueAll(project, app, app.Id, incident, incidentDetails(app, incident), now)
+	n.sendIncidents()
+}
+
+// EnqueueGroup enqueues notifications of an incident leading a group of incidents caused by a shared dependency.
+func (n *IncidentNotifier) EnqueueGroup(project *db.Project, app *model.Application, incident *model.ApplicationIncident, group *db.IncidentNotificationDetailsGroup, now timeseries.Time) {
+	details := incidentDetails(app, incident)
+	if details == nil {
+		details = &db.IncidentNotificationDetails{}
+	}
+	details.Group = group
+	n.enqueueAll(project, app, app.Id, incident, details, now)
+	n.sendIncidents()
+}
+
+// EnqueueAlertRule enqueues notifications of an alert rule incident; app is nil for rules not bound to an application.
+func (n *IncidentNotifier) EnqueueAlertRule(project *db.Project, rule *db.AlertRule, app *model.Application, incident *model.ApplicationIncident, reports []db.IncidentNotificationDetailsReport, now timeseries.Time) {
+	details := &db.IncidentNotificationDetails{
+		Reports: reports,
+		AlertRule: &db.IncidentNotificationDetailsAlertRule{
+			Id:     rule.Id,
+			Name:   rule.Name,
+			Expr:   rule.Expr,
+			Labels: rule.Labels,
+		},
+	}
+	n.enqueueAll(project, app, incident.ApplicationId, incident, details, now)
+	n.sendIncidents()
+}
+
+func (n *IncidentNotifier) enqueueAll(project *db.Project, app *model.Application, appId model.ApplicationId, incident *model.ApplicationIncident, details *db.IncidentNotificationDetails, now timeseries.Time) {
+	window, windowEnd := n.getActiveMaintenanceWindow(project, app, now)
+	if window != nil {
+		klog.Infof("%s: %s: incident %s notifications: %s by maintenance window %s", project.Id, appId, incident.Key, window.Action, window.Name)
+	}
+	destinations := incidentDestinations(project, app, appId, incident, details)
+	if incident.Resolved() {
+		destinations = n.withEscalatedDestinations(project, incident, destinations)
+	}
+	for _, d := range destinations {
+		notification := db.IncidentNotification{
+			ProjectId:     project.Id,
+			ApplicationId: appId,
+			IncidentKey:   incident.Key,
+			Destination:   d.Type,
+			Target:        d.Target(),
+			Timestamp:     now,
+			Status:        incident.Severity,
+		}
+		switch {
+		case incident.Grouped():
+			notification.SuppressedBy = "incident group " + incident.GroupKey
+		case window != nil && !n.resolvesDeliveredIncident(notification, incident):
+			switch window.Action {
+			case model.MaintenanceWindowActionSuppress:
+				notification.SuppressedBy = window.Name
+			case model.MaintenanceWindowActionDefer:
+				notification.DeferredUntil = windowEnd
+			}
+		}
+		n.enqueue(project, notification, incident, details)
+	}
+}
+
+// resolvesDeliveredIncident reports whether the notification resolves an incident whose opening has already been
+// delivered to the destination. Such notifications are not held back by maintenance windows,
+// otherwise the alert would stay open in the incident management system.
+func (n *IncidentNotifier) resolvesDeliveredIncident(notification db.IncidentNotification, incident *model.ApplicationIncident) bool {
+	if !incident.Resolved() {
+		return false
+	}
+	sent, err := n.db.IsIncidentOpenNotificationSent(notification)
+	if err != nil {
+		klog.Errorln(err)
+		return false
+	}
+	return sent
+}
+
+// incidentDestinations evaluates the project's notification routes,
+// falling back to the default destinations of the integrations enabled for incidents.
+func incidentDestinations(project *db.Project, app *model.Application, appId model.ApplicationId, incident *model.ApplicationIncident, details *db.IncidentNotificationDetails) []db.NotificationDestination {
+	subject := db.NotificationSubjectForIncident(project, app, appId, incident.Severity, details)
+	if res := project.Settings.NotificationRoutes.Route(subject); len(res) > 0 {
+		return res
+	}
+	var res []db.NotificationDestination
+	for _, i := range project.Settings.Integrations.GetInfo() {
 		if i.Configured && i.Incidents {
-			n.enqueue(project, app, incident, i.Type, now)
+			res = append(res, db.NotificationDestination{Type: i.Type})
 		}
 	}
-	n.sendIncidents()
+	return res
+}
+
+// withEscalatedDestinations adds the destinations the incident was escalated to, so that they are notified of its resolution.
+func (n *IncidentNotifier) withEscalatedDestinations(project *db.Project, incident *model.ApplicationIncident, destinations []db.NotificationDestination) []db.NotificationDestination {
+	targets, err := n.db.GetIncidentNotificationTargets(project.Id, incident.Key)
+	if err != nil {
+		klog.Errorln(err)
+		return destinations
+	}
+	for destination, ts := range targets {
+		for _, target := range ts {
+			if !slices.ContainsFunc(destinations, func(d db.NotificationDestination) bool {
+				return d.Type == destination && d.Target() == target
+			}) {
+				destinations = append(destinations, db.NewNotificationDestination(destination, target))
+			}
+		}
+	}
+	return destinations
+}
+
+// Escalate notifies the next escalation step of unacknowledged open incidents whose delay has passed,
+// and repeats notifications of critical ones. The progress is stored in the incident notifications,
+// so it must run on the primary replica only.
+func (n *IncidentNotifier) Escalate(project *db.Project, world *model.World, now timeseries.Time) {
+	policies, err := n.db.GetEscalationPolicies(project.Id)
+	if err != nil {
+		klog.Errorln(err)
+		return
+	}
+	if len(policies) == 0 {
+		return
+	}
+	incidents, err := n.db.GetApplicationIncidents(project.Id, now, now)
+	if err != nil {
+		klog.Errorln(err)
+		return
+	}
+	var escalated int
+	for appId, appIncidents := range incidents {
+		app := world.GetApplication(appId)
+		for _, incident := range appIncidents {
+			if incident.Resolved() || incident.Acknowledged() || incident.Grouped() {
+				continue
+			}
+			if n.escalate(project, app, incident, policies, now) {
+				escalated++
+			}
+		}
+	}
+	if escalated > 0 {
+		n.sendIncidents()
+	}
+}
+
+func (n *IncidentNotifier) escalate(project *db.Project, app *model.Application, incident *model.ApplicationIncident, policies []*db.EscalationPolicy, now timeseries.Time) bool {
+	state, err := n.db.GetIncidentEscalationState(project.Id, incident.Key)
+	if err != nil {
+		klog.Errorln(err)
+		return false
+	}
+	if state.LastNotifiedAt.IsZero() { // the incident hasn't been notified yet, e.g. it is suppressed by a maintenance window
+		return false
+	}
+	subject := db.NotificationSubjectForIncident(project, app, incident.ApplicationId, incident.Severity, state.Details)
+	var policy *db.EscalationPolicy
+	for _, p := range policies {
+		if p.Matches(subject) {
+			policy = p
+			break
+		}
+	}
+	if policy == nil {
+		return false
+	}
+	if window, _ := n.getActiveMaintenanceWindow(project, app, now); window != nil {
+		return false
+	}
+
+	var destinations []db.NotificationDestination
+	// the policy may have been edited to have fewer steps since the incident was escalated
+	step := min(state.LastSteps[policy.Id], len(policy.Steps))
+	switch {
+	case step < len(policy.Steps) && now.Sub(incident.OpenedAt) >= policy.Steps[step].Delay:
+		destinations = policy.Steps[step].Destinations
+		step++
+		klog.Infof("%s: %s: escalating incident %s to step %d of %s", project.Id, incident.ApplicationId, incident.Key, step, policy.Name)
+	case policy.RepeatInterval > 0 && incident.Severity == model.CRITICAL && now.Sub(state.LastNotifiedAt) >= policy.RepeatInterval:
+		destinations = incidentDestinations(project, app, incident.ApplicationId, incident, state.Details)
+		for _, s := range policy.Steps[:step] {
+			for _, d := range s.Destinations {
+				if !slices.ContainsFunc(destinations, func(dd db.NotificationDestination) bool {
+					return dd.Type == d.Type && dd.Target() == d.Target()
+				}) {
+					destinations = append(destinations, d)
+				}
+			}
+		}
+		step = db.EscalationRepeat
+	default:
+		return false
+	}
+	for _, d := range destinations {
+		notification := db.IncidentNotification{
+			ProjectId:          project.Id,
+			ApplicationId:      incident.ApplicationId,
+			IncidentKey:        incident.Key,
+			Destination:        d.Type,
+			Target:             d.Target(),
+			Timestamp:          now,
+			Status:             incident.Severity,
+			EscalationStep:     step,
+			EscalationPolicyId: policy.Id,
+		}
+		n.enqueue(project, notification, incident, state.Details)
+	}
+	return true
+}
+
+func (n *IncidentNotifier) getActiveMaintenanceWindow(project *db.Project, app *model.Application, now timeseries.Time) (*model.MaintenanceWindow, timeseries.Time) {
+	windows, err := n.db.GetMaintenanceWindows(project.Id)
+	if err != nil {
+		klog.Errorln(err)
+		return nil, 0
+	}
+	for _, mw := range windows {
+		if !mw.Matches(app) {
+			continue
+		}
+		if end := mw.ActiveAt(now); !end.IsZero() {
+			return mw, end
+		}
+	}
+	return nil, 0
+}
+
+// resolvedDuringMaintenance reports whether a deferred notification is no longer relevant:
+// the incident was resolved before the maintenance window ended, and nothing about it was sent before the window.
+func (n *IncidentNotifier) resolvedDuringMaintenance(notification db.IncidentNotification) bool {
+	incident, err := n.db.GetIncidentByKey(notification.ProjectId, notification.IncidentKey)
+	if err != nil {
+		klog.Errorln(err)
+		return false
+	}
+	if !incident.Resolved() || incident.ResolvedAt.After(notification.DeferredUntil) {
+		return false
+	}
+	prevNotifications, err := n.db.GetPreviousIncidentNotifications(notification)
+	if err != nil {
+		klog.Errorln(err)
+		return false
+	}
+	for _, prev := range prevNotifications {
+		if prev.DeferredUntil.IsZero() {
+			return false
+		}
+	}
+	return true
 }
 
 type destinationKey struct {
 	integration db.IntegrationType
+	target      string
 	projectId   db.ProjectId
 }
 
@@ -55,13 +301,14 @@ func (n *IncidentNotifier) sendIncidents() {
 		projects[p.Id] = p
 	}
 	failedDestinations := map[destinationKey]bool{}
-	notifications, err := n.db.GetNotSentIncidentNotifications(timeseries.Now().Add(-retryWindow))
+	now := timeseries.Now()
+	notifications, err := n.db.GetNotSentIncidentNotifications(now.Add(-retryWindow), now)
 	if err != nil {
 		klog.Errorln(err)
 		return
 	}
 	for _, notification := range notifications {
-		dKey := destinationKey{integration: notification.Destination, projectId: notification.ProjectId}
+		dKey := destinationKey{integration: notification.Destination, target: notification.Target, projectId: notification.ProjectId}
 		if failedDestinations[dKey] {
 			continue
 		}
@@ -69,9 +316,16 @@ func (n *IncidentNotifier) sendIncidents() {
 		if project == nil {
 			continue
 		}
+		if !notification.DeferredUntil.IsZero() && n.resolvedDuringMaintenance(notification) {
+			notification.SuppressedBy = "resolved during maintenance"
+			if err := n.db.SuppressIncidentNotification(notification); err != nil {
+				klog.Errorln(err)
+			}
+			continue
+		}
 		integrations := project.Settings.Integrations
 		var sendErr error
-		client := getClient(notification.Destination, integrations)
+		client := getClient(notification.Destination, project, notification.Target)
 		if client != nil {
 			if notification.Destination == db.IntegrationTypeSlack {
 				if prevNotifications, err := n.db.GetPreviousIncidentNotifications(notification); err != nil {
@@ -85,8 +339,12 @@ func (n *IncidentNotifier) sendIncidents() {
 				}
 			}
 			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			start := time.Now()
 			sendErr = client.SendIncident(ctx, integrations.BaseUrl, &notification)
 			cancel()
+			if err := n.db.UpdateIncidentNotificationDelivery(notification, deliveryResult(notification.Destination, start, sendErr)); err != nil {
+				klog.Errorln(err)
+			}
 		}
 		if sendErr != nil {
 			klog.Errorf("send error %s: %s", notification.Destination, sendErr)
@@ -100,21 +358,14 @@ func (n *IncidentNotifier) sendIncidents() {
 	}
 }
 
-func (n *IncidentNotifier) enqueue(project *db.Project, app *model.Application, incident *model.ApplicationIncident, destination db.IntegrationType, now timeseries.Time) {
-	notification := db.IncidentNotification{
-		ProjectId:     project.Id,
-		ApplicationId: app.Id,
-		IncidentKey:   incident.Key,
-		Destination:   destination,
-		Timestamp:     now,
-		Status:        incident.Severity,
-	}
-	switch destination {
-	case db.IntegrationTypeSlack, db.IntegrationTypeTeams, db.IntegrationTypeWebhook:
+func (n *IncidentNotifier) enqueue(project *db.Project, notification db.IncidentNotification, incident *model.ApplicationIncident, details *db.IncidentNotificationDetails) {
+	switch notification.Destination {
+	case db.IntegrationTypeSlack, db.IntegrationTypeTeams, db.IntegrationTypeWebhook, db.IntegrationTypeEmail,
+		db.IntegrationTypeTelegram, db.IntegrationTypeMattermost, db.IntegrationTypeGoogleChat, db.IntegrationTypeDiscord:
 		if incident.Resolved() {
-			n.onResolve("", notification, incidentDetails(app, incident))
+			n.onResolve("", notification, details)
 		} else {
-			n.onOpen("", notification, incidentDetails(app, incident))
+			n.onOpen("", notification, details)
 		}
 	case db.IntegrationTypePagerduty, db.IntegrationTypeOpsgenie:
 		openCriticalKey, openWarningKey, err := n.getOpenIncidents(notification)
@@ -135,12 +386,12 @@ func (n *IncidentNotifier) enqueue(project *db.Project, app *model.Application,
 			if openCriticalKey != "" {
 				n.onResolve(openCriticalKey, notification, nil)
 			}
-			n.onOpen(externalKey, notification, incidentDetails(app, incident))
+			n.onOpen(externalKey, notification, details)
 		case incident.Severity == model.CRITICAL:
-			n.onOpen(externalKey, notification, incidentDetails(app, incident))
+			n.onOpen(externalKey, notification, details)
 		}
 	default:
-		klog.Errorln("unknown destination:", destination)
+		klog.Errorln("unknown destination:", notification.Destination)
 	}
 }
 
@@ -158,7 +409,11 @@ func (n *IncidentNotifier) onResolve(externalKey string, notification db.Inciden
 }
 
 func (n *IncidentNotifier) getOpenIncidents(notification db.IncidentNotification) (string, string, error) {
-	prevNotifications, err := n.db.GetPreviousIncidentNotifications(notification)
+	return getOpenIncidents(n.db, notification)
+}
+
+func getOpenIncidents(database *db.DB, notification db.IncidentNotification) (string, string, error) {
+	prevNotifications, err := database.GetPreviousIncidentNotifications(notification)
 	if err != nil {
 		return "", "", err
 	}
@@ -179,3 +434,62 @@ func (n *IncidentNotifier) getOpenIncidents(notification db.IncidentNotification
 	}
 	return openCriticalKey, openWarningKey, nil
 }
+
+// ResolveAlertRuleIncidents resolves the open incidents of the alert rule, except the one attached to the keep application,
+// and enqueues their resolve notifications. The notifications are only stored here, the notifier of the primary replica sends them.
+func ResolveAlertRuleIncidents(database *db.DB, project *db.Project, rule *db.AlertRule, keep model.ApplicationId, now timeseries.Time) {
+	incidents, err := database.ResolveAlertRuleIncidents(project.Id, rule.Id, keep, now)
+	if err != nil {
+		klog.Errorln(err)
+		return
+	}
+	n := &IncidentNotifier{db: database}
+	details := &db.IncidentNotificationDetails{
+		AlertRule: &db.IncidentNotificationDetailsAlertRule{Id: rule.Id, Name: rule.Name, Expr: rule.Expr, Labels: rule.Labels},
+	}
+	for _, incident := range incidents {
+		n.enqueueAll(project, nil, incident.ApplicationId, incident, details, now)
+	}
+}
+
+// AcknowledgeIncident propagates an acknowledgement to the incident management systems
+// that have open alerts for the incident.
+func AcknowledgeIncident(database *db.DB, project *db.Project, incident *model.ApplicationIncident, user string) {
+	targets, err := database.GetIncidentNotificationTargets(project.Id, incident.Key)
+	if err != nil {
+		klog.Errorln(err)
+		return
+	}
+	for _, destination := range []db.IntegrationType{db.IntegrationTypePagerduty, db.IntegrationTypeOpsgenie} {
+		for _, target := range targets[destination] {
+			client, ok := getClient(destination, project, target).(IncidentAcknowledger)
+			if !ok {
+				continue
+			}
+			notification := db.IncidentNotification{
+				ProjectId:     project.Id,
+				ApplicationId: incident.ApplicationId,
+				IncidentKey:   incident.Key,
+				Destination:   destination,
+				Target:        target,
+				Timestamp:     timeseries.Now().Add(timeseries.Second),
+			}
+			openCriticalKey, openWarningKey, err := getOpenIncidents(database, notification)
+			if err != nil {
+				klog.Errorln(err)
+				continue
+			}
+			for _, key := range []string{openCriticalKey, openWarningKey} {
+				if key == "" {
+					continue
+				}
+				notification.ExternalKey = key
+				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+				if err := client.AcknowledgeIncident(ctx, &notification, user); err != nil {
+					klog.Errorf("acknowledge error %s: %s", destination, err)
+				}
+				cancel()
+			}
+		}
+	}
+}
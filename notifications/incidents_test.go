@@ -0,0 +1,177 @@
+package notifications
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/timeseries"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveDuringSuppressWindow(t *testing.T) {
+	var lock sync.Mutex
+	received := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		received[r.URL.Path] = append(received[r.URL.Path], string(body))
+	}))
+	defer srv.Close()
+
+	database, err := db.Open(t.TempDir(), "")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	id, err := database.SaveProject(db.Project{Name: "default"})
+	require.NoError(t, err)
+	project, err := database.GetProject(id)
+	require.NoError(t, err)
+	project.Settings.Integrations.Webhook = &db.IntegrationWebhook{Url: srv.URL, Incidents: true, IncidentTemplate: "{{.Application.Name}} {{.Status}}"}
+	require.NoError(t, database.SaveProjectSettings(project))
+	project, err = database.GetProject(id)
+	require.NoError(t, err)
+	n := &IncidentNotifier{db: database}
+
+	now := timeseries.Now()
+	before := model.NewApplication(model.NewApplicationId("default", model.ApplicationKindDeployment, "before"))
+	during := model.NewApplication(model.NewApplicationId("default", model.ApplicationKindDeployment, "during"))
+	incident, err := database.CreateOrUpdateIncident(id, before.Id, now.Add(-10*timeseries.Minute), model.CRITICAL)
+	require.NoError(t, err)
+	n.Enqueue(project, before, incident, now.Add(-10*timeseries.Minute))
+
+	require.NoError(t, database.SaveMaintenanceWindow(id, &model.MaintenanceWindow{
+		Name: "upgrade", Action: model.MaintenanceWindowActionSuppress, Start: now.Add(-5 * timeseries.Minute), End: now.Add(timeseries.Hour),
+	}))
+	incident, err = database.CreateOrUpdateIncident(id, during.Id, now.Add(-2*timeseries.Minute), model.CRITICAL)
+	require.NoError(t, err)
+	n.Enqueue(project, during, incident, now.Add(-2*timeseries.Minute))
+
+	for _, app := range []*model.Application{before, during} {
+		incident, err = database.CreateOrUpdateIncident(id, app.Id, now, model.OK)
+		require.NoError(t, err)
+		n.Enqueue(project, app, incident, now)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"before CRITICAL", "before OK"}, received["/"],
+		"the resolution of an incident notified before the window is sent, the incident opened during the window is suppressed")
+}
+
+func TestResolveAlertRuleIncidents(t *testing.T) {
+	database, err := db.Open(t.TempDir(), "")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	id, err := database.SaveProject(db.Project{Name: "default"})
+	require.NoError(t, err)
+	project, err := database.GetProject(id)
+	require.NoError(t, err)
+
+	rule := &db.AlertRule{Name: "high error rate", Expr: "vector(1)", Severity: model.CRITICAL}
+	require.NoError(t, database.SaveAlertRule(id, rule))
+	now := timeseries.Now()
+	legacy := model.NewApplicationId("", model.ApplicationKindAlertRule, rule.Name)
+	_, err = database.CreateOrUpdateAlertRuleIncident(id, legacy, rule.Id, now.Add(-timeseries.Hour), model.CRITICAL)
+	require.NoError(t, err)
+	current, err := database.CreateOrUpdateAlertRuleIncident(id, rule.IncidentApplicationId(), rule.Id, now.Add(-timeseries.Minute), model.CRITICAL)
+	require.NoError(t, err)
+
+	ResolveAlertRuleIncidents(database, project, rule, rule.IncidentApplicationId(), now)
+	incidents, err := database.GetApplicationIncidents(id, now.Add(-timeseries.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, incidents[legacy], 1)
+	assert.Equal(t, now, incidents[legacy][0].ResolvedAt, "the incident opened before the rule was renamed is resolved")
+	require.Len(t, incidents[current.ApplicationId], 1)
+	assert.False(t, incidents[current.ApplicationId][0].Resolved())
+
+	ResolveAlertRuleIncidents(database, project, rule, model.ApplicationId{}, now)
+	incidents, err = database.GetApplicationIncidents(id, now.Add(-timeseries.Hour), now)
+	require.NoError(t, err)
+	assert.True(t, incidents[current.ApplicationId][0].Resolved(), "all the incidents of a deleted or disabled rule are resolved")
+}
+
+func TestEscalatePolicyEditedDown(t *testing.T) {
+	var lock sync.Mutex
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		received = append(received, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	database, err := db.Open(t.TempDir(), "")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	id, err := database.SaveProject(db.Project{Name: "default"})
+	require.NoError(t, err)
+	project, err := database.GetProject(id)
+	require.NoError(t, err)
+	project.Settings.Integrations.Webhook = &db.IntegrationWebhook{Url: srv.URL, Incidents: true, IncidentTemplate: "{{.Status}}"}
+	require.NoError(t, database.SaveProjectSettings(project))
+	project, err = database.GetProject(id)
+	require.NoError(t, err)
+	n := &IncidentNotifier{db: database}
+
+	step := func(delay timeseries.Duration, path string) db.EscalationStep {
+		return db.EscalationStep{Delay: delay, Destinations: []db.NotificationDestination{
+			db.NewNotificationDestination(db.IntegrationTypeWebhook, srv.URL+path),
+		}}
+	}
+	policy := &db.EscalationPolicy{
+		Name:           "on-call",
+		Steps:          []db.EscalationStep{step(timeseries.Minute, "/1"), step(2*timeseries.Minute, "/2"), step(3*timeseries.Minute, "/3")},
+		RepeatInterval: 10 * timeseries.Minute,
+	}
+	require.NoError(t, database.SaveEscalationPolicy(id, policy))
+
+	now := timeseries.Now()
+	world := model.NewWorld(now, now, timeseries.Minute, timeseries.Minute)
+	app := model.NewApplication(model.NewApplicationId("default", model.ApplicationKindDeployment, "app"))
+	world.Applications[app.Id] = app
+	incident, err := database.CreateOrUpdateIncident(id, app.Id, now.Add(-30*timeseries.Minute), model.CRITICAL)
+	require.NoError(t, err)
+	n.Enqueue(project, app, incident, now.Add(-30*timeseries.Minute))
+	for _, m := range []timeseries.Duration{29, 28, 27} {
+		n.Escalate(project, world, now.Add(-m*timeseries.Minute))
+	}
+
+	policy.Steps = policy.Steps[:1]
+	require.NoError(t, database.SaveEscalationPolicy(id, policy))
+	assert.NotPanics(t, func() {
+		n.Escalate(project, world, now)
+	})
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"/", "/1", "/2", "/3", "/", "/1"}, received,
+		"the repeat is sent to the default destination and to the steps the policy still has")
+}
+
+func TestIncidentDestinations(t *testing.T) {
+	project := &db.Project{}
+	project.Settings.Integrations.Slack = &db.IntegrationSlack{Token: "xoxb", DefaultChannel: "ops", Incidents: true}
+	project.Settings.Integrations.Teams = &db.IntegrationTeams{WebhookUrl: "https://teams", Deployments: true}
+	project.Settings.NotificationRoutes = db.NotificationRoutes{{
+		Name:         "databases",
+		Match:        db.NotificationRouteMatch{Applications: []string{"pg"}},
+		Destinations: []db.NotificationDestination{{Type: db.IntegrationTypeSlack, SlackChannel: "dba"}},
+	}}
+	incident := &model.ApplicationIncident{Severity: model.CRITICAL}
+
+	pg := model.NewApplicationId("db", model.ApplicationKindStatefulSet, "pg")
+	assert.Equal(t, []db.NotificationDestination{{Type: db.IntegrationTypeSlack, SlackChannel: "dba"}},
+		incidentDestinations(project, nil, pg, incident, nil))
+
+	api := model.NewApplicationId("default", model.ApplicationKindDeployment, "api")
+	assert.Equal(t, []db.NotificationDestination{{Type: db.IntegrationTypeSlack}},
+		incidentDestinations(project, nil, api, incident, nil),
+		"unrouted incidents go to the default destinations of the integrations notifying of incidents")
+}
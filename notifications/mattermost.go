@@ -0,0 +1,105 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"codexray/db"
+	"codexray/model"
+)
+
+type Mattermost struct {
+	webhookUrl string
+	channel    string
+}
+
+type mattermostAttachment struct {
+	Fallback  string                      `json:"fallback"`
+	Color     string                      `json:"color"`
+	Title     string                      `json:"title"`
+	TitleLink string                      `json:"title_link,omitempty"`
+	Text      string                      `json:"text,omitempty"`
+	Fields    []mattermostAttachmentField `json:"fields,omitempty"`
+}
+
+type mattermostAttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func NewMattermost(webhookUrl, channel string) *Mattermost {
+	return &Mattermost{webhookUrl: webhookUrl, channel: channel}
+}
+
+func (m *Mattermost) SendIncident(ctx context.Context, baseUrl string, n *db.IncidentNotification) error {
+	var title string
+	if n.Status == model.OK {
+		title = fmt.Sprintf("%s incident resolved", n.ApplicationId.Name)
+	} else {
+		title = fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status.String()), incidentSummary(n, plain))
+	}
+	var text strings.Builder
+	if n.Details != nil {
+		for _, r := range n.Details.Reports {
+			text.WriteString(fmt.Sprintf("• **%s** / %s: %s\n", r.Name, r.Check, r.Message))
+		}
+	}
+	return m.send(ctx, mattermostAttachment{
+		Fallback:  title,
+		Color:     n.Status.Color(),
+		Title:     title,
+		TitleLink: incidentUrl(baseUrl, n),
+		Text:      text.String(),
+	})
+}
+
+func (m *Mattermost) SendDeployment(ctx context.Context, project *db.Project, ds model.ApplicationDeploymentStatus) error {
+	d := ds.Deployment
+
+	status := "Deployed"
+	switch ds.State {
+	case model.ApplicationDeploymentStateInProgress:
+		return nil
+	case model.ApplicationDeploymentStateStuck:
+		status = "Stuck"
+	case model.ApplicationDeploymentStateCancelled:
+		status = "Cancelled"
+	}
+
+	title := fmt.Sprintf("Deployment of %s to %s", d.ApplicationId.Name, project.Name)
+	a := mattermostAttachment{
+		Fallback:  title,
+		Color:     ds.Status.Color(),
+		Title:     title,
+		TitleLink: deploymentUrl(project.Settings.Integrations.BaseUrl, project.Id, d),
+		Fields: []mattermostAttachmentField{
+			{Title: "Status", Value: status, Short: true},
+			{Title: "Version", Value: d.Version(), Short: true},
+		},
+	}
+	if ds.State == model.ApplicationDeploymentStateSummary {
+		summary := "No notable changes"
+		if len(ds.Summary) > 0 {
+			var lines []string
+			for _, s := range ds.Summary {
+				lines = append(lines, fmt.Sprintf("%s %s", s.Emoji(), s.Message))
+			}
+			summary = strings.Join(lines, "\n")
+		}
+		a.Fields = append(a.Fields, mattermostAttachmentField{Title: "Summary", Value: summary})
+	}
+	return m.send(ctx, a)
+}
+
+func (m *Mattermost) send(ctx context.Context, a mattermostAttachment) error {
+	payload := map[string]any{
+		"attachments": []mattermostAttachment{a},
+	}
+	if m.channel != "" {
+		payload["channel"] = m.channel
+	}
+	_, err := postJson(ctx, m.webhookUrl, payload)
+	return err
+}
@@ -1,8 +1,16 @@
 package notifications
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"codexray/db"
@@ -20,27 +28,71 @@ type NotificationClient interface {
 	SendIncident(ctx context.Context, baseUrl string, n *db.IncidentNotification) error
 }
 
-func getClient(destination db.IntegrationType, integrations db.Integrations) NotificationClient {
+// DeploymentNotificationClient is implemented by clients of destinations that support deployment notifications.
+type DeploymentNotificationClient interface {
+	SendDeployment(ctx context.Context, project *db.Project, ds model.ApplicationDeploymentStatus) error
+}
+
+// IncidentAcknowledger is implemented by clients of incident management systems that can
+// acknowledge a previously sent alert.
+type IncidentAcknowledger interface {
+	AcknowledgeIncident(ctx context.Context, n *db.IncidentNotification, user string) error
+}
+
+// getClient returns a client for the destination if it is enabled for incidents;
+// a non-empty target overrides the integration's default channel, URL or key.
+func getClient(destination db.IntegrationType, project *db.Project, target string) NotificationClient {
+	for _, i := range project.Settings.Integrations.GetInfo() {
+		if i.Type == destination && !i.Incidents {
+			return nil
+		}
+	}
+	return newClient(destination, project, target)
+}
+
+// newClient returns a client for the configured destination regardless of the kinds of notifications enabled for it.
+func newClient(destination db.IntegrationType, project *db.Project, target string) NotificationClient {
+	integrations := project.Settings.Integrations
 	switch destination {
 	case db.IntegrationTypeSlack:
-		if cfg := integrations.Slack; cfg != nil && cfg.Incidents {
-			return NewSlack(cfg.Token, cfg.DefaultChannel)
+		if cfg := integrations.Slack; cfg != nil {
+			return NewSlack(cfg.Token, cmp.Or(target, cfg.DefaultChannel))
 		}
 	case db.IntegrationTypeTeams:
-		if cfg := integrations.Teams; cfg != nil && cfg.Incidents {
-			return NewTeams(cfg.WebhookUrl)
+		if cfg := integrations.Teams; cfg != nil {
+			return NewTeams(cmp.Or(target, cfg.WebhookUrl))
 		}
 	case db.IntegrationTypePagerduty:
-		if cfg := integrations.Pagerduty; cfg != nil && cfg.Incidents {
-			return NewPagerduty(cfg.IntegrationKey)
+		if cfg := integrations.Pagerduty; cfg != nil {
+			return NewPagerduty(cmp.Or(target, cfg.IntegrationKey))
 		}
 	case db.IntegrationTypeOpsgenie:
-		if cfg := integrations.Opsgenie; cfg != nil && cfg.Incidents {
+		if cfg := integrations.Opsgenie; cfg != nil {
 			return NewOpsgenie(cfg.ApiKey, cfg.EUInstance)
 		}
 	case db.IntegrationTypeWebhook:
-		if cfg := integrations.Webhook; cfg != nil && cfg.Incidents {
-			return NewWebhook(cfg)
+		if cfg := integrations.Webhook; cfg != nil {
+			return NewWebhook(cfg.WithUrl(target))
+		}
+	case db.IntegrationTypeEmail:
+		if cfg := integrations.Email; cfg != nil {
+			return NewEmail(cfg.WithRecipients(target), project.Settings.ApplicationCategories)
+		}
+	case db.IntegrationTypeTelegram:
+		if cfg := integrations.Telegram; cfg != nil {
+			return NewTelegram(cfg.BotToken, cmp.Or(target, cfg.ChatId))
+		}
+	case db.IntegrationTypeMattermost:
+		if cfg := integrations.Mattermost; cfg != nil {
+			return NewMattermost(cmp.Or(target, cfg.WebhookUrl), cfg.Channel)
+		}
+	case db.IntegrationTypeGoogleChat:
+		if cfg := integrations.GoogleChat; cfg != nil {
+			return NewGoogleChat(cmp.Or(target, cfg.WebhookUrl))
+		}
+	case db.IntegrationTypeDiscord:
+		if cfg := integrations.Discord; cfg != nil {
+			return NewDiscord(cmp.Or(target, cfg.WebhookUrl))
 		}
 	}
 	return nil
@@ -73,6 +125,29 @@ func incidentDetails(app *model.Application, incident *model.ApplicationIncident
 	return &db.IncidentNotificationDetails{Reports: reports}
 }
 
+// incidentSummary describes an open incident; bold is used to highlight the subject in a destination-specific markup.
+func incidentSummary(n *db.IncidentNotification, bold func(string) string) string {
+	if n.Details != nil && n.Details.AlertRule != nil {
+		if n.ApplicationId.Kind == model.ApplicationKindAlertRule {
+			return fmt.Sprintf("alert rule %s is firing", bold(n.Details.AlertRule.Name))
+		}
+		return fmt.Sprintf("alert rule %s is firing for %s", bold(n.Details.AlertRule.Name), bold(n.ApplicationId.Name))
+	}
+	if n.Details != nil && n.Details.Group != nil && len(n.Details.Group.Applications) > 1 {
+		names := make([]string, 0, len(n.Details.Group.Applications))
+		for _, id := range n.Details.Group.Applications {
+			names = append(names, bold(id.Name))
+		}
+		return fmt.Sprintf("%d applications depending on %s are not meeting their SLOs: %s",
+			len(names), bold(n.Details.Group.Root.Name), strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("%s is not meeting its SLOs", bold(n.ApplicationId.Name))
+}
+
+func plain(s string) string {
+	return s
+}
+
 func incidentUrl(baseUrl string, n *db.IncidentNotification) string {
 	return fmt.Sprintf("%s/p/%s/incidents?incident=%s", baseUrl, n.ProjectId, n.IncidentKey)
 }
@@ -80,3 +155,30 @@ func incidentUrl(baseUrl string, n *db.IncidentNotification) string {
 func deploymentUrl(baseUrl string, projectId db.ProjectId, d *model.ApplicationDeployment) string {
 	return fmt.Sprintf("%s/p/%s/app/%s/Deployments#%s", baseUrl, projectId, d.ApplicationId.String(), d.Id())
 }
+
+// postJson sends the payload to a chat webhook or bot API and returns the response body.
+func postJson(ctx context.Context, endpoint string, payload any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) { // the URL may contain a token
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, newHttpStatusError(resp, body, err)
+	}
+	return body, err
+}
@@ -44,7 +44,7 @@ func (og *Opsgenie) SendIncident(ctx context.Context, baseUrl string, n *db.Inci
 	}
 
 	req := &alert.CreateAlertRequest{
-		Message: fmt.Sprintf("[%s] %s is not meeting its SLOs", strings.ToUpper(n.Status.String()), n.ApplicationId.Name),
+		Message: fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status.String()), incidentSummary(n, plain)),
 		Alias:   n.ExternalKey,
 		Source:  "codexray",
 	}
@@ -65,3 +65,14 @@ func (og *Opsgenie) SendIncident(ctx context.Context, baseUrl string, n *db.Inci
 	_, err := og.client.Create(ctx, req)
 	return err
 }
+
+func (og *Opsgenie) AcknowledgeIncident(ctx context.Context, n *db.IncidentNotification, user string) error {
+	req := &alert.AcknowledgeAlertRequest{
+		IdentifierType:  alert.ALIAS,
+		IdentifierValue: n.ExternalKey,
+		User:            user,
+		Source:          "codexray",
+	}
+	_, err := og.client.Acknowledge(ctx, req)
+	return err
+}
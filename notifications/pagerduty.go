@@ -31,7 +31,7 @@ func (pd *Pagerduty) SendIncident(ctx context.Context, baseUrl string, n *db.Inc
 		e.Client = "codexray"
 		e.ClientURL = incidentUrl(baseUrl, n)
 		e.Payload = &pagerduty.V2Payload{
-			Summary:   fmt.Sprintf("[%s] %s is not meeting its SLOs", strings.ToUpper(n.Status.String()), n.ApplicationId.Name),
+			Summary:   fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status.String()), incidentSummary(n, plain)),
 			Source:    "codexray",
 			Severity:  n.Status.String(),
 			Timestamp: n.Timestamp.ToStandard().String(),
@@ -47,3 +47,13 @@ func (pd *Pagerduty) SendIncident(ctx context.Context, baseUrl string, n *db.Inc
 	_, err := pagerduty.ManageEventWithContext(ctx, e)
 	return err
 }
+
+func (pd *Pagerduty) AcknowledgeIncident(ctx context.Context, n *db.IncidentNotification, user string) error {
+	e := pagerduty.V2Event{
+		RoutingKey: pd.integrationKey,
+		DedupKey:   n.ExternalKey,
+		Action:     "acknowledge",
+	}
+	_, err := pagerduty.ManageEventWithContext(ctx, e)
+	return err
+}
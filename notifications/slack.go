@@ -38,8 +38,8 @@ func (s *Slack) SendIncident(ctx context.Context, baseUrl string, n *db.Incident
 		header = fmt.Sprintf("<%s|*%s* incident resolved>", incidentUrl(baseUrl, n), n.ApplicationId.Name)
 		snippet = fmt.Sprintf("%s incident resolved", n.ApplicationId.Name)
 	} else {
-		header = fmt.Sprintf("[%s] <%s|*%s* is not meeting its SLOs>", strings.ToUpper(n.Status.String()), incidentUrl(baseUrl, n), n.ApplicationId.Name)
-		snippet = fmt.Sprintf("%s is not meeting its SLOs", n.ApplicationId.Name)
+		header = fmt.Sprintf("[%s] <%s|%s>", strings.ToUpper(n.Status.String()), incidentUrl(baseUrl, n), incidentSummary(n, func(s string) string { return "*" + s + "*" }))
+		snippet = incidentSummary(n, plain)
 	}
 	var details []string
 	if n.Details != nil {
@@ -31,7 +31,7 @@ func (t *Teams) SendIncident(ctx context.Context, baseUrl string, n *db.Incident
 	if n.Status == model.OK {
 		title = fmt.Sprintf("**%s** incident resolved", n.ApplicationId.Name)
 	} else {
-		title = fmt.Sprintf("[%s] **%s** is not meeting its SLOs", strings.ToUpper(n.Status.String()), n.ApplicationId.Name)
+		title = fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status.String()), incidentSummary(n, func(s string) string { return "**" + s + "**" }))
 	}
 
 	msg := messagecard.NewMessageCard()
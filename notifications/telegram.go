@@ -0,0 +1,98 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"codexray/db"
+	"codexray/model"
+)
+
+const telegramApiUrl = "https://api.telegram.org"
+
+type Telegram struct {
+	apiUrl string
+	token  string
+	chatId string
+}
+
+func NewTelegram(token, chatId string) *Telegram {
+	return &Telegram{apiUrl: telegramApiUrl, token: token, chatId: chatId}
+}
+
+func (t *Telegram) SendIncident(ctx context.Context, baseUrl string, n *db.IncidentNotification) error {
+	var text strings.Builder
+	if n.Status == model.OK {
+		text.WriteString(fmt.Sprintf("<b>%s</b> incident resolved\n", html.EscapeString(n.ApplicationId.Name)))
+	} else {
+		text.WriteString(fmt.Sprintf("[%s] %s\n", strings.ToUpper(n.Status.String()), incidentSummary(n, telegramBold)))
+	}
+	if n.Details != nil {
+		for _, r := range n.Details.Reports {
+			text.WriteString(fmt.Sprintf("• <b>%s</b> / %s: %s\n", html.EscapeString(string(r.Name)), html.EscapeString(r.Check), html.EscapeString(r.Message)))
+		}
+	}
+	text.WriteString(fmt.Sprintf("\n<a href=\"%s\">View incident</a>", html.EscapeString(incidentUrl(baseUrl, n))))
+	return t.send(ctx, text.String())
+}
+
+func (t *Telegram) SendDeployment(ctx context.Context, project *db.Project, ds model.ApplicationDeploymentStatus) error {
+	d := ds.Deployment
+
+	status := "Deployed"
+	switch ds.State {
+	case model.ApplicationDeploymentStateInProgress:
+		return nil
+	case model.ApplicationDeploymentStateStuck:
+		status = "Stuck"
+	case model.ApplicationDeploymentStateCancelled:
+		status = "Cancelled"
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Deployment of <b>%s</b> to <b>%s</b>\n", html.EscapeString(d.ApplicationId.Name), html.EscapeString(project.Name)))
+	text.WriteString(fmt.Sprintf("<b>Status:</b> %s\n<b>Version:</b> %s\n", status, html.EscapeString(d.Version())))
+	if ds.State == model.ApplicationDeploymentStateSummary {
+		text.WriteString("\n<b>Summary</b>\n")
+		if len(ds.Summary) == 0 {
+			text.WriteString("No notable changes\n")
+		}
+		for _, s := range ds.Summary {
+			text.WriteString(fmt.Sprintf("%s %s\n", s.Emoji(), html.EscapeString(s.Message)))
+		}
+	}
+	url := deploymentUrl(project.Settings.Integrations.BaseUrl, project.Id, d)
+	text.WriteString(fmt.Sprintf("\n<a href=\"%s\">View deployment</a>", html.EscapeString(url)))
+	return t.send(ctx, text.String())
+}
+
+func (t *Telegram) send(ctx context.Context, text string) error {
+	payload := map[string]any{
+		"chat_id":                  t.chatId,
+		"text":                     text,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+	body, err := postJson(ctx, fmt.Sprintf("%s/bot%s/sendMessage", t.apiUrl, t.token), payload)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("telegram: %s", resp.Description)
+	}
+	return nil
+}
+
+func telegramBold(s string) string {
+	return "<b>" + html.EscapeString(s) + "</b>"
+}
@@ -22,10 +22,12 @@ type Webhook struct {
 }
 
 type IncidentTemplateValues struct {
-	Status      string                                 `json:"status"`
-	Application model.ApplicationId                    `json:"application"`
-	Reports     []db.IncidentNotificationDetailsReport `json:"reports"`
-	URL         string                                 `json:"url"`
+	Status      string                                   `json:"status"`
+	Application model.ApplicationId                      `json:"application"`
+	Reports     []db.IncidentNotificationDetailsReport   `json:"reports"`
+	AlertRule   *db.IncidentNotificationDetailsAlertRule `json:"alert_rule,omitempty"`
+	Group       *db.IncidentNotificationDetailsGroup     `json:"group,omitempty"`
+	URL         string                                   `json:"url"`
 }
 
 type DeploymentTemplateValues struct {
@@ -46,13 +48,18 @@ func (wh *Webhook) SendIncident(ctx context.Context, baseUrl string, n *db.Incid
 		return fmt.Errorf("invalid incident template: %s", err)
 	}
 
-	var data bytes.Buffer
-	err = tmpl.Execute(&data, IncidentTemplateValues{
+	values := IncidentTemplateValues{
 		Status:      strings.ToUpper(n.Status.String()),
 		Application: n.ApplicationId,
-		Reports:     n.Details.Reports,
 		URL:         incidentUrl(baseUrl, n),
-	})
+	}
+	if n.Details != nil {
+		values.Reports = n.Details.Reports
+		values.AlertRule = n.Details.AlertRule
+		values.Group = n.Details.Group
+	}
+	var data bytes.Buffer
+	err = tmpl.Execute(&data, values)
 	if err != nil {
 		return fmt.Errorf("invalid incident template: %s", err)
 	}
@@ -124,10 +131,7 @@ func (wh *Webhook) send(ctx context.Context, data []byte) error {
 
 	if resp.StatusCode >= http.StatusBadRequest {
 		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("response status: %s", resp.Status)
-		}
-		return fmt.Errorf("%s: %s", resp.Status, string(body))
+		return newHttpStatusError(resp, body, err)
 	}
 
 	return nil
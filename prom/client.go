@@ -49,6 +49,9 @@ func init() {
 	}
 }
 
+// ClusterLabel is added to the metrics of the sources having a cluster configured.
+const ClusterLabel = "cluster"
+
 type ClientConfig struct {
 	Url           string
 	BasicAuth     *utils.BasicAuth
@@ -57,6 +60,7 @@ type ClientConfig struct {
 	CustomHeaders []utils.Header
 	Step          timeseries.Duration
 	Transport     *http.Transport
+	Cluster       string
 }
 
 func NewClientConfig(url string, step timeseries.Duration) ClientConfig {
@@ -70,9 +74,28 @@ type Client struct {
 	config     ClientConfig
 	url        url.URL
 	httpClient *http.Client
+
+	// additional are the sources queried along with this one
+	additional []*Client
 }
 
-func NewClient(config ClientConfig) (*Client, error) {
+// NewClient creates a client querying the source and the additional ones, if any, and merging the results.
+func NewClient(config ClientConfig, additional ...ClientConfig) (*Client, error) {
+	c, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range additional {
+		ac, err := newClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		c.additional = append(c.additional, ac)
+	}
+	return c, nil
+}
+
+func newClient(config ClientConfig) (*Client, error) {
 	u, err := url.Parse(config.Url)
 	if err != nil {
 		return nil, err
@@ -106,6 +129,49 @@ func (c *Client) GetStep(from, to timeseries.Time) (timeseries.Duration, error)
 }
 
 func (c *Client) QueryRange(ctx context.Context, query string, from, to timeseries.Time, step timeseries.Duration) ([]model.MetricValues, error) {
+	if len(c.additional) == 0 {
+		return c.queryRange(ctx, query, from, to, step)
+	}
+	sources := append([]*Client{c}, c.additional...)
+	results := make([][]model.MetricValues, len(sources))
+	errs := make([]error, len(sources))
+	wg := sync.WaitGroup{}
+	for i, s := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = s.queryRange(ctx, query, from, to, step)
+		}()
+	}
+	wg.Wait()
+	// a partial result would be cached as complete, so the query fails if any of the sources fails
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sources[i].config.Url, err)
+		}
+	}
+	return mergeResults(results), nil
+}
+
+// mergeResults merges the series having the same labels, e.g., if the sources have no cluster configured.
+func mergeResults(results [][]model.MetricValues) []model.MetricValues {
+	var res []model.MetricValues
+	byHash := map[uint64]int{}
+	for _, mvs := range results {
+		for _, mv := range mvs {
+			i, ok := byHash[mv.LabelsHash]
+			if !ok {
+				byHash[mv.LabelsHash] = len(res)
+				res = append(res, mv)
+				continue
+			}
+			res[i].Values = timeseries.NewAggregate(timeseries.Any).Add(res[i].Values, mv.Values).Get()
+		}
+	}
+	return res
+}
+
+func (c *Client) queryRange(ctx context.Context, query string, from, to timeseries.Time, step timeseries.Duration) ([]model.MetricValues, error) {
 	query = strings.ReplaceAll(query, "$RANGE", fmt.Sprintf(`%.0fs`, (step*3).ToStandard().Seconds()))
 	var err error
 	query, err = addExtraSelector(query, c.config.ExtraSelector)
@@ -166,6 +232,9 @@ func (c *Client) QueryRange(ctx context.Context, query string, from, to timeseri
 		if err != nil {
 			return
 		}
+		if c.config.Cluster != "" {
+			mv.Labels[ClusterLabel] = c.config.Cluster
+		}
 		mv.LabelsHash = promModel.LabelsToSignature(mv.Labels)
 
 		_, err = jsonparser.ArrayEach(value, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
@@ -216,6 +285,7 @@ func (c *Client) QueryRange(ctx context.Context, query string, from, to timeseri
 	return res, nil
 }
 
+// Proxy forwards the request to the first source.
 func (c *Client) Proxy(r *http.Request, w http.ResponseWriter) {
 	reStr, err := mux.CurrentRoute(r).GetPathRegexp()
 	if err != nil {
@@ -53,6 +53,42 @@ func TestQueryRange(t *testing.T) {
 	assert.Equal(t, "TimeSeries(1675329015, 5, 15, [0.020000 0.200000 . . 2])", res[1].Values.String())
 }
 
+func TestQueryRangeMultipleSources(t *testing.T) {
+	source := func(data string) string {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, r.ParseForm())
+			w.Write([]byte(data))
+		}))
+		t.Cleanup(ts.Close)
+		return ts.URL
+	}
+	from := timeseries.Time(1675329015)
+	to := timeseries.Time(1675329045)
+	step := timeseries.Duration(15)
+
+	cfg1 := NewClientConfig(source(`{"data":{"result":[{"metric":{"job":"node"},"values":[[1675329015,"1"],[1675329030,"1"]]}]}}`), step)
+	cfg1.Cluster = "eu"
+	cfg2 := NewClientConfig(source(`{"data":{"result":[{"metric":{"job":"node"},"values":[[1675329030,"2"],[1675329045,"2"]]}]}}`), step)
+	cfg2.Cluster = "us"
+	cfg3 := NewClientConfig(source(`{"data":{"result":[{"metric":{"job":"node","cluster":"us"},"values":[[1675329015,"3"]]}]}}`), step)
+	client, err := NewClient(cfg1, cfg2, cfg3)
+	require.NoError(t, err)
+
+	res, err := client.QueryRange(context.Background(), `up`, from, to, step)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, model.Labels{"job": "node", "cluster": "eu"}, res[0].Labels)
+	assert.Equal(t, "TimeSeries(1675329015, 3, 15, [1 1 .])", res[0].Values.String())
+	assert.Equal(t, model.Labels{"job": "node", "cluster": "us"}, res[1].Labels)
+	assert.Equal(t, "TimeSeries(1675329015, 3, 15, [3 2 2])", res[1].Values.String(), "series with the same labels are merged")
+
+	failing := NewClientConfig("http://127.0.0.1:1", step)
+	client, err = NewClient(cfg1, failing)
+	require.NoError(t, err)
+	_, err = client.QueryRange(context.Background(), `up`, from, to, step)
+	assert.Error(t, err, "a partial result is not returned")
+}
+
 func Test_addExtraSelector(t *testing.T) {
 	check := func(src, extraSelector, expected string) {
 		actual, err := addExtraSelector(src, extraSelector)
@@ -1,6 +1,8 @@
 package prom
 
 import (
+	"strings"
+
 	"github.com/prometheus/prometheus/promql/parser"
 )
 
@@ -11,3 +13,8 @@ func IsSelectorValid(selector string) bool {
 	_, err := parser.ParseMetricSelector(selector)
 	return err == nil
 }
+
+func IsExprValid(expr string) bool {
+	_, err := parser.ParseExpr(strings.ReplaceAll(expr, "$RANGE", "1m"))
+	return err == nil
+}
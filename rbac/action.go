@@ -18,6 +18,7 @@ const (
 	ScopeProjectApplicationCategories Scope = "project.application_categories"
 	ScopeProjectCustomApplications    Scope = "project.custom_applications"
 	ScopeProjectInspections           Scope = "project.inspections"
+	ScopeProjectIncidents             Scope = "project.incidents"
 	ScopeProjectInstrumentations      Scope = "project.instrumentations"
 	ScopeProjectTraces                Scope = "project.traces"
 	ScopeProjectCosts                 Scope = "project.costs"
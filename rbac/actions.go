@@ -31,6 +31,7 @@ func (as ActionSet) List() []Action {
 		as.Project("").ApplicationCategories().Edit(),
 		as.Project("").CustomApplications().Edit(),
 		as.Project("").Inspections().Edit(),
+		as.Project("").Incidents().Edit(),
 		as.Project("").Instrumentations().Edit(),
 		as.Project("").Traces().View(),
 		as.Project("").Costs().View(),
@@ -79,6 +80,10 @@ func (as ProjectActionSet) Inspections() ProjectEditAction {
 	return ProjectEditAction{project: &as, scope: ScopeProjectInspections}
 }
 
+func (as ProjectActionSet) Incidents() ProjectEditAction {
+	return ProjectEditAction{project: &as, scope: ScopeProjectIncidents}
+}
+
 func (as ProjectActionSet) Instrumentations() ProjectEditAction {
 	return ProjectEditAction{project: &as, scope: ScopeProjectInstrumentations}
 }
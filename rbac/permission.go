@@ -1,6 +1,8 @@
 package rbac
 
 import (
+	"fmt"
+
 	"codexray/utils"
 )
 
@@ -14,6 +16,42 @@ func NewPermission(scope Scope, action Verb, object Object) Permission {
 	return Permission{Scope: scope, Action: action, Object: object}
 }
 
+// Validate checks that the permission grants a known verb over existing scopes,
+// and that its object only restricts the attributes of those scopes' objects.
+func (p Permission) Validate() error {
+	switch p.Action {
+	case ActionAll, ActionView, ActionEdit:
+	default:
+		return fmt.Errorf("unknown action: %s", p.Action)
+	}
+	if !utils.GlobValidate([]string{string(p.Scope)}) {
+		return fmt.Errorf("invalid scope: %s", p.Scope)
+	}
+	attributes := map[string]bool{}
+	matched := false
+	for _, a := range Actions.List() {
+		if !utils.GlobMatch(string(a.Scope), string(p.Scope)) {
+			continue
+		}
+		matched = true
+		for k := range a.Object {
+			attributes[k] = true
+		}
+	}
+	if !matched {
+		return fmt.Errorf("unknown scope: %s", p.Scope)
+	}
+	for k, v := range p.Object {
+		if !attributes[k] {
+			return fmt.Errorf("%s cannot be restricted by %s", p.Scope, k)
+		}
+		if !utils.GlobValidate([]string{v}) {
+			return fmt.Errorf("invalid pattern for %s: %s", k, v)
+		}
+	}
+	return nil
+}
+
 func (p Permission) allows(action Action) bool {
 	if !utils.GlobMatch(string(action.Scope), string(p.Scope)) {
 		return false
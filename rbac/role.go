@@ -1,7 +1,9 @@
 package rbac
 
 import (
+	"fmt"
 	"slices"
+	"strings"
 )
 
 const (
@@ -20,6 +22,7 @@ var (
 			NewPermission(ScopeProjectApplicationCategories, ActionEdit, nil),
 			NewPermission(ScopeProjectCustomApplications, ActionEdit, nil),
 			NewPermission(ScopeProjectInspections, ActionEdit, nil),
+			NewPermission(ScopeProjectIncidents, ActionEdit, nil),
 		),
 		NewRole(RoleViewer,
 			NewPermission(ScopeAll, ActionView, nil),
@@ -59,3 +62,30 @@ func NewStaticRoleManager() *StaticRoleManager {
 func (mgr *StaticRoleManager) GetRoles() ([]Role, error) {
 	return Roles, nil
 }
+
+// EditableRoleManager is implemented by role managers that store custom roles.
+// Built-in roles cannot be changed.
+type EditableRoleManager interface {
+	RoleManager
+	CreateRole(role Role) error
+	UpdateRole(name RoleName, role Role) error
+	DeleteRole(name RoleName) error
+}
+
+func (r Role) Validate() error {
+	if strings.TrimSpace(string(r.Name)) == "" {
+		return fmt.Errorf("role name is required")
+	}
+	if r.Name.Builtin() {
+		return fmt.Errorf("%s is a built-in role", r.Name)
+	}
+	if len(r.Permissions) == 0 {
+		return fmt.Errorf("no permissions")
+	}
+	for _, p := range r.Permissions {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
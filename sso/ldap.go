@@ -0,0 +1,167 @@
+package sso
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const ldapTimeout = 10 * time.Second
+
+type LDAPConfig struct {
+	// Url is the address of the directory server, e.g., ldaps://ldap.example.com:636.
+	Url           string
+	StartTLS      bool
+	TlsSkipVerify bool
+	// BindDN and BindPassword are the credentials of the service account used to look up users and their groups.
+	// If empty, the lookups are made anonymously.
+	BindDN       string
+	BindPassword string
+
+	UserBaseDN string
+	// UserFilter selects the user by the login entered on the login page; {login} is replaced with the escaped login.
+	UserFilter     string
+	EmailAttribute string
+	NameAttribute  string
+
+	// GroupBaseDN enables the group search; if empty, the groups are taken from the user's memberOf attribute.
+	GroupBaseDN string
+	// GroupFilter selects the user's groups; {dn} and {login} are replaced with the escaped user DN and login.
+	GroupFilter    string
+	GroupAttribute string
+}
+
+// LDAP authenticates users by binding to the directory with their credentials.
+type LDAP struct {
+	cfg LDAPConfig
+}
+
+func NewLDAP(cfg LDAPConfig) (*LDAP, error) {
+	if cfg.Url == "" || cfg.UserBaseDN == "" {
+		return nil, fmt.Errorf("LDAP URL and user base DN are required")
+	}
+	if !strings.Contains(cfg.UserFilter, "{login}") {
+		return nil, fmt.Errorf("LDAP user filter must contain {login}")
+	}
+	if cfg.GroupBaseDN != "" && cfg.GroupFilter == "" {
+		return nil, fmt.Errorf("LDAP group filter is required for the group search")
+	}
+	return &LDAP{cfg: cfg}, nil
+}
+
+func (l *LDAP) Authenticate(login, password string) (*Identity, error) {
+	// an empty password would result in an unauthenticated bind, which most servers accept
+	if login == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	conn, err := l.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if err = l.bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+	attrs := []string{l.cfg.EmailAttribute, l.cfg.NameAttribute}
+	if l.cfg.GroupBaseDN == "" {
+		attrs = append(attrs, "memberOf")
+	}
+	filter := strings.ReplaceAll(l.cfg.UserFilter, "{login}", ldap.EscapeFilter(login))
+	res, err := conn.Search(ldap.NewSearchRequest(
+		l.cfg.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false, filter, attrs, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for the user: %w", err)
+	}
+	switch len(res.Entries) {
+	case 0:
+		return nil, ErrInvalidCredentials
+	case 1:
+	default:
+		return nil, fmt.Errorf("the user filter matched multiple entries for %s", login)
+	}
+	user := res.Entries[0]
+
+	if err = conn.Bind(user.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	id := &Identity{
+		Subject: user.DN,
+		Email:   user.GetAttributeValue(l.cfg.EmailAttribute),
+		Name:    user.GetAttributeValue(l.cfg.NameAttribute),
+	}
+	if id.Email == "" {
+		id.Email = login
+	}
+	if id.Name == "" {
+		id.Name = login
+	}
+	if l.cfg.GroupBaseDN == "" {
+		id.Groups = user.GetAttributeValues("memberOf")
+		return id, nil
+	}
+
+	// the user may not be allowed to search the directory
+	if err = l.bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+	filter = strings.NewReplacer("{dn}", ldap.EscapeFilter(user.DN), "{login}", ldap.EscapeFilter(login)).Replace(l.cfg.GroupFilter)
+	res, err = conn.Search(ldap.NewSearchRequest(
+		l.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(ldapTimeout.Seconds()), false, filter, []string{l.cfg.GroupAttribute}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for the user's groups: %w", err)
+	}
+	for _, g := range res.Entries {
+		if l.cfg.GroupAttribute == "dn" {
+			id.Groups = append(id.Groups, g.DN)
+		} else {
+			id.Groups = append(id.Groups, g.GetAttributeValues(l.cfg.GroupAttribute)...)
+		}
+	}
+	return id, nil
+}
+
+func (l *LDAP) connect() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: l.cfg.TlsSkipVerify}
+	conn, err := ldap.DialURL(l.cfg.Url, ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the LDAP server: %w", err)
+	}
+	conn.SetTimeout(ldapTimeout)
+	if l.cfg.StartTLS {
+		if u, err := url.Parse(l.cfg.Url); err == nil {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (l *LDAP) bindServiceAccount(conn *ldap.Conn) error {
+	var err error
+	if l.cfg.BindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(l.cfg.BindDN, l.cfg.BindPassword)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to bind to the LDAP server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512" // registers SHA-384 and SHA-512
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"codexray/db"
+	"codexray/utils"
+)
+
+const (
+	httpTimeout    = 10 * time.Second
+	clockSkew      = time.Minute
+	defaultScope   = "openid"
+	discoveryPath  = "/.well-known/openid-configuration"
+	maxBodyLength  = 1 << 20
+	pkceMethodS256 = "S256"
+)
+
+// OIDC authenticates users with the authorization code flow and PKCE.
+type OIDC struct {
+	cfg         *db.SSOSettingsOIDC
+	redirectUrl string
+	metadata    oidcMetadata
+	client      *http.Client
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// NewOIDC discovers the provider's endpoints.
+func NewOIDC(ctx context.Context, cfg *db.SSOSettingsOIDC, redirectUrl string) (*OIDC, error) {
+	p := &OIDC{cfg: cfg, redirectUrl: redirectUrl, client: &http.Client{Timeout: httpTimeout}}
+	if err := p.getJson(ctx, strings.TrimRight(cfg.Issuer, "/")+discoveryPath, &p.metadata); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if p.metadata.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("OIDC issuer mismatch: expected %s, got %s", cfg.Issuer, p.metadata.Issuer)
+	}
+	if p.metadata.AuthorizationEndpoint == "" || p.metadata.TokenEndpoint == "" || p.metadata.JwksUri == "" {
+		return nil, fmt.Errorf("OIDC discovery document is incomplete")
+	}
+	return p, nil
+}
+
+// AuthCodeUrl returns the URL to redirect the user to for authentication.
+func (p *OIDC) AuthCodeUrl(state, nonce, verifier string) string {
+	scopes := p.cfg.Scopes
+	if !slices.Contains(scopes, defaultScope) {
+		scopes = append([]string{defaultScope}, scopes...)
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientId},
+		"redirect_uri":          {p.redirectUrl},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(verifier)},
+		"code_challenge_method": {pkceMethodS256},
+	}
+	sep := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems the authorization code and returns the identity from the verified ID token.
+func (p *OIDC) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectUrl},
+		"code_verifier": {verifier},
+		"client_id":     {p.cfg.ClientId},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientId), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	var token struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.do(req, &token); err != nil {
+		if token.Error != "" {
+			return nil, fmt.Errorf("token exchange failed: %s: %s", token.Error, token.ErrorDescription)
+		}
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if token.IdToken == "" {
+		return nil, fmt.Errorf("no ID token in the token response")
+	}
+	claims, err := p.verify(ctx, token.IdToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if n, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(n), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("invalid ID token: nonce mismatch")
+	}
+	id := &Identity{Claims: claims}
+	id.Subject, _ = claims["sub"].(string)
+	id.Email, _ = claims["email"].(string)
+	id.Name, _ = claims["name"].(string)
+	if id.Email == "" {
+		return nil, fmt.Errorf("the ID token has no email claim")
+	}
+	// users are matched by email, so an unverified one would let anyone who can set it log in as another user
+	if !emailVerified(claims["email_verified"]) {
+		return nil, fmt.Errorf("the email %s is not verified by the identity provider", id.Email)
+	}
+	if id.Name == "" {
+		id.Name = id.Email
+	}
+	id.Groups = claimValues(claims, p.cfg.RoleClaim)
+	return id, nil
+}
+
+// emailVerified accepts both the boolean and the string form of the email_verified claim, some providers use the latter.
+func emailVerified(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// verify checks the signature of the ID token and its standard claims.
+func (p *OIDC) verify(ctx context.Context, token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	keys, err := p.keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if header.Kid != "" && k.Kid != "" && k.Kid != header.Kid {
+			continue
+		}
+		if err := k.verify(header.Alg, signed, signature); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("signature verification failed")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != p.metadata.Issuer {
+		return nil, fmt.Errorf("unexpected issuer: %s", iss)
+	}
+	if !slices.Contains(claimValues(claims, "aud"), p.cfg.ClientId) {
+		return nil, fmt.Errorf("the token is issued for another client")
+	}
+	now := time.Now()
+	exp, _ := claims["exp"].(float64)
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("the token is expired")
+	}
+	if iat, ok := claims["iat"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(iat), 0)) {
+		return nil, fmt.Errorf("the token is issued in the future")
+	}
+	return claims, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (p *OIDC) keys(ctx context.Context) ([]jwk, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJson(ctx, p.metadata.JwksUri, &set); err != nil {
+		return nil, fmt.Errorf("failed to get JWKS: %w", err)
+	}
+	return set.Keys, nil
+}
+
+func (k jwk) verify(alg string, signed, signature []byte) error {
+	if k.Use != "" && k.Use != "sig" {
+		return fmt.Errorf("not a signing key")
+	}
+	if k.Alg != "" && k.Alg != alg {
+		return fmt.Errorf("algorithm mismatch")
+	}
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch {
+	case k.Kty == "RSA" && strings.HasPrefix(alg, "RS"):
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return err
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+	case k.Kty == "EC" && strings.HasPrefix(alg, "ES"):
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid signature length")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("key type %s doesn't match algorithm %s", k.Kty, alg)
+}
+
+func (p *OIDC) getJson(ctx context.Context, u string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return p.do(req, dest)
+}
+
+func (p *OIDC) do(req *http.Request, dest any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyLength))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = json.Unmarshal(body, dest)
+		return fmt.Errorf("response status: %s", resp.Status)
+	}
+	return json.Unmarshal(body, dest)
+}
+
+func decodeSegment(s string, dest any) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// claimValues returns the string values of the claim, which may be a string or an array of strings.
+// Nested claims are addressed with dots, e.g., realm_access.roles.
+func claimValues(claims map[string]any, name string) []string {
+	if name == "" {
+		return nil
+	}
+	var v any = claims
+	for _, part := range strings.Split(name, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var res []string
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// NewPKCEVerifier returns a random code verifier; nanoid's alphabet is a subset of the allowed characters.
+func NewPKCEVerifier() string {
+	return utils.RandomString(64)
+}
+
+func CodeChallenge(verifier string) string {
+	h := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,158 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"codexray/db"
+	"codexray/rbac"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockProvider is a minimal OIDC provider issuing RS256-signed ID tokens.
+type mockProvider struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	claims    map[string]any
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := &mockProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("code") != "the-code" || CodeChallenge(r.Form.Get("code_verifier")) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(t)})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *mockProvider) sign(t *testing.T) string {
+	claims := map[string]any{
+		"iss":            p.URL,
+		"aud":            "codexray",
+		"sub":            "1",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane",
+		"nonce":          p.nonce,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"realm_access": map[string]any{
+			"roles": []string{"sre", "dev"},
+		},
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (p *mockProvider) authorize(t *testing.T, authUrl string) {
+	u, err := url.Parse(authUrl)
+	require.NoError(t, err)
+	assert.Equal(t, "/authorize", u.Path)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid email groups", u.Query().Get("scope"))
+	p.challenge = u.Query().Get("code_challenge")
+	p.nonce = u.Query().Get("nonce")
+}
+
+func TestOIDC(t *testing.T) {
+	ctx := context.Background()
+	p := newMockProvider(t)
+	cfg := &db.SSOSettingsOIDC{Issuer: p.URL, ClientId: "codexray", ClientSecret: "secret", Scopes: []string{"email", "groups"}, RoleClaim: "realm_access.roles"}
+	oidc, err := NewOIDC(ctx, cfg, "https://codexray.example.com/api/sso/oidc/callback")
+	require.NoError(t, err)
+
+	verifier := NewPKCEVerifier()
+	p.authorize(t, oidc.AuthCodeUrl("state", "nonce", verifier))
+	id, err := oidc.Exchange(ctx, "the-code", verifier, "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", id.Email)
+	assert.Equal(t, "Jane", id.Name)
+	assert.Equal(t, []string{"sre", "dev"}, id.Groups)
+
+	mapping := RoleMapping{Rules: []db.SSORoleMapping{{Value: "admins", Role: rbac.RoleAdmin}, {Value: "s*", Role: rbac.RoleEditor}}}
+	roles, err := id.Roles(mapping)
+	require.NoError(t, err)
+	assert.Equal(t, []rbac.RoleName{rbac.RoleEditor}, roles)
+	id.Groups = nil
+	_, err = id.Roles(mapping)
+	assert.ErrorIs(t, err, ErrNoRole)
+	mapping.Default = rbac.RoleViewer
+	roles, err = id.Roles(mapping)
+	require.NoError(t, err)
+	assert.Equal(t, []rbac.RoleName{rbac.RoleViewer}, roles)
+
+	_, err = oidc.Exchange(ctx, "the-code", "another-verifier", "nonce")
+	assert.ErrorContains(t, err, "invalid_grant")
+
+	_, err = oidc.Exchange(ctx, "the-code", verifier, "another-nonce")
+	assert.ErrorContains(t, err, "nonce mismatch")
+
+	p.claims = map[string]any{"aud": []string{"another-client"}}
+	_, err = oidc.Exchange(ctx, "the-code", verifier, "nonce")
+	assert.ErrorContains(t, err, "another client")
+
+	p.claims = map[string]any{"email_verified": false}
+	_, err = oidc.Exchange(ctx, "the-code", verifier, "nonce")
+	assert.ErrorContains(t, err, "not verified")
+
+	p.claims = map[string]any{"email": "", "preferred_username": "admin"}
+	_, err = oidc.Exchange(ctx, "the-code", verifier, "nonce")
+	assert.ErrorContains(t, err, "no email claim")
+
+	p.claims = map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}
+	_, err = oidc.Exchange(ctx, "the-code", verifier, "nonce")
+	assert.ErrorContains(t, err, "expired")
+
+	p.claims = nil
+	p.key, _ = rsa.GenerateKey(rand.Reader, 2048) // signed with a key missing from the JWKS
+	_, err = oidc.Exchange(ctx, "the-code", verifier, "nonce")
+	assert.ErrorContains(t, err, "signature verification failed")
+}
@@ -0,0 +1,171 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+)
+
+type SAMLConfig struct {
+	// BaseUrl is the public URL of the instance, e.g., https://codexray.example.com/
+	BaseUrl string
+	// IdPMetadata is the URL or the path of the identity provider's metadata.
+	IdPMetadata string
+	// Certificate and Key are the paths of the PEM-encoded key pair used to sign requests and decrypt assertions.
+	Certificate string
+	Key         string
+
+	// EmailAttribute is the attribute holding the user's email; if empty, the NameID is used.
+	EmailAttribute string
+	NameAttribute  string
+	// RoleAttribute is the attribute holding the values to map to roles, e.g., groups.
+	RoleAttribute string
+}
+
+// SAML is a SAML 2.0 service provider; the identity provider sends assertions to the ACS URL using the HTTP-POST binding.
+type SAML struct {
+	cfg SAMLConfig
+	sp  *saml.ServiceProvider
+}
+
+func NewSAML(ctx context.Context, cfg SAMLConfig) (*SAML, error) {
+	base, err := url.Parse(strings.TrimRight(cfg.BaseUrl, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid SAML base URL: %s", cfg.BaseUrl)
+	}
+	keyPair, err := tls.LoadX509KeyPair(cfg.Certificate, cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the SAML key pair: %w", err)
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported SAML private key")
+	}
+	idp, err := loadIdPMetadata(ctx, cfg.IdPMetadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the IdP metadata: %w", err)
+	}
+	metadataUrl := base.JoinPath("/api/sso/saml/metadata")
+	sp := &saml.ServiceProvider{
+		EntityID:          metadataUrl.String(),
+		Key:               key,
+		Certificate:       cert,
+		MetadataURL:       *metadataUrl,
+		AcsURL:            *base.JoinPath("/api/sso/saml/acs"),
+		IDPMetadata:       idp,
+		AuthnNameIDFormat: saml.EmailAddressNameIDFormat,
+	}
+	return &SAML{cfg: cfg, sp: sp}, nil
+}
+
+func loadIdPMetadata(ctx context.Context, location string) (*saml.EntityDescriptor, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+		return samlsp.FetchMetadata(ctx, http.DefaultClient, *u)
+	}
+	data, err := os.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+	return samlsp.ParseMetadata(data)
+}
+
+func (s *SAML) BaseUrl() string {
+	return s.cfg.BaseUrl
+}
+
+// Metadata returns the service provider's metadata to be registered with the identity provider.
+func (s *SAML) Metadata() ([]byte, error) {
+	data, err := xml.MarshalIndent(s.sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// AuthnRequestUrl returns the URL to redirect the user to and the ID of the request to be checked in the response.
+func (s *SAML) AuthnRequestUrl(relayState string) (string, string, error) {
+	req, err := s.sp.MakeAuthenticationRequest(s.sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", "", err
+	}
+	u, err := req.Redirect(relayState, s.sp)
+	if err != nil {
+		return "", "", err
+	}
+	return u.String(), req.ID, nil
+}
+
+// ParseResponse verifies the response posted to the ACS URL and returns the authenticated identity.
+func (s *SAML) ParseResponse(r *http.Request, requestId string) (*Identity, error) {
+	assertion, err := s.sp.ParseResponse(r, []string{requestId})
+	if err != nil {
+		var ie *saml.InvalidResponseError
+		if errors.As(err, &ie) {
+			return nil, fmt.Errorf("invalid SAML response: %w", ie.PrivateErr) // the public error says nothing
+		}
+		return nil, err
+	}
+	return s.identity(assertion)
+}
+
+func (s *SAML) identity(assertion *saml.Assertion) (*Identity, error) {
+	id := &Identity{}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		id.Subject = assertion.Subject.NameID.Value
+	}
+	id.Email = id.Subject
+	if s.cfg.EmailAttribute != "" {
+		if v := samlAttributeValues(assertion, s.cfg.EmailAttribute); len(v) > 0 {
+			id.Email = v[0]
+		}
+	}
+	if id.Email == "" {
+		return nil, fmt.Errorf("the assertion has no email")
+	}
+	id.Name = id.Email
+	if v := samlAttributeValues(assertion, s.cfg.NameAttribute); len(v) > 0 {
+		id.Name = v[0]
+	}
+	id.Groups = samlAttributeValues(assertion, s.cfg.RoleAttribute)
+	return id, nil
+}
+
+// samlAttributeValues returns the values of the attribute referred to by its name or friendly name.
+func samlAttributeValues(assertion *saml.Assertion, name string) []string {
+	if name == "" {
+		return nil
+	}
+	var res []string
+	for _, st := range assertion.AttributeStatements {
+		for _, a := range st.Attributes {
+			if a.Name != name && a.FriendlyName != name {
+				continue
+			}
+			for _, v := range a.Values {
+				if v.Value != "" {
+					res = append(res, v.Value)
+				}
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+package sso
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"codexray/db"
+	"codexray/rbac"
+	"codexray/utils"
+)
+
+var (
+	ErrNoRole             = errors.New("no role is assigned to the user")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type BackendType string
+
+const (
+	BackendLocal BackendType = "local"
+	BackendLDAP  BackendType = "ldap"
+	BackendSAML  BackendType = "saml"
+)
+
+// Identity is a user authenticated by an identity provider.
+type Identity struct {
+	Subject string
+	Email   string
+	Name    string
+	// Groups are the values of the claim or attribute used for role mapping.
+	Groups []string
+	Claims map[string]any
+}
+
+// RoleMapping assigns roles to identities based on their groups.
+type RoleMapping struct {
+	Rules []db.SSORoleMapping
+	// Default is assigned to identities none of the rules apply to; if empty, such identities are denied access.
+	Default rbac.RoleName
+}
+
+// ParseRoleMapping parses rules in the <role>=<group glob> format, e.g., Editor=cn=sre,ou=groups,*.
+func ParseRoleMapping(rules []string, defaultRole string, roles []rbac.Role) (RoleMapping, error) {
+	m := RoleMapping{Default: rbac.RoleName(defaultRole)}
+	if m.Default != "" && !m.Default.Valid(roles) {
+		return m, fmt.Errorf("unknown role: %s", m.Default)
+	}
+	for _, r := range rules {
+		role, value, ok := strings.Cut(r, "=")
+		if !ok || value == "" {
+			return m, fmt.Errorf("invalid role mapping '%s', expected <role>=<group>", r)
+		}
+		rule := db.SSORoleMapping{Role: rbac.RoleName(strings.TrimSpace(role)), Value: strings.TrimSpace(value)}
+		if !rule.Role.Valid(roles) {
+			return m, fmt.Errorf("unknown role: %s", rule.Role)
+		}
+		m.Rules = append(m.Rules, rule)
+	}
+	return m, nil
+}
+
+// Roles maps the identity's groups to roles, falling back to the default role if none of the rules apply.
+func (id *Identity) Roles(mapping RoleMapping) ([]rbac.RoleName, error) {
+	var res []rbac.RoleName
+	for _, m := range mapping.Rules {
+		for _, g := range id.Groups {
+			if utils.GlobMatch(g, m.Value) && !slices.Contains(res, m.Role) {
+				res = append(res, m.Role)
+				break
+			}
+		}
+	}
+	if len(res) == 0 && mapping.Default != "" {
+		res = append(res, mapping.Default)
+	}
+	if len(res) == 0 {
+		return nil, ErrNoRole
+	}
+	return res, nil
+}
@@ -0,0 +1,59 @@
+package sso
+
+import (
+	"testing"
+
+	"codexray/db"
+	"codexray/rbac"
+
+	"github.com/crewjam/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoleMapping(t *testing.T) {
+	m, err := ParseRoleMapping([]string{"Admin=cn=admins,ou=groups,dc=example,dc=com", " Editor = sre* "}, "Viewer", rbac.Roles)
+	require.NoError(t, err)
+	assert.Equal(t, rbac.RoleViewer, m.Default)
+	assert.Equal(t, []db.SSORoleMapping{
+		{Role: rbac.RoleAdmin, Value: "cn=admins,ou=groups,dc=example,dc=com"},
+		{Role: rbac.RoleEditor, Value: "sre*"},
+	}, m.Rules)
+
+	id := &Identity{Groups: []string{"cn=admins,ou=groups,dc=example,dc=com"}}
+	roles, err := id.Roles(m)
+	require.NoError(t, err)
+	assert.Equal(t, []rbac.RoleName{rbac.RoleAdmin}, roles)
+
+	_, err = ParseRoleMapping([]string{"Editor"}, "", rbac.Roles)
+	assert.Error(t, err)
+	_, err = ParseRoleMapping([]string{"Unknown=sre"}, "", rbac.Roles)
+	assert.ErrorContains(t, err, "unknown role")
+	_, err = ParseRoleMapping(nil, "Unknown", rbac.Roles)
+	assert.ErrorContains(t, err, "unknown role")
+}
+
+func TestSAMLIdentity(t *testing.T) {
+	assertion := &saml.Assertion{
+		Subject: &saml.Subject{NameID: &saml.NameID{Value: "jane@example.com"}},
+		AttributeStatements: []saml.AttributeStatement{{Attributes: []saml.Attribute{
+			{Name: "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name", FriendlyName: "displayName", Values: []saml.AttributeValue{{Value: "Jane"}}},
+			{Name: "groups", Values: []saml.AttributeValue{{Value: "sre"}, {Value: "dev"}}},
+		}}},
+	}
+	s := &SAML{cfg: SAMLConfig{NameAttribute: "displayName", RoleAttribute: "groups"}}
+	id, err := s.identity(assertion)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", id.Email)
+	assert.Equal(t, "Jane", id.Name)
+	assert.Equal(t, []string{"sre", "dev"}, id.Groups)
+
+	s.cfg.EmailAttribute = "mail"
+	id, err = s.identity(assertion)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", id.Email, "falls back to the NameID")
+
+	assertion.Subject = nil
+	_, err = s.identity(assertion)
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+package watchers
+
+import (
+	"context"
+	"time"
+
+	"codexray/cache"
+	"codexray/db"
+	"codexray/model"
+	"codexray/notifications"
+	"codexray/prom"
+	"codexray/timeseries"
+	"codexray/utils"
+
+	"k8s.io/klog"
+)
+
+const (
+	alertRuleEvaluationTimeout = 30 * time.Second
+	alertRuleMaxReportedSeries = 10
+)
+
+type AlertRules struct {
+	db       *db.DB
+	cache    *cache.Cache
+	notifier *notifications.IncidentNotifier
+	// states are the rule states seen by the previous check of each project
+	states map[db.ProjectId]map[string]alertRuleState
+}
+
+// alertRuleState is what decides which of the rule's incidents stay open.
+type alertRuleState struct {
+	disabled bool
+	appId    model.ApplicationId
+}
+
+func NewAlertRules(database *db.DB, cache *cache.Cache, notifier *notifications.IncidentNotifier) *AlertRules {
+	return &AlertRules{db: database, cache: cache, notifier: notifier, states: map[db.ProjectId]map[string]alertRuleState{}}
+}
+
+func (w *AlertRules) Check(project *db.Project, world *model.World) {
+	start := time.Now()
+
+	rules, err := w.db.GetAlertRules(project.Id)
+	if err != nil {
+		klog.Errorln(err)
+		return
+	}
+	if len(rules) == 0 {
+		delete(w.states, project.Id)
+		return
+	}
+	promClient, err := w.cache.GetPrometheusClient(project)
+	if err != nil {
+		klog.Warningln(project.Id, err)
+		return
+	}
+
+	var checked int
+	prev, states := w.states[project.Id], map[string]alertRuleState{}
+	for _, rule := range rules {
+		state := alertRuleState{disabled: rule.Disabled, appId: rule.IncidentApplicationId()}
+		states[rule.Id] = state
+		if p, ok := prev[rule.Id]; !ok || p != state {
+			// resolves all the incidents of a disabled rule,
+			// or the ones opened before the rule was bound to another application
+			keep := state.appId
+			if rule.Disabled {
+				keep = model.ApplicationId{}
+			}
+			notifications.ResolveAlertRuleIncidents(w.db, project, rule, keep, timeseries.Now())
+		}
+		if rule.Disabled {
+			continue
+		}
+		firing, err := evaluateAlertRule(promClient, rule, world.Ctx.To, world.Ctx.Step)
+		if err != nil {
+			klog.Warningf("%s: failed to evaluate alert rule %s: %s", project.Id, rule.Name, err)
+			continue
+		}
+		checked++
+		severity := model.OK
+		if len(firing) > 0 {
+			severity = rule.Severity
+		}
+		now := timeseries.Now()
+		incident, err := w.db.CreateOrUpdateAlertRuleIncident(project.Id, rule.IncidentApplicationId(), rule.Id, now, severity)
+		if err != nil {
+			klog.Errorln(err)
+			continue
+		}
+		if incident == nil {
+			continue
+		}
+		var app *model.Application
+		if !rule.ApplicationId.IsZero() {
+			app = world.GetApplication(rule.ApplicationId)
+		}
+		w.notifier.EnqueueAlertRule(project, rule, app, incident, alertRuleReports(rule, firing), now)
+	}
+	w.states[project.Id] = states
+	klog.Infof("%s: checked %d alert rules in %s", project.Id, checked, time.Since(start).Truncate(time.Millisecond))
+}
+
+// evaluateAlertRule returns the series for which the rule's expression has returned a value
+// at every step of the `for` window ending at `to`.
+func evaluateAlertRule(promClient *prom.Client, rule *db.AlertRule, to timeseries.Time, step timeseries.Duration) ([]model.MetricValues, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), alertRuleEvaluationTimeout)
+	defer cancel()
+	from := to.Add(-rule.For)
+	mvs, err := promClient.QueryRange(ctx, rule.Expr, from, to, step)
+	if err != nil {
+		return nil, err
+	}
+	var res []model.MetricValues
+	for _, mv := range mvs {
+		if alertRuleConditionHolds(mv.Values) {
+			res = append(res, mv)
+		}
+	}
+	return res, nil
+}
+
+func alertRuleConditionHolds(values *timeseries.TimeSeries) bool {
+	if values.IsEmpty() {
+		return false
+	}
+	iter := values.Iter()
+	for iter.Next() {
+		if _, v := iter.Value(); timeseries.IsNaN(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func alertRuleReports(rule *db.AlertRule, firing []model.MetricValues) []db.IncidentNotificationDetailsReport {
+	var res []db.IncidentNotificationDetailsReport
+	for i, mv := range firing {
+		if i == alertRuleMaxReportedSeries {
+			break
+		}
+		res = append(res, db.IncidentNotificationDetailsReport{
+			Name:    model.AuditReportName(rule.Name),
+			Check:   mv.Labels.String(),
+			Message: "value: " + utils.FormatFloat(mv.Values.Last()),
+		})
+	}
+	return res
+}
@@ -0,0 +1,18 @@
+package watchers
+
+import (
+	"testing"
+
+	"codexray/timeseries"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertRuleConditionHolds(t *testing.T) {
+	nan := timeseries.NaN
+	assert.False(t, alertRuleConditionHolds(nil))
+	assert.True(t, alertRuleConditionHolds(timeseries.NewWithData(0, 30, []float32{1})))
+	assert.True(t, alertRuleConditionHolds(timeseries.NewWithData(0, 30, []float32{0, 1, 2})))
+	assert.False(t, alertRuleConditionHolds(timeseries.NewWithData(0, 30, []float32{nan, 1, 2})))
+	assert.False(t, alertRuleConditionHolds(timeseries.NewWithData(0, 30, []float32{1, 1, nan})))
+}
@@ -0,0 +1,37 @@
+package watchers
+
+import (
+	"context"
+	"time"
+
+	"codexray/db"
+	"codexray/timeseries"
+
+	"k8s.io/klog"
+)
+
+const auditLogCleanupInterval = time.Hour
+
+// StartAuditLogCleanup periodically deletes audit log entries older than the configured retention period.
+func StartAuditLogCleanup(database *db.DB) {
+	go func() {
+		for range time.Tick(auditLogCleanupInterval) {
+			if !database.GetPrimaryLock(context.TODO()) {
+				continue
+			}
+			settings, err := database.GetAuditLogSettings()
+			if err != nil {
+				klog.Errorln(err)
+				continue
+			}
+			deleted, err := database.DeleteAuditLogEntriesBefore(timeseries.Now().Add(-settings.Retention))
+			if err != nil {
+				klog.Errorln("failed to delete expired audit log entries:", err)
+				continue
+			}
+			if deleted > 0 {
+				klog.Infof("deleted %d expired audit log entries", deleted)
+			}
+		}
+	}()
+}
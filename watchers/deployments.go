@@ -1,6 +1,7 @@
 package watchers
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"sort"
@@ -113,9 +114,10 @@ func (w *Deployments) sendNotifications(project *db.Project, world *model.World)
 			if d.Notifications.State >= ds.State {
 				continue
 			}
+			targets := deploymentTargets(project, app, ds.Status)
 			needSave := false
-			if cfg := integrations.Slack; cfg != nil && cfg.Deployments && d.Notifications.Slack.State < ds.State {
-				client := notifications.NewSlack(cfg.Token, cfg.DefaultChannel)
+			if cfg := integrations.Slack; cfg != nil && cfg.Deployments && d.Notifications.Slack.State < ds.State && targets.enabled(db.IntegrationTypeSlack) {
+				client := notifications.NewSlack(cfg.Token, cmp.Or(targets.get(db.IntegrationTypeSlack), cfg.DefaultChannel))
 				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
 				err := client.SendDeployment(ctx, project, ds)
 				cancel()
@@ -126,8 +128,8 @@ func (w *Deployments) sendNotifications(project *db.Project, world *model.World)
 					needSave = true
 				}
 			}
-			if cfg := integrations.Teams; cfg != nil && cfg.Deployments && d.Notifications.Teams.State < ds.State {
-				client := notifications.NewTeams(cfg.WebhookUrl)
+			if cfg := integrations.Teams; cfg != nil && cfg.Deployments && d.Notifications.Teams.State < ds.State && targets.enabled(db.IntegrationTypeTeams) {
+				client := notifications.NewTeams(cmp.Or(targets.get(db.IntegrationTypeTeams), cfg.WebhookUrl))
 				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
 				err := client.SendDeployment(ctx, project, ds)
 				cancel()
@@ -138,8 +140,8 @@ func (w *Deployments) sendNotifications(project *db.Project, world *model.World)
 					needSave = true
 				}
 			}
-			if cfg := integrations.Webhook; cfg != nil && cfg.Deployments && d.Notifications.Webhook.State < ds.State {
-				client := notifications.NewWebhook(cfg)
+			if cfg := integrations.Webhook; cfg != nil && cfg.Deployments && d.Notifications.Webhook.State < ds.State && targets.enabled(db.IntegrationTypeWebhook) {
+				client := notifications.NewWebhook(cfg.WithUrl(targets.get(db.IntegrationTypeWebhook)))
 				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
 				err := client.SendDeployment(ctx, project, ds)
 				cancel()
@@ -150,6 +152,66 @@ func (w *Deployments) sendNotifications(project *db.Project, world *model.World)
 					needSave = true
 				}
 			}
+			if cfg := integrations.Email; cfg != nil && cfg.Deployments && d.Notifications.Email.State < ds.State && targets.enabled(db.IntegrationTypeEmail) {
+				client := notifications.NewEmail(cfg.WithRecipients(targets.get(db.IntegrationTypeEmail)), project.Settings.ApplicationCategories)
+				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+				err := client.SendDeployment(ctx, project, ds)
+				cancel()
+				if err != nil {
+					klog.Errorln(err)
+				} else {
+					d.Notifications.Email.State = ds.State
+					needSave = true
+				}
+			}
+			if cfg := integrations.Telegram; cfg != nil && cfg.Deployments && d.Notifications.Telegram.State < ds.State && targets.enabled(db.IntegrationTypeTelegram) {
+				client := notifications.NewTelegram(cfg.BotToken, cmp.Or(targets.get(db.IntegrationTypeTelegram), cfg.ChatId))
+				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+				err := client.SendDeployment(ctx, project, ds)
+				cancel()
+				if err != nil {
+					klog.Errorln(err)
+				} else {
+					d.Notifications.Telegram.State = ds.State
+					needSave = true
+				}
+			}
+			if cfg := integrations.Mattermost; cfg != nil && cfg.Deployments && d.Notifications.Mattermost.State < ds.State && targets.enabled(db.IntegrationTypeMattermost) {
+				client := notifications.NewMattermost(cmp.Or(targets.get(db.IntegrationTypeMattermost), cfg.WebhookUrl), cfg.Channel)
+				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+				err := client.SendDeployment(ctx, project, ds)
+				cancel()
+				if err != nil {
+					klog.Errorln(err)
+				} else {
+					d.Notifications.Mattermost.State = ds.State
+					needSave = true
+				}
+			}
+			if cfg := integrations.GoogleChat; cfg != nil && cfg.Deployments && d.Notifications.GoogleChat.State < ds.State && targets.enabled(db.IntegrationTypeGoogleChat) {
+				client := notifications.NewGoogleChat(cmp.Or(targets.get(db.IntegrationTypeGoogleChat), cfg.WebhookUrl))
+				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+				err := client.SendDeployment(ctx, project, ds)
+				cancel()
+				if err != nil {
+					klog.Errorln(err)
+				} else {
+					d.Notifications.GoogleChat.State = ds.State
+					needSave = true
+				}
+			}
+			if cfg := integrations.Discord; cfg != nil && cfg.Deployments && d.Notifications.Discord.State < ds.State && targets.enabled(db.IntegrationTypeDiscord) {
+				client := notifications.NewDiscord(cmp.Or(targets.get(db.IntegrationTypeDiscord), cfg.WebhookUrl))
+				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+				err := client.SendDeployment(ctx, project, ds)
+				cancel()
+				if err != nil {
+					klog.Errorln(err)
+				} else {
+					d.Notifications.Discord.State = ds.State
+					needSave = true
+				}
+			}
 			if !needSave {
 				continue
 			}
@@ -160,6 +222,40 @@ func (w *Deployments) sendNotifications(project *db.Project, world *model.World)
 	}
 }
 
+// deploymentRouting holds the first routed target per integration, or nil if no notification route matches the application.
+type deploymentRouting map[db.IntegrationType]string
+
+func deploymentTargets(project *db.Project, app *model.Application, status model.Status) deploymentRouting {
+	destinations := project.Settings.NotificationRoutes.Route(db.NotificationSubject{
+		ApplicationId: app.Id,
+		Category:      app.Category,
+		Severity:      status,
+		Labels:        app.Labels(),
+	})
+	if len(destinations) == 0 {
+		return nil
+	}
+	res := deploymentRouting{}
+	for _, d := range destinations {
+		if _, ok := res[d.Type]; !ok {
+			res[d.Type] = d.Target()
+		}
+	}
+	return res
+}
+
+func (r deploymentRouting) enabled(t db.IntegrationType) bool {
+	if r == nil {
+		return true
+	}
+	_, ok := r[t]
+	return ok
+}
+
+func (r deploymentRouting) get(t db.IntegrationType) string {
+	return r[t]
+}
+
 func calcDeployments(app *model.Application) []*model.ApplicationDeployment {
 	if app.Id.Kind != model.ApplicationKindDeployment || len(app.Instances) == 0 {
 		return nil
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"codexray/db"
 	"codexray/model"
 	"codexray/timeseries"
 
@@ -94,3 +95,40 @@ func TestCalcDeployments(t *testing.T) {
 	addInstance("i2", "rs2", 0, 0, 1, 1, 0, 0)
 	checkDeployments("3-0:rs2;5-5:rs1")
 }
+
+func TestDeploymentTargets(t *testing.T) {
+	project := &db.Project{}
+	app := model.NewApplication(model.NewApplicationId("team-a", model.ApplicationKindDeployment, "catalog"))
+	app.Category = model.ApplicationCategoryApplication
+
+	r := deploymentTargets(project, app, model.OK)
+	assert.Nil(t, r)
+	assert.True(t, r.enabled(db.IntegrationTypeSlack))
+
+	project.Settings.NotificationRoutes = db.NotificationRoutes{
+		{
+			Name:         "team-b",
+			Match:        db.NotificationRouteMatch{Namespaces: []string{"team-b"}},
+			Destinations: []db.NotificationDestination{{Type: db.IntegrationTypeSlack, SlackChannel: "team-b"}},
+		},
+		{
+			Name:         "team-a",
+			Match:        db.NotificationRouteMatch{Namespaces: []string{"team-a"}},
+			Destinations: []db.NotificationDestination{{Type: db.IntegrationTypeSlack, SlackChannel: "team-a"}},
+			Routes: db.NotificationRoutes{
+				{
+					Name:         "team-a-critical",
+					Match:        db.NotificationRouteMatch{Severities: []model.Status{model.CRITICAL}},
+					Destinations: []db.NotificationDestination{{Type: db.IntegrationTypePagerduty, PagerdutyIntegrationKey: "key"}},
+				},
+			},
+		},
+	}
+	r = deploymentTargets(project, app, model.OK)
+	assert.Equal(t, "team-a", r.get(db.IntegrationTypeSlack))
+	assert.False(t, r.enabled(db.IntegrationTypeTeams))
+
+	r = deploymentTargets(project, app, model.CRITICAL)
+	assert.False(t, r.enabled(db.IntegrationTypeSlack))
+	assert.Equal(t, "key", r.get(db.IntegrationTypePagerduty))
+}
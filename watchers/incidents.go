@@ -1,6 +1,8 @@
 package watchers
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"codexray/auditor"
@@ -13,12 +15,13 @@ import (
 )
 
 type Incidents struct {
-	db       *db.DB
-	notifier *notifications.IncidentNotifier
+	db        *db.DB
+	notifier  *notifications.IncidentNotifier
+	debouncer *incidentDebouncer
 }
 
-func NewIncidents(db *db.DB) *Incidents {
-	return &Incidents{db: db, notifier: notifications.NewIncidentNotifier(db)}
+func NewIncidents(db *db.DB, notifier *notifications.IncidentNotifier) *Incidents {
+	return &Incidents{db: db, notifier: notifier, debouncer: newIncidentDebouncer()}
 }
 
 func (w *Incidents) Check(project *db.Project, world *model.World) {
@@ -26,14 +29,24 @@ func (w *Incidents) Check(project *db.Project, world *model.World) {
 
 	auditor.Audit(world, project, nil, false)
 
-	var apps int
+	now := timeseries.Now()
+	settings := project.Settings.Incidents
+	open, err := w.getOpenIncidents(project.Id, now)
+	if err != nil {
+		klog.Errorln(err)
+		return
+	}
+
+	var opened []appIncident
+	resolved := map[string]bool{}
+	evaluated := map[model.ApplicationId]bool{}
 	for _, app := range world.Applications {
-		status := app.SLOStatus()
-		if status == model.UNKNOWN {
+		observed := app.SLOStatus()
+		if observed == model.UNKNOWN {
 			continue
 		}
-		apps++
-		now := timeseries.Now()
+		evaluated[app.Id] = true
+		status := w.debouncer.status(project.Id, app.Id, open[app.Id], observed, now, settings)
 		incident, err := w.db.CreateOrUpdateIncident(project.Id, app.Id, now, status)
 		if err != nil {
 			klog.Errorln(err)
@@ -42,7 +55,301 @@ func (w *Incidents) Check(project *db.Project, world *model.World) {
 		if incident == nil {
 			continue
 		}
+		if incident.Resolved() {
+			resolved[incident.Key] = true
+		}
+		if settings.GroupByDependency && open[app.Id] == nil && !incident.Resolved() {
+			opened = append(opened, appIncident{app: app, incident: incident})
+			continue
+		}
 		w.notifier.Enqueue(project, app, incident, now)
 	}
-	klog.Infof("%s: checked %d apps in %s", project.Id, apps, time.Since(start).Truncate(time.Millisecond))
+	if len(opened) > 0 {
+		w.enqueueGroups(project, opened, open, now)
+	}
+	w.ungroupOrphans(project, world, orphanedGroupMembers(open, resolved), now)
+	w.debouncer.prune(project.Id, evaluated, now)
+	klog.Infof("%s: checked %d apps in %s", project.Id, len(evaluated), time.Since(start).Truncate(time.Millisecond))
+}
+
+// getOpenIncidents returns the open SLO-based incidents by application.
+func (w *Incidents) getOpenIncidents(projectId db.ProjectId, now timeseries.Time) (map[model.ApplicationId]*model.ApplicationIncident, error) {
+	incidents, err := w.db.GetApplicationIncidents(projectId, now, now)
+	if err != nil {
+		return nil, err
+	}
+	res := map[model.ApplicationId]*model.ApplicationIncident{}
+	for appId, appIncidents := range incidents {
+		for _, i := range appIncidents {
+			if !i.Resolved() && i.AlertRuleId == "" {
+				res[appId] = i
+			}
+		}
+	}
+	return res, nil
+}
+
+type appIncident struct {
+	app      *model.Application
+	incident *model.ApplicationIncident
+}
+
+// enqueueGroups notifies of the newly opened incidents, sending a single notification
+// for the ones caused by a shared unhealthy dependency.
+func (w *Incidents) enqueueGroups(project *db.Project, opened []appIncident, open map[model.ApplicationId]*model.ApplicationIncident, now timeseries.Time) {
+	groups, ungrouped := groupIncidents(opened, open)
+	for _, g := range groups {
+		group := &db.IncidentNotificationDetailsGroup{Root: g.root}
+		for _, m := range g.members {
+			if m.incident.Key != g.leaderKey {
+				group.Applications = append(group.Applications, m.app.Id)
+			}
+			m.incident.GroupKey, m.incident.GroupRoot = g.leaderKey, g.root
+			if err := w.db.SetIncidentGroup(project.Id, m.incident.Key, g.leaderKey, g.root); err != nil {
+				klog.Errorln(err)
+			}
+		}
+		if g.leader == nil { // the group is led by an incident notified earlier
+			klog.Infof("%s: incidents of %v joined the group of incident %s", project.Id, group.Applications, g.leaderKey)
+		} else {
+			group.Applications = append([]model.ApplicationId{g.leader.app.Id}, group.Applications...)
+			w.notifier.EnqueueGroup(project, g.leader.app, g.leader.incident, group, now)
+		}
+		for _, m := range g.members {
+			if m.incident.Grouped() {
+				w.notifier.Enqueue(project, m.app, m.incident, now)
+			}
+		}
+	}
+	for _, i := range ungrouped {
+		w.notifier.Enqueue(project, i.app, i.incident, now)
+	}
+}
+
+// ungroupOrphans notifies of the incidents whose group leader has been resolved,
+// since the notifications of grouped incidents are suppressed and nobody would know they are still open.
+func (w *Incidents) ungroupOrphans(project *db.Project, world *model.World, orphans []*model.ApplicationIncident, now timeseries.Time) {
+	for _, i := range orphans {
+		klog.Infof("%s: incident %s left the group of resolved incident %s", project.Id, i.Key, i.GroupKey)
+		i.GroupKey, i.GroupRoot = "", model.ApplicationId{}
+		if err := w.db.SetIncidentGroup(project.Id, i.Key, i.GroupKey, i.GroupRoot); err != nil {
+			klog.Errorln(err)
+			continue
+		}
+		if app := world.GetApplication(i.ApplicationId); app != nil {
+			w.notifier.Enqueue(project, app, i, now)
+		}
+	}
+}
+
+// orphanedGroupMembers returns the open incidents of the groups whose leaders are among the resolved ones.
+func orphanedGroupMembers(open map[model.ApplicationId]*model.ApplicationIncident, resolved map[string]bool) []*model.ApplicationIncident {
+	var res []*model.ApplicationIncident
+	for _, i := range open {
+		if i.Grouped() && resolved[i.GroupKey] && !resolved[i.Key] {
+			res = append(res, i)
+		}
+	}
+	slices.SortFunc(res, func(a, b *model.ApplicationIncident) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return res
+}
+
+type incidentGroup struct {
+	root      model.ApplicationId
+	leaderKey string
+	leader    *appIncident // nil if the group is led by an incident notified earlier
+	members   []appIncident
+}
+
+// groupIncidents groups the newly opened incidents by the unhealthy dependencies of their applications.
+// An incident joins the group of an open incident caused by the same dependency, or the dependency's own incident.
+// Otherwise, incidents sharing a dependency form a new group led by the dependency's incident, if any, or by the first one.
+func groupIncidents(opened []appIncident, open map[model.ApplicationId]*model.ApplicationIncident) ([]*incidentGroup, []appIncident) {
+	openedByApp := map[model.ApplicationId]appIncident{}
+	deps := map[model.ApplicationId][]model.ApplicationId{}
+	counts := map[model.ApplicationId]int{}
+	for _, i := range opened {
+		openedByApp[i.app.Id] = i
+		deps[i.app.Id] = unhealthyDependencies(i.app)
+		for _, d := range deps[i.app.Id] {
+			counts[d]++
+		}
+	}
+
+	groups := map[model.ApplicationId]*incidentGroup{}
+	assigned := map[model.ApplicationId]bool{}
+	for _, i := range opened {
+		for _, d := range deps[i.app.Id] {
+			if leader := existingGroupLeader(d, open); leader != nil {
+				g := groups[d]
+				if g == nil {
+					g = &incidentGroup{root: d, leaderKey: leader.Key}
+					groups[d] = g
+				}
+				g.members = append(g.members, i)
+				assigned[i.app.Id] = true
+				break
+			}
+		}
+	}
+	for _, i := range opened {
+		if assigned[i.app.Id] {
+			continue
+		}
+		var root model.ApplicationId
+		for _, d := range deps[i.app.Id] {
+			dep, depOpened := openedByApp[d]
+			if counts[d] > 1 || (depOpened && !assigned[dep.app.Id]) {
+				if root.IsZero() || counts[d] > counts[root] {
+					root = d
+				}
+			}
+		}
+		if root.IsZero() {
+			continue
+		}
+		g := groups[root]
+		if g == nil {
+			leader := i
+			if dep, ok := openedByApp[root]; ok && !assigned[root] {
+				leader = dep
+			}
+			g = &incidentGroup{root: root, leaderKey: leader.incident.Key, leader: &leader}
+			groups[root] = g
+			if leader.app != i.app {
+				g.members = append(g.members, leader)
+				assigned[leader.app.Id] = true
+			}
+		}
+		g.members = append(g.members, i)
+		assigned[i.app.Id] = true
+	}
+
+	var res []*incidentGroup
+	for _, g := range groups {
+		if g.leader != nil && len(g.members) < 2 {
+			for _, m := range g.members {
+				delete(assigned, m.app.Id)
+			}
+			continue
+		}
+		res = append(res, g)
+	}
+	slices.SortFunc(res, func(a, b *incidentGroup) int {
+		return strings.Compare(a.root.String(), b.root.String())
+	})
+	var ungrouped []appIncident
+	for _, i := range opened {
+		if !assigned[i.app.Id] {
+			ungrouped = append(ungrouped, i)
+		}
+	}
+	return res, ungrouped
+}
+
+func existingGroupLeader(dependency model.ApplicationId, open map[model.ApplicationId]*model.ApplicationIncident) *model.ApplicationIncident {
+	if i := open[dependency]; i != nil && !i.Grouped() {
+		return i
+	}
+	for _, i := range open {
+		if i.GroupRoot == dependency && i.GroupKey == i.Key {
+			return i
+		}
+	}
+	return nil
+}
+
+// unhealthyDependencies returns the applications the app calls that have problems themselves.
+func unhealthyDependencies(app *model.Application) []model.ApplicationId {
+	var res []model.ApplicationId
+	for _, instance := range app.Instances {
+		for _, u := range instance.Upstreams {
+			r := u.RemoteApplication
+			if r == nil || r == app || u.IsObsolete() || r.Status < model.WARNING {
+				continue
+			}
+			if !slices.Contains(res, r.Id) {
+				res = append(res, r.Id)
+			}
+		}
+	}
+	slices.SortFunc(res, func(a, b model.ApplicationId) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return res
+}
+
+type incidentDebounceKey struct {
+	projectId db.ProjectId
+	appId     model.ApplicationId
+}
+
+// incidentDebouncerProjectTTL is how long the pending changes of a project that is no longer checked,
+// e.g., a deleted one, are kept.
+const incidentDebouncerProjectTTL = timeseries.Hour
+
+// incidentDebouncer holds back SLO status changes until they last long enough to open or resolve an incident.
+// The state is kept in memory, so pending changes are observed anew after a restart.
+type incidentDebouncer struct {
+	since   map[incidentDebounceKey]timeseries.Time
+	checked map[db.ProjectId]timeseries.Time
+}
+
+func newIncidentDebouncer() *incidentDebouncer {
+	return &incidentDebouncer{since: map[incidentDebounceKey]timeseries.Time{}, checked: map[db.ProjectId]timeseries.Time{}}
+}
+
+// prune drops the pending changes of the project's applications that haven't been evaluated in the check,
+// and the ones of the projects that haven't been checked for incidentDebouncerProjectTTL.
+func (d *incidentDebouncer) prune(projectId db.ProjectId, evaluated map[model.ApplicationId]bool, now timeseries.Time) {
+	d.checked[projectId] = now
+	expired := map[db.ProjectId]bool{}
+	for id, t := range d.checked {
+		if now.Sub(t) > incidentDebouncerProjectTTL {
+			expired[id] = true
+			delete(d.checked, id)
+		}
+	}
+	for key := range d.since {
+		if expired[key.projectId] || (key.projectId == projectId && !evaluated[key.appId]) {
+			delete(d.since, key)
+		}
+	}
+}
+
+// status returns the severity the incident of the application should have given the observed SLO status.
+func (d *incidentDebouncer) status(projectId db.ProjectId, appId model.ApplicationId, open *model.ApplicationIncident, observed model.Status, now timeseries.Time, settings db.IncidentSettings) model.Status {
+	key := incidentDebounceKey{projectId: projectId, appId: appId}
+	violated := observed > model.OK
+	if open == nil {
+		if !violated {
+			delete(d.since, key)
+			return observed
+		}
+		if now.Sub(d.pendingSince(key, now)) < settings.MinOpenDuration {
+			return model.OK
+		}
+		delete(d.since, key)
+		return observed
+	}
+	if violated {
+		delete(d.since, key)
+		return observed
+	}
+	if now.Sub(d.pendingSince(key, now)) < settings.ResolveHysteresis || now.Sub(open.OpenedAt) < settings.MinResolveDuration {
+		return open.Severity
+	}
+	delete(d.since, key)
+	return observed
+}
+
+func (d *incidentDebouncer) pendingSince(key incidentDebounceKey, now timeseries.Time) timeseries.Time {
+	since, ok := d.since[key]
+	if !ok {
+		since = now
+		d.since[key] = since
+	}
+	return since
 }
@@ -0,0 +1,110 @@
+package watchers
+
+import (
+	"testing"
+
+	"codexray/db"
+	"codexray/model"
+	"codexray/timeseries"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncidentDebouncer(t *testing.T) {
+	d := newIncidentDebouncer()
+	appId := model.NewApplicationId("default", model.ApplicationKindDeployment, "catalog")
+	settings := db.IncidentSettings{
+		MinOpenDuration:    2 * timeseries.Minute,
+		MinResolveDuration: 10 * timeseries.Minute,
+		ResolveHysteresis:  3 * timeseries.Minute,
+	}
+	status := func(open *model.ApplicationIncident, observed model.Status, now timeseries.Time) model.Status {
+		return d.status("p", appId, open, observed, now, settings)
+	}
+
+	assert.Equal(t, model.OK, status(nil, model.CRITICAL, 0))
+	assert.Equal(t, model.OK, status(nil, model.OK, 60))
+	assert.Equal(t, model.OK, status(nil, model.CRITICAL, 120))
+	assert.Equal(t, model.OK, status(nil, model.CRITICAL, 180))
+	assert.Equal(t, model.CRITICAL, status(nil, model.CRITICAL, 240))
+
+	open := &model.ApplicationIncident{OpenedAt: 240, Severity: model.CRITICAL}
+	assert.Equal(t, model.WARNING, status(open, model.WARNING, 300))
+	open.Severity = model.WARNING
+	assert.Equal(t, model.WARNING, status(open, model.OK, 360))
+	assert.Equal(t, model.WARNING, status(open, model.OK, 600)) // hysteresis passed, but the incident is too young
+	assert.Equal(t, model.WARNING, status(open, model.WARNING, 840))
+	assert.Equal(t, model.WARNING, status(open, model.OK, 900)) // hysteresis restarts
+	assert.Equal(t, model.OK, status(open, model.OK, 1080))
+
+	deleted := model.NewApplicationId("default", model.ApplicationKindDeployment, "deleted")
+	d.status("p", appId, nil, model.CRITICAL, 1200, settings)
+	d.status("p", deleted, nil, model.CRITICAL, 1200, settings)
+	d.status("removed-project", appId, nil, model.CRITICAL, 1200, settings)
+	d.prune("removed-project", map[model.ApplicationId]bool{appId: true}, 1200)
+	d.prune("p", map[model.ApplicationId]bool{appId: true}, 1200)
+	assert.Len(t, d.since, 2, "the applications not evaluated in the check are pruned")
+	d.prune("p", map[model.ApplicationId]bool{appId: true}, timeseries.Time(1200).Add(incidentDebouncerProjectTTL+1))
+	assert.Equal(t, map[incidentDebounceKey]timeseries.Time{{projectId: "p", appId: appId}: 1200}, d.since,
+		"so are the projects that are no longer checked")
+}
+
+func TestGroupIncidents(t *testing.T) {
+	newApp := func(name string, status model.Status, deps ...*model.Application) appIncident {
+		app := model.NewApplication(model.NewApplicationId("default", model.ApplicationKindDeployment, name))
+		app.Status = status
+		i := app.GetOrCreateInstance(name+"-1", nil)
+		for _, d := range deps {
+			i.Upstreams[model.ConnectionKey{Destination: d.Id.Name}] = &model.Connection{RemoteApplication: d}
+		}
+		return appIncident{app: app, incident: &model.ApplicationIncident{ApplicationId: app.Id, Key: name}}
+	}
+	names := func(is []appIncident) []string {
+		var res []string
+		for _, i := range is {
+			res = append(res, i.app.Id.Name)
+		}
+		return res
+	}
+
+	pg := newApp("pg", model.CRITICAL)
+	redis := newApp("redis", model.OK)
+	a := newApp("a", model.CRITICAL, pg.app, redis.app)
+	b := newApp("b", model.CRITICAL, pg.app)
+	c := newApp("c", model.CRITICAL, redis.app)
+
+	groups, ungrouped := groupIncidents([]appIncident{a, b, c}, nil)
+	assert.Equal(t, []string{"c"}, names(ungrouped))
+	assert.Len(t, groups, 1)
+	assert.Equal(t, pg.app.Id, groups[0].root)
+	assert.Equal(t, "a", groups[0].leaderKey)
+	assert.Equal(t, []string{"a", "b"}, names(groups[0].members))
+
+	groups, ungrouped = groupIncidents([]appIncident{a, pg, b}, nil)
+	assert.Empty(t, ungrouped)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, "pg", groups[0].leaderKey)
+	assert.Equal(t, []string{"pg", "a", "b"}, names(groups[0].members))
+
+	groups, ungrouped = groupIncidents([]appIncident{c}, nil)
+	assert.Empty(t, groups)
+	assert.Equal(t, []string{"c"}, names(ungrouped))
+
+	open := map[model.ApplicationId]*model.ApplicationIncident{
+		b.app.Id: {Key: "b", GroupKey: "b", GroupRoot: pg.app.Id},
+	}
+	groups, ungrouped = groupIncidents([]appIncident{a}, open)
+	assert.Empty(t, ungrouped)
+	assert.Len(t, groups, 1)
+	assert.Nil(t, groups[0].leader)
+	assert.Equal(t, "b", groups[0].leaderKey)
+
+	open[a.app.Id] = &model.ApplicationIncident{Key: "a", GroupKey: "b", GroupRoot: pg.app.Id}
+	open[c.app.Id] = &model.ApplicationIncident{Key: "c", GroupKey: "b", GroupRoot: pg.app.Id}
+	assert.Empty(t, orphanedGroupMembers(open, map[string]bool{"a": true}))
+	var orphans []string
+	for _, i := range orphanedGroupMembers(open, map[string]bool{"b": true, "c": true}) {
+		orphans = append(orphans, i.Key)
+	}
+	assert.Equal(t, []string{"a"}, orphans, "the still open members of a resolved leader are ungrouped")
+}
@@ -6,18 +6,25 @@ import (
 	"time"
 
 	"codexray/cache"
+	"codexray/clickhouse"
 	cloud_pricing "codexray/cloud-pricing"
+	"codexray/collector"
 	"codexray/constructor"
 	"codexray/db"
+	"codexray/notifications"
 	"codexray/timeseries"
 
 	"k8s.io/klog"
 )
 
-func Start(db *db.DB, cache *cache.Cache, pricing *cloud_pricing.Manager, checkIncidents, checkDeployments bool) {
+func Start(db *db.DB, cache *cache.Cache, pricing *cloud_pricing.Manager, coll *collector.Collector, globalClickHouse *db.IntegrationClickhouse, checkIncidents, checkDeployments bool) {
 	var incidents *Incidents
+	var alertRules *AlertRules
+	var notifier *notifications.IncidentNotifier
 	if checkIncidents {
-		incidents = NewIncidents(db)
+		notifier = notifications.NewIncidentNotifier(db)
+		incidents = NewIncidents(db, notifier)
+		alertRules = NewAlertRules(db, cache, notifier)
 	}
 	var deployments *Deployments
 	if checkDeployments {
@@ -58,6 +65,11 @@ func Start(db *db.DB, cache *cache.Cache, pricing *cloud_pricing.Manager, checkI
 				continue
 			}
 			ctr := constructor.New(db, project, cacheClient, pricing)
+			if ch, err := clickhouse.NewProjectClient(project, globalClickHouse, coll); err != nil {
+				klog.Warningln(err)
+			} else if ch != nil {
+				ctr.SetKubernetesEventsSource(ch)
+			}
 			world, err := ctr.LoadWorld(context.TODO(), from, to, step, nil)
 			if err != nil {
 				klog.Errorln("failed to load world:", err)
@@ -70,6 +82,8 @@ func Start(db *db.DB, cache *cache.Cache, pricing *cloud_pricing.Manager, checkI
 				go func() {
 					defer wg.Done()
 					incidents.Check(project, world)
+					alertRules.Check(project, world)
+					notifier.Escalate(project, world, timeseries.Now())
 				}()
 			}
 			if deployments != nil {